@@ -2,6 +2,7 @@ package main
 
 import (
 	"faynoSync/server"
+	"faynoSync/server/utils"
 	"flag"
 	"os"
 
@@ -10,14 +11,22 @@ import (
 )
 
 var (
-	migration bool
-	rollback  bool
-	logLevel  string
+	migration         bool
+	rollback          bool
+	backfillChecksums bool
+	createAdmin       bool
+	adminUsername     string
+	adminPassword     string
+	logLevel          string
 )
 
 func init() {
 	flag.BoolVar(&migration, "migration", false, "Set true to run migrations.")
 	flag.BoolVar(&rollback, "rollback", false, "Set true to rollback migrations.")
+	flag.BoolVar(&backfillChecksums, "backfill-checksums", false, "Set true to compute missing checksums and sizes of artifacts and exit.")
+	flag.BoolVar(&createAdmin, "create-admin", false, "Set true to create the admin user in MongoDB, unless it exists, and exit.")
+	flag.StringVar(&adminUsername, "admin-username", "", "Username of the admin created with -create-admin, ADMIN_USERNAME when empty.")
+	flag.StringVar(&adminPassword, "admin-password", "", "Password of the admin created with -create-admin, ADMIN_PASSWORD when empty.")
 	flag.StringVar(&logLevel, "loglevel", "info", "log level (debug, info, warn, error, fatal, panic)")
 
 	logrus.New()
@@ -37,19 +46,19 @@ func main() {
 		FullTimestamp: true,
 	})
 
-	// Set the file name of the configuration file
-	viper.SetConfigType("env")
-	viper.SetConfigName(".env")
-	// Set the configuration file path
-	viper.AddConfigPath(".")
-	// Read in the configuration file
-	if err := viper.ReadInConfig(); err != nil {
+	// Read the .env file, environment variables win over it
+	if err := utils.LoadConfig(viper.GetViper(), "."); err != nil {
 		panic(err)
 	}
+	utils.LogEffectiveConfig(viper.GetViper())
 
 	flagMap := map[string]interface{}{
-		"migration": migration,
-		"rollback":  rollback,
+		"migration":          migration,
+		"rollback":           rollback,
+		"backfill_checksums": backfillChecksums,
+		"create_admin":       createAdmin,
+		"admin_username":     adminUsername,
+		"admin_password":     adminPassword,
 	}
 
 	// Pass the config to another function
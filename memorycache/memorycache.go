@@ -0,0 +1,133 @@
+package memorycache
+
+import (
+	"container/list"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Cache is a bounded in-process LRU cache with per-entry expiration.
+// It's used instead of Redis on single-node deployments without performance mode.
+type Cache struct {
+	mu         sync.Mutex
+	maxEntries int
+	ttl        time.Duration
+	entries    map[string]*list.Element
+	order      *list.List
+}
+
+type entry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+var cache *Cache
+
+// New creates a cache holding at most maxEntries values for ttl each
+func New(maxEntries int, ttl time.Duration) *Cache {
+	return &Cache{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// Init enables the shared cache
+func Init(maxEntries int, ttl time.Duration) *Cache {
+	if cache != nil {
+		return cache
+	}
+	cache = New(maxEntries, ttl)
+	logrus.Infof("In-memory cache is enabled (%d entries, TTL %s)", maxEntries, ttl)
+	return cache
+}
+
+// Default returns the shared cache, or nil when it isn't enabled
+func Default() *Cache {
+	return cache
+}
+
+// Enabled reports whether the shared cache is enabled
+func Enabled() bool {
+	return cache != nil
+}
+
+func (c *Cache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	item := element.Value.(*entry)
+	if time.Now().After(item.expiresAt) {
+		c.removeElement(element)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return item.value, true
+}
+
+func (c *Cache) Set(key string, value []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := time.Now().Add(c.ttl)
+	if element, ok := c.entries[key]; ok {
+		item := element.Value.(*entry)
+		item.value = value
+		item.expiresAt = expiresAt
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&entry{key: key, value: value, expiresAt: expiresAt})
+	for c.maxEntries > 0 && c.order.Len() > c.maxEntries {
+		c.removeElement(c.order.Back())
+	}
+}
+
+// DeletePattern removes all keys matching the glob pattern, as Redis KEYS does.
+// It returns the number of removed keys.
+func (c *Cache) DeletePattern(pattern string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for key, element := range c.entries {
+		if matched, _ := path.Match(pattern, key); matched {
+			c.removeElement(element)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Keys returns the keys of the cached values, including expired ones not evicted yet
+func (c *Cache) Keys() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys := make([]string, 0, len(c.entries))
+	for key := range c.entries {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *Cache) removeElement(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*entry).key)
+}
@@ -0,0 +1,118 @@
+package mongod
+
+import (
+	"context"
+	"faynoSync/server/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ActivityFilter narrows the activity feed, empty fields match everything
+type ActivityFilter struct {
+	Since   time.Time
+	Actor   string
+	AppName string
+	// Apps limits the feed to the apps of scoped tokens, nil for admins. AppName must be one of them.
+	Apps  []string
+	Limit int64
+}
+
+// RecentActivity lists the most recently uploaded or updated versions of all apps, newest first.
+// The query walks the updated_at index, names of apps and channels are resolved afterwards.
+func (c *appRepository) RecentActivity(filter ActivityFilter, ctx context.Context) ([]model.Activity, error) {
+	activity := []model.Activity{}
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+	collection := c.client.Database(c.config.Database).Collection("apps")
+
+	appFilter := bson.D{{Key: "app_name", Value: bson.M{"$exists": true}}}
+	switch {
+	case filter.AppName != "":
+		appFilter = bson.D{{Key: "app_name", Value: filter.AppName}}
+	case filter.Apps != nil:
+		appFilter = bson.D{{Key: "app_name", Value: bson.M{"$in": filter.Apps}}}
+	}
+	cur, err := metaCollection.Find(ctx, appFilter)
+	if err != nil {
+		return activity, err
+	}
+	appNames := map[primitive.ObjectID]string{}
+	for cur.Next(ctx) {
+		var app struct {
+			ID   primitive.ObjectID `bson:"_id"`
+			Name string             `bson:"app_name"`
+		}
+		if err := cur.Decode(&app); err != nil {
+			cur.Close(ctx)
+			return activity, err
+		}
+		appNames[app.ID] = app.Name
+	}
+	cur.Close(ctx)
+	if err := cur.Err(); err != nil {
+		return activity, err
+	}
+	if len(appNames) == 0 {
+		return activity, nil
+	}
+
+	query := bson.D{}
+	if filter.AppName != "" || filter.Apps != nil {
+		appIDs := make([]primitive.ObjectID, 0, len(appNames))
+		for id := range appNames {
+			appIDs = append(appIDs, id)
+		}
+		query = append(query, bson.E{Key: "app_id", Value: bson.M{"$in": appIDs}})
+	}
+	if !filter.Since.IsZero() {
+		query = append(query, bson.E{Key: "updated_at", Value: bson.M{"$gte": filter.Since}})
+	}
+	if filter.Actor != "" {
+		query = append(query, bson.E{Key: "updated_by", Value: filter.Actor})
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "updated_at", Value: -1}}).
+		SetLimit(filter.Limit).
+		SetProjection(bson.D{
+			{Key: "app_id", Value: 1},
+			{Key: "version", Value: 1},
+			{Key: "channel_id", Value: 1},
+			{Key: "updated_by", Value: 1},
+			{Key: "updated_at", Value: 1},
+		})
+	cur, err = collection.Find(ctx, query, opts)
+	if err != nil {
+		return activity, err
+	}
+	var versions []struct {
+		AppID     primitive.ObjectID `bson:"app_id"`
+		Version   string             `bson:"version"`
+		ChannelID primitive.ObjectID `bson:"channel_id"`
+		UpdatedBy string             `bson:"updated_by"`
+		UpdatedAt primitive.DateTime `bson:"updated_at"`
+	}
+	if err := cur.All(ctx, &versions); err != nil {
+		return activity, err
+	}
+
+	names, err := c.metaNames(ctx)
+	if err != nil {
+		return activity, err
+	}
+	for _, version := range versions {
+		appName, ok := appNames[version.AppID]
+		if !ok {
+			continue
+		}
+		activity = append(activity, model.Activity{
+			AppName:   appName,
+			Version:   version.Version,
+			Channel:   names[version.ChannelID],
+			Actor:     version.UpdatedBy,
+			UpdatedAt: version.UpdatedAt,
+		})
+	}
+	return activity, nil
+}
@@ -2,6 +2,7 @@ package mongod
 
 import (
 	"context"
+	"faynoSync/tracing"
 
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -15,7 +16,7 @@ func ConnectToDatabase(mongoUrl string, flags map[string]interface{}) (*mongo.Cl
 		panic(err)
 	}
 	// set up the connection options
-	clientOptions := options.Client().ApplyURI(mongoUrl)
+	clientOptions := options.Client().ApplyURI(mongoUrl).SetMonitor(newQueryMonitor(tracing.NewMongoMonitor()))
 
 	// connect to the MongoDB server
 	client, err := mongo.Connect(context.TODO(), clientOptions)
@@ -0,0 +1,116 @@
+package mongod
+
+import (
+	"context"
+	"faynoSync/server/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// AvailableVersions returns a page of the published versions of the app in the channel, newest first, and the
+// number of all of them. Yanked versions and versions targeted at other cohorts are left out. A non-empty platform
+// or arch only considers versions with a matching artifact and returns only those artifacts.
+func (c *appRepository) AvailableVersions(appName, channel, platform, arch, cohort string, offset, limit int64, ctx context.Context) ([]model.AvailableVersion, int64, error) {
+	collection := c.client.Database(c.config.Database).Collection("apps")
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+
+	var appMeta, channelMeta, platformMeta, archMeta struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := c.getMeta(ctx, metaCollection, "app_name", appName, &appMeta); err != nil {
+		if ctx.Err() != nil {
+			return nil, 0, ctx.Err()
+		}
+		return nil, 0, ErrAppNotFound
+	}
+	// Nothing is published in a channel, platform or arch that doesn't exist
+	if err := c.getMeta(ctx, metaCollection, "channel_name", channel, &channelMeta); err != nil {
+		return []model.AvailableVersion{}, 0, ctx.Err()
+	}
+	artifactFilter := bson.D{}
+	if platform != "" {
+		if err := c.getMeta(ctx, metaCollection, "platform_name", platform, &platformMeta); err != nil {
+			return []model.AvailableVersion{}, 0, ctx.Err()
+		}
+		artifactFilter = append(artifactFilter, bson.E{Key: "platform", Value: platformMeta.ID})
+	}
+	if arch != "" {
+		if err := c.getMeta(ctx, metaCollection, "arch_id", arch, &archMeta); err != nil {
+			return []model.AvailableVersion{}, 0, ctx.Err()
+		}
+		artifactFilter = append(artifactFilter, bson.E{Key: "arch", Value: archMeta.ID})
+	}
+
+	filter := bson.D{
+		{Key: "app_id", Value: appMeta.ID},
+		{Key: "channel_id", Value: channelMeta.ID},
+		{Key: "published", Value: true},
+		{Key: "yanked", Value: notYanked},
+	}
+	filter = append(filter, targetingFilter(cohort)...)
+	if len(artifactFilter) > 0 {
+		filter = append(filter, bson.E{Key: "artifacts", Value: bson.D{{Key: "$elemMatch", Value: artifactFilter}}})
+	}
+
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	if total == 0 || offset >= total {
+		return []model.AvailableVersion{}, total, nil
+	}
+
+	names, err := c.metaNames(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// The id breaks ties between equal versions, so pages don't overlap
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: filter}}}
+	pipeline = append(pipeline, versionPartsPipeline()...)
+	pipeline = append(pipeline,
+		bson.D{{Key: "$sort", Value: append(versionSort(-1), bson.E{Key: "_id", Value: -1})}},
+		bson.D{{Key: "$skip", Value: offset}},
+		bson.D{{Key: "$limit", Value: limit}},
+	)
+	cur, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cur.Close(ctx)
+
+	versions := []model.AvailableVersion{}
+	for cur.Next(ctx) {
+		var app model.SpecificApp
+		if err := cur.Decode(&app); err != nil {
+			return nil, 0, err
+		}
+		available := model.AvailableVersion{
+			Version:   app.Version,
+			Critical:  app.Critical,
+			Artifacts: []model.AvailableArtifact{},
+			Changelog: app.Changelog,
+			UpdatedAt: app.Updated_at,
+		}
+		for _, artifact := range app.Artifacts {
+			if platform != "" && artifact.Platform != platformMeta.ID {
+				continue
+			}
+			if arch != "" && artifact.Arch != archMeta.ID {
+				continue
+			}
+			available.Artifacts = append(available.Artifacts, model.AvailableArtifact{
+				Platform: names[artifact.Platform],
+				Arch:     names[artifact.Arch],
+				Package:  artifact.Package,
+				URL:      artifact.Link,
+				Size:     artifact.Size,
+				Checksum: artifact.Checksum,
+			})
+		}
+		versions = append(versions, available)
+	}
+	return versions, total, cur.Err()
+}
@@ -0,0 +1,115 @@
+package mongod
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"faynoSync/server/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrBetaTokenRequired = errors.New("channel not found")
+	ErrBetaTokenInvalid  = errors.New("invalid beta token")
+)
+
+// SetChannelGate restricts the channel to clients presenting a beta token, or lifts the restriction
+func (c *appRepository) SetChannelGate(id primitive.ObjectID, gated bool, ctx context.Context) (interface{}, error) {
+	filter := bson.D{{Key: "_id", Value: id}, {Key: "channel_name", Value: bson.M{"$exists": true}}}
+	var update bson.D
+	if gated {
+		update = bson.D{{Key: "$set", Value: bson.D{{Key: "gated", Value: true}}}}
+	} else {
+		update = bson.D{{Key: "$unset", Value: bson.D{{Key: "gated", Value: ""}}}}
+	}
+	return c.UpdateDocument("apps_meta", filter, update, "", "channel", ctx)
+}
+
+// CreateBetaToken issues a token for the channels and returns it with its id.
+// The token itself isn't stored, it can't be shown again.
+func (c *appRepository) CreateBetaToken(label string, channels []string, ctx context.Context) (string, interface{}, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, err
+	}
+	token := hex.EncodeToString(secret)
+	if channels == nil {
+		channels = []string{}
+	}
+
+	document := bson.D{
+		{Key: "token_hash", Value: hashBetaToken(token)},
+		{Key: "label", Value: label},
+		{Key: "channels", Value: channels},
+		{Key: "revoked", Value: false},
+	}
+	id, err := c.CreateDocument("beta_tokens", document, "", "beta token", ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, id, nil
+}
+
+// ListBetaTokens returns the issued tokens, without their secrets
+func (c *appRepository) ListBetaTokens(ctx context.Context) ([]*model.BetaToken, error) {
+	var tokens []*model.BetaToken
+	if err := c.listItems(ctx, "beta_tokens", bson.M{}, &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// RevokeBetaToken marks the token as revoked, it is kept so the list shows who had access
+func (c *appRepository) RevokeBetaToken(id primitive.ObjectID, ctx context.Context) (interface{}, error) {
+	filter := bson.D{{Key: "_id", Value: id}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "revoked", Value: true}}}}
+	return c.UpdateDocument("beta_tokens", filter, update, "", "beta token", ctx)
+}
+
+// CheckChannelAccess returns ErrBetaTokenRequired when the channel is gated and no token is given,
+// and ErrBetaTokenInvalid when the token is unknown, revoked or doesn't grant access to the channel.
+func (c *appRepository) CheckChannelAccess(channel, token string, ctx context.Context) error {
+	if channel == "" {
+		return nil
+	}
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+	count, err := metaCollection.CountDocuments(ctx, bson.D{{Key: "channel_name", Value: channel}, {Key: "gated", Value: true}})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return nil
+	}
+	if token == "" {
+		return ErrBetaTokenRequired
+	}
+
+	var betaToken model.BetaToken
+	collection := c.client.Database(c.config.Database).Collection("beta_tokens")
+	err = collection.FindOne(ctx, bson.D{{Key: "token_hash", Value: hashBetaToken(token)}, {Key: "revoked", Value: false}}).Decode(&betaToken)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrBetaTokenInvalid
+	}
+	if err != nil {
+		return err
+	}
+	if len(betaToken.Channels) == 0 {
+		return nil
+	}
+	for _, allowed := range betaToken.Channels {
+		if allowed == channel {
+			return nil
+		}
+	}
+	return ErrBetaTokenInvalid
+}
+
+func hashBetaToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
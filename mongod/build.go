@@ -0,0 +1,53 @@
+package mongod
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// NextBuildNumber increments the build counter of the app and channel and returns the new value.
+// The counter starts at 1 and is never below minimum, e.g. to continue after build numbers assigned elsewhere.
+// The increment is a single findAndModify, so parallel pipelines never get the same number.
+func (c *appRepository) NextBuildNumber(appName, channel string, minimum int64, ctx context.Context) (int64, error) {
+	collection := c.client.Database(c.config.Database).Collection("build_counters")
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+
+	var appMeta, channelMeta struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := c.getMeta(ctx, metaCollection, "app_name", appName, &appMeta); err != nil {
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		return 0, ErrAppMetaNotFound
+	}
+	if channel != "" {
+		if err := c.getMeta(ctx, metaCollection, "channel_name", channel, &channelMeta); err != nil {
+			if ctx.Err() != nil {
+				return 0, ctx.Err()
+			}
+			return 0, ErrChannelNotFound
+		}
+	}
+
+	filter := bson.D{{Key: "app_id", Value: appMeta.ID}, {Key: "channel_id", Value: channelMeta.ID}}
+	next := bson.D{{Key: "$add", Value: bson.A{bson.D{{Key: "$ifNull", Value: bson.A{"$value", 0}}}, 1}}}
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.D{
+		{Key: "value", Value: bson.D{{Key: "$max", Value: bson.A{next, minimum}}}},
+		{Key: "updated_at", Value: time.Now()},
+	}}}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var counter struct {
+		Value int64 `bson:"value"`
+	}
+	if err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&counter); err != nil {
+		return 0, err
+	}
+	return counter.Value, nil
+}
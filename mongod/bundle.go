@@ -0,0 +1,248 @@
+package mongod
+
+import (
+	"context"
+	"faynoSync/server/model"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ExportApp collects the app, its versions and the names of the channels, platforms and archs they use.
+// The artifacts are exported with their links, resolving keys and checksums is left to the caller.
+func (c *appRepository) ExportApp(appName string, ctx context.Context) (model.AppBundle, error) {
+	collection := c.client.Database(c.config.Database).Collection("apps")
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+
+	var app struct {
+		ID             primitive.ObjectID `bson:"_id"`
+		VersionPattern string             `bson:"version_pattern"`
+	}
+	if err := c.getMeta(ctx, metaCollection, "app_name", appName, &app); err != nil {
+		if ctx.Err() != nil {
+			return model.AppBundle{}, ctx.Err()
+		}
+		return model.AppBundle{}, ErrAppNotFound
+	}
+
+	names, err := c.metaNames(ctx)
+	if err != nil {
+		return model.AppBundle{}, err
+	}
+
+	cur, err := collection.Find(ctx, bson.D{{Key: "app_id", Value: app.ID}})
+	if err != nil {
+		return model.AppBundle{}, err
+	}
+	defer cur.Close(ctx)
+
+	bundle := model.AppBundle{
+		Format:         model.AppBundleFormat,
+		ExportedAt:     time.Now().UTC(),
+		AppName:        appName,
+		VersionPattern: app.VersionPattern,
+		Versions:       []model.BundleVersion{},
+	}
+	channels, platforms, archs := map[string]bool{}, map[string]bool{}, map[string]bool{}
+	for cur.Next(ctx) {
+		var version model.SpecificApp
+		if err := cur.Decode(&version); err != nil {
+			return model.AppBundle{}, err
+		}
+		exported := model.BundleVersion{
+			Version:    version.Version,
+			Channel:    names[version.ChannelID],
+			Published:  version.Published,
+			Critical:   version.Critical,
+			Artifacts:  []model.BundleArtifact{},
+			Properties: version.Properties,
+			Signatures: version.Signatures,
+			UpdatedAt:  version.Updated_at.Time().UTC(),
+		}
+		channels[exported.Channel] = true
+		for _, artifact := range version.Artifacts {
+			exportedArtifact := model.BundleArtifact{
+				Link:     artifact.Link,
+				Platform: names[artifact.Platform],
+				Arch:     names[artifact.Arch],
+				Package:  artifact.Package,
+				Size:     artifact.Size,
+			}
+			platforms[exportedArtifact.Platform] = true
+			archs[exportedArtifact.Arch] = true
+			exported.Artifacts = append(exported.Artifacts, exportedArtifact)
+		}
+		for _, entry := range version.Changelog {
+			exported.Changelog = append(exported.Changelog, model.ChangelogSnakeCase{Version: entry.Version, Changes: entry.Changes, Date: entry.Date})
+		}
+		bundle.Versions = append(bundle.Versions, exported)
+	}
+	if err := cur.Err(); err != nil {
+		return model.AppBundle{}, err
+	}
+
+	bundle.Channels = sortedNames(channels)
+	bundle.Platforms = sortedNames(platforms)
+	bundle.Archs = sortedNames(archs)
+	return bundle, nil
+}
+
+// ImportApp recreates an exported app. Missing channels, platforms and archs are created,
+// the artifacts are stored with the links set in the bundle. Empty names map to the nil id, like on upload.
+func (c *appRepository) ImportApp(bundle model.AppBundle, ctx context.Context) (primitive.ObjectID, int64, error) {
+	collection := c.client.Database(c.config.Database).Collection("apps")
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+
+	count, err := metaCollection.CountDocuments(ctx, bson.D{{Key: "app_name", Value: bundle.AppName}})
+	if err != nil {
+		return primitive.NilObjectID, 0, err
+	}
+	if count > 0 {
+		return primitive.NilObjectID, 0, ErrAppAlreadyExists
+	}
+
+	// Collect the names from the versions rather than the bundle lists, so every name used exists after this
+	channels, platforms, archs := map[string]bool{}, map[string]bool{}, map[string]bool{}
+	for _, version := range bundle.Versions {
+		channels[version.Channel] = true
+		for _, artifact := range version.Artifacts {
+			platforms[artifact.Platform] = true
+			archs[artifact.Arch] = true
+		}
+	}
+	channelIDs, err := c.ensureMeta("channel_name", sortedNames(channels), c.CreateChannel, ctx)
+	if err != nil {
+		return primitive.NilObjectID, 0, err
+	}
+	platformIDs, err := c.ensureMeta("platform_name", sortedNames(platforms), c.CreatePlatform, ctx)
+	if err != nil {
+		return primitive.NilObjectID, 0, err
+	}
+	archIDs, err := c.ensureMeta("arch_id", sortedNames(archs), c.CreateArch, ctx)
+	if err != nil {
+		return primitive.NilObjectID, 0, err
+	}
+
+	var versions []interface{}
+	for _, version := range bundle.Versions {
+		artifacts := []model.Artifact{}
+		for _, artifact := range version.Artifacts {
+			artifacts = append(artifacts, model.Artifact{
+				Link:     artifact.Link,
+				Platform: platformIDs[artifact.Platform],
+				Arch:     archIDs[artifact.Arch],
+				Package:  artifact.Package,
+				Size:     artifact.Size,
+			})
+		}
+		changelog := []model.Changelog{}
+		for _, entry := range version.Changelog {
+			changelog = append(changelog, model.Changelog{Version: entry.Version, Changes: entry.Changes, Raw: entry.Raw, Date: entry.Date})
+		}
+		updatedAt := version.UpdatedAt
+		if updatedAt.IsZero() {
+			updatedAt = time.Now()
+		}
+		document := bson.D{
+			{Key: "version", Value: version.Version},
+			{Key: "channel_id", Value: channelIDs[version.Channel]},
+			{Key: "published", Value: version.Published},
+			{Key: "critical", Value: version.Critical},
+			{Key: "artifacts", Value: artifacts},
+			{Key: "changelog", Value: changelog},
+			{Key: "updated_at", Value: updatedAt},
+		}
+		if len(version.Properties) > 0 {
+			document = append(document, bson.E{Key: "properties", Value: version.Properties})
+		}
+		if len(version.Signatures) > 0 {
+			document = append(document, bson.E{Key: "signatures", Value: version.Signatures})
+		}
+		versions = append(versions, document)
+	}
+
+	result, err := c.CreateAppWithVersionPattern(bundle.AppName, bundle.VersionPattern, ctx)
+	if err != nil {
+		return primitive.NilObjectID, 0, err
+	}
+	appID := result.(primitive.ObjectID)
+	if len(versions) == 0 {
+		return appID, 0, nil
+	}
+	for i := range versions {
+		versions[i] = append(bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "app_id", Value: appID}}, versions[i].(bson.D)...)
+	}
+
+	insertResult, err := collection.InsertMany(ctx, versions)
+	if err != nil {
+		return appID, 0, err
+	}
+	if err := c.RecomputeAppUsage(appID, ctx); err != nil {
+		logrus.Errorf("Error recomputing usage for app %s: %v", appID.Hex(), err)
+	}
+	return appID, int64(len(insertResult.InsertedIDs)), nil
+}
+
+// metaNames maps the ids of all channels, platforms and archs to their names
+func (c *appRepository) metaNames(ctx context.Context) (map[primitive.ObjectID]string, error) {
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+	cur, err := metaCollection.Find(ctx, bson.D{{Key: "app_name", Value: bson.M{"$exists": false}}})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	names := map[primitive.ObjectID]string{}
+	for cur.Next(ctx) {
+		var meta struct {
+			ID       primitive.ObjectID `bson:"_id"`
+			Channel  string             `bson:"channel_name"`
+			Platform string             `bson:"platform_name"`
+			Arch     string             `bson:"arch_id"`
+		}
+		if err := cur.Decode(&meta); err != nil {
+			return nil, err
+		}
+		names[meta.ID] = meta.Channel + meta.Platform + meta.Arch
+	}
+	return names, cur.Err()
+}
+
+// ensureMeta returns the ids of the named entities, creating the ones that don't exist yet
+func (c *appRepository) ensureMeta(key string, names []string, create func(string, context.Context) (interface{}, error), ctx context.Context) (map[string]primitive.ObjectID, error) {
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+	ids := map[string]primitive.ObjectID{}
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		var meta struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if err := c.getMeta(ctx, metaCollection, key, name, &meta); err == nil {
+			ids[name] = meta.ID
+			continue
+		}
+		result, err := create(name, ctx)
+		if err != nil {
+			return nil, err
+		}
+		logrus.Infof("Created %s %s for the imported app", key, name)
+		ids[name] = result.(primitive.ObjectID)
+	}
+	return ids, nil
+}
+
+func sortedNames(set map[string]bool) []string {
+	names := []string{}
+	for name := range set {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,146 @@
+package mongod
+
+import (
+	"context"
+	"faynoSync/server/model"
+	"faynoSync/server/utils"
+	"time"
+
+	"github.com/hashicorp/go-version"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CompareChangelogs matches the changelog entries of two channels of an app by version
+// and returns the entries that exist only in one of them and the common ones
+func (c *appRepository) CompareChangelogs(appName, channelA, channelB string, ctx context.Context) (model.ChangelogDiff, error) {
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+
+	var appMeta, channelAMeta, channelBMeta struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := c.getMeta(ctx, metaCollection, "app_name", appName, &appMeta); err != nil {
+		return model.ChangelogDiff{}, err
+	}
+	if err := c.getMeta(ctx, metaCollection, "channel_name", channelA, &channelAMeta); err != nil {
+		return model.ChangelogDiff{}, err
+	}
+	if err := c.getMeta(ctx, metaCollection, "channel_name", channelB, &channelBMeta); err != nil {
+		return model.ChangelogDiff{}, err
+	}
+
+	entriesA, err := c.channelChangelog(appMeta.ID, channelAMeta.ID, ctx)
+	if err != nil {
+		return model.ChangelogDiff{}, err
+	}
+	entriesB, err := c.channelChangelog(appMeta.ID, channelBMeta.ID, ctx)
+	if err != nil {
+		return model.ChangelogDiff{}, err
+	}
+
+	diff := model.ChangelogDiff{
+		OnlyInA: []model.ChangelogSnakeCase{},
+		OnlyInB: []model.ChangelogSnakeCase{},
+		Common:  []model.ChangelogDiffCommon{},
+	}
+	matched := make([]bool, len(entriesB))
+	for _, entryA := range entriesA {
+		found := false
+		for i, entryB := range entriesB {
+			if matched[i] || !sameVersion(entryA.Version, entryB.Version) {
+				continue
+			}
+			matched[i] = true
+			found = true
+			diff.Common = append(diff.Common, model.ChangelogDiffCommon{
+				Version:  entryA.Version,
+				ChangesA: entryA.Changes,
+				ChangesB: entryB.Changes,
+				Equal:    entryA.Changes == entryB.Changes,
+			})
+			break
+		}
+		if !found {
+			diff.OnlyInA = append(diff.OnlyInA, model.ChangelogSnakeCase(entryA))
+		}
+	}
+	for i, entryB := range entriesB {
+		if !matched[i] {
+			diff.OnlyInB = append(diff.OnlyInB, model.ChangelogSnakeCase(entryB))
+		}
+	}
+	return diff, nil
+}
+
+// channelChangelog returns the changelog entries of all versions of the app in the channel
+func (c *appRepository) channelChangelog(appID, channelID primitive.ObjectID, ctx context.Context) ([]model.Changelog, error) {
+	collection := c.client.Database(c.config.Database).Collection("apps")
+
+	cur, err := collection.Find(ctx, bson.D{
+		{Key: "app_id", Value: appID},
+		{Key: "channel_id", Value: channelID},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var entries []model.Changelog
+	for cur.Next(ctx) {
+		var app model.SpecificApp
+		if err := cur.Decode(&app); err != nil {
+			return nil, err
+		}
+		entries = append(entries, app.Changelog...)
+	}
+	return entries, cur.Err()
+}
+
+// sameVersion compares versions semantically, so 1.2 and 1.2.0 match
+func sameVersion(a, b string) bool {
+	versionA, errA := version.NewVersion(a)
+	versionB, errB := version.NewVersion(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return versionA.Equal(versionB)
+}
+
+// mergeChangelog adds the changes of a version to entries.
+// By default they replace all entries of the version, so updating a version again doesn't duplicate its notes.
+// With utils.ChangelogAppend they are added as another entry, unless the version already has the same changes.
+func mergeChangelog(entries []model.Changelog, appVersion, changes, raw, mode string) []model.Changelog {
+	entry := model.Changelog{
+		Version: appVersion,
+		Changes: changes,
+		Raw:     raw,
+		Date:    time.Now().Format("2006-01-02"),
+	}
+
+	if mode == utils.ChangelogAppend {
+		for _, existing := range entries {
+			if sameVersion(existing.Version, appVersion) && existing.Changes == changes {
+				return entries
+			}
+		}
+		return append(entries, entry)
+	}
+
+	merged := make([]model.Changelog, 0, len(entries)+1)
+	replaced := false
+	for _, existing := range entries {
+		if !sameVersion(existing.Version, appVersion) {
+			merged = append(merged, existing)
+			continue
+		}
+		// The first entry of the version keeps its position
+		if !replaced {
+			merged = append(merged, entry)
+			replaced = true
+		}
+	}
+	if !replaced {
+		merged = append(merged, entry)
+	}
+	return merged
+}
@@ -0,0 +1,71 @@
+package mongod
+
+import (
+	"context"
+	"errors"
+	"faynoSync/server/model"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// SetChannelChangelogRequired requires a changelog for versions published to the channel, or lifts the requirement
+func (c *appRepository) SetChannelChangelogRequired(id primitive.ObjectID, required bool, ctx context.Context) (interface{}, error) {
+	filter := bson.D{{Key: "_id", Value: id}, {Key: "channel_name", Value: bson.M{"$exists": true}}}
+	var update bson.D
+	if required {
+		update = bson.D{{Key: "$set", Value: bson.D{{Key: "require_changelog", Value: true}}}}
+	} else {
+		update = bson.D{{Key: "$unset", Value: bson.D{{Key: "require_changelog", Value: ""}}}}
+	}
+	return c.UpdateDocument("apps_meta", filter, update, "", "channel", ctx)
+}
+
+// MissingRequiredChangelog reports whether publishing the version to the channel is blocked for lack of a changelog:
+// the channel requires one and the version doesn't exist yet or has no changelog entry with changes.
+// Unknown channels and apps don't block, the request fails on them itself.
+func (c *appRepository) MissingRequiredChangelog(appName, version, channel string, ctx context.Context) (bool, error) {
+	if channel == "" {
+		return false, nil
+	}
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+	var channelMeta model.Channel
+	err := metaCollection.FindOne(ctx, bson.D{{Key: "channel_name", Value: channel}}).Decode(&channelMeta)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !channelMeta.RequireChangelog {
+		return false, nil
+	}
+
+	var appMeta struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := c.getMeta(ctx, metaCollection, "app_name", appName, &appMeta); err != nil {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		return false, nil
+	}
+
+	var app model.SpecificApp
+	collection := c.client.Database(c.config.Database).Collection("apps")
+	err = collection.FindOne(ctx, bson.D{{Key: "app_id", Value: appMeta.ID}, {Key: "version", Value: version}}).Decode(&app)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range app.Changelog {
+		if strings.TrimSpace(entry.Changes) != "" {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,50 @@
+package mongod
+
+import (
+	"context"
+	"faynoSync/server/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SetAppChangelogTemplate sets the template the changelogs of the app are wrapped in, an empty template removes it
+func (c *appRepository) SetAppChangelogTemplate(appName, changelogTemplate string, ctx context.Context) error {
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+
+	var app struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := c.getMeta(ctx, metaCollection, "app_name", appName, &app); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return ErrAppNotFound
+	}
+
+	filter := bson.D{{Key: "_id", Value: app.ID}}
+	var update bson.D
+	if changelogTemplate == "" {
+		update = bson.D{{Key: "$unset", Value: bson.D{{Key: "changelog_template", Value: ""}}}}
+	} else {
+		update = bson.D{{Key: "$set", Value: bson.D{{Key: "changelog_template", Value: changelogTemplate}}}}
+	}
+	_, err := c.UpdateDocument("apps_meta", filter, update, "", "app", ctx)
+	return err
+}
+
+// AppChangelogTemplate returns the changelog template of the app, empty when it has none
+func (c *appRepository) AppChangelogTemplate(appName string, ctx context.Context) (string, error) {
+	if appName == "" {
+		return "", nil
+	}
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+	var app model.App
+	if err := c.getMeta(ctx, metaCollection, "app_name", appName, &app); err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", nil
+	}
+	return app.ChangelogTemplate, nil
+}
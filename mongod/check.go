@@ -5,6 +5,7 @@ import (
 	"errors"
 	"faynoSync/server/model"
 	"fmt"
+	"slices"
 
 	"github.com/hashicorp/go-version"
 	"github.com/sirupsen/logrus"
@@ -13,6 +14,9 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
+// ErrVersionNewerThanLatest is returned when the requested version is ahead of the latest published one
+var ErrVersionNewerThanLatest = errors.New("newer than the latest version available")
+
 func (c *appRepository) Get(ctx context.Context) ([]*model.SpecificAppWithoutIDs, error) {
 	collection := c.client.Database(c.config.Database).Collection("apps")
 	basePipeline := c.getBasePipeline()
@@ -29,48 +33,189 @@ func (c *appRepository) Get(ctx context.Context) ([]*model.SpecificAppWithoutIDs
 	defer cur.Close(ctx)
 	return c.processApps(cur, ctx)
 }
-func (c *appRepository) GetAppByName(appName string, ctx context.Context) ([]*model.SpecificAppWithoutIDs, error) {
+
+// GetAppByName returns up to opts.MaxVersions versions of the app (and one more, see CutSearchResult) ordered by opts.Sort.
+// With opts.LatestOnly only the newest published version of every channel, platform and arch is returned.
+func (c *appRepository) GetAppByName(appName string, opts SearchOptions, ctx context.Context) ([]*model.SpecificAppWithoutIDs, error) {
 	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
 	metaFilter := bson.D{{Key: "app_name", Value: appName}}
+	var appMeta struct {
+		ID              primitive.ObjectID     `bson:"_id"`
+		Logo            string                 `bson:"logo"`
+		RequiredTargets []model.RequiredTarget `bson:"required_targets"`
+	}
 	err := metaCollection.FindOne(ctx, metaFilter).Decode(&appMeta)
+	if err == mongo.ErrNoDocuments {
+		// An unknown app has no versions
+		return []*model.SpecificAppWithoutIDs{}, nil
+	}
 	if err != nil {
-		return nil, errors.New("app_name not found in apps_meta collection")
+		return nil, fmt.Errorf("error finding app_name in apps_meta collection: %w", err)
 	}
 
 	collection := c.client.Database(c.config.Database).Collection("apps")
 
-	basePipeline := c.getBasePipeline()
 	pipeline := mongo.Pipeline{
 		bson.D{{Key: "$match", Value: bson.M{"app_id": appMeta.ID}}},
 	}
-	pipeline = append(pipeline, basePipeline...)
+	if len(opts.Fields) > 0 {
+		pipeline = append(pipeline, searchExclusionPipeline(opts.Fields)...)
+	}
+	if opts.LatestOnly {
+		pipeline = append(pipeline, latestOnlyPipeline()...)
+	}
+	pipeline = append(pipeline, c.groupVersionsPipeline()...)
+	pipeline = append(pipeline, searchSortPipeline(opts.Sort)...)
+	pipeline = append(pipeline, bson.D{{Key: "$limit", Value: opts.maxVersions() + 1}})
+	if len(opts.Fields) > 0 {
+		pipeline = append(pipeline, searchProjectionPipeline(opts.Fields)...)
+	}
 
 	cur, err := collection.Aggregate(ctx, pipeline)
 	if err != nil {
-		logrus.Fatal(err)
+		logrus.Error("Aggregation failed: ", err)
 		return nil, err
 	}
 	defer cur.Close(ctx)
 
-	return c.processApps(cur, ctx)
+	apps, err := c.processApps(cur, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(opts.Fields) == 0 || slices.Contains(opts.Fields, "logo") {
+		for _, app := range apps {
+			app.Logo = appMeta.Logo
+		}
+	}
+	if len(opts.Fields) == 0 || slices.Contains(opts.Fields, "artifacts") {
+		markCompleteness(apps, appMeta.RequiredTargets)
+	}
+	return apps, nil
 }
-func (c *appRepository) CheckLatestVersion(appName, currentVersion, channelName, platformName, archName string, ctx context.Context) (CheckResult, error) {
+
+// GetAppsByNames returns the versions of several apps with a single query, grouped by app name.
+// Every app gets up to opts.MaxVersions versions (and one more) ordered by opts.Sort, like with GetAppByName;
+// unknown apps get none.
+func (c *appRepository) GetAppsByNames(appNames []string, opts SearchOptions, ctx context.Context) (map[string][]*model.SpecificAppWithoutIDs, error) {
+	grouped := make(map[string][]*model.SpecificAppWithoutIDs, len(appNames))
+	for _, appName := range appNames {
+		grouped[appName] = []*model.SpecificAppWithoutIDs{}
+	}
+
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+	metaCur, err := metaCollection.Find(ctx, bson.D{{Key: "app_name", Value: bson.D{{Key: "$in", Value: appNames}}}})
+	if err != nil {
+		return nil, fmt.Errorf("error finding app_name in apps_meta collection: %w", err)
+	}
+	var appMetas []struct {
+		ID              primitive.ObjectID     `bson:"_id"`
+		AppName         string                 `bson:"app_name"`
+		Logo            string                 `bson:"logo"`
+		RequiredTargets []model.RequiredTarget `bson:"required_targets"`
+	}
+	if err := metaCur.All(ctx, &appMetas); err != nil {
+		return nil, fmt.Errorf("error finding app_name in apps_meta collection: %w", err)
+	}
+	if len(appMetas) == 0 {
+		return grouped, nil
+	}
+	appIDs := make([]primitive.ObjectID, 0, len(appMetas))
+	logos := make(map[string]string, len(appMetas))
+	requiredTargets := make(map[string][]model.RequiredTarget, len(appMetas))
+	for _, appMeta := range appMetas {
+		appIDs = append(appIDs, appMeta.ID)
+		logos[appMeta.AppName] = appMeta.Logo
+		requiredTargets[appMeta.AppName] = appMeta.RequiredTargets
+	}
+
+	collection := c.client.Database(c.config.Database).Collection("apps")
+
+	pipeline := mongo.Pipeline{
+		bson.D{{Key: "$match", Value: bson.M{"app_id": bson.M{"$in": appIDs}}}},
+	}
+	if len(opts.Fields) > 0 {
+		pipeline = append(pipeline, searchExclusionPipeline(opts.Fields)...)
+	}
+	if opts.LatestOnly {
+		pipeline = append(pipeline, latestOnlyPipeline()...)
+	}
+	pipeline = append(pipeline, c.groupVersionsPipeline()...)
+	pipeline = append(pipeline, searchSortPipeline(opts.Sort)...)
+	pipeline = append(pipeline, perAppLimitPipeline(opts.maxVersions()+1)...)
+	if len(opts.Fields) > 0 {
+		// The app name is needed to group the versions, the handler drops it when it isn't selected
+		pipeline = append(pipeline, searchProjectionPipeline(append(slices.Clone(opts.Fields), "app_name"))...)
+	}
+
+	cur, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		logrus.Error("Aggregation failed: ", err)
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	apps, err := c.processApps(cur, ctx)
+	if err != nil {
+		return nil, err
+	}
+	withLogo := len(opts.Fields) == 0 || slices.Contains(opts.Fields, "logo")
+	for _, app := range apps {
+		if withLogo {
+			app.Logo = logos[app.AppName]
+		}
+		grouped[app.AppName] = append(grouped[app.AppName], app)
+	}
+	if len(opts.Fields) == 0 || slices.Contains(opts.Fields, "artifacts") {
+		for appName, apps := range grouped {
+			markCompleteness(apps, requiredTargets[appName])
+		}
+	}
+	return grouped, nil
+}
+
+// CheckOptions select the version CheckLatestVersion offers to a client
+type CheckOptions struct {
+	AppName string
+	// Version is the version the client runs
+	Version string
+	// Channel, Platform and Arch narrow the versions to the client's target, empty matches versions without one
+	Channel  string
+	Platform string
+	Arch     string
+	// Package only considers versions with an artifact of that package and returns only those artifacts
+	Package string
+	// Cohort of the client, versions targeted at cohorts are only considered for a matching one
+	Cohort string
+	// PreferCritical offers a client behind a critical version the newest critical version
+	// newer than its own instead of a newer non-critical one
+	PreferCritical bool
+	// Step offers the next version after the client's own instead of the newest one, so it can
+	// update one version at a time. A pinned version is the last step then.
+	Step bool
+}
+
+// CheckLatestVersion returns the latest published version when it's newer than opts.Version.
+func (c *appRepository) CheckLatestVersion(opts CheckOptions, ctx context.Context) (CheckResult, error) {
 	collection := c.client.Database(c.config.Database).Collection("apps")
 	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
 
-	var appMeta, channelMeta, platformMeta, archMeta struct {
+	var appMeta struct {
+		ID              primitive.ObjectID     `bson:"_id"`
+		RequiredTargets []model.RequiredTarget `bson:"required_targets"`
+	}
+	var channelMeta, platformMeta, archMeta struct {
 		ID primitive.ObjectID `bson:"_id"`
 	}
 
 	// Find app_id from apps_meta by app_name
-	err := c.getMeta(ctx, metaCollection, "app_name", appName, &appMeta)
+	err := c.getMeta(ctx, metaCollection, "app_name", opts.AppName, &appMeta)
 	if err != nil {
 		return CheckResult{Found: false, Artifacts: []Artifact{}}, err
 	}
 
 	// Fetch channel_id
-	if channelName != "" {
-		err = c.getMeta(ctx, metaCollection, "channel_name", channelName, &channelMeta)
+	if opts.Channel != "" {
+		err = c.getMeta(ctx, metaCollection, "channel_name", opts.Channel, &channelMeta)
 		if err != nil {
 			return CheckResult{Found: false, Artifacts: []Artifact{}}, err
 		}
@@ -78,8 +223,8 @@ func (c *appRepository) CheckLatestVersion(appName, currentVersion, channelName,
 	}
 
 	// Fetch platform_id
-	if platformName != "" {
-		err = c.getMeta(ctx, metaCollection, "platform_name", platformName, &platformMeta)
+	if opts.Platform != "" {
+		err = c.getMeta(ctx, metaCollection, "platform_name", opts.Platform, &platformMeta)
 		if err != nil {
 			return CheckResult{Found: false, Artifacts: []Artifact{}}, err
 		}
@@ -87,29 +232,51 @@ func (c *appRepository) CheckLatestVersion(appName, currentVersion, channelName,
 	}
 
 	// Fetch arch_id
-	if archName != "" {
-		err = c.getMeta(ctx, metaCollection, "arch_id", archName, &archMeta)
+	if opts.Arch != "" {
+		err = c.getMeta(ctx, metaCollection, "arch_id", opts.Arch, &archMeta)
 		if err != nil {
 			return CheckResult{Found: false, Artifacts: []Artifact{}}, err
 		}
 		logrus.Debugf("Found archMeta: %v", archMeta)
 	}
-	// Define the filter based on app_id and optional channel
+	// Define the filter based on app_id and optional channel.
+	// Only published versions are considered as the latest one.
+	artifactFilter := bson.D{
+		{Key: "platform", Value: platformMeta.ID},
+		{Key: "arch", Value: archMeta.ID},
+	}
+	if opts.Package != "" {
+		artifactFilter = append(artifactFilter, bson.E{Key: "package", Value: storedPackage(opts.Package)})
+	}
 	filter := bson.D{
 		{Key: "app_id", Value: appMeta.ID},
 		{Key: "published", Value: true},
-		{
-			Key: "artifacts", Value: bson.D{
-				{Key: "$elemMatch", Value: bson.D{
-					{Key: "platform", Value: platformMeta.ID},
-					{Key: "arch", Value: archMeta.ID},
-				}},
-			},
-		},
+		{Key: "yanked", Value: notYanked},
+		{Key: "artifacts", Value: bson.D{{Key: "$elemMatch", Value: artifactFilter}}},
+	}
+	filter = append(filter, targetingFilter(opts.Cohort)...)
+	// Releases missing a required platform or arch aren't offered on any of them
+	if len(appMeta.RequiredTargets) > 0 {
+		complete, err := c.completenessFilter(ctx, metaCollection, appMeta.RequiredTargets)
+		if err != nil {
+			return CheckResult{Found: false, Artifacts: []Artifact{}}, err
+		}
+		filter = append(filter, complete)
 	}
 
-	if channelName != "" {
+	var pinned string
+	if opts.Channel != "" {
 		filter = append(filter, bson.E{Key: "channel_id", Value: channelMeta.ID})
+
+		// A pinned version overrides the newest one
+		pinned, err = c.pinnedVersion(appMeta.ID, channelMeta.ID, platformMeta.ID, archMeta.ID, ctx)
+		if err != nil {
+			return CheckResult{Found: false, Artifacts: []Artifact{}}, err
+		}
+		// Stepping goes through the versions before the pinned one as well
+		if pinned != "" && !opts.Step {
+			filter = append(filter, bson.E{Key: "version", Value: pinned})
+		}
 	}
 
 	// Create an aggregation pipeline to sort by version and updated_at
@@ -118,6 +285,10 @@ func (c *appRepository) CheckLatestVersion(appName, currentVersion, channelName,
 		{{Key: "$match", Value: filter}},
 	}
 	pipeline = append(pipeline, c.sortVersionPipeline()...)
+	// Stepping and preferring critical versions walk past the newest version
+	if !opts.Step && !opts.PreferCritical {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: 1}})
+	}
 	logrus.Debug("MongoDB Filter: ", filter)
 	logrus.Debug("MongoDB Pipeline: ", pipeline)
 	// Execute the aggregation pipeline
@@ -127,6 +298,10 @@ func (c *appRepository) CheckLatestVersion(appName, currentVersion, channelName,
 	}
 	defer cursor.Close(ctx)
 
+	if opts.Step {
+		return nextVersion(cursor, opts.Version, pinned, opts.Package, ctx)
+	}
+
 	// Decode the result
 	var latestApp *model.SpecificApp
 	if cursor.Next(ctx) {
@@ -140,43 +315,142 @@ func (c *appRepository) CheckLatestVersion(appName, currentVersion, channelName,
 			return CheckResult{Found: false, Artifacts: []Artifact{}}, err
 		}
 
-		requestedVersion, err := version.NewVersion(currentVersion)
+		requestedVersion, err := version.NewVersion(opts.Version)
 		if err != nil {
 			return CheckResult{Found: false, Artifacts: []Artifact{}}, err
 		}
-		var artifacts []Artifact
 
-		// Convert latestApp.Changelog to []Changelog
-		changelog := make([]Changelog, len(latestApp.Changelog))
-		for i, entry := range latestApp.Changelog {
-			changelog[i] = Changelog{
-				Changes: entry.Changes,
+		newerAvailable := false
+		if opts.PreferCritical && !latestApp.Critical && requestedVersion.LessThan(latestAppVersion) {
+			criticalApp, err := nextCriticalVersion(cursor, requestedVersion, ctx)
+			if err != nil {
+				return CheckResult{Found: false, Artifacts: []Artifact{}}, err
+			}
+			if criticalApp != nil {
+				latestApp = criticalApp
+				newerAvailable = true
 			}
 		}
-		// Iterate through all elements in latestApp.Artifacts and append both link and package type
-		for _, artifact := range latestApp.Artifacts {
-			artifacts = append(artifacts, Artifact{
-				Link:    artifact.Link,
-				Package: artifact.Package,
-			})
+		return versionResult(latestApp, requestedVersion, latestAppVersion, opts.Package, newerAvailable)
+
+	} else {
+		return CheckResult{Found: false, Artifacts: []Artifact{}}, fmt.Errorf("no matching documents found for app_name: %s", opts.AppName)
+	}
+
+}
+
+// versionResult builds the result of a check of requested against the selected version app.
+// latest is the newest version the client could get, it decides whether the client is up to date or ahead.
+func versionResult(app *model.SpecificApp, requested, latest *version.Version, pkg string, newerAvailable bool) (CheckResult, error) {
+	var artifacts []Artifact
+
+	// Convert app.Changelog to []Changelog
+	changelog := make([]Changelog, len(app.Changelog))
+	for i, entry := range app.Changelog {
+		changelog[i] = Changelog{
+			Changes: entry.Changes,
 		}
-		if requestedVersion.Equal(latestAppVersion) {
-			return CheckResult{Found: false, Artifacts: artifacts}, nil
-		} else if requestedVersion.GreaterThan(latestAppVersion) {
-			return CheckResult{Found: false, Artifacts: []Artifact{}}, fmt.Errorf("requested version %s is newer than the latest version available", requestedVersion)
-		} else {
-			return CheckResult{Found: true, Artifacts: artifacts, Changelog: changelog, Critical: latestApp.Critical}, nil
+	}
+	// Iterate through all elements in app.Artifacts and append both link and package type
+	for _, artifact := range app.Artifacts {
+		if pkg != "" && artifact.Package != storedPackage(pkg) {
+			continue
 		}
-
+		artifacts = append(artifacts, Artifact{
+			Link:      artifact.Link,
+			Package:   artifact.Package,
+			Preferred: artifact.Preferred,
+		})
+	}
+	if requested.Equal(latest) {
+		return CheckResult{Found: false, Artifacts: artifacts}, nil
+	} else if requested.GreaterThan(latest) {
+		return CheckResult{Found: false, Artifacts: []Artifact{}}, fmt.Errorf("requested version %s is %w", requested, ErrVersionNewerThanLatest)
 	} else {
-		return CheckResult{Found: false, Artifacts: []Artifact{}}, fmt.Errorf("no matching documents found for app_name: %s", appName)
+		return CheckResult{Found: true, Artifacts: artifacts, Changelog: changelog, Critical: app.Critical, Properties: app.Properties, NewerAvailable: newerAvailable}, nil
+	}
+}
+
+// nextVersion returns the oldest version in the sorted cursor that is newer than currentVersion.
+// Versions newer than pinned are skipped. NewerAvailable tells the client that more steps follow.
+func nextVersion(cursor *mongo.Cursor, currentVersion, pinned, pkg string, ctx context.Context) (CheckResult, error) {
+	requestedVersion, err := version.NewVersion(currentVersion)
+	if err != nil {
+		return CheckResult{Found: false, Artifacts: []Artifact{}}, err
+	}
+	var pinnedVersion *version.Version
+	if pinned != "" {
+		if pinnedVersion, err = version.NewVersion(pinned); err != nil {
+			return CheckResult{Found: false, Artifacts: []Artifact{}}, err
+		}
+	}
+
+	var latestApp, nextApp *model.SpecificApp
+	var latestVersion, candidateVersion *version.Version
+	for cursor.Next(ctx) {
+		var candidate model.SpecificApp
+		if err := cursor.Decode(&candidate); err != nil {
+			return CheckResult{Found: false, Artifacts: []Artifact{}}, err
+		}
+		candidateVersion, err = version.NewVersion(candidate.Version)
+		if err != nil {
+			return CheckResult{Found: false, Artifacts: []Artifact{}}, err
+		}
+		if pinnedVersion != nil && candidateVersion.GreaterThan(pinnedVersion) {
+			continue
+		}
+		if latestApp == nil {
+			latestApp, latestVersion = &candidate, candidateVersion
+		}
+		if !candidateVersion.GreaterThan(requestedVersion) {
+			break
+		}
+		nextApp = &candidate
+	}
+	if err := cursor.Err(); err != nil {
+		return CheckResult{Found: false, Artifacts: []Artifact{}}, err
+	}
+	if latestApp == nil {
+		return CheckResult{Found: false, Artifacts: []Artifact{}}, errors.New("no matching documents found")
 	}
+	if nextApp == nil {
+		// Up to date or ahead of the latest version
+		return versionResult(latestApp, requestedVersion, latestVersion, pkg, false)
+	}
+	return versionResult(nextApp, requestedVersion, latestVersion, pkg, nextApp != latestApp)
+}
 
+// nextCriticalVersion returns the newest critical version left in the sorted cursor that is
+// newer than requested, or nil when the client isn't behind any critical version
+func nextCriticalVersion(cursor *mongo.Cursor, requested *version.Version, ctx context.Context) (*model.SpecificApp, error) {
+	for cursor.Next(ctx) {
+		var candidate model.SpecificApp
+		if err := cursor.Decode(&candidate); err != nil {
+			return nil, err
+		}
+		candidateVersion, err := version.NewVersion(candidate.Version)
+		if err != nil {
+			return nil, err
+		}
+		if !candidateVersion.GreaterThan(requested) {
+			return nil, nil
+		}
+		if candidate.Critical {
+			return &candidate, nil
+		}
+	}
+	return nil, cursor.Err()
 }
 
-func (c *appRepository) FetchLatestVersionOfApp(appName, channel string, ctx context.Context) ([]*model.SpecificAppWithoutIDs, error) {
+// FetchLatestVersionOfApp returns the latest published version of the app.
+// A non-empty pkg only considers versions with an artifact of that package.
+func (c *appRepository) FetchLatestVersionOfApp(appName, channel, pkg string, ctx context.Context) ([]*model.SpecificAppWithoutIDs, error) {
 	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
 	metaFilter := bson.D{{Key: "app_name", Value: appName}}
+	var appMeta struct {
+		ID              primitive.ObjectID     `bson:"_id"`
+		RequiredTargets []model.RequiredTarget `bson:"required_targets"`
+	}
 	err := metaCollection.FindOne(ctx, metaFilter).Decode(&appMeta)
 	if err != nil {
 		return nil, errors.New("app_name not found in apps_meta collection")
@@ -192,16 +466,36 @@ func (c *appRepository) FetchLatestVersionOfApp(appName, channel string, ctx con
 		}
 	}
 	collection := c.client.Database(c.config.Database).Collection("apps")
-	matchFilter := bson.M{"app_id": appMeta.ID, "published": true}
+	matchFilter := bson.M{"app_id": appMeta.ID, "published": true, "yanked": notYanked}
+	if pkg != "" {
+		matchFilter["artifacts.package"] = storedPackage(pkg)
+	}
+	if len(appMeta.RequiredTargets) > 0 {
+		complete, err := c.completenessFilter(ctx, metaCollection, appMeta.RequiredTargets)
+		if err != nil {
+			return nil, err
+		}
+		matchFilter[complete.Key] = complete.Value
+	}
 
 	if channel != "" {
 		matchFilter["channel_id"] = channelMeta.ID
+
+		// Only pins for all platforms and archs apply, the response isn't limited to one of them
+		pinned, err := c.pinnedVersion(appMeta.ID, channelMeta.ID, primitive.NilObjectID, primitive.NilObjectID, ctx)
+		if err != nil {
+			return nil, err
+		}
+		if pinned != "" {
+			matchFilter["version"] = pinned
+		}
 	}
 
 	pipeline := mongo.Pipeline{
 		{{Key: "$match", Value: matchFilter}},
 	}
 	pipeline = append(pipeline, c.sortVersionPipeline()...)
+	pipeline = append(pipeline, bson.D{{Key: "$limit", Value: 1}})
 	basePipeline := c.getBasePipeline()
 	pipeline = append(pipeline, basePipeline...)
 
@@ -256,18 +550,40 @@ func (c *appRepository) processApps(cur *mongo.Cursor, ctx context.Context) ([]*
 			return nil, err
 		}
 		app := &model.SpecificAppWithoutIDs{
-			ID:        tempApp.ID,
-			AppName:   tempApp.AppName,
-			Version:   tempApp.Version,
-			Channel:   tempApp.Channel,
-			Published: tempApp.Published,
-			Critical:  tempApp.Critical,
-			Artifacts: tempApp.Artifacts,
-			Changelog: tempApp.Changelog,
-			UpdatedAt: tempApp.UpdatedAt,
+			ID:         tempApp.ID,
+			AppName:    tempApp.AppName,
+			Version:    tempApp.Version,
+			Channel:    tempApp.Channel,
+			Published:  tempApp.Published,
+			Critical:   tempApp.Critical,
+			Artifacts:  tempApp.Artifacts,
+			Changelog:  tempApp.Changelog,
+			Properties: tempApp.Properties,
+			Targeting:  tempApp.Targeting,
+			Signatures: tempApp.Signatures,
+			Yanked:     tempApp.Yanked,
+			YankReason: tempApp.YankReason,
+			Revision:   tempApp.Revision,
+			UpdatedAt:  tempApp.UpdatedAt,
 		}
 
 		apps = append(apps, app)
 	}
 	return apps, nil
 }
+
+// targetingFilter matches the versions offered to clients of cohort.
+// Versions without allowed cohorts are offered to everyone who isn't denied, clients without a cohort can't be denied.
+func targetingFilter(cohort string) bson.D {
+	untargeted := bson.A{
+		bson.D{{Key: "targeting.allow", Value: bson.D{{Key: "$exists", Value: false}}}},
+		bson.D{{Key: "targeting.allow", Value: bson.D{{Key: "$size", Value: 0}}}},
+	}
+	if cohort == "" {
+		return bson.D{{Key: "$or", Value: untargeted}}
+	}
+	return bson.D{
+		{Key: "$or", Value: append(untargeted, bson.D{{Key: "targeting.allow", Value: cohort}})},
+		{Key: "targeting.deny", Value: bson.D{{Key: "$ne", Value: cohort}}},
+	}
+}
@@ -0,0 +1,71 @@
+package mongod
+
+import (
+	"context"
+	"faynoSync/server/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// missingChecksumFilter matches versions with an artifact stored without checksum or size
+var missingChecksumFilter = bson.D{{Key: "artifacts", Value: bson.D{{Key: "$elemMatch", Value: bson.D{{Key: "$or", Value: bson.A{
+	bson.D{{Key: "checksum", Value: bson.D{{Key: "$in", Value: bson.A{nil, ""}}}}},
+	bson.D{{Key: "size", Value: bson.D{{Key: "$in", Value: bson.A{nil, 0}}}}},
+}}}}}}}
+
+// CountArtifactsMissingChecksums counts the versions with an artifact lacking checksum or size after the version id
+func (c *appRepository) CountArtifactsMissingChecksums(after primitive.ObjectID, ctx context.Context) (int64, error) {
+	collection := c.client.Database(c.config.Database).Collection("apps")
+	return collection.CountDocuments(ctx, missingChecksumsAfter(after))
+}
+
+// ArtifactsMissingChecksums returns up to limit versions with an artifact lacking checksum or size, ordered by id
+// and starting after the version id, so a backfill can continue where it stopped
+func (c *appRepository) ArtifactsMissingChecksums(after primitive.ObjectID, limit int64, ctx context.Context) ([]model.SpecificApp, error) {
+	collection := c.client.Database(c.config.Database).Collection("apps")
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetLimit(limit).
+		SetProjection(bson.D{{Key: "app_id", Value: 1}, {Key: "version", Value: 1}, {Key: "artifacts", Value: 1}})
+	cursor, err := collection.Find(ctx, missingChecksumsAfter(after), opts)
+	if err != nil {
+		return nil, err
+	}
+	var versions []model.SpecificApp
+	if err := cursor.All(ctx, &versions); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+func missingChecksumsAfter(after primitive.ObjectID) bson.D {
+	filter := append(bson.D{}, missingChecksumFilter...)
+	if !after.IsZero() {
+		filter = append(filter, bson.E{Key: "_id", Value: bson.D{{Key: "$gt", Value: after}}})
+	}
+	return filter
+}
+
+// BackfillArtifactChecksum records the size and checksum of an existing artifact.
+// Unlike ReplaceArtifact it leaves updated_at alone, the file didn't change; the caller recomputes the usage of the app.
+func (c *appRepository) BackfillArtifactChecksum(id primitive.ObjectID, link string, size int64, checksum string, ctx context.Context) error {
+	collection := c.client.Database(c.config.Database).Collection("apps")
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "artifacts.link", Value: link},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "artifacts.$.size", Value: size},
+		{Key: "artifacts.$.checksum", Value: checksum},
+	}}, bumpRevision()}
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrArtifactNotFound
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package mongod
+
+import (
+	"context"
+	"errors"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrAppNotFound      = errors.New("source app not found")
+	ErrAppAlreadyExists = errors.New("app with this name already exists")
+	// ErrAlreadyExists is wrapped by the errors of creates and updates rejected as duplicates
+	ErrAlreadyExists = errors.New("already exists")
+)
+
+// CloneApp copies the app_name document of source to a new app named target.
+// When includeVersions is set the versions are copied too, their artifacts keep pointing
+// at the objects uploaded for the source app.
+func (c *appRepository) CloneApp(source, target string, includeVersions bool, ctx context.Context) (primitive.ObjectID, int64, error) {
+	collection := c.client.Database(c.config.Database).Collection("apps")
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+
+	var sourceMeta bson.D
+	if err := c.getMeta(ctx, metaCollection, "app_name", source, &sourceMeta); err != nil {
+		if ctx.Err() != nil {
+			return primitive.NilObjectID, 0, ctx.Err()
+		}
+		return primitive.NilObjectID, 0, ErrAppNotFound
+	}
+
+	count, err := metaCollection.CountDocuments(ctx, bson.D{{Key: "app_name", Value: target}})
+	if err != nil {
+		return primitive.NilObjectID, 0, err
+	}
+	if count > 0 {
+		return primitive.NilObjectID, 0, ErrAppAlreadyExists
+	}
+
+	var sourceID primitive.ObjectID
+	document := bson.D{{Key: "app_name", Value: target}}
+	for _, field := range sourceMeta {
+		switch field.Key {
+		case "_id":
+			sourceID, _ = field.Value.(primitive.ObjectID)
+		case "app_name", "updated_at", "storage_bytes", "versions_count", "usage_stale":
+			// Usage is recomputed for the copied versions
+		default:
+			document = append(document, field)
+		}
+	}
+
+	result, err := c.CreateDocument("apps_meta", document, "app_name_sort_by_asc_created", "app", ctx)
+	if err != nil {
+		return primitive.NilObjectID, 0, err
+	}
+	targetID := result.(primitive.ObjectID)
+
+	if !includeVersions {
+		return targetID, 0, nil
+	}
+
+	cur, err := collection.Find(ctx, bson.D{{Key: "app_id", Value: sourceID}})
+	if err != nil {
+		return targetID, 0, err
+	}
+	defer cur.Close(ctx)
+
+	var versions []interface{}
+	for cur.Next(ctx) {
+		var version bson.D
+		if err := cur.Decode(&version); err != nil {
+			return targetID, 0, err
+		}
+		copied := bson.D{{Key: "_id", Value: primitive.NewObjectID()}}
+		for _, field := range version {
+			switch field.Key {
+			case "_id":
+			case "app_id":
+				copied = append(copied, bson.E{Key: "app_id", Value: targetID})
+			default:
+				copied = append(copied, field)
+			}
+		}
+		versions = append(versions, copied)
+	}
+	if err := cur.Err(); err != nil {
+		return targetID, 0, err
+	}
+	if len(versions) == 0 {
+		return targetID, 0, nil
+	}
+
+	insertResult, err := collection.InsertMany(ctx, versions)
+	if err != nil {
+		return targetID, 0, err
+	}
+	if err := c.RecomputeAppUsage(targetID, ctx); err != nil {
+		logrus.Errorf("Error recomputing usage for app %s: %v", targetID.Hex(), err)
+	}
+	return targetID, int64(len(insertResult.InsertedIDs)), nil
+}
@@ -0,0 +1,110 @@
+package mongod
+
+import (
+	"context"
+	"errors"
+	"faynoSync/server/model"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrUnknownTarget is returned when a required target names a platform or arch that doesn't exist
+var ErrUnknownTarget = errors.New("platform or arch does not exist")
+
+// SetAppRequiredTargets sets the platform and arch combinations a version of the app needs artifacts for
+// before it can be the latest one. No targets remove the requirement.
+func (c *appRepository) SetAppRequiredTargets(appName string, targets []model.RequiredTarget, ctx context.Context) error {
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+
+	var app struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := c.getMeta(ctx, metaCollection, "app_name", appName, &app); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return ErrAppNotFound
+	}
+	if _, err := c.requiredTargetIDs(ctx, metaCollection, targets, true); err != nil {
+		return err
+	}
+
+	filter := bson.D{{Key: "_id", Value: app.ID}}
+	var update bson.D
+	if len(targets) == 0 {
+		update = bson.D{{Key: "$unset", Value: bson.D{{Key: "required_targets", Value: ""}}}}
+	} else {
+		update = bson.D{{Key: "$set", Value: bson.D{{Key: "required_targets", Value: targets}}}}
+	}
+	_, err := c.UpdateDocument("apps_meta", filter, update, "", "app", ctx)
+	return err
+}
+
+// requiredTargetIDs resolves the names of the targets to the ids of their platforms and archs.
+// Unless strict, a platform or arch deleted since gets a nil id, which no artifact has.
+func (c *appRepository) requiredTargetIDs(ctx context.Context, metaCollection *mongo.Collection, targets []model.RequiredTarget, strict bool) ([][2]primitive.ObjectID, error) {
+	ids := make([][2]primitive.ObjectID, 0, len(targets))
+	for _, target := range targets {
+		var platform, arch struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		platformErr := c.getMeta(ctx, metaCollection, "platform_name", target.Platform, &platform)
+		archErr := c.getMeta(ctx, metaCollection, "arch_id", target.Arch, &arch)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if strict && (platformErr != nil || archErr != nil) {
+			return nil, fmt.Errorf("%w: %s/%s", ErrUnknownTarget, target.Platform, target.Arch)
+		}
+		ids = append(ids, [2]primitive.ObjectID{platform.ID, arch.ID})
+	}
+	return ids, nil
+}
+
+// completenessFilter matches the versions with an artifact for every required target
+func (c *appRepository) completenessFilter(ctx context.Context, metaCollection *mongo.Collection, targets []model.RequiredTarget) (bson.E, error) {
+	ids, err := c.requiredTargetIDs(ctx, metaCollection, targets, false)
+	if err != nil {
+		return bson.E{}, err
+	}
+	required := bson.A{}
+	for _, id := range ids {
+		required = append(required, bson.D{{Key: "artifacts", Value: bson.D{{Key: "$elemMatch", Value: bson.D{
+			{Key: "platform", Value: id[0]},
+			{Key: "arch", Value: id[1]},
+		}}}}})
+	}
+	return bson.E{Key: "$and", Value: required}, nil
+}
+
+// markCompleteness sets whether the versions have artifacts for all required targets.
+// Versions are left unmarked when the app has no required targets.
+func markCompleteness(apps []*model.SpecificAppWithoutIDs, targets []model.RequiredTarget) {
+	if len(targets) == 0 {
+		return
+	}
+	for _, app := range apps {
+		complete := ReleaseComplete(app.Artifacts, targets)
+		app.Complete = &complete
+	}
+}
+
+// ReleaseComplete reports whether the artifacts cover every required target
+func ReleaseComplete(artifacts []model.SpecificArtifactsWithoutIDs, targets []model.RequiredTarget) bool {
+	for _, target := range targets {
+		found := false
+		for _, artifact := range artifacts {
+			if artifact.Platform == target.Platform && artifact.Arch == target.Arch {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
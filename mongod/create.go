@@ -15,6 +15,24 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
+// ErrInvalidProperties is returned when the properties of a version would exceed the limits once merged
+var ErrInvalidProperties = errors.New("invalid properties")
+
+// mergeProperties overwrites the stored properties with the sent ones and keeps the others
+func mergeProperties(stored, sent map[string]interface{}) (map[string]interface{}, error) {
+	merged := make(map[string]interface{}, len(stored)+len(sent))
+	for key, value := range stored {
+		merged[key] = value
+	}
+	for key, value := range sent {
+		merged[key] = value
+	}
+	if err := utils.ValidateProperties(merged); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidProperties, err)
+	}
+	return merged, nil
+}
+
 func (c *appRepository) CreateDocument(collectionName string, document bson.D, uniqueKey, keyType string, ctx context.Context) (interface{}, error) {
 	collection := c.client.Database(c.config.Database).Collection(collectionName)
 
@@ -23,12 +41,8 @@ func (c *appRepository) CreateDocument(collectionName string, document bson.D, u
 	logrus.Debugln("Document: ", document)
 	uploadResult, err := collection.InsertOne(ctx, document)
 	if err != nil {
-		if mongoErr, ok := err.(mongo.WriteException); ok {
-			for _, writeErr := range mongoErr.WriteErrors {
-				if writeErr.Code == 11000 && strings.Contains(writeErr.Message, uniqueKey) {
-					return nil, fmt.Errorf("%s with this name already exists", keyType)
-				}
-			}
+		if dupErr := duplicateKeyError(err, uniqueKey, keyType); dupErr != nil {
+			return nil, dupErr
 		}
 		logrus.Errorf("Error inserting document: %v", err)
 		return nil, err
@@ -37,6 +51,22 @@ func (c *appRepository) CreateDocument(collectionName string, document bson.D, u
 	return uploadResult.InsertedID, nil
 }
 
+// duplicateKeyError returns the "already exists" error of keyType when err was caused by the uniqueKey index.
+// The unique index is what rejects concurrent creates of the same name, so no lookup is done before writing.
+func duplicateKeyError(err error, uniqueKey, keyType string) error {
+	if uniqueKey == "" {
+		return nil
+	}
+	if mongoErr, ok := err.(mongo.WriteException); ok {
+		for _, writeErr := range mongoErr.WriteErrors {
+			if writeErr.Code == 11000 && strings.Contains(writeErr.Message, uniqueKey) {
+				return fmt.Errorf("%s with this name %w", keyType, ErrAlreadyExists)
+			}
+		}
+	}
+	return nil
+}
+
 // CreateChannel creates a new channel document
 func (c *appRepository) CreateChannel(channelName string, ctx context.Context) (interface{}, error) {
 	document := bson.D{{Key: "channel_name", Value: channelName}}
@@ -57,11 +87,20 @@ func (c *appRepository) CreateArch(archID string, ctx context.Context) (interfac
 
 // CreateApp creates a new app_name document
 func (c *appRepository) CreateApp(appName string, ctx context.Context) (interface{}, error) {
+	return c.CreateAppWithVersionPattern(appName, "", ctx)
+}
+
+// CreateAppWithVersionPattern creates a new app_name document whose versions have to match versionPattern.
+// An empty pattern keeps the default version format.
+func (c *appRepository) CreateAppWithVersionPattern(appName, versionPattern string, ctx context.Context) (interface{}, error) {
 	document := bson.D{{Key: "app_name", Value: appName}}
+	if versionPattern != "" {
+		document = append(document, bson.E{Key: "version_pattern", Value: versionPattern})
+	}
 	return c.CreateDocument("apps_meta", document, "app_name_sort_by_asc_created", "app", ctx)
 }
 
-func (c *appRepository) Upload(ctxQuery map[string]interface{}, appLink, extension string, ctx context.Context) (interface{}, error) {
+func (c *appRepository) Upload(ctxQuery map[string]interface{}, appLink, extension string, size int64, ctx context.Context) (interface{}, error) {
 	collection := c.client.Database(c.config.Database).Collection("apps")
 	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
 	var uploadResult interface{}
@@ -114,25 +153,57 @@ func (c *appRepository) Upload(ctxQuery map[string]interface{}, appLink, extensi
 
 		for _, artifact := range appData.Artifacts {
 			if artifact.Package == extension && artifact.Arch == archMeta.ID && artifact.Platform == platformMeta.ID {
-				msg := "app with this name, version, platform, architecture and extension already exists"
-				return msg, errors.New(msg)
+				err := fmt.Errorf("app with this name, version, platform, architecture and extension %w", ErrAlreadyExists)
+				return err.Error(), err
 			}
 		}
 
+		preferred := isPreferredPackage(ctxQuery, extension)
+		if preferred {
+			// Only one package is recommended per platform and arch
+			for i := range appData.Artifacts {
+				if appData.Artifacts[i].Arch == archMeta.ID && appData.Artifacts[i].Platform == platformMeta.ID {
+					appData.Artifacts[i].Preferred = false
+				}
+			}
+		}
 		appData.Artifacts = append(appData.Artifacts, model.Artifact{
-			Link:     appLink,
-			Platform: platformMeta.ID,
-			Arch:     archMeta.ID,
-			Package:  extension,
+			Link:      appLink,
+			Platform:  platformMeta.ID,
+			Arch:      archMeta.ID,
+			Package:   extension,
+			Size:      size,
+			Checksum:  utils.GetStringValue(ctxQuery, "checksum"),
+			Preferred: preferred,
 		})
+		updateFields := bson.D{
+			{Key: "artifacts", Value: appData.Artifacts},
+			{Key: "updated_by", Value: utils.GetStringValue(ctxQuery, "updated_by")},
+			{Key: "updated_at", Value: time.Now()},
+		}
+		// A version with an artifact missing in the bucket isn't offered to clients anymore
+		if utils.GetBoolParam(ctxQuery["unverified"]) {
+			updateFields = append(updateFields, bson.E{Key: "published", Value: false})
+		}
+		// Properties sent with the artifact are merged into the ones of the version
+		if properties, ok := ctxQuery["properties"].(map[string]interface{}); ok && len(properties) > 0 {
+			merged, err := mergeProperties(appData.Properties, properties)
+			if err != nil {
+				return nil, err
+			}
+			updateFields = append(updateFields, bson.E{Key: "properties", Value: merged})
+		}
 		_, err = collection.UpdateOne(
 			ctx,
 			bson.D{{Key: "app_id", Value: appMeta.ID}, {Key: "version", Value: ctxQuery["version"].(string)}},
-			bson.D{{Key: "$set", Value: bson.D{{Key: "artifacts", Value: appData.Artifacts}, {Key: "updated_at", Value: time.Now()}}}},
+			bson.D{{Key: "$set", Value: updateFields}, bumpRevision()},
 		)
 		if err != nil {
 			return nil, err
 		}
+		if err := c.incrementAppUsage(appMeta.ID, size, 0, ctx); err != nil {
+			logrus.Errorf("Error updating usage for app %s: %v", appMeta.ID.Hex(), err)
+		}
 
 		uploadResult = appData.ID
 	} else {
@@ -151,14 +222,18 @@ func (c *appRepository) Upload(ctxQuery map[string]interface{}, appLink, extensi
 		}
 
 		artifact := model.Artifact{
-			Link:     appLink,
-			Platform: platformMeta.ID,
-			Arch:     archMeta.ID,
-			Package:  extension,
+			Link:      appLink,
+			Platform:  platformMeta.ID,
+			Arch:      archMeta.ID,
+			Package:   extension,
+			Size:      size,
+			Checksum:  utils.GetStringValue(ctxQuery, "checksum"),
+			Preferred: isPreferredPackage(ctxQuery, extension),
 		}
 		changelog := model.Changelog{
 			Version: ctxQuery["version"].(string),
 			Changes: ctxQuery["changelog"].(string),
+			Raw:     utils.GetStringValue(ctxQuery, "changelog_raw"),
 			Date:    time.Now().Format("2006-01-02"),
 		}
 		filter := bson.D{
@@ -169,8 +244,15 @@ func (c *appRepository) Upload(ctxQuery map[string]interface{}, appLink, extensi
 			{Key: "critical", Value: critical},
 			{Key: "artifacts", Value: []model.Artifact{artifact}},
 			{Key: "changelog", Value: []model.Changelog{changelog}},
+			{Key: "updated_by", Value: utils.GetStringValue(ctxQuery, "updated_by")},
 			{Key: "updated_at", Value: time.Now()},
 		}
+		if properties, ok := ctxQuery["properties"].(map[string]interface{}); ok && len(properties) > 0 {
+			filter = append(filter, bson.E{Key: "properties", Value: properties})
+		}
+		if targeting, ok := queryTargeting(ctxQuery); ok && targeting != nil {
+			filter = append(filter, bson.E{Key: "targeting", Value: targeting})
+		}
 		logrus.Debugf("Channel Meta: %v", channelMeta)
 		logrus.Debugf("Platform Meta: %v", platformMeta)
 		logrus.Debugf("Arch Meta: %v", archMeta)
@@ -188,6 +270,9 @@ func (c *appRepository) Upload(ctxQuery map[string]interface{}, appLink, extensi
 				}
 			}
 		}
+		if err := c.incrementAppUsage(appMeta.ID, size, 1, ctx); err != nil {
+			logrus.Errorf("Error updating usage for app %s: %v", appMeta.ID.Hex(), err)
+		}
 	}
 
 	switch v := uploadResult.(type) {
@@ -217,3 +302,9 @@ func (c *appRepository) Upload(ctxQuery map[string]interface{}, appLink, extensi
 		return nil, errors.New("unexpected return type")
 	}
 }
+
+// isPreferredPackage reports whether the preferred_package of an upload is the stored extension
+func isPreferredPackage(ctxQuery map[string]interface{}, extension string) bool {
+	pkg, _ := ctxQuery["preferred_package"].(string)
+	return pkg != "" && storedPackage(pkg) == extension
+}
@@ -2,7 +2,9 @@ package mongod
 
 import (
 	"context"
+	"errors"
 	"faynoSync/server/model"
+	"faynoSync/server/utils"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -23,6 +25,14 @@ func CreateUser(client *mongo.Client, dbName *mongo.Database, credentials *model
 		{Key: "password", Value: string(hashedPassword)},
 		{Key: "updated_at", Value: time.Now()},
 	}
+	// Users without apps are admins and see every app
+	if credentials.Apps != nil {
+		filter = append(filter, bson.E{Key: "apps", Value: credentials.Apps})
+	}
+	// Users without a role get the default one of their scope when they log in
+	if credentials.Role != "" {
+		filter = append(filter, bson.E{Key: "role", Value: credentials.Role})
+	}
 
 	_, err = collection.InsertOne(context.Background(), filter)
 	if err != nil {
@@ -31,3 +41,26 @@ func CreateUser(client *mongo.Client, dbName *mongo.Database, credentials *model
 
 	return nil
 }
+
+// EnsureAdmin creates an admin with credentials unless a user with the username already exists, whose
+// password is then left unchanged. It reports whether the admin was created, so provisioning can run it
+// on every deployment.
+func EnsureAdmin(ctx context.Context, database *mongo.Database, credentials *model.Credentials) (bool, error) {
+	admins := database.Collection("admins")
+	err := admins.FindOne(ctx, bson.D{{Key: "username", Value: credentials.Username}}).Err()
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return false, err
+	}
+	admin := *credentials
+	admin.Apps = nil
+	admin.Role = utils.RoleAdmin
+	err = CreateUser(database.Client(), database, &admin)
+	if mongo.IsDuplicateKeyError(err) {
+		// Created by another instance provisioning at the same time
+		return false, nil
+	}
+	return err == nil, err
+}
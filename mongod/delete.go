@@ -2,14 +2,17 @@ package mongod
 
 import (
 	"context"
+	"errors"
 	"faynoSync/server/model"
 	"fmt"
 	"log"
+	"strings"
 
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func (c *appRepository) DeleteSpecificVersionOfApp(id primitive.ObjectID, ctx context.Context) ([]string, int64, error) {
@@ -35,13 +38,41 @@ func (c *appRepository) DeleteSpecificVersionOfApp(id primitive.ObjectID, ctx co
 		return nil, 0, err
 	}
 
+	if err := c.RecomputeAppUsage(app.AppID, ctx); err != nil {
+		logrus.Errorf("Error recomputing usage for app %s: %v", app.AppID.Hex(), err)
+	}
+
 	var links []string
 	for _, artifact := range app.Artifacts {
 		link := string(artifact.Link)
 		links = append(links, link)
 	}
+	links = append(links, app.Signatures...)
 
-	return links, deleteResult.DeletedCount, nil
+	return c.unreferencedLinks(links, ctx), deleteResult.DeletedCount, nil
+}
+
+// unreferencedLinks filters out links that are still used by other versions, e.g. by a cloned app,
+// so their objects aren't removed from the bucket
+func (c *appRepository) unreferencedLinks(links []string, ctx context.Context) []string {
+	collection := c.client.Database(c.config.Database).Collection("apps")
+
+	var unreferenced []string
+	for _, link := range links {
+		filter := bson.D{{Key: "$or", Value: bson.A{
+			bson.D{{Key: "artifacts.link", Value: link}},
+			bson.D{{Key: "signatures", Value: link}},
+		}}}
+		count, err := collection.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+		if err != nil {
+			logrus.Errorf("Error checking references of %s: %v", link, err)
+			continue
+		}
+		if count == 0 {
+			unreferenced = append(unreferenced, link)
+		}
+	}
+	return unreferenced
 }
 
 type Document interface{}
@@ -88,3 +119,153 @@ func (c *appRepository) DeleteApp(id primitive.ObjectID, ctx context.Context) (i
 	var app model.App
 	return c.DeleteDocument("apps_meta", id, &app, ctx)
 }
+
+// referenceFields maps meta item types to the version field that references them
+var referenceFields = map[string]string{
+	"channel":  "channel_id",
+	"platform": "artifacts.platform",
+	"arch":     "artifacts.arch",
+}
+
+// CountDependentVersions returns the number of versions that use the channel, platform or arch
+func (c *appRepository) CountDependentVersions(itemType string, id primitive.ObjectID, ctx context.Context) (int64, error) {
+	field, ok := referenceFields[itemType]
+	if !ok {
+		return 0, fmt.Errorf("invalid item type %s", itemType)
+	}
+	collection := c.client.Database(c.config.Database).Collection("apps")
+	return collection.CountDocuments(ctx, bson.D{{Key: field, Value: id}})
+}
+
+// DeleteDependentVersions removes the artifacts that use the channel, platform or arch.
+// Versions of a deleted channel and versions left without artifacts are deleted.
+// It returns the links of the removed artifacts and the names of the apps they belonged to.
+func (c *appRepository) DeleteDependentVersions(itemType string, id primitive.ObjectID, ctx context.Context) ([]string, []string, error) {
+	field, ok := referenceFields[itemType]
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid item type %s", itemType)
+	}
+	collection := c.client.Database(c.config.Database).Collection("apps")
+	filter := bson.D{{Key: field, Value: id}}
+
+	cur, err := collection.Find(ctx, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	var apps []model.SpecificApp
+	if err := cur.All(ctx, &apps); err != nil {
+		return nil, nil, err
+	}
+
+	var links []string
+	var ids []primitive.ObjectID
+	appIDs := make(map[primitive.ObjectID]bool)
+	for _, app := range apps {
+		ids = append(ids, app.ID)
+		appIDs[app.AppID] = true
+		for _, artifact := range app.Artifacts {
+			if itemType == "channel" || (itemType == "platform" && artifact.Platform == id) || (itemType == "arch" && artifact.Arch == id) {
+				links = append(links, artifact.Link)
+			}
+		}
+		if itemType == "channel" {
+			links = append(links, app.Signatures...)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil, nil
+	}
+
+	if itemType == "channel" {
+		_, err = collection.DeleteMany(ctx, filter)
+	} else {
+		artifactField := strings.TrimPrefix(field, "artifacts.")
+		_, err = collection.UpdateMany(ctx, filter, bson.D{{Key: "$pull", Value: bson.D{{Key: "artifacts", Value: bson.D{{Key: artifactField, Value: id}}}}}, bumpRevision()})
+		if err == nil {
+			_, err = collection.DeleteMany(ctx, bson.D{
+				{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}},
+				{Key: "artifacts", Value: bson.D{{Key: "$size", Value: 0}}},
+			})
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var appNames []string
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+	for appID := range appIDs {
+		if err := c.RecomputeAppUsage(appID, ctx); err != nil {
+			logrus.Errorf("Error recomputing usage for app %s: %v", appID.Hex(), err)
+		}
+		var meta model.App
+		if err := metaCollection.FindOne(ctx, bson.D{{Key: "_id", Value: appID}}).Decode(&meta); err != nil {
+			logrus.Errorf("Error fetching app %s: %v", appID.Hex(), err)
+			continue
+		}
+		appNames = append(appNames, meta.AppName)
+	}
+	return c.unreferencedLinks(links, ctx), appNames, nil
+}
+
+var (
+	ErrAppMetaNotFound = errors.New("app not found")
+	ErrChannelNotFound = errors.New("channel not found")
+)
+
+// DeleteAppVersions deletes all versions of the app, or only those of the channel when it's set.
+// The app, channels, platforms and archs are kept. It returns the links of the removed files
+// and the number of deleted versions.
+func (c *appRepository) DeleteAppVersions(appName, channel string, ctx context.Context) ([]string, int64, error) {
+	collection := c.client.Database(c.config.Database).Collection("apps")
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+
+	var app model.App
+	if err := c.getMeta(ctx, metaCollection, "app_name", appName, &app); err != nil {
+		if ctx.Err() != nil {
+			return nil, 0, ctx.Err()
+		}
+		return nil, 0, ErrAppMetaNotFound
+	}
+	filter := bson.D{{Key: "app_id", Value: app.ID}}
+	if channel != "" {
+		var channelMeta model.Channel
+		if err := c.getMeta(ctx, metaCollection, "channel_name", channel, &channelMeta); err != nil {
+			if ctx.Err() != nil {
+				return nil, 0, ctx.Err()
+			}
+			return nil, 0, ErrChannelNotFound
+		}
+		filter = append(filter, bson.E{Key: "channel_id", Value: channelMeta.ID})
+	}
+
+	cur, err := collection.Find(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	var versions []model.SpecificApp
+	if err := cur.All(ctx, &versions); err != nil {
+		return nil, 0, err
+	}
+	if len(versions) == 0 {
+		return nil, 0, nil
+	}
+
+	var links []string
+	for _, version := range versions {
+		for _, artifact := range version.Artifacts {
+			links = append(links, artifact.Link)
+		}
+		links = append(links, version.Signatures...)
+	}
+
+	result, err := collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := c.RecomputeAppUsage(app.ID, ctx); err != nil {
+		logrus.Errorf("Error recomputing usage for app %s: %v", app.ID.Hex(), err)
+	}
+	// Cloned apps may still use the same files
+	return c.unreferencedLinks(links, ctx), result.DeletedCount, nil
+}
@@ -0,0 +1,51 @@
+package mongod
+
+import (
+	"context"
+	"faynoSync/server/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SetAppDeprecation deprecates the app, a nil deprecation lifts it
+func (c *appRepository) SetAppDeprecation(appName string, deprecation *model.AppDeprecation, ctx context.Context) error {
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+
+	var app struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := c.getMeta(ctx, metaCollection, "app_name", appName, &app); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return ErrAppNotFound
+	}
+
+	filter := bson.D{{Key: "_id", Value: app.ID}}
+	var update bson.D
+	if deprecation == nil {
+		update = bson.D{{Key: "$unset", Value: bson.D{{Key: "deprecation", Value: ""}}}}
+	} else {
+		update = bson.D{{Key: "$set", Value: bson.D{{Key: "deprecation", Value: deprecation}}}}
+	}
+	_, err := c.UpdateDocument("apps_meta", filter, update, "", "app", ctx)
+	return err
+}
+
+// AppDeprecation returns the deprecation of the app, nil when it isn't deprecated.
+// Unknown apps aren't deprecated, the request fails on the app itself.
+func (c *appRepository) AppDeprecation(appName string, ctx context.Context) (*model.AppDeprecation, error) {
+	if appName == "" {
+		return nil, nil
+	}
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+	var app model.App
+	if err := c.getMeta(ctx, metaCollection, "app_name", appName, &app); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, nil
+	}
+	return app.Deprecation, nil
+}
@@ -0,0 +1,123 @@
+package mongod
+
+import (
+	"context"
+	"faynoSync/server/model"
+	"sort"
+
+	"github.com/hashicorp/go-version"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type downloadKey struct {
+	platform primitive.ObjectID
+	arch     primitive.ObjectID
+	pkg      string
+}
+
+// LatestDownloads returns the newest published artifact of every platform, arch and package of the app in the channel.
+// The artifacts can come from different versions, e.g. when the newest version was only released for one platform.
+// Pinned latest versions apply like on /checkVersion, newer versions are skipped for their platform and arch.
+func (c *appRepository) LatestDownloads(appName, channel string, ctx context.Context) ([]model.Download, error) {
+	collection := c.client.Database(c.config.Database).Collection("apps")
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+
+	var appMeta, channelMeta struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := c.getMeta(ctx, metaCollection, "app_name", appName, &appMeta); err != nil {
+		return nil, ErrAppNotFound
+	}
+	if err := c.getMeta(ctx, metaCollection, "channel_name", channel, &channelMeta); err != nil {
+		// Nothing can be published in a channel that doesn't exist
+		return []model.Download{}, nil
+	}
+
+	names, err := c.metaNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.D{
+		{Key: "app_id", Value: appMeta.ID},
+		{Key: "channel_id", Value: channelMeta.ID},
+		{Key: "published", Value: true},
+		{Key: "yanked", Value: notYanked},
+	}}}}
+	pipeline = append(pipeline, c.sortVersionPipeline()...)
+	cur, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	pins := map[[2]primitive.ObjectID]*version.Version{}
+	pinFor := func(platform, arch primitive.ObjectID) (*version.Version, error) {
+		key := [2]primitive.ObjectID{platform, arch}
+		if pin, ok := pins[key]; ok {
+			return pin, nil
+		}
+		pinned, err := c.pinnedVersion(appMeta.ID, channelMeta.ID, platform, arch, ctx)
+		if err != nil || pinned == "" {
+			pins[key] = nil
+			return nil, err
+		}
+		pin, err := version.NewVersion(pinned)
+		pins[key] = pin
+		return pin, err
+	}
+
+	seen := map[downloadKey]bool{}
+	downloads := []model.Download{}
+	for cur.Next(ctx) {
+		var app model.SpecificApp
+		if err := cur.Decode(&app); err != nil {
+			return nil, err
+		}
+		appVersion, err := version.NewVersion(app.Version)
+		if err != nil {
+			return nil, err
+		}
+		for _, artifact := range app.Artifacts {
+			key := downloadKey{artifact.Platform, artifact.Arch, artifact.Package}
+			if seen[key] {
+				continue
+			}
+			pin, err := pinFor(artifact.Platform, artifact.Arch)
+			if err != nil {
+				return nil, err
+			}
+			if pin != nil && appVersion.GreaterThan(pin) {
+				continue
+			}
+			seen[key] = true
+			downloads = append(downloads, model.Download{
+				Platform:  names[artifact.Platform],
+				Arch:      names[artifact.Arch],
+				Package:   artifact.Package,
+				Version:   app.Version,
+				Critical:  app.Critical,
+				URL:       artifact.Link,
+				Size:      artifact.Size,
+				UpdatedAt: app.Updated_at,
+			})
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(downloads, func(i, j int) bool {
+		a, b := downloads[i], downloads[j]
+		if a.Platform != b.Platform {
+			return a.Platform < b.Platform
+		}
+		if a.Arch != b.Arch {
+			return a.Arch < b.Arch
+		}
+		return a.Package < b.Package
+	})
+	return downloads, nil
+}
@@ -0,0 +1,77 @@
+package mongod
+
+import (
+	"context"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// VersionExists reports whether a version matching the given parameters is stored.
+// Empty channel, platform, arch and package parameters are not used for filtering.
+func (c *appRepository) VersionExists(appName, version, channel, platform, arch, pkg string, publishedOnly bool, ctx context.Context) (bool, error) {
+	collection := c.client.Database(c.config.Database).Collection("apps")
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+
+	var appMeta, channelMeta, platformMeta, archMeta struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+
+	// An unknown app, channel, platform or arch simply means the version doesn't exist,
+	// unless the lookup failed because the context was cancelled
+	if err := c.getMeta(ctx, metaCollection, "app_name", appName, &appMeta); err != nil {
+		return false, ctx.Err()
+	}
+
+	filter := bson.D{
+		{Key: "app_id", Value: appMeta.ID},
+		{Key: "version", Value: version},
+	}
+	if publishedOnly {
+		filter = append(filter, bson.E{Key: "published", Value: true})
+	}
+
+	if channel != "" {
+		if err := c.getMeta(ctx, metaCollection, "channel_name", channel, &channelMeta); err != nil {
+			return false, ctx.Err()
+		}
+		filter = append(filter, bson.E{Key: "channel_id", Value: channelMeta.ID})
+	}
+
+	artifactFilter := bson.D{}
+	if platform != "" {
+		if err := c.getMeta(ctx, metaCollection, "platform_name", platform, &platformMeta); err != nil {
+			return false, ctx.Err()
+		}
+		artifactFilter = append(artifactFilter, bson.E{Key: "platform", Value: platformMeta.ID})
+	}
+	if arch != "" {
+		if err := c.getMeta(ctx, metaCollection, "arch_id", arch, &archMeta); err != nil {
+			return false, ctx.Err()
+		}
+		artifactFilter = append(artifactFilter, bson.E{Key: "arch", Value: archMeta.ID})
+	}
+	if pkg != "" {
+		artifactFilter = append(artifactFilter, bson.E{Key: "package", Value: storedPackage(pkg)})
+	}
+	if len(artifactFilter) > 0 {
+		filter = append(filter, bson.E{Key: "artifacts", Value: bson.D{{Key: "$elemMatch", Value: artifactFilter}}})
+	}
+
+	count, err := collection.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// storedPackage converts a package parameter like deb or no-extension to the stored extension.
+// Artifacts without extension are stored with an empty package.
+func storedPackage(pkg string) string {
+	if pkg == "no-extension" {
+		return ""
+	}
+	return "." + strings.TrimPrefix(pkg, ".")
+}
@@ -0,0 +1,141 @@
+package mongod
+
+import (
+	"context"
+	"errors"
+	"faynoSync/server/model"
+	"fmt"
+
+	"github.com/hashicorp/go-version"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrFlagNotFound = errors.New("flag not found")
+
+// CreateFlag creates a feature flag for an existing app
+func (c *appRepository) CreateFlag(flag model.Flag, ctx context.Context) (interface{}, error) {
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+	if err := c.getMeta(ctx, metaCollection, "app_name", flag.AppName, &appMeta); err != nil {
+		return nil, err
+	}
+	document := bson.D{
+		{Key: "app_name", Value: flag.AppName},
+		{Key: "key", Value: flag.Key},
+		{Key: "value", Value: flag.Value},
+		{Key: "channel", Value: flag.Channel},
+		{Key: "min_version", Value: flag.MinVersion},
+		{Key: "max_version", Value: flag.MaxVersion},
+	}
+	return c.CreateDocument("flags", document, "flag_unique_targeting", "flag", ctx)
+}
+
+// ListFlags returns all feature flags of the app
+func (c *appRepository) ListFlags(appName string, ctx context.Context) ([]*model.Flag, error) {
+	var flags []*model.Flag
+	filter := bson.M{"app_name": appName}
+	if err := c.listItems(ctx, "flags", filter, &flags); err != nil {
+		return nil, err
+	}
+	return flags, nil
+}
+
+// GetFlag returns the feature flag with the id
+func (c *appRepository) GetFlag(id primitive.ObjectID, ctx context.Context) (model.Flag, error) {
+	var flag model.Flag
+	err := c.client.Database(c.config.Database).Collection("flags").FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&flag)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return flag, ErrFlagNotFound
+	}
+	return flag, err
+}
+
+// UpdateFlag replaces the value and the targeting of a feature flag
+func (c *appRepository) UpdateFlag(id primitive.ObjectID, flag model.Flag, ctx context.Context) (interface{}, error) {
+	filter := bson.D{{Key: "_id", Value: id}}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "key", Value: flag.Key},
+		{Key: "value", Value: flag.Value},
+		{Key: "channel", Value: flag.Channel},
+		{Key: "min_version", Value: flag.MinVersion},
+		{Key: "max_version", Value: flag.MaxVersion},
+	}}}
+	return c.UpdateDocument("flags", filter, update, "flag_unique_targeting", "flag", ctx)
+}
+
+// DeleteFlag deletes a feature flag
+func (c *appRepository) DeleteFlag(id primitive.ObjectID, ctx context.Context) (int64, error) {
+	var flag model.Flag
+	return c.DeleteDocument("flags", id, &flag, ctx)
+}
+
+// ApplicableFlags returns the flags of the app that target the channel and the version.
+// When several flags share a key, the one targeting the channel or a version range wins.
+func (c *appRepository) ApplicableFlags(appName, channel, clientVersion string, ctx context.Context) (map[string]interface{}, error) {
+	collection := c.client.Database(c.config.Database).Collection("flags")
+
+	filter := bson.D{
+		{Key: "app_name", Value: appName},
+		{Key: "channel", Value: bson.D{{Key: "$in", Value: bson.A{"", channel}}}},
+	}
+	cur, err := collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	var flags []model.Flag
+	if err := cur.All(ctx, &flags); err != nil {
+		return nil, err
+	}
+
+	var requested *version.Version
+	if clientVersion != "" {
+		requested, err = version.NewVersion(clientVersion)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %s: %w", clientVersion, err)
+		}
+	}
+
+	values := make(map[string]interface{})
+	scores := make(map[string]int)
+	for _, flag := range flags {
+		score, ok := flagScore(flag, requested)
+		if !ok {
+			continue
+		}
+		if best, exists := scores[flag.Key]; exists && best >= score {
+			continue
+		}
+		values[flag.Key] = flag.Value
+		scores[flag.Key] = score
+	}
+	return values, nil
+}
+
+// flagScore reports whether the flag applies to the version and how specific its targeting is
+func flagScore(flag model.Flag, requested *version.Version) (int, bool) {
+	score := 0
+	if flag.Channel != "" {
+		score += 2
+	}
+	if flag.MinVersion == "" && flag.MaxVersion == "" {
+		return score, true
+	}
+	// Flags targeting versions only apply when the client sends its version
+	if requested == nil {
+		return 0, false
+	}
+	if flag.MinVersion != "" {
+		minVersion, err := version.NewVersion(flag.MinVersion)
+		if err != nil || requested.LessThan(minVersion) {
+			return 0, false
+		}
+	}
+	if flag.MaxVersion != "" {
+		maxVersion, err := version.NewVersion(flag.MaxVersion)
+		if err != nil || requested.GreaterThan(maxVersion) {
+			return 0, false
+		}
+	}
+	return score + 1, true
+}
@@ -0,0 +1,54 @@
+package mongod
+
+import (
+	"context"
+	"errors"
+	"faynoSync/server/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// FreezeStatus tells whether uploads to a channel are blocked. Until is nil when the channel
+// is frozen until it's unfrozen.
+type FreezeStatus struct {
+	Frozen bool
+	Reason string
+	Until  *time.Time
+}
+
+// SetChannelFreeze stores the freeze of a channel, a freeze that isn't frozen and has no windows removes it
+func (c *appRepository) SetChannelFreeze(id primitive.ObjectID, freeze model.ChannelFreeze, ctx context.Context) (interface{}, error) {
+	filter := bson.D{{Key: "_id", Value: id}, {Key: "channel_name", Value: bson.M{"$exists": true}}}
+	var update bson.D
+	if !freeze.Frozen && len(freeze.Windows) == 0 {
+		update = bson.D{{Key: "$unset", Value: bson.D{{Key: "freeze", Value: ""}}}}
+	} else {
+		update = bson.D{{Key: "$set", Value: bson.D{{Key: "freeze", Value: freeze}}}}
+	}
+	return c.UpdateDocument("apps_meta", filter, update, "", "channel", ctx)
+}
+
+// ChannelFreezeStatus returns whether uploads to the channel are blocked at now.
+// Unknown channels aren't frozen, the upload fails on the channel itself.
+func (c *appRepository) ChannelFreezeStatus(channel string, now time.Time, ctx context.Context) (FreezeStatus, error) {
+	if channel == "" {
+		return FreezeStatus{}, nil
+	}
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+	var meta model.Channel
+	err := metaCollection.FindOne(ctx, bson.D{{Key: "channel_name", Value: channel}}).Decode(&meta)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return FreezeStatus{}, nil
+	}
+	if err != nil {
+		return FreezeStatus{}, err
+	}
+	if meta.Freeze == nil {
+		return FreezeStatus{}, nil
+	}
+	frozen, reason, until := meta.Freeze.Active(now)
+	return FreezeStatus{Frozen: frozen, Reason: reason, Until: until}, nil
+}
@@ -0,0 +1,84 @@
+package mongod
+
+import (
+	"context"
+	"errors"
+	"faynoSync/server/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// VersionImpact reports what deleting or yanking the version would change for the clients of its channel,
+// without changing anything. Pins for all platforms and archs are taken into account, clients of a pinned
+// version get no update once it's removed.
+func (c *appRepository) VersionImpact(id primitive.ObjectID, ctx context.Context) (model.VersionImpact, error) {
+	collection := c.client.Database(c.config.Database).Collection("apps")
+
+	var app model.SpecificApp
+	if err := collection.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&app); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return model.VersionImpact{}, ErrVersionNotFound
+		}
+		return model.VersionImpact{}, err
+	}
+	impact := model.VersionImpact{
+		ID:        id.Hex(),
+		Version:   app.Version,
+		Published: app.Published,
+		Yanked:    app.Yanked,
+		Artifacts: len(app.Artifacts),
+	}
+
+	named, err := c.FetchAppByID(id, ctx)
+	if err != nil {
+		return model.VersionImpact{}, err
+	}
+	if len(named) > 0 {
+		impact.AppName, impact.Channel = named[0].AppName, named[0].Channel
+	}
+
+	pinned, err := c.pinnedVersion(app.AppID, app.ChannelID, primitive.NilObjectID, primitive.NilObjectID, ctx)
+	if err != nil {
+		return model.VersionImpact{}, err
+	}
+	offered := app.Published && !app.Yanked
+	if pinned != "" {
+		impact.Pinned = pinned == app.Version
+		impact.Latest = impact.Pinned && offered
+		return impact, nil
+	}
+	if !offered {
+		return impact, nil
+	}
+
+	// The two newest offered versions tell whether this one is the latest and which one follows it
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"app_id": app.AppID, "channel_id": app.ChannelID, "published": true, "yanked": notYanked}}},
+	}
+	pipeline = append(pipeline, versionPartsPipeline()...)
+	pipeline = append(pipeline,
+		bson.D{{Key: "$sort", Value: versionSort(-1)}},
+		bson.D{{Key: "$limit", Value: 2}},
+		bson.D{{Key: "$project", Value: bson.D{{Key: "version", Value: 1}}}},
+	)
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return model.VersionImpact{}, err
+	}
+	var newest []struct {
+		ID      primitive.ObjectID `bson:"_id"`
+		Version string             `bson:"version"`
+	}
+	if err := cursor.All(ctx, &newest); err != nil {
+		return model.VersionImpact{}, err
+	}
+	if len(newest) > 0 && newest[0].ID == id {
+		impact.Latest = true
+		if len(newest) > 1 {
+			impact.NextLatest = newest[1].Version
+		}
+	}
+	return impact, nil
+}
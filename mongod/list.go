@@ -2,14 +2,21 @@ package mongod
 
 import (
 	"context"
+	"errors"
 	"faynoSync/server/model"
+	"fmt"
 	"reflect"
 
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// ErrItemNotFound is returned when no app, channel, platform or arch has the given id
+var ErrItemNotFound = errors.New("item not found")
+
 func (c *appRepository) listItems(ctx context.Context, collectionName string, filter bson.M, resultSlice interface{}) error {
 	findOptions := options.Find()
 	findOptions.SetLimit(100)
@@ -72,11 +79,42 @@ func (c *appRepository) ListArchs(ctx context.Context) ([]*model.Arch, error) {
 	return archs, nil
 }
 
-func (c *appRepository) ListApps(ctx context.Context) ([]*model.App, error) {
+// ListApps returns the app_name documents, limited to appNames unless it's nil
+func (c *appRepository) ListApps(appNames []string, ctx context.Context) ([]*model.App, error) {
 	var apps []*model.App
 	filter := bson.M{"app_name": bson.M{"$exists": true}}
+	if appNames != nil {
+		filter = bson.M{"app_name": bson.M{"$in": appNames}}
+	}
 	if err := c.listItems(ctx, "apps_meta", filter, &apps); err != nil {
 		return nil, err
 	}
 	return apps, nil
 }
+
+// GetItem returns the app, channel, platform or arch with the id, as listed by ListApps, ListChannels,
+// ListPlatforms and ListArchs
+func (c *appRepository) GetItem(itemType string, id primitive.ObjectID, ctx context.Context) (interface{}, error) {
+	var item interface{}
+	switch itemType {
+	case "app":
+		item = &model.App{}
+	case "channel":
+		item = &model.Channel{}
+	case "platform":
+		item = &model.Platform{}
+	case "arch":
+		item = &model.Arch{}
+	default:
+		return nil, fmt.Errorf("invalid item type %s", itemType)
+	}
+
+	collection := c.client.Database(c.config.Database).Collection("apps_meta")
+	if err := collection.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(item); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrItemNotFound
+		}
+		return nil, err
+	}
+	return item, nil
+}
@@ -0,0 +1,37 @@
+package mongod
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SetAppLogo stores the link of the app logo, an empty link removes it.
+// It returns the link of the previous logo so its object can be removed.
+func (c *appRepository) SetAppLogo(appName, link string, ctx context.Context) (string, error) {
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+
+	var app struct {
+		ID   primitive.ObjectID `bson:"_id"`
+		Logo string             `bson:"logo"`
+	}
+	if err := c.getMeta(ctx, metaCollection, "app_name", appName, &app); err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", ErrAppNotFound
+	}
+
+	filter := bson.D{{Key: "_id", Value: app.ID}}
+	var update bson.D
+	if link == "" {
+		update = bson.D{{Key: "$unset", Value: bson.D{{Key: "logo", Value: ""}}}}
+	} else {
+		update = bson.D{{Key: "$set", Value: bson.D{{Key: "logo", Value: link}}}}
+	}
+	if _, err := c.UpdateDocument("apps_meta", filter, update, "", "app", ctx); err != nil {
+		return "", err
+	}
+	return app.Logo, nil
+}
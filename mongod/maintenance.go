@@ -0,0 +1,43 @@
+package mongod
+
+import (
+	"context"
+	"errors"
+	"faynoSync/server/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SetChannelMaintenance disables the update checks of a channel, nil enables them again
+func (c *appRepository) SetChannelMaintenance(id primitive.ObjectID, maintenance *model.ChannelMaintenance, ctx context.Context) (interface{}, error) {
+	filter := bson.D{{Key: "_id", Value: id}, {Key: "channel_name", Value: bson.M{"$exists": true}}}
+	var update bson.D
+	if maintenance != nil {
+		update = bson.D{{Key: "$set", Value: bson.D{{Key: "maintenance", Value: maintenance}}}}
+	} else {
+		update = bson.D{{Key: "$unset", Value: bson.D{{Key: "maintenance", Value: ""}}}}
+	}
+	return c.UpdateDocument("apps_meta", filter, update, "", "channel", ctx)
+}
+
+// ChannelMaintenance returns the maintenance of the channel, nil when its update checks are enabled.
+// Unknown channels aren't in maintenance, the request fails on the channel itself.
+func (c *appRepository) ChannelMaintenance(channel string, ctx context.Context) (*model.ChannelMaintenance, error) {
+	if channel == "" {
+		return nil, nil
+	}
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+	var meta model.Channel
+	opts := options.FindOne().SetProjection(bson.D{{Key: "maintenance", Value: 1}})
+	err := metaCollection.FindOne(ctx, bson.D{{Key: "channel_name", Value: channel}}, opts).Decode(&meta)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return meta.Maintenance, nil
+}
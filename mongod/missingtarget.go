@@ -0,0 +1,50 @@
+package mongod
+
+import (
+	"context"
+	"faynoSync/server/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SetAppMissingTarget sets the missing platform/arch policy of the app, a nil policy resets it to the default
+func (c *appRepository) SetAppMissingTarget(appName string, policy *model.MissingTargetPolicy, ctx context.Context) error {
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+
+	var app struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := c.getMeta(ctx, metaCollection, "app_name", appName, &app); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return ErrAppNotFound
+	}
+
+	filter := bson.D{{Key: "_id", Value: app.ID}}
+	var update bson.D
+	if policy == nil {
+		update = bson.D{{Key: "$unset", Value: bson.D{{Key: "missing_target", Value: ""}}}}
+	} else {
+		update = bson.D{{Key: "$set", Value: bson.D{{Key: "missing_target", Value: policy}}}}
+	}
+	_, err := c.UpdateDocument("apps_meta", filter, update, "", "app", ctx)
+	return err
+}
+
+// AppMissingTarget returns the missing platform/arch policy of the app, nil when it has none
+func (c *appRepository) AppMissingTarget(appName string, ctx context.Context) (*model.MissingTargetPolicy, error) {
+	if appName == "" {
+		return nil, nil
+	}
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+	var app model.App
+	if err := c.getMeta(ctx, metaCollection, "app_name", appName, &app); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, nil
+	}
+	return app.MissingTarget, nil
+}
@@ -0,0 +1,121 @@
+package mongod
+
+import (
+	"context"
+	"errors"
+	"faynoSync/server/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrPinnedVersionNotFound = errors.New("no published version found to pin")
+
+// PinLatestVersion makes the given version the latest one for the app, channel, platform and arch
+// until the pin is removed. An empty version removes the pin.
+func (c *appRepository) PinLatestVersion(appName, channel, platform, arch, pinnedVersion string, ctx context.Context) (bool, error) {
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+
+	var appMeta, channelMeta, platformMeta, archMeta struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := c.getMeta(ctx, metaCollection, "app_name", appName, &appMeta); err != nil {
+		return false, err
+	}
+	if err := c.getMeta(ctx, metaCollection, "channel_name", channel, &channelMeta); err != nil {
+		return false, err
+	}
+	if platform != "" {
+		if err := c.getMeta(ctx, metaCollection, "platform_name", platform, &platformMeta); err != nil {
+			return false, err
+		}
+	}
+	if arch != "" {
+		if err := c.getMeta(ctx, metaCollection, "arch_id", arch, &archMeta); err != nil {
+			return false, err
+		}
+	}
+
+	if pinnedVersion != "" {
+		exists, err := c.VersionExists(appName, pinnedVersion, channel, platform, arch, "", true, ctx)
+		if err != nil {
+			return false, err
+		}
+		if !exists {
+			return false, ErrPinnedVersionNotFound
+		}
+	}
+
+	pin := model.LatestPin{
+		ChannelID:  channelMeta.ID,
+		PlatformID: platformMeta.ID,
+		ArchID:     archMeta.ID,
+		Version:    pinnedVersion,
+	}
+	filter := bson.D{{Key: "_id", Value: appMeta.ID}}
+
+	// Replace the previous pin for the same channel, platform and arch
+	update := bson.D{{Key: "$pull", Value: bson.D{{Key: "latest_pins", Value: bson.D{
+		{Key: "channel_id", Value: pin.ChannelID},
+		{Key: "platform", Value: pin.PlatformID},
+		{Key: "arch", Value: pin.ArchID},
+	}}}}}
+	result, err := metaCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	if pinnedVersion == "" {
+		return result.ModifiedCount > 0, nil
+	}
+
+	update = bson.D{
+		{Key: "$push", Value: bson.D{{Key: "latest_pins", Value: pin}}},
+		{Key: "$set", Value: bson.D{{Key: "updated_at", Value: time.Now()}}},
+	}
+	if _, err := metaCollection.UpdateOne(ctx, filter, update); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// pinnedVersion returns the version pinned for the app, channel, platform and arch.
+// A pin for the exact platform and arch wins over a pin for all platforms or archs.
+func (c *appRepository) pinnedVersion(appID, channelID, platformID, archID primitive.ObjectID, ctx context.Context) (string, error) {
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+
+	var app struct {
+		LatestPins []model.LatestPin `bson:"latest_pins"`
+	}
+	if err := metaCollection.FindOne(ctx, bson.D{{Key: "_id", Value: appID}}).Decode(&app); err != nil {
+		return "", err
+	}
+
+	pinned := ""
+	bestScore := -1
+	for _, pin := range app.LatestPins {
+		if pin.ChannelID != channelID {
+			continue
+		}
+		score := 0
+		switch pin.PlatformID {
+		case platformID:
+			score += 2
+		case primitive.NilObjectID:
+		default:
+			continue
+		}
+		switch pin.ArchID {
+		case archID:
+			score++
+		case primitive.NilObjectID:
+		default:
+			continue
+		}
+		if score > bestScore {
+			pinned = pin.Version
+			bestScore = score
+		}
+	}
+	return pinned, nil
+}
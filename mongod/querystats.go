@@ -0,0 +1,176 @@
+package mongod
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// maxLoggedQueryLength cuts long filters and pipelines in the slow query log
+const maxLoggedQueryLength = 1000
+
+// queryFields are the parts of a command that describe what it matches, logged with slow queries
+var queryFields = []string{"filter", "pipeline", "query", "updates", "deletes"}
+
+// QueryStats are the counters of one command on one collection, durations are in milliseconds
+type QueryStats struct {
+	Collection string  `json:"collection"`
+	Command    string  `json:"command"`
+	Count      int64   `json:"count"`
+	Failed     int64   `json:"failed"`
+	Slow       int64   `json:"slow"`
+	TotalMs    float64 `json:"total_ms"`
+	AvgMs      float64 `json:"avg_ms"`
+	MaxMs      float64 `json:"max_ms"`
+}
+
+type startedQuery struct {
+	collection string
+	command    bson.Raw
+}
+
+type queryStatsCollector struct {
+	mu      sync.Mutex
+	queries map[[2]string]*QueryStats
+	started sync.Map
+}
+
+var (
+	queryStatistics    = &queryStatsCollector{queries: map[[2]string]*QueryStats{}}
+	slowQueryThreshold atomic.Int64
+)
+
+// SetSlowQueryThreshold logs the commands that take longer than threshold, zero disables the log
+func SetSlowQueryThreshold(threshold time.Duration) {
+	slowQueryThreshold.Store(int64(threshold))
+}
+
+// SlowQueryThreshold returns the threshold of the slow query log, zero when it's disabled
+func SlowQueryThreshold() time.Duration {
+	return time.Duration(slowQueryThreshold.Load())
+}
+
+// ResetQueryStats drops all counters
+func ResetQueryStats() {
+	queryStatistics.mu.Lock()
+	defer queryStatistics.mu.Unlock()
+	queryStatistics.queries = map[[2]string]*QueryStats{}
+}
+
+// QueryStatistics returns the counters of every command and collection since the start, slowest in total first
+func QueryStatistics() []QueryStats {
+	queryStatistics.mu.Lock()
+	stats := make([]QueryStats, 0, len(queryStatistics.queries))
+	for _, query := range queryStatistics.queries {
+		stats = append(stats, *query)
+	}
+	queryStatistics.mu.Unlock()
+
+	for i := range stats {
+		stats[i].AvgMs = stats[i].TotalMs / float64(stats[i].Count)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].TotalMs != stats[j].TotalMs {
+			return stats[i].TotalMs > stats[j].TotalMs
+		}
+		return stats[i].Collection+stats[i].Command < stats[j].Collection+stats[j].Command
+	})
+	return stats
+}
+
+// newQueryMonitor times the commands on collections and logs the slow ones, next still receives all events
+func newQueryMonitor(next *event.CommandMonitor) *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, evt *event.CommandStartedEvent) {
+			queryStatistics.start(evt)
+			next.Started(ctx, evt)
+		},
+		Succeeded: func(ctx context.Context, evt *event.CommandSucceededEvent) {
+			queryStatistics.finish(evt.CommandFinishedEvent, false)
+			next.Succeeded(ctx, evt)
+		},
+		Failed: func(ctx context.Context, evt *event.CommandFailedEvent) {
+			queryStatistics.finish(evt.CommandFinishedEvent, true)
+			next.Failed(ctx, evt)
+		},
+	}
+}
+
+func (s *queryStatsCollector) start(evt *event.CommandStartedEvent) {
+	// Commands such as ping and hello have no collection and aren't counted
+	field := evt.CommandName
+	if field == "getMore" {
+		field = "collection"
+	}
+	collection, ok := evt.Command.Lookup(field).StringValueOK()
+	if !ok {
+		return
+	}
+	query := startedQuery{collection: collection}
+	if SlowQueryThreshold() > 0 {
+		// The event's command is only valid during the callback
+		query.command = append(bson.Raw{}, evt.Command...)
+	}
+	s.started.Store(evt.RequestID, query)
+}
+
+func (s *queryStatsCollector) finish(evt event.CommandFinishedEvent, failed bool) {
+	value, ok := s.started.LoadAndDelete(evt.RequestID)
+	if !ok {
+		return
+	}
+	query := value.(startedQuery)
+	duration := time.Duration(evt.DurationNanos)
+	threshold := SlowQueryThreshold()
+	slow := threshold > 0 && duration > threshold
+
+	s.mu.Lock()
+	key := [2]string{query.collection, evt.CommandName}
+	stats, ok := s.queries[key]
+	if !ok {
+		stats = &QueryStats{Collection: query.collection, Command: evt.CommandName}
+		s.queries[key] = stats
+	}
+	ms := float64(duration) / float64(time.Millisecond)
+	stats.Count++
+	stats.TotalMs += ms
+	if ms > stats.MaxMs {
+		stats.MaxMs = ms
+	}
+	if failed {
+		stats.Failed++
+	}
+	if slow {
+		stats.Slow++
+	}
+	s.mu.Unlock()
+
+	if slow {
+		logrus.WithFields(logrus.Fields{
+			"collection": query.collection,
+			"command":    evt.CommandName,
+			"duration":   duration,
+			"query":      loggedQuery(query.command),
+		}).Warn("Slow MongoDB query")
+	}
+}
+
+// loggedQuery returns the filter or pipeline of a command, shortened for the log
+func loggedQuery(command bson.Raw) string {
+	for _, field := range queryFields {
+		if value, err := command.LookupErr(field); err == nil {
+			query := value.String()
+			if len(query) > maxLoggedQueryLength {
+				query = query[:maxLoggedQueryLength] + "..."
+			}
+			return query
+		}
+	}
+	return ""
+}
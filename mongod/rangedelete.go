@@ -0,0 +1,151 @@
+package mongod
+
+import (
+	"context"
+	"faynoSync/server/model"
+	"time"
+
+	"github.com/hashicorp/go-version"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// VersionRange selects the versions of an app older than BeforeVersion, optionally of one channel, platform and arch
+type VersionRange struct {
+	AppName         string
+	Channel         string
+	Platform        string
+	Arch            string
+	BeforeVersion   string
+	IncludeCritical bool
+}
+
+// VersionRangeResult lists what DeleteVersionRange deleted, or would delete in a dry run
+type VersionRangeResult struct {
+	// Versions are the affected versions, deleted unless artifacts of other platforms or archs remain
+	Versions         []string `json:"versions"`
+	DeletedCount     int64    `json:"deleted_count"`
+	DeletedArtifacts int64    `json:"deleted_artifacts"`
+	// Links are the files no version uses anymore, to be removed from the bucket
+	Links []string `json:"-"`
+}
+
+// DeleteVersionRange deletes the versions older than r.BeforeVersion, compared as versions and not as strings.
+// Critical versions are kept unless r.IncludeCritical is set. With a platform or arch only their artifacts
+// are removed, versions are deleted once no artifact is left. With dryRun nothing is changed.
+func (c *appRepository) DeleteVersionRange(r VersionRange, dryRun bool, ctx context.Context) (VersionRangeResult, error) {
+	collection := c.client.Database(c.config.Database).Collection("apps")
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+	result := VersionRangeResult{Versions: []string{}}
+
+	before, err := version.NewVersion(r.BeforeVersion)
+	if err != nil {
+		return result, err
+	}
+
+	var appMeta, channelMeta, platformMeta, archMeta struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := c.getMeta(ctx, metaCollection, "app_name", r.AppName, &appMeta); err != nil {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		return result, ErrAppMetaNotFound
+	}
+	filter := bson.D{{Key: "app_id", Value: appMeta.ID}}
+	if r.Channel != "" {
+		if err := c.getMeta(ctx, metaCollection, "channel_name", r.Channel, &channelMeta); err != nil {
+			if ctx.Err() != nil {
+				return result, ctx.Err()
+			}
+			return result, ErrChannelNotFound
+		}
+		filter = append(filter, bson.E{Key: "channel_id", Value: channelMeta.ID})
+	}
+	if !r.IncludeCritical {
+		filter = append(filter, bson.E{Key: "critical", Value: bson.M{"$ne": true}})
+	}
+	// Unknown platforms and archs have no artifacts to delete
+	if r.Platform != "" {
+		if err := c.getMeta(ctx, metaCollection, "platform_name", r.Platform, &platformMeta); err != nil {
+			return result, ctx.Err()
+		}
+	}
+	if r.Arch != "" {
+		if err := c.getMeta(ctx, metaCollection, "arch_id", r.Arch, &archMeta); err != nil {
+			return result, ctx.Err()
+		}
+	}
+	matches := func(artifact model.Artifact) bool {
+		return (r.Platform == "" || artifact.Platform == platformMeta.ID) && (r.Arch == "" || artifact.Arch == archMeta.ID)
+	}
+
+	// Versions are listed in the order they were uploaded
+	cur, err := collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return result, err
+	}
+	var versions []model.SpecificApp
+	if err := cur.All(ctx, &versions); err != nil {
+		return result, err
+	}
+
+	var deleteIDs []primitive.ObjectID
+	trimmed := map[primitive.ObjectID][]model.Artifact{}
+	for _, app := range versions {
+		appVersion, err := version.NewVersion(app.Version)
+		if err != nil {
+			logrus.Warnf("Skipping version %s of app %s in range delete: %v", app.Version, r.AppName, err)
+			continue
+		}
+		if !appVersion.LessThan(before) {
+			continue
+		}
+
+		var kept []model.Artifact
+		var removed int64
+		for _, artifact := range app.Artifacts {
+			if matches(artifact) {
+				removed++
+				result.Links = append(result.Links, artifact.Link)
+			} else {
+				kept = append(kept, artifact)
+			}
+		}
+		if removed == 0 && (r.Platform != "" || r.Arch != "") {
+			continue
+		}
+		result.Versions = append(result.Versions, app.Version)
+		result.DeletedArtifacts += removed
+		if len(kept) == 0 {
+			deleteIDs = append(deleteIDs, app.ID)
+			result.Links = append(result.Links, app.Signatures...)
+		} else {
+			trimmed[app.ID] = kept
+		}
+	}
+	result.DeletedCount = int64(len(deleteIDs))
+	if dryRun || len(result.Versions) == 0 {
+		return result, nil
+	}
+
+	if len(deleteIDs) > 0 {
+		if _, err := collection.DeleteMany(ctx, bson.D{{Key: "_id", Value: bson.M{"$in": deleteIDs}}}); err != nil {
+			return result, err
+		}
+	}
+	for id, kept := range trimmed {
+		update := bson.D{{Key: "$set", Value: bson.D{{Key: "artifacts", Value: kept}, {Key: "updated_at", Value: time.Now()}}}, bumpRevision()}
+		if _, err := collection.UpdateOne(ctx, bson.D{{Key: "_id", Value: id}}, update); err != nil {
+			return result, err
+		}
+	}
+	if err := c.RecomputeAppUsage(appMeta.ID, ctx); err != nil {
+		logrus.Errorf("Error recomputing usage for app %s: %v", appMeta.ID.Hex(), err)
+	}
+	// Cloned apps may still use the same files
+	result.Links = c.unreferencedLinks(result.Links, ctx)
+	return result, nil
+}
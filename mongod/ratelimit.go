@@ -0,0 +1,48 @@
+package mongod
+
+import (
+	"context"
+	"faynoSync/server/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SetAppRateLimit sets the rate limit of the public info endpoints for the app, a nil limit removes it
+func (c *appRepository) SetAppRateLimit(appName string, limit *model.AppRateLimit, ctx context.Context) error {
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+
+	var app struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := c.getMeta(ctx, metaCollection, "app_name", appName, &app); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return ErrAppNotFound
+	}
+
+	filter := bson.D{{Key: "_id", Value: app.ID}}
+	var update bson.D
+	if limit == nil {
+		update = bson.D{{Key: "$unset", Value: bson.D{{Key: "rate_limit", Value: ""}}}}
+	} else {
+		update = bson.D{{Key: "$set", Value: bson.D{{Key: "rate_limit", Value: limit}}}}
+	}
+	_, err := c.UpdateDocument("apps_meta", filter, update, "", "app", ctx)
+	return err
+}
+
+// AppRateLimit returns the rate limit of the app, nil when it has none. It returns ErrAppNotFound
+// when the app doesn't exist.
+func (c *appRepository) AppRateLimit(appName string, ctx context.Context) (*model.AppRateLimit, error) {
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+	var app model.App
+	if err := c.getMeta(ctx, metaCollection, "app_name", appName, &app); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, ErrAppNotFound
+	}
+	return app.RateLimit, nil
+}
@@ -0,0 +1,187 @@
+package mongod
+
+import (
+	"context"
+	"faynoSync/server/model"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type retentionKey struct {
+	appID     primitive.ObjectID
+	channelID primitive.ObjectID
+}
+
+type RetentionResult struct {
+	AppName     string
+	Channel     string
+	Unpublished int64
+	Deleted     int64
+	Links       []string
+}
+
+// SetChannelRetention stores the retention rules of a channel, a zero retention removes them
+func (c *appRepository) SetChannelRetention(id primitive.ObjectID, retention model.ChannelRetention, ctx context.Context) (interface{}, error) {
+	filter := bson.D{{Key: "_id", Value: id}, {Key: "channel_name", Value: bson.M{"$exists": true}}}
+	var update bson.D
+	if retention.UnpublishAfterDays == 0 && retention.DeleteAfterDays == 0 {
+		update = bson.D{{Key: "$unset", Value: bson.D{{Key: "retention", Value: ""}}}}
+	} else {
+		update = bson.D{{Key: "$set", Value: bson.D{{Key: "retention", Value: retention}}}}
+	}
+	return c.UpdateDocument("apps_meta", filter, update, "", "channel", ctx)
+}
+
+// ApplyChannelRetention unpublishes and deletes non-critical versions that are older than
+// the retention windows of their channel. The age of a version is taken from its ObjectID.
+func (c *appRepository) ApplyChannelRetention(now time.Time, ctx context.Context) ([]RetentionResult, error) {
+	var channels []*model.Channel
+	filter := bson.M{"channel_name": bson.M{"$exists": true}, "retention": bson.M{"$exists": true}}
+	if err := c.listItems(ctx, "apps_meta", filter, &channels); err != nil {
+		return nil, err
+	}
+
+	results := make(map[retentionKey]*RetentionResult)
+	for _, channel := range channels {
+		if channel.Retention == nil {
+			continue
+		}
+		kept, err := c.keptVersions(channel, ctx)
+		if err != nil {
+			return nil, err
+		}
+		if days := channel.Retention.DeleteAfterDays; days > 0 {
+			if err := c.applyRetentionStep(channel, now.AddDate(0, 0, -days), true, kept, results, ctx); err != nil {
+				return nil, err
+			}
+		}
+		if days := channel.Retention.UnpublishAfterDays; days > 0 {
+			if err := c.applyRetentionStep(channel, now.AddDate(0, 0, -days), false, kept, results, ctx); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var summary []RetentionResult
+	for key, result := range results {
+		if result.Deleted > 0 {
+			if err := c.RecomputeAppUsage(key.appID, ctx); err != nil {
+				logrus.Errorf("Error recomputing usage for app %s: %v", key.appID.Hex(), err)
+			}
+			result.Links = c.unreferencedLinks(result.Links, ctx)
+		}
+		summary = append(summary, *result)
+	}
+	return summary, nil
+}
+
+// keptVersions returns the IDs of the KeepLatest newest versions of every app in the channel
+func (c *appRepository) keptVersions(channel *model.Channel, ctx context.Context) ([]primitive.ObjectID, error) {
+	keep := channel.Retention.KeepLatest
+	if keep <= 0 {
+		return []primitive.ObjectID{}, nil
+	}
+	collection := c.client.Database(c.config.Database).Collection("apps")
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: -1}}).
+		SetProjection(bson.D{{Key: "app_id", Value: 1}})
+	cur, err := collection.Find(ctx, bson.D{{Key: "channel_id", Value: channel.ID}}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	kept := []primitive.ObjectID{}
+	counts := make(map[primitive.ObjectID]int)
+	for cur.Next(ctx) {
+		var version struct {
+			ID    primitive.ObjectID `bson:"_id"`
+			AppID primitive.ObjectID `bson:"app_id"`
+		}
+		if err := cur.Decode(&version); err != nil {
+			return nil, err
+		}
+		if counts[version.AppID] < keep {
+			counts[version.AppID]++
+			kept = append(kept, version.ID)
+		}
+	}
+	return kept, cur.Err()
+}
+
+func (c *appRepository) applyRetentionStep(channel *model.Channel, cutoff time.Time, remove bool, kept []primitive.ObjectID, results map[retentionKey]*RetentionResult, ctx context.Context) error {
+	collection := c.client.Database(c.config.Database).Collection("apps")
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+
+	filter := bson.D{
+		{Key: "channel_id", Value: channel.ID},
+		{Key: "critical", Value: bson.M{"$ne": true}},
+		{Key: "_id", Value: bson.M{"$lt": primitive.NewObjectIDFromTimestamp(cutoff), "$nin": kept}},
+	}
+	if !remove {
+		filter = append(filter, bson.E{Key: "published", Value: true})
+	}
+
+	cur, err := collection.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	var ids []primitive.ObjectID
+	for cur.Next(ctx) {
+		var app model.SpecificApp
+		if err := cur.Decode(&app); err != nil {
+			return err
+		}
+		ids = append(ids, app.ID)
+
+		key := retentionKey{appID: app.AppID, channelID: channel.ID}
+		result, exists := results[key]
+		if !exists {
+			var meta model.App
+			if err := metaCollection.FindOne(ctx, bson.D{{Key: "_id", Value: app.AppID}}).Decode(&meta); err != nil {
+				logrus.Errorf("Error fetching app %s for retention: %v", app.AppID.Hex(), err)
+			}
+			result = &RetentionResult{AppName: meta.AppName, Channel: channel.ChannelName}
+			results[key] = result
+		}
+		if remove {
+			result.Deleted++
+			for _, artifact := range app.Artifacts {
+				result.Links = append(result.Links, artifact.Link)
+			}
+			result.Links = append(result.Links, app.Signatures...)
+		} else {
+			result.Unpublished++
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	idFilter := bson.D{{Key: "_id", Value: bson.M{"$in": ids}}}
+	action := "deleted"
+	if remove {
+		_, err = collection.DeleteMany(ctx, idFilter)
+	} else {
+		action = "unpublished"
+		_, err = collection.UpdateMany(ctx, idFilter, bson.D{{Key: "$set", Value: bson.D{
+			{Key: "published", Value: false},
+			{Key: "updated_at", Value: time.Now()},
+		}}, bumpRevision()})
+	}
+	if err != nil {
+		return err
+	}
+	logrus.Infof("Retention of channel %s: %d versions older than %s %s", channel.ChannelName, len(ids), cutoff.Format(time.RFC3339), action)
+	return nil
+}
@@ -0,0 +1,55 @@
+package mongod
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrRevisionMismatch is returned when a version was changed since the revision a client sent with If-Match
+var ErrRevisionMismatch = errors.New("the version was changed by another request, fetch it and try again")
+
+// AnyRevision skips the revision check of a version update
+const AnyRevision int64 = -1
+
+// revisionFilter matches versions at revision. Versions stored before revisions were added are at revision 0.
+func revisionFilter(revision int64) bson.E {
+	if revision == 0 {
+		return bson.E{Key: "revision", Value: bson.D{{Key: "$in", Value: bson.A{0, nil}}}}
+	}
+	return bson.E{Key: "revision", Value: revision}
+}
+
+// bumpRevision increments the revision of a version with every change, so concurrent edits are detected
+func bumpRevision() bson.E {
+	return bson.E{Key: "$inc", Value: bson.D{{Key: "revision", Value: 1}}}
+}
+
+// expectedRevision returns the revision sent with If-Match of an update, AnyRevision without one
+func expectedRevision(ctxQuery map[string]interface{}) int64 {
+	if revision, ok := ctxQuery["if_match"].(int64); ok {
+		return revision
+	}
+	return AnyRevision
+}
+
+// ClaimRevision advances a version from revision to the next one, before a change that can't be
+// applied atomically together with the check, such as overwriting a file in the bucket.
+// It returns ErrRevisionMismatch when the version is at another revision.
+func (c *appRepository) ClaimRevision(id primitive.ObjectID, revision int64, ctx context.Context) error {
+	collection := c.client.Database(c.config.Database).Collection("apps")
+	filter := bson.D{{Key: "_id", Value: id}, revisionFilter(revision)}
+	result, err := collection.UpdateOne(ctx, filter, bson.D{bumpRevision()})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		if count, err := collection.CountDocuments(ctx, bson.D{{Key: "_id", Value: id}}); err == nil && count == 0 {
+			return ErrVersionNotFound
+		}
+		return ErrRevisionMismatch
+	}
+	return nil
+}
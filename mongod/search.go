@@ -0,0 +1,192 @@
+package mongod
+
+import (
+	"faynoSync/server/model"
+	"slices"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Orderings of the versions returned by GetAppByName
+const (
+	SearchSortVersionAsc  = "version_asc"
+	SearchSortVersionDesc = "version_desc"
+	SearchSortUpdatedAsc  = "updated_asc"
+	SearchSortUpdatedDesc = "updated_desc"
+)
+
+// SearchSorts lists the accepted values of SearchOptions.Sort
+var SearchSorts = []string{SearchSortVersionAsc, SearchSortVersionDesc, SearchSortUpdatedAsc, SearchSortUpdatedDesc}
+
+// SearchOptions shape the versions returned by GetAppByName
+type SearchOptions struct {
+	// LatestOnly keeps only the newest published version of every channel, platform and arch
+	LatestOnly bool
+	// Sort is one of SearchSorts, empty sorts by ascending version
+	Sort string
+	// Fields limits the returned fields to the listed SearchFields, empty returns all of them
+	Fields []string
+	// MaxVersions caps the versions returned for every app, 0 is DefaultSearchMaxVersions.
+	// One more version is fetched, so CutSearchResult can tell whether the app has more.
+	MaxVersions int
+}
+
+// DefaultSearchMaxVersions is the number of versions returned for every app when SEARCH_MAX_VERSIONS isn't set
+const DefaultSearchMaxVersions = 100
+
+// maxVersions returns MaxVersions or its default
+func (opts SearchOptions) maxVersions() int {
+	if opts.MaxVersions > 0 {
+		return opts.MaxVersions
+	}
+	return DefaultSearchMaxVersions
+}
+
+// CutSearchResult drops the extra version fetched by GetAppByName or for an app by GetAppsByNames
+// and reports whether the app has more versions than opts.MaxVersions
+func CutSearchResult(apps []*model.SpecificAppWithoutIDs, opts SearchOptions) ([]*model.SpecificAppWithoutIDs, bool) {
+	if max := opts.maxVersions(); len(apps) > max {
+		return apps[:max], true
+	}
+	return apps, false
+}
+
+// SearchFields are the fields of versions that can be selected with SearchOptions.Fields
+var SearchFields = []string{
+	"app_name", "logo", "version", "channel", "published", "critical", "artifacts", "changelog",
+	"properties", "targeting", "signatures", "yanked", "yank_reason", "updated_at",
+}
+
+// searchHeavyFields are dropped before the versions are grouped when they aren't selected
+var searchHeavyFields = []string{"changelog", "properties", "targeting", "signatures"}
+
+// ValidSearchSort reports whether sort is one of SearchSorts
+func ValidSearchSort(sort string) bool {
+	for _, allowed := range SearchSorts {
+		if sort == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidSearchField reports whether field is one of SearchFields
+func ValidSearchField(field string) bool {
+	for _, allowed := range SearchFields {
+		if field == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// searchExclusionPipeline drops the heavy fields that aren't selected, so they aren't carried through the grouping
+func searchExclusionPipeline(fields []string) mongo.Pipeline {
+	exclude := bson.D{}
+	for _, field := range searchHeavyFields {
+		if !slices.Contains(fields, field) {
+			exclude = append(exclude, bson.E{Key: field, Value: 0})
+		}
+	}
+	if len(exclude) == 0 {
+		return nil
+	}
+	return mongo.Pipeline{{{Key: "$project", Value: exclude}}}
+}
+
+// searchProjectionPipeline keeps the id and the selected fields of the grouped versions.
+// updated_at is always kept, it is needed for the Last-Modified header of the response.
+func searchProjectionPipeline(fields []string) mongo.Pipeline {
+	include := bson.D{{Key: "_id", Value: 1}, {Key: "updated_at", Value: 1}}
+	for _, field := range fields {
+		// The logo is taken from the app, not from the versions
+		if field != "logo" && field != "updated_at" {
+			include = append(include, bson.E{Key: field, Value: 1})
+		}
+	}
+	return mongo.Pipeline{{{Key: "$project", Value: include}}}
+}
+
+// versionPart converts the index-th element of the split version parts to a number.
+// Parts that aren't numbers are null, so versions like 2024.05-beta don't fail the aggregation.
+func versionPart(parts interface{}, index int) bson.D {
+	return bson.D{{Key: "$convert", Value: bson.D{
+		{Key: "input", Value: bson.D{{Key: "$arrayElemAt", Value: bson.A{parts, index}}}},
+		{Key: "to", Value: "long"},
+		{Key: "onError", Value: nil},
+		{Key: "onNull", Value: nil},
+	}}}
+}
+
+// versionPartsPipeline adds the numeric parts of the version as major_v, minor_v, patch_v and build_v
+func versionPartsPipeline() mongo.Pipeline {
+	split := bson.D{{Key: "$split", Value: bson.A{"$version", "."}}}
+	parts := bson.D{}
+	for i, name := range []string{"major_v", "minor_v", "patch_v", "build_v"} {
+		parts = append(parts, bson.E{Key: name, Value: versionPart(split, i)})
+	}
+	return mongo.Pipeline{{{Key: "$addFields", Value: parts}}}
+}
+
+func versionSort(direction int) bson.D {
+	return bson.D{
+		{Key: "major_v", Value: direction},
+		{Key: "minor_v", Value: direction},
+		{Key: "patch_v", Value: direction},
+		{Key: "build_v", Value: direction},
+		{Key: "version", Value: direction},
+	}
+}
+
+// latestOnlyPipeline keeps, for every app, channel, platform and arch, the artifacts of the newest published version.
+// Yanked versions are never the newest one.
+// Versions that aren't the newest of any combination are dropped.
+func latestOnlyPipeline() mongo.Pipeline {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"published": true, "yanked": notYanked}}},
+		{{Key: "$unwind", Value: "$artifacts"}},
+	}
+	pipeline = append(pipeline, versionPartsPipeline()...)
+	return append(pipeline,
+		bson.D{{Key: "$sort", Value: versionSort(-1)}},
+		bson.D{{Key: "$group", Value: bson.M{
+			"_id":       bson.M{"app_id": "$app_id", "channel_id": "$channel_id", "platform": "$artifacts.platform", "arch": "$artifacts.arch"},
+			"latest_id": bson.M{"$first": "$_id"},
+			"versions":  bson.M{"$push": "$$ROOT"},
+		}}},
+		bson.D{{Key: "$unwind", Value: "$versions"}},
+		bson.D{{Key: "$match", Value: bson.M{"$expr": bson.M{"$eq": bson.A{"$versions._id", "$latest_id"}}}}},
+		// Put the kept artifacts of each version back together
+		bson.D{{Key: "$group", Value: bson.M{
+			"_id":       "$versions._id",
+			"version":   bson.M{"$first": "$versions"},
+			"artifacts": bson.M{"$push": "$versions.artifacts"},
+		}}},
+		bson.D{{Key: "$replaceRoot", Value: bson.M{"newRoot": bson.M{"$mergeObjects": bson.A{"$version", bson.M{"artifacts": "$artifacts"}}}}}},
+	)
+}
+
+// searchSortPipeline orders the grouped versions by sort
+func searchSortPipeline(sort string) mongo.Pipeline {
+	switch sort {
+	case SearchSortUpdatedAsc:
+		return mongo.Pipeline{{{Key: "$sort", Value: bson.D{{Key: "updated_at", Value: 1}, {Key: "_id", Value: 1}}}}}
+	case SearchSortUpdatedDesc:
+		return mongo.Pipeline{{{Key: "$sort", Value: bson.D{{Key: "updated_at", Value: -1}, {Key: "_id", Value: -1}}}}}
+	case SearchSortVersionDesc:
+		return append(versionPartsPipeline(), bson.D{{Key: "$sort", Value: versionSort(-1)}})
+	default:
+		return append(versionPartsPipeline(), bson.D{{Key: "$sort", Value: versionSort(1)}})
+	}
+}
+
+// perAppLimitPipeline keeps the first limit of the sorted versions of every app, in their order
+func perAppLimitPipeline(limit int) mongo.Pipeline {
+	return mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{"_id": "$app_name", "versions": bson.M{"$push": "$$ROOT"}}}},
+		{{Key: "$project", Value: bson.M{"versions": bson.M{"$slice": bson.A{"$versions", limit}}}}},
+		{{Key: "$unwind", Value: "$versions"}},
+		{{Key: "$replaceRoot", Value: bson.M{"newRoot": "$versions"}}},
+	}
+}
@@ -0,0 +1,155 @@
+package mongod
+
+import (
+	"context"
+	"faynoSync/server/model"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// StatsSummary counts the apps, versions, artifacts and their stored sizes, in total and per channel,
+// finds the newest published version of every app and reads the usage tracked for the upload quota
+func (c *appRepository) StatsSummary(ctx context.Context) (model.StatsSummary, error) {
+	summary := model.StatsSummary{Channels: []model.ChannelStats{}, LatestReleases: []model.LatestRelease{}, Usage: []model.AppUsage{}}
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+	collection := c.client.Database(c.config.Database).Collection("apps")
+
+	appNames := map[primitive.ObjectID]string{}
+	cur, err := metaCollection.Find(ctx, bson.D{{Key: "app_name", Value: bson.M{"$exists": true}}})
+	if err != nil {
+		return summary, err
+	}
+	for cur.Next(ctx) {
+		var app struct {
+			ID            primitive.ObjectID `bson:"_id"`
+			Name          string             `bson:"app_name"`
+			StorageBytes  int64              `bson:"storage_bytes"`
+			VersionsCount int64              `bson:"versions_count"`
+		}
+		if err := cur.Decode(&app); err != nil {
+			cur.Close(ctx)
+			return summary, err
+		}
+		appNames[app.ID] = app.Name
+		summary.Usage = append(summary.Usage, model.AppUsage{
+			AppID:         app.ID,
+			AppName:       app.Name,
+			StorageBytes:  app.StorageBytes,
+			VersionsCount: app.VersionsCount,
+		})
+	}
+	cur.Close(ctx)
+	if err := cur.Err(); err != nil {
+		return summary, err
+	}
+	summary.Apps = int64(len(appNames))
+	sort.Slice(summary.Usage, func(i, j int) bool { return summary.Usage[i].AppName < summary.Usage[j].AppName })
+
+	names, err := c.metaNames(ctx)
+	if err != nil {
+		return summary, err
+	}
+
+	// The sizes are summed per version first, $sum of an array in $group would ignore it
+	versionBytes := bson.D{{Key: "$sum", Value: "$artifacts.size"}}
+	versionArtifacts := bson.D{{Key: "$size", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$artifacts", bson.A{}}}}}}
+	totalsPipeline := mongo.Pipeline{
+		{{Key: "$facet", Value: bson.D{
+			{Key: "totals", Value: bson.A{
+				bson.D{{Key: "$group", Value: bson.D{
+					{Key: "_id", Value: nil},
+					{Key: "versions", Value: bson.D{{Key: "$sum", Value: 1}}},
+					{Key: "artifacts", Value: bson.D{{Key: "$sum", Value: versionArtifacts}}},
+					{Key: "storage_bytes", Value: bson.D{{Key: "$sum", Value: versionBytes}}},
+				}}},
+			}},
+			{Key: "channels", Value: bson.A{
+				bson.D{{Key: "$group", Value: bson.D{
+					{Key: "_id", Value: "$channel_id"},
+					{Key: "versions", Value: bson.D{{Key: "$sum", Value: 1}}},
+					{Key: "storage_bytes", Value: bson.D{{Key: "$sum", Value: versionBytes}}},
+				}}},
+			}},
+		}}},
+	}
+	var totals []struct {
+		Totals []struct {
+			Versions     int64 `bson:"versions"`
+			Artifacts    int64 `bson:"artifacts"`
+			StorageBytes int64 `bson:"storage_bytes"`
+		} `bson:"totals"`
+		Channels []struct {
+			ID           primitive.ObjectID `bson:"_id"`
+			Versions     int64              `bson:"versions"`
+			StorageBytes int64              `bson:"storage_bytes"`
+		} `bson:"channels"`
+	}
+	cur, err = collection.Aggregate(ctx, totalsPipeline)
+	if err != nil {
+		return summary, err
+	}
+	if err := cur.All(ctx, &totals); err != nil {
+		return summary, err
+	}
+	if len(totals) > 0 {
+		if len(totals[0].Totals) > 0 {
+			summary.Versions = totals[0].Totals[0].Versions
+			summary.Artifacts = totals[0].Totals[0].Artifacts
+			summary.StorageBytes = totals[0].Totals[0].StorageBytes
+		}
+		for _, channel := range totals[0].Channels {
+			summary.Channels = append(summary.Channels, model.ChannelStats{
+				Channel:      names[channel.ID],
+				Versions:     channel.Versions,
+				StorageBytes: channel.StorageBytes,
+			})
+		}
+	}
+	sort.Slice(summary.Channels, func(i, j int) bool { return summary.Channels[i].Channel < summary.Channels[j].Channel })
+
+	latestPipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"published": true, "yanked": notYanked}}},
+	}
+	latestPipeline = append(latestPipeline, versionPartsPipeline()...)
+	latestPipeline = append(latestPipeline,
+		bson.D{{Key: "$sort", Value: append(bson.D{{Key: "app_id", Value: 1}}, versionSort(-1)...)}},
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$app_id"},
+			{Key: "version", Value: bson.D{{Key: "$first", Value: "$version"}}},
+			{Key: "channel_id", Value: bson.D{{Key: "$first", Value: "$channel_id"}}},
+			{Key: "updated_at", Value: bson.D{{Key: "$first", Value: "$updated_at"}}},
+		}}},
+	)
+	var latest []struct {
+		AppID     primitive.ObjectID `bson:"_id"`
+		Version   string             `bson:"version"`
+		ChannelID primitive.ObjectID `bson:"channel_id"`
+		UpdatedAt primitive.DateTime `bson:"updated_at"`
+	}
+	cur, err = collection.Aggregate(ctx, latestPipeline)
+	if err != nil {
+		return summary, err
+	}
+	if err := cur.All(ctx, &latest); err != nil {
+		return summary, err
+	}
+	for _, release := range latest {
+		appName, ok := appNames[release.AppID]
+		if !ok {
+			continue
+		}
+		summary.LatestReleases = append(summary.LatestReleases, model.LatestRelease{
+			AppName:   appName,
+			Version:   release.Version,
+			Channel:   names[release.ChannelID],
+			UpdatedAt: release.UpdatedAt,
+		})
+	}
+	sort.Slice(summary.LatestReleases, func(i, j int) bool {
+		return summary.LatestReleases[i].AppName < summary.LatestReleases[j].AppName
+	})
+	return summary, nil
+}
@@ -2,6 +2,7 @@ package mongod
 
 import (
 	"context"
+	"time"
 
 	"faynoSync/server/model"
 
@@ -13,13 +14,14 @@ import (
 
 type AppRepository interface {
 	Get(ctx context.Context) ([]*model.SpecificAppWithoutIDs, error)
-	GetAppByName(email string, ctx context.Context) ([]*model.SpecificAppWithoutIDs, error)
+	GetAppByName(appName string, opts SearchOptions, ctx context.Context) ([]*model.SpecificAppWithoutIDs, error)
+	GetAppsByNames(appNames []string, opts SearchOptions, ctx context.Context) (map[string][]*model.SpecificAppWithoutIDs, error)
 	DeleteSpecificVersionOfApp(id primitive.ObjectID, ctx context.Context) ([]string, int64, error)
 	DeleteChannel(id primitive.ObjectID, ctx context.Context) (int64, error)
-	Upload(ctxQuery map[string]interface{}, appLink, extension string, ctx context.Context) (interface{}, error)
-	UpdateSpecificApp(objID primitive.ObjectID, ctxQuery map[string]interface{}, appLink, extension string, ctx context.Context) (bool, error)
-	CheckLatestVersion(appName, version, channel, platform, arch string, ctx context.Context) (CheckResult, error)
-	FetchLatestVersionOfApp(appName, channel string, ctx context.Context) ([]*model.SpecificAppWithoutIDs, error)
+	Upload(ctxQuery map[string]interface{}, appLink, extension string, size int64, ctx context.Context) (interface{}, error)
+	UpdateSpecificApp(objID primitive.ObjectID, ctxQuery map[string]interface{}, appLink, extension string, size int64, ctx context.Context) (bool, error)
+	CheckLatestVersion(opts CheckOptions, ctx context.Context) (CheckResult, error)
+	FetchLatestVersionOfApp(appName, channel, pkg string, ctx context.Context) ([]*model.SpecificAppWithoutIDs, error)
 	FetchAppByID(appID primitive.ObjectID, ctx context.Context) ([]*model.SpecificAppWithoutIDs, error)
 	CreateChannel(channelName string, ctx context.Context) (interface{}, error)
 	ListChannels(ctx context.Context) ([]*model.Channel, error)
@@ -30,12 +32,70 @@ type AppRepository interface {
 	ListArchs(ctx context.Context) ([]*model.Arch, error)
 	DeleteArch(id primitive.ObjectID, ctx context.Context) (int64, error)
 	CreateApp(archName string, ctx context.Context) (interface{}, error)
-	ListApps(ctx context.Context) ([]*model.App, error)
+	CreateAppWithVersionPattern(appName, versionPattern string, ctx context.Context) (interface{}, error)
+	ListApps(appNames []string, ctx context.Context) ([]*model.App, error)
+	GetItem(itemType string, id primitive.ObjectID, ctx context.Context) (interface{}, error)
 	DeleteApp(id primitive.ObjectID, ctx context.Context) (int64, error)
 	UpdateApp(id primitive.ObjectID, paramValue string, ctx context.Context) (interface{}, error)
+	SetAppLogo(appName, link string, ctx context.Context) (string, error)
+	SetAppDeprecation(appName string, deprecation *model.AppDeprecation, ctx context.Context) error
+	AppDeprecation(appName string, ctx context.Context) (*model.AppDeprecation, error)
+	SetAppMissingTarget(appName string, policy *model.MissingTargetPolicy, ctx context.Context) error
+	AppMissingTarget(appName string, ctx context.Context) (*model.MissingTargetPolicy, error)
+	SetAppRequiredTargets(appName string, targets []model.RequiredTarget, ctx context.Context) error
+	SetAppChangelogTemplate(appName, changelogTemplate string, ctx context.Context) error
+	AppChangelogTemplate(appName string, ctx context.Context) (string, error)
+	SetAppRateLimit(appName string, limit *model.AppRateLimit, ctx context.Context) error
+	AppRateLimit(appName string, ctx context.Context) (*model.AppRateLimit, error)
 	UpdateChannel(id primitive.ObjectID, paramValue string, ctx context.Context) (interface{}, error)
 	UpdatePlatform(id primitive.ObjectID, paramValue string, ctx context.Context) (interface{}, error)
 	UpdateArch(id primitive.ObjectID, paramValue string, ctx context.Context) (interface{}, error)
+	GetAppUsage(appName string, ctx context.Context) (model.AppUsage, error)
+	CheckUploadQuota(appName, version string, uploadBytes, maxBytes, maxVersions int64, ctx context.Context) (model.AppUsage, error)
+	RecomputeAppUsage(appID primitive.ObjectID, ctx context.Context) error
+	StatsSummary(ctx context.Context) (model.StatsSummary, error)
+	RecentActivity(filter ActivityFilter, ctx context.Context) ([]model.Activity, error)
+	VersionExists(appName, version, channel, platform, arch, pkg string, publishedOnly bool, ctx context.Context) (bool, error)
+	SetChannelRetention(id primitive.ObjectID, retention model.ChannelRetention, ctx context.Context) (interface{}, error)
+	ApplyChannelRetention(now time.Time, ctx context.Context) ([]RetentionResult, error)
+	SetChannelGate(id primitive.ObjectID, gated bool, ctx context.Context) (interface{}, error)
+	SetChannelFreeze(id primitive.ObjectID, freeze model.ChannelFreeze, ctx context.Context) (interface{}, error)
+	ChannelFreezeStatus(channel string, now time.Time, ctx context.Context) (FreezeStatus, error)
+	SetChannelMaintenance(id primitive.ObjectID, maintenance *model.ChannelMaintenance, ctx context.Context) (interface{}, error)
+	ChannelMaintenance(channel string, ctx context.Context) (*model.ChannelMaintenance, error)
+	SetChannelChangelogRequired(id primitive.ObjectID, required bool, ctx context.Context) (interface{}, error)
+	MissingRequiredChangelog(appName, version, channel string, ctx context.Context) (bool, error)
+	CreateBetaToken(label string, channels []string, ctx context.Context) (string, interface{}, error)
+	ListBetaTokens(ctx context.Context) ([]*model.BetaToken, error)
+	RevokeBetaToken(id primitive.ObjectID, ctx context.Context) (interface{}, error)
+	CheckChannelAccess(channel, token string, ctx context.Context) error
+	CountDependentVersions(itemType string, id primitive.ObjectID, ctx context.Context) (int64, error)
+	DeleteDependentVersions(itemType string, id primitive.ObjectID, ctx context.Context) ([]string, []string, error)
+	DeleteAppVersions(appName, channel string, ctx context.Context) ([]string, int64, error)
+	CloneApp(source, target string, includeVersions bool, ctx context.Context) (primitive.ObjectID, int64, error)
+	LatestDownloads(appName, channel string, ctx context.Context) ([]model.Download, error)
+	AvailableVersions(appName, channel, platform, arch, cohort string, offset, limit int64, ctx context.Context) ([]model.AvailableVersion, int64, error)
+	YankVersion(id primitive.ObjectID, yanked bool, reason string, ctx context.Context) (bool, error)
+	VersionImpact(id primitive.ObjectID, ctx context.Context) (model.VersionImpact, error)
+	NextBuildNumber(appName, channel string, minimum int64, ctx context.Context) (int64, error)
+	DeleteVersionRange(r VersionRange, dryRun bool, ctx context.Context) (VersionRangeResult, error)
+	ExportApp(appName string, ctx context.Context) (model.AppBundle, error)
+	ImportApp(bundle model.AppBundle, ctx context.Context) (primitive.ObjectID, int64, error)
+	CompareChangelogs(appName, channelA, channelB string, ctx context.Context) (model.ChangelogDiff, error)
+	PinLatestVersion(appName, channel, platform, arch, pinnedVersion string, ctx context.Context) (bool, error)
+	AddSignatures(id primitive.ObjectID, links []string, ctx context.Context) error
+	MoveArtifact(id primitive.ObjectID, link, newLink, platform, arch string, ctx context.Context) ([]string, error)
+	ReplaceArtifact(id primitive.ObjectID, link string, size int64, checksum string, revision int64, ctx context.Context) error
+	ClaimRevision(id primitive.ObjectID, revision int64, ctx context.Context) error
+	CountArtifactsMissingChecksums(after primitive.ObjectID, ctx context.Context) (int64, error)
+	ArtifactsMissingChecksums(after primitive.ObjectID, limit int64, ctx context.Context) ([]model.SpecificApp, error)
+	BackfillArtifactChecksum(id primitive.ObjectID, link string, size int64, checksum string, ctx context.Context) error
+	CreateFlag(flag model.Flag, ctx context.Context) (interface{}, error)
+	ListFlags(appName string, ctx context.Context) ([]*model.Flag, error)
+	GetFlag(id primitive.ObjectID, ctx context.Context) (model.Flag, error)
+	UpdateFlag(id primitive.ObjectID, flag model.Flag, ctx context.Context) (interface{}, error)
+	DeleteFlag(id primitive.ObjectID, ctx context.Context) (int64, error)
+	ApplicableFlags(appName, channel, clientVersion string, ctx context.Context) (map[string]interface{}, error)
 }
 
 type appRepository struct {
@@ -52,20 +112,36 @@ func NewAppRepository(config *connstring.ConnString, client *mongo.Client) AppRe
 }
 
 type Artifact struct {
-	Link    string
-	Package string
+	Link      string
+	Package   string
+	Preferred bool
 }
 type Changelog struct {
 	Changes string
 }
 type CheckResult struct {
-	Found     bool
-	Critical  bool
-	Artifacts []Artifact
-	Changelog []Changelog
+	Found      bool
+	Critical   bool
+	Artifacts  []Artifact
+	Changelog  []Changelog
+	Properties map[string]interface{}
+	// NewerAvailable is set when a critical version was preferred over a newer non-critical one
+	NewerAvailable bool
 }
 
 func (c *appRepository) getBasePipeline() mongo.Pipeline {
+	pipeline := c.groupVersionsPipeline()
+	return append(pipeline,
+		bson.D{{Key: "$sort", Value: bson.D{
+			{Key: "app_name", Value: 1},
+			{Key: "version", Value: 1},
+		}}},
+		bson.D{{Key: "$limit", Value: 100}},
+	)
+}
+
+// groupVersionsPipeline resolves the names of the meta documents and returns one document per version, unsorted
+func (c *appRepository) groupVersionsPipeline() mongo.Pipeline {
 	return mongo.Pipeline{
 		bson.D{{Key: "$lookup", Value: bson.M{
 			"from":         "apps_meta",
@@ -106,21 +182,9 @@ func (c *appRepository) getBasePipeline() mongo.Pipeline {
 			}},
 		}}},
 		bson.D{{Key: "$addFields", Value: bson.D{
-			{Key: "major_v", Value: bson.D{
-				{Key: "$toInt", Value: bson.D{
-					{Key: "$arrayElemAt", Value: bson.A{"$versions_arr", 0}},
-				}},
-			}},
-			{Key: "minor_v", Value: bson.D{
-				{Key: "$toInt", Value: bson.D{
-					{Key: "$arrayElemAt", Value: bson.A{"$versions_arr", 1}},
-				}},
-			}},
-			{Key: "patch_v", Value: bson.D{
-				{Key: "$toInt", Value: bson.D{
-					{Key: "$arrayElemAt", Value: bson.A{"$versions_arr", 2}},
-				}},
-			}},
+			{Key: "major_v", Value: versionPart("$versions_arr", 0)},
+			{Key: "minor_v", Value: versionPart("$versions_arr", 1)},
+			{Key: "patch_v", Value: versionPart("$versions_arr", 2)},
 		}}},
 		bson.D{{Key: "$sort", Value: bson.D{
 			{Key: "major_v", Value: -1},
@@ -128,23 +192,26 @@ func (c *appRepository) getBasePipeline() mongo.Pipeline {
 			{Key: "patch_v", Value: -1},
 		}}},
 		bson.D{{Key: "$group", Value: bson.M{
-			"_id":        "$_id",
-			"app_name":   bson.M{"$first": "$app_meta.app_name"},
-			"channel":    bson.M{"$first": "$channel_meta.channel_name"},
-			"version":    bson.M{"$first": "$version"},
-			"published":  bson.M{"$first": "$published"},
-			"critical":   bson.M{"$first": "$critical"},
-			"artifacts":  bson.M{"$push": "$artifacts"},
-			"changelog":  bson.M{"$first": "$changelog"},
-			"updated_at": bson.M{"$first": "$updated_at"},
+			"_id":         "$_id",
+			"app_name":    bson.M{"$first": "$app_meta.app_name"},
+			"channel":     bson.M{"$first": "$channel_meta.channel_name"},
+			"version":     bson.M{"$first": "$version"},
+			"published":   bson.M{"$first": "$published"},
+			"critical":    bson.M{"$first": "$critical"},
+			"artifacts":   bson.M{"$push": "$artifacts"},
+			"changelog":   bson.M{"$first": "$changelog"},
+			"properties":  bson.M{"$first": "$properties"},
+			"targeting":   bson.M{"$first": "$targeting"},
+			"signatures":  bson.M{"$first": "$signatures"},
+			"yanked":      bson.M{"$first": "$yanked"},
+			"yank_reason": bson.M{"$first": "$yank_reason"},
+			"updated_at":  bson.M{"$first": "$updated_at"},
+			"revision":    bson.M{"$first": "$revision"},
 		}}},
-		bson.D{{Key: "$sort", Value: bson.D{
-			{Key: "app_name", Value: 1},
-			{Key: "version", Value: 1},
-		}}},
-		bson.D{{Key: "$limit", Value: 100}},
 	}
 }
+
+// sortVersionPipeline orders versions from the newest to the oldest
 func (c *appRepository) sortVersionPipeline() mongo.Pipeline {
 	return mongo.Pipeline{
 		{{Key: "$addFields", Value: bson.D{
@@ -153,26 +220,10 @@ func (c *appRepository) sortVersionPipeline() mongo.Pipeline {
 			}},
 		}}},
 		{{Key: "$addFields", Value: bson.D{
-			{Key: "major_v", Value: bson.D{
-				{Key: "$toInt", Value: bson.D{
-					{Key: "$arrayElemAt", Value: bson.A{"$versions_arr", 0}},
-				}},
-			}},
-			{Key: "minor_v", Value: bson.D{
-				{Key: "$toInt", Value: bson.D{
-					{Key: "$arrayElemAt", Value: bson.A{"$versions_arr", 1}},
-				}},
-			}},
-			{Key: "patch_v", Value: bson.D{
-				{Key: "$toInt", Value: bson.D{
-					{Key: "$arrayElemAt", Value: bson.A{"$versions_arr", 2}},
-				}},
-			}},
-			{Key: "build_v", Value: bson.D{
-				{Key: "$toInt", Value: bson.D{
-					{Key: "$arrayElemAt", Value: bson.A{"$versions_arr", 3}},
-				}},
-			}},
+			{Key: "major_v", Value: versionPart("$versions_arr", 0)},
+			{Key: "minor_v", Value: versionPart("$versions_arr", 1)},
+			{Key: "patch_v", Value: versionPart("$versions_arr", 2)},
+			{Key: "build_v", Value: versionPart("$versions_arr", 3)},
 		}}},
 		{{Key: "$sort", Value: bson.D{
 			{Key: "major_v", Value: -1},
@@ -180,6 +231,5 @@ func (c *appRepository) sortVersionPipeline() mongo.Pipeline {
 			{Key: "patch_v", Value: -1},
 			{Key: "build_v", Value: -1},
 		}}},
-		{{Key: "$limit", Value: 1}},
 	}
 }
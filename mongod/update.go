@@ -11,6 +11,8 @@ import (
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func (c *appRepository) UpdateDocument(collectionName string, filter bson.D, update bson.D, uniqueKey, keyType string, ctx context.Context) (bool, error) {
@@ -21,6 +23,9 @@ func (c *appRepository) UpdateDocument(collectionName string, filter bson.D, upd
 	logrus.Debugln("Update document: ", update)
 	updateResult, err := collection.UpdateOne(ctx, filter, update)
 	if err != nil {
+		if dupErr := duplicateKeyError(err, uniqueKey, keyType); dupErr != nil {
+			return false, dupErr
+		}
 		logrus.Errorf("Error updating document: %v", err)
 		return false, err
 	}
@@ -36,31 +41,31 @@ func (c *appRepository) UpdateDocument(collectionName string, filter bson.D, upd
 func (c *appRepository) UpdateChannel(id primitive.ObjectID, channelName string, ctx context.Context) (interface{}, error) {
 	filter := bson.D{{Key: "_id", Value: id}}
 	update := bson.D{{Key: "$set", Value: bson.D{{Key: "channel_name", Value: channelName}}}}
-	return c.UpdateDocument("apps_meta", filter, update, "channel_name_sort_by_asc_updated", "channel", ctx)
+	return c.UpdateDocument("apps_meta", filter, update, "channel_name_sort_by_asc_created", "channel", ctx)
 }
 
 // UpdatePlatform updates an existing platform document
 func (c *appRepository) UpdatePlatform(id primitive.ObjectID, platformName string, ctx context.Context) (interface{}, error) {
 	filter := bson.D{{Key: "_id", Value: id}}
 	update := bson.D{{Key: "$set", Value: bson.D{{Key: "platform_name", Value: platformName}}}}
-	return c.UpdateDocument("apps_meta", filter, update, "platform_name_sort_by_asc_updated", "platform", ctx)
+	return c.UpdateDocument("apps_meta", filter, update, "platform_name_sort_by_asc_created", "platform", ctx)
 }
 
 // UpdateArch updates an existing arch document
 func (c *appRepository) UpdateArch(id primitive.ObjectID, archID string, ctx context.Context) (interface{}, error) {
 	filter := bson.D{{Key: "_id", Value: id}}
 	update := bson.D{{Key: "$set", Value: bson.D{{Key: "arch_id", Value: archID}}}}
-	return c.UpdateDocument("apps_meta", filter, update, "arch_id_sort_by_asc_updated", "arch", ctx)
+	return c.UpdateDocument("apps_meta", filter, update, "arch_id_sort_by_asc_created", "arch", ctx)
 }
 
 // UpdateApp updates an existing app_name document
 func (c *appRepository) UpdateApp(id primitive.ObjectID, appName string, ctx context.Context) (interface{}, error) {
 	filter := bson.D{{Key: "_id", Value: id}}
 	update := bson.D{{Key: "$set", Value: bson.D{{Key: "app_name", Value: appName}}}}
-	return c.UpdateDocument("apps_meta", filter, update, "app_name_sort_by_asc_updated", "app", ctx)
+	return c.UpdateDocument("apps_meta", filter, update, "app_name_sort_by_asc_created", "app", ctx)
 }
 
-func (c *appRepository) UpdateSpecificApp(objID primitive.ObjectID, ctxQuery map[string]interface{}, appLink, extension string, ctx context.Context) (bool, error) {
+func (c *appRepository) UpdateSpecificApp(objID primitive.ObjectID, ctxQuery map[string]interface{}, appLink, extension string, size int64, ctx context.Context) (bool, error) {
 	collection := c.client.Database(c.config.Database).Collection("apps")
 	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
 	var err error
@@ -114,8 +119,15 @@ func (c *appRepository) UpdateSpecificApp(objID primitive.ObjectID, ctxQuery map
 		if channelMeta.ID != appData.ChannelID {
 			return false, errors.New("updating the channel is not allowed")
 		}
+		revision := expectedRevision(ctxQuery)
+		if revision != AnyRevision && revision != appData.Revision {
+			return false, ErrRevisionMismatch
+		}
 
 		updateFields := bson.D{{Key: "updated_at", Value: time.Now()}}
+		if actor := utils.GetStringValue(ctxQuery, "updated_by"); actor != "" {
+			updateFields = append(updateFields, bson.E{Key: "updated_by", Value: actor})
+		}
 
 		publishParam, publishExists := ctxQuery["publish"]
 		criticalParam, criticalExists := ctxQuery["critical"]
@@ -140,52 +152,191 @@ func (c *appRepository) UpdateSpecificApp(objID primitive.ObjectID, ctxQuery map
 			}
 		}
 
+		artifactAdded := false
 		if !duplicateFound && appLink != "" && extension != "" {
 			newArtifact := model.Artifact{
 				Link:     appLink,
 				Platform: platformMeta.ID,
 				Arch:     archMeta.ID,
 				Package:  extension,
+				Size:     size,
 			}
 			appData.Artifacts = append(appData.Artifacts, newArtifact)
+			artifactAdded = true
 		}
+		preferArtifact(appData.Artifacts, ctxQuery, platformMeta.ID, archMeta.ID)
 		if len(appData.Artifacts) > 0 {
 			updateFields = append(updateFields, bson.E{Key: "artifacts", Value: appData.Artifacts})
 		}
 
-		// Add or update changelog
+		// Replace or append the changelog of the version
 		if changelog, exists := ctxQuery["changelog"].(string); exists && changelog != "" {
-			changelogUpdated := false
-			for i, log := range appData.Changelog {
-				if log.Version == ctxQuery["version"].(string) {
-					appData.Changelog[i].Changes = changelog
-					appData.Changelog[i].Date = time.Now().Format("2006-01-02")
-					changelogUpdated = true
-					break
-				}
-			}
-			if !changelogUpdated {
-				newChangelog := model.Changelog{
-					Version: ctxQuery["version"].(string),
-					Changes: changelog,
-					Date:    time.Now().Format("2006-01-02"),
-				}
-				appData.Changelog = append(appData.Changelog, newChangelog)
-			}
+			appData.Changelog = mergeChangelog(appData.Changelog, ctxQuery["version"].(string), changelog, utils.GetStringValue(ctxQuery, "changelog_raw"), utils.GetStringValue(ctxQuery, "changelog_mode"))
 			updateFields = append(updateFields, bson.E{Key: "changelog", Value: appData.Changelog})
 		}
 
-		_, err = collection.UpdateOne(
+		// Replace properties only when they were sent with the request
+		if properties, ok := ctxQuery["properties"].(map[string]interface{}); ok && properties != nil {
+			updateFields = append(updateFields, bson.E{Key: "properties", Value: properties})
+		}
+		// Replace the targeting when any cohorts were sent, empty lists remove it
+		if targeting, ok := queryTargeting(ctxQuery); ok {
+			updateFields = append(updateFields, bson.E{Key: "targeting", Value: targeting})
+		}
+
+		// With If-Match the version must still be at the revision it was read at
+		filter := bson.D{{Key: "_id", Value: objID}}
+		if revision != AnyRevision {
+			filter = append(filter, revisionFilter(revision))
+		}
+		result, err := collection.UpdateOne(
 			ctx,
-			bson.D{{Key: "_id", Value: objID}},
-			bson.D{{Key: "$set", Value: updateFields}},
+			filter,
+			bson.D{{Key: "$set", Value: updateFields}, bumpRevision()},
 		)
 		if err != nil {
 			return false, err
 		}
+		if result.MatchedCount == 0 && revision != AnyRevision {
+			return false, ErrRevisionMismatch
+		}
+		if artifactAdded {
+			if err := c.incrementAppUsage(appMeta.ID, size, 0, ctx); err != nil {
+				logrus.Errorf("Error updating usage for app %s: %v", appMeta.ID.Hex(), err)
+			}
+		}
 
 		return true, nil
 	} else {
 		return false, errors.New("app with this parameters doesn't exist")
 	}
 }
+
+// preferArtifact marks the preferred_package of an update as the recommended package of the platform and arch.
+// It is left as is until the package exists, files of a multi-file update are added one call at a time.
+func preferArtifact(artifacts []model.Artifact, ctxQuery map[string]interface{}, platform, arch primitive.ObjectID) {
+	pkg, _ := ctxQuery["preferred_package"].(string)
+	if pkg == "" {
+		return
+	}
+	found := false
+	for _, artifact := range artifacts {
+		if artifact.Platform == platform && artifact.Arch == arch && artifact.Package == storedPackage(pkg) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return
+	}
+	for i := range artifacts {
+		if artifacts[i].Platform == platform && artifacts[i].Arch == arch {
+			artifacts[i].Preferred = artifacts[i].Package == storedPackage(pkg)
+		}
+	}
+}
+
+// queryTargeting returns the targeting of the allow_cohorts and deny_cohorts of an upload or update.
+// It reports whether any of them was sent, the targeting is nil when both are empty.
+func queryTargeting(ctxQuery map[string]interface{}) (*model.Targeting, bool) {
+	allow, allowSent := ctxQuery["allow_cohorts"].([]string)
+	deny, denySent := ctxQuery["deny_cohorts"].([]string)
+	if (!allowSent || allow == nil) && (!denySent || deny == nil) {
+		return nil, false
+	}
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil, true
+	}
+	return &model.Targeting{Allow: allow, Deny: deny}, true
+}
+
+// AddSignatures attaches the links of uploaded signature files to a version
+func (c *appRepository) AddSignatures(id primitive.ObjectID, links []string, ctx context.Context) error {
+	filter := bson.D{{Key: "_id", Value: id}}
+	update := bson.D{{Key: "$addToSet", Value: bson.D{{Key: "signatures", Value: bson.D{{Key: "$each", Value: links}}}}}, bumpRevision()}
+	_, err := c.UpdateDocument("apps", filter, update, "", "app", ctx)
+	return err
+}
+
+// ErrArtifactNotFound is returned when the version has no artifact with the given link
+var ErrArtifactNotFound = errors.New("artifact not found")
+
+// MoveArtifact assigns the artifact stored at link to another platform and arch, its file now stored at newLink.
+// It returns the old link when no other version uses it anymore.
+func (c *appRepository) MoveArtifact(id primitive.ObjectID, link, newLink, platform, arch string, ctx context.Context) ([]string, error) {
+	collection := c.client.Database(c.config.Database).Collection("apps")
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+
+	var platformMeta, archMeta struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if platform != "" {
+		if err := c.getMeta(ctx, metaCollection, "platform_name", platform, &platformMeta); err != nil {
+			return nil, err
+		}
+	}
+	if arch != "" {
+		if err := c.getMeta(ctx, metaCollection, "arch_id", arch, &archMeta); err != nil {
+			return nil, err
+		}
+	}
+
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "artifacts.link", Value: link},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "artifacts.$.link", Value: newLink},
+		{Key: "artifacts.$.platform", Value: platformMeta.ID},
+		{Key: "artifacts.$.arch", Value: archMeta.ID},
+		{Key: "updated_at", Value: time.Now()},
+	}}, bumpRevision()}
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount == 0 {
+		return nil, ErrArtifactNotFound
+	}
+	if newLink == link {
+		return nil, nil
+	}
+	return c.unreferencedLinks([]string{link}, ctx), nil
+}
+
+// ReplaceArtifact records the size and checksum of an artifact whose file was replaced in place.
+// The link stays the same, the storage usage of the app is recomputed.
+// Unless revision is AnyRevision, the version must be at revision, ErrRevisionMismatch is returned otherwise.
+func (c *appRepository) ReplaceArtifact(id primitive.ObjectID, link string, size int64, checksum string, revision int64, ctx context.Context) error {
+	collection := c.client.Database(c.config.Database).Collection("apps")
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "artifacts.link", Value: link},
+	}
+	if revision != AnyRevision {
+		filter = append(filter, revisionFilter(revision))
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "artifacts.$.size", Value: size},
+		{Key: "artifacts.$.checksum", Value: checksum},
+		{Key: "updated_at", Value: time.Now()},
+	}}, bumpRevision()}
+	var app struct {
+		AppID primitive.ObjectID `bson:"app_id"`
+	}
+	err := collection.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetProjection(bson.D{{Key: "app_id", Value: 1}})).Decode(&app)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		if revision != AnyRevision {
+			// The artifact may exist at another revision
+			count, countErr := collection.CountDocuments(ctx, filter[:2])
+			if countErr == nil && count > 0 {
+				return ErrRevisionMismatch
+			}
+		}
+		return ErrArtifactNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return c.RecomputeAppUsage(app.AppID, ctx)
+}
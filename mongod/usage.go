@@ -0,0 +1,161 @@
+package mongod
+
+import (
+	"context"
+	"errors"
+	"faynoSync/server/model"
+	"faynoSync/server/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrQuotaExceeded = errors.New("app storage quota exceeded")
+
+// GetAppUsage returns the storage usage tracked on the app_name document.
+// The usage of apps uploaded to before it was tracked, or whose last update failed, is recomputed first.
+func (c *appRepository) GetAppUsage(appName string, ctx context.Context) (model.AppUsage, error) {
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+
+	var meta struct {
+		ID            primitive.ObjectID `bson:"_id"`
+		StorageBytes  *int64             `bson:"storage_bytes"`
+		VersionsCount *int64             `bson:"versions_count"`
+		UsageStale    bool               `bson:"usage_stale"`
+	}
+	if err := c.getMeta(ctx, metaCollection, "app_name", appName, &meta); err != nil {
+		return model.AppUsage{AppName: appName}, err
+	}
+
+	usage := model.AppUsage{AppID: meta.ID, AppName: appName}
+	if meta.StorageBytes == nil || meta.VersionsCount == nil || meta.UsageStale {
+		storageBytes, versionsCount, err := c.recomputeAppUsage(meta.ID, ctx)
+		if err != nil {
+			return usage, err
+		}
+		usage.StorageBytes, usage.VersionsCount = storageBytes, versionsCount
+		return usage, nil
+	}
+	usage.StorageBytes, usage.VersionsCount = *meta.StorageBytes, *meta.VersionsCount
+	return usage, nil
+}
+
+// CheckUploadQuota verifies that uploading uploadBytes for the given version stays within the limits.
+// A limit of 0 disables the corresponding check.
+func (c *appRepository) CheckUploadQuota(appName, version string, uploadBytes, maxBytes, maxVersions int64, ctx context.Context) (model.AppUsage, error) {
+	usage, err := c.GetAppUsage(appName, ctx)
+	if err != nil {
+		return usage, err
+	}
+
+	if maxBytes > 0 && usage.StorageBytes+uploadBytes > maxBytes {
+		return usage, ErrQuotaExceeded
+	}
+
+	if maxVersions > 0 && usage.VersionsCount >= maxVersions {
+		// Adding artifacts to an existing version doesn't create a new one
+		collection := c.client.Database(c.config.Database).Collection("apps")
+		count, err := collection.CountDocuments(ctx, bson.D{
+			{Key: "app_id", Value: usage.AppID},
+			{Key: "version", Value: version},
+		})
+		if err != nil {
+			return usage, err
+		}
+		if count == 0 {
+			return usage, ErrQuotaExceeded
+		}
+	}
+
+	return usage, nil
+}
+
+// incrementAppUsage adds an upload to the usage tracked on the app_name document.
+// Usage that isn't tracked yet or is stale is recomputed from the versions instead, as incrementing it would
+// only count the uploads since. When the update fails the usage is marked stale for the next read to recompute it.
+func (c *appRepository) incrementAppUsage(appID primitive.ObjectID, bytes, versions int64, ctx context.Context) error {
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+
+	result, err := metaCollection.UpdateOne(ctx,
+		bson.D{
+			{Key: "_id", Value: appID},
+			{Key: "storage_bytes", Value: bson.D{{Key: "$exists", Value: true}}},
+			{Key: "versions_count", Value: bson.D{{Key: "$exists", Value: true}}},
+			{Key: "usage_stale", Value: bson.D{{Key: "$ne", Value: true}}},
+		},
+		bson.D{{Key: "$inc", Value: bson.D{
+			{Key: "storage_bytes", Value: bytes},
+			{Key: "versions_count", Value: versions},
+		}}},
+	)
+	if err == nil && result.MatchedCount == 0 {
+		_, _, err = c.recomputeAppUsage(appID, ctx)
+	}
+	if err != nil {
+		// The request may have timed out, marking the usage stale mustn't fail with it
+		staleCtx, cancel := utils.WithTimeout(context.WithoutCancel(ctx), utils.OperationWrite)
+		defer cancel()
+		_, staleErr := metaCollection.UpdateOne(staleCtx,
+			bson.D{{Key: "_id", Value: appID}},
+			bson.D{{Key: "$set", Value: bson.D{{Key: "usage_stale", Value: true}}}},
+		)
+		return errors.Join(err, staleErr)
+	}
+	return nil
+}
+
+// RecomputeAppUsage recalculates the usage of the app from its stored versions
+func (c *appRepository) RecomputeAppUsage(appID primitive.ObjectID, ctx context.Context) error {
+	_, _, err := c.recomputeAppUsage(appID, ctx)
+	return err
+}
+
+// recomputeAppUsage stores and returns the storage bytes and versions count of the app computed from its versions
+func (c *appRepository) recomputeAppUsage(appID primitive.ObjectID, ctx context.Context) (int64, int64, error) {
+	collection := c.client.Database(c.config.Database).Collection("apps")
+	metaCollection := c.client.Database(c.config.Database).Collection("apps_meta")
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"app_id": appID}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":            "$app_id",
+			"versions_count": bson.M{"$sum": 1},
+			"storage_bytes":  bson.M{"$sum": bson.M{"$sum": "$artifacts.size"}},
+		}}},
+	}
+
+	cur, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer cur.Close(ctx)
+
+	var usage struct {
+		StorageBytes  int64 `bson:"storage_bytes"`
+		VersionsCount int64 `bson:"versions_count"`
+	}
+	if cur.Next(ctx) {
+		if err := cur.Decode(&usage); err != nil {
+			return 0, 0, err
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	_, err = metaCollection.UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: appID}},
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "storage_bytes", Value: usage.StorageBytes},
+				{Key: "versions_count", Value: usage.VersionsCount},
+			}},
+			{Key: "$unset", Value: bson.D{{Key: "usage_stale", Value: ""}}},
+		},
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+	return usage.StorageBytes, usage.VersionsCount, nil
+}
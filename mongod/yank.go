@@ -0,0 +1,44 @@
+package mongod
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrVersionNotFound is returned when no version has the given id
+var ErrVersionNotFound = errors.New("version not found")
+
+// notYanked matches the versions that can be selected as the latest one
+var notYanked = bson.D{{Key: "$ne", Value: true}}
+
+// YankVersion marks a version as yanked, or removes the mark with yanked set to false.
+// A yanked version stays stored and downloadable, but it's never selected as the latest version.
+// The reason is returned in search results, it's removed when the version is unyanked.
+func (c *appRepository) YankVersion(id primitive.ObjectID, yanked bool, reason string, ctx context.Context) (bool, error) {
+	collection := c.client.Database(c.config.Database).Collection("apps")
+
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "yanked", Value: true},
+		{Key: "yank_reason", Value: reason},
+		{Key: "updated_at", Value: time.Now()},
+	}}, bumpRevision()}
+	if !yanked {
+		update = bson.D{
+			{Key: "$unset", Value: bson.D{{Key: "yanked", Value: ""}, {Key: "yank_reason", Value: ""}}},
+			{Key: "$set", Value: bson.D{{Key: "updated_at", Value: time.Now()}}},
+			bumpRevision(),
+		}
+	}
+	result, err := collection.UpdateOne(ctx, bson.D{{Key: "_id", Value: id}}, update)
+	if err != nil {
+		return false, err
+	}
+	if result.MatchedCount == 0 {
+		return false, ErrVersionNotFound
+	}
+	return result.ModifiedCount > 0, nil
+}
@@ -0,0 +1,99 @@
+package redisdb
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"faynoSync/memorycache"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+)
+
+// InvalidationChannel is the pub/sub channel on which the instances announce invalidated cache patterns
+const InvalidationChannel = "faynosync:cache-invalidation"
+
+type invalidationMessage struct {
+	Origin  string `json:"origin"`
+	Pattern string `json:"pattern"`
+}
+
+// InvalidationBroadcast keeps the in-memory caches of several instances consistent.
+// Patterns invalidated on one instance are published to Redis and removed from the caches of the other instances.
+type InvalidationBroadcast struct {
+	rdb    *redis.Client
+	cache  *memorycache.Cache
+	origin string
+	pubsub *redis.PubSub
+}
+
+var broadcast *InvalidationBroadcast
+
+// NewInvalidationBroadcast subscribes cache to the invalidations published by the other instances
+func NewInvalidationBroadcast(ctx context.Context, rdb *redis.Client, cache *memorycache.Cache) (*InvalidationBroadcast, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	pubsub := rdb.Subscribe(ctx, InvalidationChannel)
+	// Wait for the subscription, so no invalidation published after returning is missed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	b := &InvalidationBroadcast{rdb: rdb, cache: cache, origin: hex.EncodeToString(id), pubsub: pubsub}
+	go b.listen()
+	return b, nil
+}
+
+// StartInvalidationBroadcast enables the shared broadcast for the shared in-memory cache
+func StartInvalidationBroadcast(ctx context.Context, rdb *redis.Client, cache *memorycache.Cache) error {
+	if broadcast != nil {
+		return nil
+	}
+	b, err := NewInvalidationBroadcast(ctx, rdb, cache)
+	if err != nil {
+		return err
+	}
+	broadcast = b
+	logrus.Infof("Cache invalidation broadcast is enabled on channel %s", InvalidationChannel)
+	return nil
+}
+
+// DefaultInvalidationBroadcast returns the shared broadcast, or nil when it isn't enabled
+func DefaultInvalidationBroadcast() *InvalidationBroadcast {
+	return broadcast
+}
+
+// Publish announces to the other instances that the keys matching pattern are stale
+func (b *InvalidationBroadcast) Publish(ctx context.Context, pattern string) error {
+	payload, err := json.Marshal(invalidationMessage{Origin: b.origin, Pattern: pattern})
+	if err != nil {
+		return err
+	}
+	return b.rdb.Publish(ctx, InvalidationChannel, payload).Err()
+}
+
+// Close stops receiving invalidations
+func (b *InvalidationBroadcast) Close() error {
+	return b.pubsub.Close()
+}
+
+func (b *InvalidationBroadcast) listen() {
+	for msg := range b.pubsub.Channel() {
+		var invalidation invalidationMessage
+		if err := json.Unmarshal([]byte(msg.Payload), &invalidation); err != nil {
+			logrus.Warnf("Ignoring malformed cache invalidation %q: %v", msg.Payload, err)
+			continue
+		}
+		// The publishing instance already invalidated its own cache
+		if invalidation.Origin == b.origin {
+			continue
+		}
+		removed := b.cache.DeletePattern(invalidation.Pattern)
+		logrus.Debugf("Invalidated %d in-memory keys matching %s on broadcast.", removed, invalidation.Pattern)
+	}
+}
@@ -2,6 +2,7 @@ package redisdb
 
 import (
 	"context"
+	"faynoSync/tracing"
 	"os"
 	"time"
 
@@ -29,6 +30,7 @@ func ConnectToRedis(config RedisConfig) *redis.Client {
 	}
 
 	client = redis.NewClient(options)
+	client.AddHook(tracing.RedisHook{})
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
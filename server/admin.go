@@ -0,0 +1,42 @@
+package server
+
+import (
+	"context"
+	"errors"
+	db "faynoSync/mongod"
+	"faynoSync/server/model"
+	"faynoSync/server/utils"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// createAdmin creates the admin user of -create-admin from the -admin-username and -admin-password flags,
+// or ADMIN_USERNAME and ADMIN_PASSWORD, so a deployment can be provisioned without calling /signup
+func createAdmin(config *viper.Viper, flags map[string]interface{}, database *mongo.Database) error {
+	username, _ := flags["admin_username"].(string)
+	if username == "" {
+		username = config.GetString("ADMIN_USERNAME")
+	}
+	password, _ := flags["admin_password"].(string)
+	if password == "" {
+		password = config.GetString("ADMIN_PASSWORD")
+	}
+	if username == "" || password == "" {
+		return errors.New("-create-admin requires a username and a password, set ADMIN_USERNAME and ADMIN_PASSWORD or pass -admin-username and -admin-password")
+	}
+
+	ctx, cancel := utils.WithTimeout(context.Background(), utils.OperationWrite)
+	defer cancel()
+	created, err := db.EnsureAdmin(ctx, database, &model.Credentials{Username: username, Password: password})
+	if err != nil {
+		return err
+	}
+	if created {
+		logrus.Infof("Admin user %s created", username)
+	} else {
+		logrus.Infof("User %s already exists, left unchanged", username)
+	}
+	return nil
+}
@@ -4,44 +4,267 @@ import (
 	"context"
 	db "faynoSync/mongod"
 	"faynoSync/server/model"
+	"faynoSync/server/utils"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
 )
 
+// maxSearchApps limits the apps of one search request
+const maxSearchApps = 32
+
+// truncatedHint tells clients how to get the versions cut by SEARCH_MAX_VERSIONS
+const truncatedHint = "more versions exist, use /apps/available-versions to page through them or latest_only=true"
+
+// GetAppByName returns the versions of an app. Several apps can be requested at once with a comma-separated
+// app_name or a POST of {"app_names": [...]}, the versions are then grouped by app name.
 func GetAppByName(c *gin.Context, repository db.AppRepository) {
-	ctx, ctxErr := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	responseCase, err := utils.ResponseCase(c)
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, ctxErr := utils.WithTimeout(c.Request.Context(), utils.OperationRead)
 	defer ctxErr()
 
-	var appList []*model.SpecificAppWithoutIDs
+	//get parameters
+	appNames, grouped, err := searchAppNames(c)
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	opts, err := searchOptions(c)
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	//get parameter
-	appName := c.Query("app_name")
+	if grouped {
+		getAppsByNames(c, repository, appNames, opts, responseCase, ctx)
+		return
+	}
 
 	//request on repository
-	if result, err := repository.GetAppByName(appName, ctx); err != nil {
+	appList, err := repository.GetAppByName(appNames[0], opts, ctx)
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, "failed to get apps")
+		return
+	}
+	if appList == nil {
+		appList = []*model.SpecificAppWithoutIDs{}
+	}
+	appList, truncated := db.CutSearchResult(appList, opts)
+
+	if notModified(c, appList) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	apps, err := searchResult(appList, opts, responseCase)
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, "failed to get apps")
+		return
+	}
+	response := gin.H{"apps": apps}
+	if truncated {
+		response["truncated"] = true
+		response["hint"] = truncatedHint
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// getAppsByNames responds with the versions of several apps, keyed by app name
+func getAppsByNames(c *gin.Context, repository db.AppRepository, appNames []string, opts db.SearchOptions, responseCase string, ctx context.Context) {
+	groupedApps, err := repository.GetAppsByNames(appNames, opts, ctx)
+	if err != nil {
 		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, "failed to get apps")
+		return
+	}
+
+	var allApps []*model.SpecificAppWithoutIDs
+	var truncatedApps []string
+	for _, appName := range appNames {
+		appList, truncated := db.CutSearchResult(groupedApps[appName], opts)
+		if truncated {
+			truncatedApps = append(truncatedApps, appName)
+		}
+		groupedApps[appName] = appList
+		allApps = append(allApps, appList...)
+	}
+	if notModified(c, allApps) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	result := make(map[string]interface{}, len(appNames))
+	for _, appName := range appNames {
+		appList := groupedApps[appName]
+		if appList == nil {
+			appList = []*model.SpecificAppWithoutIDs{}
+		}
+		apps, err := searchResult(appList, opts, responseCase)
+		if err != nil {
+			logrus.Error(err)
+			utils.RespondError(c, http.StatusInternalServerError, "failed to get apps")
+			return
+		}
+		result[appName] = apps
+	}
+	response := gin.H{"apps": result}
+	if len(truncatedApps) > 0 {
+		response["truncated"] = true
+		response["truncated_apps"] = truncatedApps
+		response["hint"] = truncatedHint
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// searchAppNames returns the requested app names and whether the response is grouped by app.
+// A GET with a single app_name keeps the flat list of versions.
+func searchAppNames(c *gin.Context) ([]string, bool, error) {
+	var requested []string
+	if c.Request.Method == http.MethodPost {
+		var body struct {
+			AppNames []string `json:"app_names"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			return nil, false, fmt.Errorf("invalid request body")
+		}
+		requested = body.AppNames
 	} else {
-		appList = result
+		appName := c.Query("app_name")
+		if !strings.Contains(appName, ",") {
+			return []string{appName}, false, nil
+		}
+		requested = strings.Split(appName, ",")
 	}
 
-	c.JSON(http.StatusOK, gin.H{"apps": appList})
+	var appNames []string
+	seen := map[string]bool{}
+	for _, appName := range requested {
+		appName = strings.TrimSpace(appName)
+		if appName == "" || seen[appName] {
+			continue
+		}
+		seen[appName] = true
+		appNames = append(appNames, appName)
+	}
+	if len(appNames) == 0 {
+		return nil, false, fmt.Errorf("at least one app name is required")
+	}
+	if len(appNames) > maxSearchApps {
+		return nil, false, fmt.Errorf("at most %d apps can be requested at once", maxSearchApps)
+	}
+	return appNames, true, nil
+}
+
+// searchOptions reads latest_only, sort and fields of a search request, the version cap comes from SEARCH_MAX_VERSIONS
+func searchOptions(c *gin.Context) (db.SearchOptions, error) {
+	opts := db.SearchOptions{
+		LatestOnly:  utils.GetBoolParam(c.Query("latest_only")),
+		Sort:        c.Query("sort"),
+		MaxVersions: viper.GetInt("SEARCH_MAX_VERSIONS"),
+	}
+	if opts.Sort == "" {
+		opts.Sort = viper.GetString("SEARCH_DEFAULT_SORT")
+	}
+	if opts.Sort != "" && !db.ValidSearchSort(opts.Sort) {
+		return opts, fmt.Errorf("invalid sort parameter, allowed: %s", strings.Join(db.SearchSorts, ", "))
+	}
+	for _, field := range strings.Split(c.Query("fields"), ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		if !db.ValidSearchField(field) {
+			return opts, fmt.Errorf("invalid fields parameter %q, allowed: %s", field, strings.Join(db.SearchFields, ", "))
+		}
+		opts.Fields = append(opts.Fields, field)
+	}
+	return opts, nil
+}
+
+// searchResult formats the versions of a search, keeping only the selected fields
+func searchResult(appList []*model.SpecificAppWithoutIDs, opts db.SearchOptions, responseCase string) (interface{}, error) {
+	publicLinks(appList)
+	utils.TruncateChangelogs(appList)
+	if len(opts.Fields) == 0 {
+		return utils.FormatApps(appList, responseCase), nil
+	}
+	return utils.SelectAppFields(utils.FormatApps(appList, responseCase), opts.Fields, responseCase)
+}
+
+// publicLinks rewrites the artifact and logo links to PUBLIC_DOWNLOAD_BASE, so clients download through it
+func publicLinks(apps []*model.SpecificAppWithoutIDs) {
+	env := viper.GetViper()
+	for _, app := range apps {
+		if app.Logo != "" {
+			app.Logo = utils.PublicDownloadLink(app.Logo, env)
+		}
+		for i := range app.Artifacts {
+			app.Artifacts[i].Link = utils.PublicDownloadLink(app.Artifacts[i].Link, env)
+		}
+	}
+}
+
+// notModified sets the Last-Modified and ETag headers of the app list and reports
+// whether the client's copy is still fresh. The ETag also covers deleted versions,
+// which don't change the latest Updated_at.
+func notModified(c *gin.Context, apps []*model.SpecificAppWithoutIDs) bool {
+	var lastModified time.Time
+	for _, app := range apps {
+		if updatedAt := app.UpdatedAt.Time(); updatedAt.After(lastModified) {
+			lastModified = updatedAt
+		}
+	}
+	if lastModified.IsZero() {
+		return false
+	}
+	lastModified = lastModified.UTC().Truncate(time.Second)
+	etag := fmt.Sprintf(`W/"%d-%d"`, len(apps), lastModified.Unix())
+
+	c.Header("Last-Modified", lastModified.Format(http.TimeFormat))
+	c.Header("ETag", etag)
+
+	if ifNoneMatch := c.GetHeader("If-None-Match"); ifNoneMatch != "" {
+		return ifNoneMatch == etag
+	}
+	if since, err := http.ParseTime(c.GetHeader("If-Modified-Since")); err == nil {
+		return !lastModified.After(since)
+	}
+	return false
 }
 
 func GetAllApps(c *gin.Context, repository db.AppRepository) {
-	ctx, ctxErr := context.WithTimeout(c.Request.Context(), 30*time.Second)
-	defer ctxErr()
+	responseCase, err := utils.ResponseCase(c)
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	var appList []*model.SpecificAppWithoutIDs
+	ctx, ctxErr := utils.WithTimeout(c.Request.Context(), utils.OperationRead)
+	defer ctxErr()
 
 	//request on repository
-	if result, err := repository.Get(ctx); err != nil {
+	appList, err := repository.Get(ctx)
+	if err != nil {
 		logrus.Error(err)
-	} else {
-		appList = result
+		utils.RespondError(c, http.StatusInternalServerError, "failed to get apps")
+		return
+	}
+	if appList == nil {
+		appList = []*model.SpecificAppWithoutIDs{}
 	}
 
-	c.JSON(http.StatusOK, gin.H{"apps": &appList})
+	publicLinks(appList)
+	utils.TruncateChangelogs(appList)
+	c.JSON(http.StatusOK, gin.H{"apps": utils.FormatApps(appList, responseCase)})
 }
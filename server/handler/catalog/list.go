@@ -1,18 +1,18 @@
 package catalog
 
 import (
-	"context"
 	db "faynoSync/mongod"
 	"faynoSync/server/model"
+	"faynoSync/server/utils"
 	"net/http"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
 )
 
 func ListChannels(c *gin.Context, repository db.AppRepository) {
-	ctx, ctxErr := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	ctx, ctxErr := utils.WithTimeout(c.Request.Context(), utils.OperationRead)
 	defer ctxErr()
 
 	var channelsList []*model.Channel
@@ -28,7 +28,7 @@ func ListChannels(c *gin.Context, repository db.AppRepository) {
 }
 
 func ListPlatforms(c *gin.Context, repository db.AppRepository) {
-	ctx, ctxErr := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	ctx, ctxErr := utils.WithTimeout(c.Request.Context(), utils.OperationRead)
 	defer ctxErr()
 
 	var platformsList []*model.Platform
@@ -44,7 +44,7 @@ func ListPlatforms(c *gin.Context, repository db.AppRepository) {
 }
 
 func ListArchs(c *gin.Context, repository db.AppRepository) {
-	ctx, ctxErr := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	ctx, ctxErr := utils.WithTimeout(c.Request.Context(), utils.OperationRead)
 	defer ctxErr()
 
 	var archsList []*model.Arch
@@ -60,17 +60,65 @@ func ListArchs(c *gin.Context, repository db.AppRepository) {
 }
 
 func ListApps(c *gin.Context, repository db.AppRepository) {
-	ctx, ctxErr := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	ctx, ctxErr := utils.WithTimeout(c.Request.Context(), utils.OperationRead)
 	defer ctxErr()
 
 	var appsList []*model.App
 
+	// Scoped callers only see their own apps, admins see everything
+	var appNames []string
+	if scope, ok := utils.ScopedApps(c); ok {
+		appNames = scope
+	}
+
 	//request on repository
-	if result, err := repository.ListApps(ctx); err != nil {
+	if result, err := repository.ListApps(appNames, ctx); err != nil {
 		logrus.Error(err)
 	} else {
 		appsList = result
 	}
+	for _, app := range appsList {
+		if app.Logo != "" {
+			app.Logo = utils.PublicDownloadLink(app.Logo, viper.GetViper())
+		}
+	}
 
 	c.JSON(http.StatusOK, gin.H{"apps": &appsList})
 }
+
+func ListFlags(c *gin.Context, repository db.AppRepository) {
+	appName := c.Query("app_name")
+	if appName == "" {
+		utils.RespondError(c, http.StatusBadRequest, "Parameter 'app_name' is required")
+		return
+	}
+
+	ctx, ctxErr := utils.WithTimeout(c.Request.Context(), utils.OperationRead)
+	defer ctxErr()
+
+	flags, err := repository.ListFlags(appName, ctx)
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"flags": flags})
+}
+
+func ListBetaTokens(c *gin.Context, repository db.AppRepository) {
+	if !utils.RequireAdmin(c, "list beta tokens") {
+		return
+	}
+	ctx, ctxErr := utils.WithTimeout(c.Request.Context(), utils.OperationRead)
+	defer ctxErr()
+
+	tokens, err := repository.ListBetaTokens(ctx)
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"beta_tokens": tokens})
+}
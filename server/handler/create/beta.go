@@ -0,0 +1,53 @@
+package create
+
+import (
+	"encoding/json"
+	db "faynoSync/mongod"
+	"faynoSync/server/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateBetaToken issues a token giving access to gated channels.
+// The token is only returned here, the server keeps its hash. Only admins can issue tokens.
+func CreateBetaToken(c *gin.Context, repository db.AppRepository) {
+	if !utils.RequireAdmin(c, "issue beta tokens") {
+		return
+	}
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationWrite)
+	defer cancel()
+
+	jsonData := c.PostForm("data")
+	if jsonData == "" {
+		utils.RespondError(c, http.StatusBadRequest, "No JSON data provided")
+		return
+	}
+
+	var params struct {
+		Label    string   `json:"label"`
+		Channels []string `json:"channels"`
+	}
+	if err := json.Unmarshal([]byte(jsonData), &params); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid JSON data")
+		return
+	}
+	if params.Label == "" {
+		utils.RespondError(c, http.StatusBadRequest, "label is required")
+		return
+	}
+	for _, channel := range params.Channels {
+		if channel == "" || !utils.IsValidChannelName(channel) {
+			utils.RespondError(c, http.StatusBadRequest, "invalid channel name: "+channel)
+			return
+		}
+	}
+
+	token, id, err := repository.CreateBetaToken(params.Label, params.Channels, ctx)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"createBetaTokenResult.Created": id, "token": token})
+}
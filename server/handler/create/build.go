@@ -0,0 +1,70 @@
+package create
+
+import (
+	"encoding/json"
+	"errors"
+	db "faynoSync/mongod"
+	"faynoSync/server/utils"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// NextBuildNumber assigns the next build number of an app and channel, so parallel CI pipelines never upload the same version.
+// With a base version, the version with the build number appended is returned as well.
+func NextBuildNumber(c *gin.Context, repository db.AppRepository) {
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationWrite)
+	defer cancel()
+
+	jsonData := c.PostForm("data")
+	if jsonData == "" {
+		utils.RespondError(c, http.StatusBadRequest, "No JSON data provided")
+		return
+	}
+
+	var params struct {
+		AppName string `json:"app_name"`
+		Channel string `json:"channel"`
+		Min     int64  `json:"min"`
+		Base    string `json:"base"`
+	}
+	if err := json.Unmarshal([]byte(jsonData), &params); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid JSON data")
+		return
+	}
+	if params.AppName == "" {
+		utils.RespondError(c, http.StatusBadRequest, "app_name is required")
+		return
+	}
+	if !utils.IsValidChannelName(params.Channel) {
+		utils.RespondError(c, http.StatusBadRequest, "invalid channel parameter")
+		return
+	}
+	if params.Min < 0 {
+		utils.RespondError(c, http.StatusBadRequest, "min must not be negative")
+		return
+	}
+	if params.Base != "" && !utils.IsValidVersion(params.Base) {
+		utils.RespondError(c, http.StatusBadRequest, "invalid base parameter")
+		return
+	}
+
+	build, err := repository.NextBuildNumber(params.AppName, params.Channel, params.Min, ctx)
+	if errors.Is(err, db.ErrAppMetaNotFound) || errors.Is(err, db.ErrChannelNotFound) {
+		utils.RespondError(c, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response := gin.H{"nextBuildNumberResult.Build": build}
+	if params.Base != "" {
+		response["version"] = fmt.Sprintf("%s.%d", params.Base, build)
+	}
+	c.JSON(http.StatusOK, response)
+}
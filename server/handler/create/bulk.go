@@ -0,0 +1,89 @@
+package create
+
+import (
+	"context"
+	"encoding/json"
+	db "faynoSync/mongod"
+	"faynoSync/server/utils"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxBulkItems = 100
+
+type BulkCreateRequest struct {
+	Channels  []string `json:"channels"`
+	Platforms []string `json:"platforms"`
+	Archs     []string `json:"archs"`
+}
+
+type BulkCreateItemResult struct {
+	Name  string      `json:"name"`
+	ID    interface{} `json:"id,omitempty"`
+	Error string      `json:"error,omitempty"`
+}
+
+// BulkCreate creates several channels, platforms and archs in one request.
+// Invalid or duplicate items are reported per item and don't fail the whole batch.
+func BulkCreate(c *gin.Context, repository db.AppRepository) {
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationWrite)
+	defer cancel()
+
+	jsonData := c.PostForm("data")
+	if jsonData == "" {
+		utils.RespondError(c, http.StatusBadRequest, "No JSON data provided")
+		return
+	}
+
+	var params BulkCreateRequest
+	if err := json.Unmarshal([]byte(jsonData), &params); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid JSON data")
+		return
+	}
+
+	total := len(params.Channels) + len(params.Platforms) + len(params.Archs)
+	if total == 0 {
+		utils.RespondError(c, http.StatusBadRequest, "at least one channel, platform or arch is required")
+		return
+	}
+	if total > maxBulkItems {
+		utils.RespondError(c, http.StatusBadRequest, fmt.Sprintf("too many items, maximum is %d", maxBulkItems))
+		return
+	}
+
+	result := gin.H{
+		"channels":  bulkCreateItems(repository, "channel", params.Channels, ctx),
+		"platforms": bulkCreateItems(repository, "platform", params.Platforms, ctx),
+		"archs":     bulkCreateItems(repository, "arch", params.Archs, ctx),
+	}
+	utils.RefreshStrictCatalog(ctx)
+	c.JSON(http.StatusOK, gin.H{"bulkCreateResult.Created": result})
+}
+
+func bulkCreateItems(repository db.AppRepository, itemType string, names []string, ctx context.Context) []BulkCreateItemResult {
+	results := []BulkCreateItemResult{}
+	seen := make(map[string]bool)
+	for _, name := range names {
+		result := BulkCreateItemResult{Name: name}
+		if seen[name] {
+			result.Error = "duplicate " + itemType + " in request"
+			results = append(results, result)
+			continue
+		}
+		seen[name] = true
+
+		if name == "" {
+			result.Error = itemType + " is required"
+		} else if err := utils.ValidateItemName(itemType, name); err != nil {
+			result.Error = err.Error()
+		} else if id, err := createItem(repository, itemType, name, "", ctx); err != nil {
+			result.Error = err.Error()
+		} else {
+			result.ID = id
+		}
+		results = append(results, result)
+	}
+	return results
+}
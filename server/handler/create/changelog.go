@@ -0,0 +1,65 @@
+package create
+
+import (
+	db "faynoSync/mongod"
+	"faynoSync/server/utils"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// SanitizeChangelog cleans the changelog of an upload or update with utils.SanitizeChangelog before it is checked
+// and stored. It returns the warning for the response when the changelog was cut, empty otherwise.
+func SanitizeChangelog(ctxQueryMap map[string]interface{}) string {
+	changelog := utils.GetStringValue(ctxQueryMap, "changelog")
+	if changelog == "" {
+		return ""
+	}
+	env := viper.GetViper()
+	sanitized, truncated := utils.SanitizeChangelog(changelog, env)
+	ctxQueryMap["changelog"] = sanitized
+	if !truncated {
+		return ""
+	}
+	warning := fmt.Sprintf("changelog truncated to %d bytes", env.GetInt("CHANGELOG_STORE_MAX_BYTES"))
+	logrus.Warnf("%s for %s %s", warning, utils.GetStringValue(ctxQueryMap, "app_name"), utils.GetStringValue(ctxQueryMap, "version"))
+	return warning
+}
+
+// ApplyChangelogTemplate wraps the sanitized changelog of an upload or update in the changelog template of its app.
+// The supplied changes are kept as changelog_raw, so they are stored next to the rendered changelog.
+// It returns false if the response was already written.
+func ApplyChangelogTemplate(c *gin.Context, repository db.AppRepository, ctxQueryMap map[string]interface{}) bool {
+	changes := utils.GetStringValue(ctxQueryMap, "changelog")
+	if changes == "" {
+		return true
+	}
+	appName := utils.GetStringValue(ctxQueryMap, "app_name")
+	changelogTemplate, err := repository.AppChangelogTemplate(appName, c.Request.Context())
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	if changelogTemplate == "" {
+		return true
+	}
+	rendered, err := utils.RenderChangelogTemplate(changelogTemplate, utils.ChangelogTemplateData{
+		AppName: appName,
+		Version: utils.GetStringValue(ctxQueryMap, "version"),
+		Channel: utils.GetStringValue(ctxQueryMap, "channel"),
+		Changes: changes,
+	})
+	if err != nil {
+		logrus.Errorf("Changelog template of %s: %v", appName, err)
+		utils.RespondError(c, http.StatusInternalServerError, "failed to apply the changelog template of the app")
+		return false
+	}
+	// The template may push the changelog over CHANGELOG_STORE_MAX_BYTES
+	ctxQueryMap["changelog"], _ = utils.SanitizeChangelog(rendered, viper.GetViper())
+	ctxQueryMap["changelog_raw"] = changes
+	return true
+}
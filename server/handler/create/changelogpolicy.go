@@ -0,0 +1,33 @@
+package create
+
+import (
+	db "faynoSync/mongod"
+	"faynoSync/server/utils"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// CheckChangelogPolicy rejects publishing a version without a changelog with 400 when its channel requires one.
+// A changelog stored with the version before satisfies the requirement as well.
+// It returns false if the response was already written.
+func CheckChangelogPolicy(c *gin.Context, repository db.AppRepository, ctxQueryMap map[string]interface{}) bool {
+	if !utils.GetBoolParam(ctxQueryMap["publish"]) || strings.TrimSpace(utils.GetStringValue(ctxQueryMap, "changelog")) != "" {
+		return true
+	}
+	channel := utils.GetStringValue(ctxQueryMap, "channel")
+	missing, err := repository.MissingRequiredChangelog(utils.GetStringValue(ctxQueryMap, "app_name"), utils.GetStringValue(ctxQueryMap, "version"), channel, c.Request.Context())
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	if missing {
+		utils.RespondError(c, http.StatusBadRequest, fmt.Sprintf("channel %s requires a changelog for published versions", channel))
+		return false
+	}
+	return true
+}
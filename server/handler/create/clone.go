@@ -0,0 +1,50 @@
+package create
+
+import (
+	"errors"
+	db "faynoSync/mongod"
+	"faynoSync/server/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// CloneApp copies the configuration of the source app to a new app.
+// Binaries are never copied, with include_versions=true the copied versions share the source artifacts.
+// Tokens scoped to apps need both the source and the target app in their scope.
+func CloneApp(c *gin.Context, repository db.AppRepository) {
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationWrite)
+	defer cancel()
+
+	source := c.Query("source")
+	target := c.Query("target")
+	if source == "" || target == "" {
+		utils.RespondError(c, http.StatusBadRequest, "Parameters 'source' and 'target' are required")
+		return
+	}
+	if !utils.IsValidAppName(target) {
+		utils.RespondError(c, http.StatusBadRequest, "invalid app_name parameter")
+		return
+	}
+	if !utils.RequireAppInScope(c, source) || !utils.RequireAppInScope(c, target) {
+		return
+	}
+	includeVersions := utils.GetBoolParam(c.Query("include_versions"))
+
+	id, copied, err := repository.CloneApp(source, target, includeVersions, ctx)
+	switch {
+	case errors.Is(err, db.ErrAppNotFound):
+		utils.RespondError(c, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, db.ErrAppAlreadyExists), errors.Is(err, db.ErrAlreadyExists):
+		utils.RespondError(c, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"cloneAppResult.Created": id.Hex(), "versions_copied": copied})
+}
@@ -3,69 +3,118 @@ package create
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	db "faynoSync/mongod"
+	"faynoSync/server/model"
 	"faynoSync/server/utils"
 	"net/http"
-	"time"
 
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 func CreateItem(c *gin.Context, repository db.AppRepository, itemType string) {
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationWrite)
 	defer cancel()
 
 	jsonData := c.PostForm("data")
 	if jsonData == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No JSON data provided"})
+		utils.RespondError(c, http.StatusBadRequest, "No JSON data provided")
 		return
 	}
 
 	var params map[string]string
 	if err := json.Unmarshal([]byte(jsonData), &params); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON data"})
+		utils.RespondError(c, http.StatusBadRequest, "Invalid JSON data")
 		return
 	}
 
 	paramName := itemType
 	paramValue, exists := params[paramName]
 	if !exists || paramValue == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": paramName + " is required"})
+		utils.RespondError(c, http.StatusBadRequest, paramName+" is required")
 		return
 	}
 	if err := utils.ValidateItemName(itemType, paramValue); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.RespondError(c, http.StatusBadRequest, err.Error())
 		return
 	}
-	var result interface{}
-	var err error
-
-	switch itemType {
-	case "channel":
-		result, err = repository.CreateChannel(paramValue, ctx)
-	case "platform":
-		result, err = repository.CreatePlatform(paramValue, ctx)
-	case "arch":
-		result, err = repository.CreateArch(paramValue, ctx)
-	case "app":
-		result, err = repository.CreateApp(paramValue, ctx)
-	default:
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item type"})
+	versionPattern := params["version_pattern"]
+	if versionPattern != "" {
+		if itemType != "app" {
+			utils.RespondError(c, http.StatusBadRequest, "version_pattern can only be set for apps")
+			return
+		}
+		if err := utils.ValidateVersionPattern(versionPattern); err != nil {
+			utils.RespondError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	result, err := createItem(repository, itemType, paramValue, versionPattern, ctx)
+	if err == errInvalidItemType {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid item type")
+		return
+	}
+	if errors.Is(err, db.ErrAlreadyExists) {
+		utils.RespondError(c, http.StatusConflict, err.Error())
 		return
 	}
-
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
 		return
 	}
+	if itemType != "app" {
+		utils.RefreshStrictCatalog(ctx)
+	}
 	var tag language.Tag
 	titleCase := cases.Title(tag)
 
 	capitalizedItemType := titleCase.String(itemType)
-	c.JSON(http.StatusOK, gin.H{"create" + capitalizedItemType + "Result.Created": result})
+	id, _ := result.(primitive.ObjectID)
+	legacy := gin.H{"create" + capitalizedItemType + "Result.Created": result}
+	c.JSON(http.StatusOK, utils.ResultResponse(utils.ActionCreated, itemType, ItemEntity(repository, itemType, id, ctx), legacy))
+}
+
+// ItemEntity returns the app, channel, platform or arch for the response of a request that changed it.
+// The request already succeeded, so a failed lookup only leaves the entity out.
+func ItemEntity(repository db.AppRepository, itemType string, id primitive.ObjectID, ctx context.Context) interface{} {
+	item, err := repository.GetItem(itemType, id, ctx)
+	if err != nil {
+		logrus.Errorf("Error fetching %s %s: %v", itemType, id.Hex(), err)
+		return nil
+	}
+	return item
+}
+
+// VersionEntity returns the version for the response of a request that changed it, like ItemEntity
+func VersionEntity(repository db.AppRepository, id primitive.ObjectID, ctx context.Context) *model.SpecificAppWithoutIDs {
+	apps, err := repository.FetchAppByID(id, ctx)
+	if err != nil || len(apps) == 0 {
+		logrus.Errorf("Error fetching version %s: %v", id.Hex(), err)
+		return nil
+	}
+	return apps[0]
+}
+
+var errInvalidItemType = errors.New("invalid item type")
+
+func createItem(repository db.AppRepository, itemType, name, versionPattern string, ctx context.Context) (interface{}, error) {
+	switch itemType {
+	case "channel":
+		return repository.CreateChannel(name, ctx)
+	case "platform":
+		return repository.CreatePlatform(name, ctx)
+	case "arch":
+		return repository.CreateArch(name, ctx)
+	case "app":
+		return repository.CreateAppWithVersionPattern(name, versionPattern, ctx)
+	default:
+		return nil, errInvalidItemType
+	}
 }
 
 func CreateChannel(c *gin.Context, repository db.AppRepository) {
@@ -0,0 +1,37 @@
+package create
+
+import (
+	db "faynoSync/mongod"
+	"faynoSync/server/utils"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// CheckAppDeprecation rejects uploads to a deprecated app with 409.
+// It returns false if the response was already written.
+func CheckAppDeprecation(c *gin.Context, repository db.AppRepository, ctxQueryMap map[string]interface{}) bool {
+	appName := utils.GetStringValue(ctxQueryMap, "app_name")
+	deprecation, err := repository.AppDeprecation(appName, c.Request.Context())
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	if deprecation == nil {
+		return true
+	}
+
+	message := fmt.Sprintf("app %s is deprecated", appName)
+	if deprecation.Message != "" {
+		message += ": " + deprecation.Message
+	}
+	var details gin.H
+	if deprecation.EOLDate != "" {
+		details = gin.H{"eol_date": deprecation.EOLDate}
+	}
+	utils.RespondErrorDetails(c, http.StatusConflict, message, details)
+	return false
+}
@@ -0,0 +1,51 @@
+package create
+
+import (
+	"errors"
+	"faynoSync/server/utils"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// CheckFileTypes rejects uploaded files of a type in UPLOAD_DISALLOWED_TYPES with 400 and returns false.
+// It also sniffs the files when UPLOAD_FILE_TYPE_CHECK is enabled, so a mislabeled artifact, e.g. a zip
+// uploaded as app.dmg, doesn't reach clients. In strict mode it responds with 400 and returns false,
+// otherwise the mismatch is only logged.
+func CheckFileTypes(c *gin.Context, files []*multipart.FileHeader) bool {
+	env := viper.GetViper()
+	for _, file := range files {
+		err := utils.CheckDisallowedFileType(file, env)
+		if errors.Is(err, utils.ErrFileTypeDisallowed) {
+			utils.RespondError(c, http.StatusBadRequest, err.Error())
+			return false
+		} else if err != nil {
+			logrus.Error(err)
+			utils.RespondError(c, http.StatusBadRequest, "failed to read file")
+			return false
+		}
+	}
+
+	mode := utils.FileTypeCheckMode(env)
+	if mode == utils.FileTypeCheckOff {
+		return true
+	}
+	for _, file := range files {
+		err := utils.CheckFileType(file)
+		if errors.Is(err, utils.ErrFileTypeMismatch) {
+			if mode == utils.FileTypeCheckStrict {
+				utils.RespondError(c, http.StatusBadRequest, err.Error())
+				return false
+			}
+			logrus.Warn(err)
+		} else if err != nil {
+			logrus.Error(err)
+			utils.RespondError(c, http.StatusBadRequest, "failed to read file")
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,83 @@
+package create
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	db "faynoSync/mongod"
+	"faynoSync/server/model"
+	"faynoSync/server/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// InvalidateFlagsCache removes the cached feature flags after a flag was changed
+func InvalidateFlagsCache(c *gin.Context, rdb *redis.Client, performanceMode bool) {
+	if !CachingEnabled(performanceMode, rdb) {
+		return
+	}
+	if err := InvalidateCachePattern(c.Request.Context(), utils.FlagsCacheInvalidationPattern(), rdb); err != nil {
+		logrus.Error("Error invalidating cache:", err)
+	}
+}
+
+// RequireFlagInScope responds with an error unless the flag exists and its app is in the scope of the token.
+// It returns false if the response was already written.
+func RequireFlagInScope(c *gin.Context, repository db.AppRepository, id primitive.ObjectID, ctx context.Context) bool {
+	flag, err := repository.GetFlag(id, ctx)
+	if errors.Is(err, db.ErrFlagNotFound) {
+		utils.RespondError(c, http.StatusNotFound, err.Error())
+		return false
+	}
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, "failed to get flag")
+		return false
+	}
+	return utils.RequireAppInScope(c, flag.AppName)
+}
+
+func CreateFlag(c *gin.Context, repository db.AppRepository, rdb *redis.Client, performanceMode bool) {
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationWrite)
+	defer cancel()
+
+	jsonData := c.PostForm("data")
+	if jsonData == "" {
+		utils.RespondError(c, http.StatusBadRequest, "No JSON data provided")
+		return
+	}
+
+	var flag model.Flag
+	if err := json.Unmarshal([]byte(jsonData), &flag); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid JSON data")
+		return
+	}
+	if flag.AppName == "" || flag.Key == "" {
+		utils.RespondError(c, http.StatusBadRequest, "app_name and key are required")
+		return
+	}
+	if !utils.RequireAppInScope(c, flag.AppName) {
+		return
+	}
+	if err := utils.ValidateFlag(flag); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := repository.CreateFlag(flag, ctx)
+	if errors.Is(err, db.ErrAlreadyExists) {
+		utils.RespondError(c, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	InvalidateFlagsCache(c, rdb, performanceMode)
+
+	c.JSON(http.StatusOK, gin.H{"createFlagResult.Created": result})
+}
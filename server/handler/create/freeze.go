@@ -0,0 +1,47 @@
+package create
+
+import (
+	db "faynoSync/mongod"
+	"faynoSync/server/utils"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// CheckChannelFreeze rejects the request with 423 when uploads to its channel are frozen.
+// Admins can upload anyway with override_freeze=true, tokens scoped to apps can't.
+// It returns false if the response was already written.
+func CheckChannelFreeze(c *gin.Context, repository db.AppRepository, ctxQueryMap map[string]interface{}) bool {
+	channel := utils.GetStringValue(ctxQueryMap, "channel")
+	status, err := repository.ChannelFreezeStatus(channel, time.Now(), c.Request.Context())
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	if !status.Frozen {
+		return true
+	}
+
+	if override, _ := strconv.ParseBool(c.PostForm("override_freeze")); override {
+		if utils.IsAdmin(c) {
+			logrus.Warnf("Upload of app %s to frozen channel %s by admin override", utils.GetStringValue(ctxQueryMap, "app_name"), channel)
+			return true
+		}
+	}
+
+	message := fmt.Sprintf("channel %s is frozen", channel)
+	if status.Reason != "" {
+		message += ": " + status.Reason
+	}
+	details := gin.H{"reason": status.Reason}
+	if status.Until != nil {
+		details["frozen_until"] = status.Until.UTC().Format(time.RFC3339)
+	}
+	utils.RespondErrorDetails(c, http.StatusLocked, message, details)
+	return false
+}
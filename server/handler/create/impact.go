@@ -0,0 +1,33 @@
+package create
+
+import (
+	"context"
+	"errors"
+	db "faynoSync/mongod"
+	"faynoSync/server/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PreviewRequested reports whether a destructive request only asks what it would change, with preview=true
+func PreviewRequested(c *gin.Context) bool {
+	return utils.GetBoolParam(c.Query("preview"))
+}
+
+// RespondVersionImpact responds with what deleting or yanking the version would change, instead of doing it
+func RespondVersionImpact(c *gin.Context, repository db.AppRepository, id primitive.ObjectID, ctx context.Context) {
+	impact, err := repository.VersionImpact(id, ctx)
+	if errors.Is(err, db.ErrVersionNotFound) {
+		utils.RespondError(c, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"preview": impact})
+}
@@ -0,0 +1,136 @@
+package create
+
+import (
+	"encoding/json"
+	"errors"
+	db "faynoSync/mongod"
+	"faynoSync/server/model"
+	"faynoSync/server/utils"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// ImportApp recreates an app from a bundle written by GET /apps/export.
+// Artifacts that carry their content are uploaded, the others must already exist in the bucket,
+// e.g. because the instances share it or it was copied. Only admins can import.
+func ImportApp(c *gin.Context, repository db.AppRepository) {
+	if !utils.RequireAdmin(c, "import apps") {
+		return
+	}
+
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationWrite)
+	defer cancel()
+
+	file, err := c.FormFile("bundle")
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "bundle file is required")
+		return
+	}
+	reader, err := file.Open()
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, "failed to open bundle")
+		return
+	}
+	defer reader.Close()
+
+	var bundle model.AppBundle
+	if err := json.NewDecoder(reader).Decode(&bundle); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "invalid bundle: "+err.Error())
+		return
+	}
+	if err := validateBundle(bundle); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Check before touching the bucket, uploaded content would overwrite the objects of the existing app
+	existing, err := repository.ListApps([]string{bundle.AppName}, ctx)
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(existing) > 0 {
+		utils.RespondError(c, http.StatusConflict, db.ErrAppAlreadyExists.Error())
+		return
+	}
+
+	env := viper.GetViper()
+	uploaded := 0
+	for i := range bundle.Versions {
+		version := &bundle.Versions[i]
+		for j := range version.Artifacts {
+			artifact := &version.Artifacts[j]
+			params := map[string]interface{}{"app_name": bundle.AppName, "version": version.Version, "channel": version.Channel, "platform": artifact.Platform, "arch": artifact.Arch}
+			link, s3Key, _ := utils.BuildS3Object(params, bundle.AppName+artifact.Package, env)
+			bucket := utils.ArtifactBucket(params, env)
+
+			if len(artifact.Content) > 0 {
+				link, err = utils.WriteS3Object(ctx, bucket, s3Key, artifact.Content, "", link, env)
+				if err != nil {
+					logrus.Error(err)
+					utils.RespondError(c, http.StatusInternalServerError, "failed to upload "+s3Key)
+					return
+				}
+				artifact.Size = int64(len(artifact.Content))
+				uploaded++
+			} else {
+				_, etag, statLink, err := utils.StatS3Object(ctx, bucket, s3Key, link, params, env)
+				if err != nil {
+					utils.RespondError(c, http.StatusNotFound, "object not found: "+s3Key)
+					return
+				}
+				if artifact.Checksum != "" && artifact.Checksum != etag {
+					utils.RespondError(c, http.StatusBadRequest, "checksum mismatch for "+s3Key)
+					return
+				}
+				link = statLink
+			}
+			artifact.Link = link
+			artifact.Key = s3Key
+		}
+	}
+
+	id, imported, err := repository.ImportApp(bundle, ctx)
+	switch {
+	case errors.Is(err, db.ErrAppAlreadyExists):
+		utils.RespondError(c, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"importAppResult.Created": id.Hex(), "versions_imported": imported, "objects_uploaded": uploaded})
+}
+
+func validateBundle(bundle model.AppBundle) error {
+	if bundle.Format != model.AppBundleFormat {
+		return fmt.Errorf("unsupported bundle format %d", bundle.Format)
+	}
+	if !utils.IsValidAppName(bundle.AppName) {
+		return errors.New("invalid app_name parameter")
+	}
+	if bundle.VersionPattern != "" {
+		if err := utils.ValidateVersionPattern(bundle.VersionPattern); err != nil {
+			return err
+		}
+	}
+	seen := map[string]bool{}
+	for _, version := range bundle.Versions {
+		if version.Version == "" {
+			return errors.New("invalid bundle: version is required")
+		}
+		if seen[version.Version] {
+			return fmt.Errorf("invalid bundle: version %s is listed twice", version.Version)
+		}
+		seen[version.Version] = true
+	}
+	return nil
+}
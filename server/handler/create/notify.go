@@ -0,0 +1,119 @@
+package create
+
+import (
+	"context"
+	db "faynoSync/mongod"
+	"faynoSync/server/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// NotifyRelease sends the notifications of an uploaded or published version in the background.
+// Slack is notified of uploads, email of the events and channels enabled with EMAIL_EVENTS and EMAIL_CHANNELS.
+// Without artifacts and changelog the ones stored with the version are sent.
+func NotifyRelease(repository db.AppRepository, id primitive.ObjectID, event string, artifacts, changelog []string) {
+	env := viper.GetViper()
+	slackEnabled := event == utils.ReleaseEventUpload && env.GetBool("SLACK_ENABLE")
+	if !slackEnabled && !env.GetBool("EMAIL_ENABLE") {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		humanReadableData, err := repository.FetchAppByID(id, ctx)
+		if err != nil || len(humanReadableData) == 0 {
+			logrus.Error("Error fetching human-readable data for notification: ", err)
+			return
+		}
+		release := humanReadableData[0]
+
+		var platforms, arches, pkgs, storedArtifacts, storedChangelog []string
+		for _, artifact := range release.Artifacts {
+			platforms = append(platforms, artifact.Platform)
+			arches = append(arches, artifact.Arch)
+			pkgs = append(pkgs, artifact.Package)
+			storedArtifacts = append(storedArtifacts, artifact.Link)
+		}
+		for _, change := range release.Changelog {
+			if change.Changes != "" {
+				storedChangelog = append(storedChangelog, change.Changes)
+			}
+		}
+		if artifacts == nil {
+			artifacts = storedArtifacts
+		}
+		if changelog == nil {
+			changelog = storedChangelog
+		}
+
+		if slackEnabled {
+			utils.SendSlackNotification(
+				release.AppName,
+				release.Channel,
+				release.Version,
+				platforms,
+				arches,
+				artifacts,
+				changelog,
+				pkgs,
+				env,
+				release.Published,
+				release.Critical,
+			)
+		}
+		// An upload publishing its version is announced as a release
+		emailEvent := event
+		if event == utils.ReleaseEventUpload && release.Published {
+			emailEvent = utils.ReleaseEventPublish
+		}
+		if utils.EmailNotificationEnabled(emailEvent, release.Channel, env) {
+			links := make([]string, len(artifacts))
+			for i, artifact := range artifacts {
+				links[i] = utils.PublicDownloadLink(artifact, env)
+			}
+			utils.QueueEmailNotification(utils.ReleaseNotification{
+				Event:     emailEvent,
+				AppName:   release.AppName,
+				Channel:   release.Channel,
+				Version:   release.Version,
+				Published: release.Published,
+				Critical:  release.Critical,
+				Artifacts: links,
+				Changelog: changelog,
+			}, env)
+		}
+	}()
+}
+
+// SendTestNotification sends a test notification through each enabled backend, so the notification settings can
+// be checked after a change without a release. It responds with the result of each backend, 502 when one of them
+// failed. Only admins can send it.
+func SendTestNotification(c *gin.Context) {
+	if !utils.RequireAdmin(c, "send test notifications") {
+		return
+	}
+	env := viper.GetViper()
+	if len(utils.NotificationBackends(env)) == 0 {
+		utils.RespondError(c, http.StatusBadRequest, "no notification backends are enabled, set SLACK_ENABLE or EMAIL_ENABLE")
+		return
+	}
+
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationWrite)
+	defer cancel()
+
+	status := http.StatusOK
+	results := utils.SendTestNotifications(ctx, env)
+	for backend, result := range results {
+		if !result.OK {
+			logrus.Errorf("Test notification through %s failed: %s", backend, result.Error)
+			status = http.StatusBadGateway
+		}
+	}
+	c.JSON(status, gin.H{"notifications": results})
+}
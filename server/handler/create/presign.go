@@ -0,0 +1,166 @@
+package create
+
+import (
+	"errors"
+	db "faynoSync/mongod"
+	"faynoSync/server/model"
+	"faynoSync/server/utils"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const defaultPresignExpiry = 15 * time.Minute
+
+// PresignUpload returns a presigned PUT URL, so large artifacts can be uploaded directly to S3
+func PresignUpload(c *gin.Context, repository db.AppRepository, db *mongo.Database) {
+	ctxQueryMap, err := utils.ValidateParams(c, db)
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	filename := c.PostForm("filename")
+	if filename == "" {
+		utils.RespondError(c, http.StatusBadRequest, "filename is required")
+		return
+	}
+	env := viper.GetViper()
+	// The content is sent to the bucket directly, only the extension can be checked
+	if utils.IsDisallowedExtension(filename, env) {
+		utils.RespondError(c, http.StatusBadRequest, fmt.Sprintf("%s: %s", utils.ErrFileTypeDisallowed, filename))
+		return
+	}
+	if !CheckAppDeprecation(c, repository, ctxQueryMap) {
+		return
+	}
+	if !CheckChannelFreeze(c, repository, ctxQueryMap) {
+		return
+	}
+	if !CheckChangelogPolicy(c, repository, ctxQueryMap) {
+		return
+	}
+
+	expiry := env.GetDuration("PRESIGN_EXPIRY")
+	if expiry <= 0 {
+		expiry = defaultPresignExpiry
+	}
+
+	_, s3Key, _ := utils.BuildS3Object(ctxQueryMap, filename, env)
+	presignedURL, err := utils.PresignUpload(c.Request.Context(), utils.ArtifactBucket(ctxQueryMap, env), s3Key, expiry, env)
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, "failed to presign upload")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"url":        presignedURL,
+		"key":        s3Key,
+		"expires_in": int(expiry.Seconds()),
+	})
+}
+
+// CompleteUpload registers an artifact that was uploaded with a presigned URL.
+// The SHA-256 of the object is stored with the artifact and has to match the checksum sent by the client, if any.
+func CompleteUpload(c *gin.Context, repository db.AppRepository, db *mongo.Database, rdb *redis.Client, performanceMode bool) {
+	ctxQueryMap, err := utils.ValidateParams(c, db)
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	filename := c.PostForm("filename")
+	if filename == "" {
+		utils.RespondError(c, http.StatusBadRequest, "filename is required")
+		return
+	}
+	env := viper.GetViper()
+	if utils.IsDisallowedExtension(filename, env) {
+		utils.RespondError(c, http.StatusBadRequest, fmt.Sprintf("%s: %s", utils.ErrFileTypeDisallowed, filename))
+		return
+	}
+
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationWrite)
+	defer cancel()
+
+	link, s3Key, extension := utils.BuildS3Object(ctxQueryMap, filename, env)
+	bucket := utils.ArtifactBucket(ctxQueryMap, env)
+	size, _, link, err := utils.StatS3Object(ctx, bucket, s3Key, link, ctxQueryMap, env)
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusNotFound, "uploaded object not found: "+s3Key)
+		return
+	}
+
+	// The ETag isn't a checksum of the file for multipart uploads, so the object is hashed like replaced artifacts
+	checksum, err := utils.ChecksumS3Object(ctx, bucket, s3Key, size, env)
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, "failed to compute the checksum of "+s3Key)
+		return
+	}
+	if expected := c.PostForm("checksum"); expected != "" && !strings.EqualFold(expected, checksum) {
+		utils.RespondError(c, http.StatusBadRequest, "checksum mismatch for "+s3Key)
+		return
+	}
+	ctxQueryMap["checksum"] = checksum
+
+	if !CheckAppDeprecation(c, repository, ctxQueryMap) {
+		return
+	}
+	if !CheckChannelFreeze(c, repository, ctxQueryMap) {
+		return
+	}
+	warning := SanitizeChangelog(ctxQueryMap)
+	if !CheckChangelogPolicy(c, repository, ctxQueryMap) {
+		return
+	}
+	if !ApplyChangelogTemplate(c, repository, ctxQueryMap) {
+		return
+	}
+	if !checkUploadQuotaBytes(c, repository, ctxQueryMap, size) {
+		return
+	}
+
+	result, err := repository.Upload(ctxQueryMap, link, extension, size, ctx)
+	if errors.Is(err, errAlreadyExists) {
+		utils.RespondError(c, http.StatusConflict, err.Error())
+		return
+	}
+	if errors.Is(err, errInvalidProperties) {
+		utils.RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if CachingEnabled(performanceMode, rdb) && utils.GetBoolParam(ctxQueryMap["publish"]) {
+		if err := InvalidateCache(ctx, ctxQueryMap, rdb, extension); err != nil {
+			logrus.Error("Error invalidating cache:", err)
+		}
+	}
+
+	appData, ok := result.(model.SpecificApp)
+	if !ok {
+		utils.RespondError(c, http.StatusInternalServerError, "Invalid result type")
+		return
+	}
+	legacy := gin.H{"uploadResult.Uploaded": appData.ID.Hex()}
+	response := utils.ResultResponse(utils.ActionCreated, "version", VersionEntity(repository, appData.ID, ctx), legacy)
+	if warning != "" {
+		response["warning"] = warning
+	}
+	c.JSON(http.StatusOK, response)
+	NotifyRelease(repository, appData.ID, utils.ReleaseEventUpload, nil, nil)
+}
@@ -0,0 +1,165 @@
+package create
+
+import (
+	"encoding/json"
+	"faynoSync/server/utils"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// progressInterval is how often the progress of the uploads to the bucket is sent
+const progressInterval = 500 * time.Millisecond
+
+// WantsUploadProgress reports whether the client asked for progress events instead of a single JSON response
+func WantsUploadProgress(c *gin.Context) bool {
+	return strings.Contains(c.GetHeader("Accept"), "text/event-stream")
+}
+
+// uploadProgress streams the progress of the uploads of a request as Server-Sent Events. The response of the
+// handler is captured and sent as the last event, the status of the stream itself is always 200.
+type uploadProgress struct {
+	c        *gin.Context
+	writer   gin.ResponseWriter
+	response *capturedResponse
+
+	mu      sync.Mutex
+	uploads []*utils.TrackedUpload
+	sent    map[uint64]utils.UploadStatus
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// startUploadProgress sends the headers of the event stream and reports the uploads the request starts until finish
+func startUploadProgress(c *gin.Context) *uploadProgress {
+	p := &uploadProgress{
+		c:        c,
+		writer:   c.Writer,
+		response: &capturedResponse{ResponseWriter: c.Writer},
+		sent:     map[uint64]utils.UploadStatus{},
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	p.writer.WriteHeader(http.StatusOK)
+	p.writer.WriteHeaderNow()
+	p.writer.Flush()
+
+	c.Writer = p.response
+	utils.ObserveUploads(c, func(upload *utils.TrackedUpload) {
+		p.mu.Lock()
+		p.uploads = append(p.uploads, upload)
+		p.mu.Unlock()
+	})
+
+	done := c.Request.Context().Done()
+	go func() {
+		defer close(p.stopped)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-done:
+				return
+			case <-ticker.C:
+				p.sendProgress()
+			}
+		}
+	}()
+	return p
+}
+
+// finish sends the last progress of every upload and the response of the handler as the result event
+func (p *uploadProgress) finish() {
+	close(p.stop)
+	<-p.stopped
+	p.sendProgress()
+
+	result := gin.H{"status": p.response.Status()}
+	if len(p.response.body) > 0 {
+		result["body"] = json.RawMessage(p.response.body)
+	}
+	p.send("result", result)
+	p.c.Writer = p.writer
+}
+
+// sendProgress sends a progress event for every upload that transferred bytes or finished since the last one
+func (p *uploadProgress) sendProgress() {
+	p.mu.Lock()
+	uploads := append([]*utils.TrackedUpload(nil), p.uploads...)
+	p.mu.Unlock()
+
+	for _, upload := range uploads {
+		status := upload.Status()
+		if sent, ok := p.sent[status.ID]; ok && sent.BytesTransferred == status.BytesTransferred && sent.Status == status.Status {
+			continue
+		}
+		p.sent[status.ID] = status
+		p.send("progress", status)
+	}
+}
+
+func (p *uploadProgress) send(event string, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		logrus.Errorf("Failed to encode %s event: %v", event, err)
+		return
+	}
+	if _, err := fmt.Fprintf(p.writer, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		logrus.Debugf("Failed to send %s event: %v", event, err)
+		return
+	}
+	p.writer.Flush()
+}
+
+// capturedResponse keeps the response of a handler instead of writing it. Only the first response is kept,
+// utils.UploadToS3 responds to a failed upload before the handler does.
+type capturedResponse struct {
+	gin.ResponseWriter
+	status int
+	body   []byte
+}
+
+func (w *capturedResponse) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *capturedResponse) WriteHeaderNow() {}
+
+func (w *capturedResponse) Write(data []byte) (int, error) {
+	if w.body == nil {
+		w.body = append([]byte{}, data...)
+	}
+	return len(data), nil
+}
+
+func (w *capturedResponse) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *capturedResponse) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *capturedResponse) Size() int {
+	return len(w.body)
+}
+
+func (w *capturedResponse) Written() bool {
+	return w.status != 0 || w.body != nil
+}
@@ -2,12 +2,18 @@ package create
 
 import (
 	"context"
+	"errors"
+	"faynoSync/memorycache"
 	db "faynoSync/mongod"
+	"faynoSync/redisdb"
 	"faynoSync/server/model"
 	"faynoSync/server/utils"
+	"faynoSync/tracing"
 	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
-	"time"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
@@ -16,13 +22,47 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func InvalidateCache(ctx context.Context, params map[string]interface{}, rdb *redis.Client) error {
+// The db parameters of UploadApp and CompleteUpload shadow the package name
+var (
+	errAlreadyExists     = db.ErrAlreadyExists
+	errInvalidProperties = db.ErrInvalidProperties
+)
+
+// CachingEnabled reports whether responses are cached in Redis or in memory
+func CachingEnabled(performanceMode bool, rdb *redis.Client) bool {
+	return (performanceMode && rdb != nil) || memorycache.Enabled()
+}
 
-	appName, _ := params["app_name"].(string)
-	channel, _ := params["channel"].(string)
+// InvalidateCache removes the cached responses affected by an upload to params.
+// With the uploaded packages, cached responses scoped to other packages are kept.
+func InvalidateCache(ctx context.Context, params map[string]interface{}, rdb *redis.Client, packages ...string) error {
+	for _, pattern := range utils.CacheInvalidationPatterns(params, packages...) {
+		if err := InvalidateCachePattern(ctx, pattern, rdb); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InvalidateCachePattern removes the cached responses matching pattern from Redis and the in-memory cache
+func InvalidateCachePattern(ctx context.Context, pattern string, rdb *redis.Client) error {
+	removed := 0
+	defer func() { utils.RecordCacheInvalidation(pattern, removed) }()
+
+	if memoryCache := memorycache.Default(); memoryCache != nil {
+		removed = memoryCache.DeletePattern(pattern)
+		logrus.Debugf("Invalidated %d in-memory keys matching %s.", removed, pattern)
+		// Other instances keep their own in-memory caches
+		if broadcast := redisdb.DefaultInvalidationBroadcast(); broadcast != nil {
+			if err := broadcast.Publish(ctx, pattern); err != nil {
+				logrus.Errorf("Failed to broadcast invalidation of %s: %v", pattern, err)
+			}
+		}
+	}
+	if rdb == nil {
+		return nil
+	}
 
-	pattern := fmt.Sprintf("app_name=%s&version=*&channel=%s&platform=*&arch=*",
-		appName, channel)
 	logrus.Debugf("Redis pattern %s will be invalidated.", pattern)
 
 	keys, err := rdb.Keys(ctx, pattern).Result()
@@ -39,113 +79,289 @@ func InvalidateCache(ctx context.Context, params map[string]interface{}, rdb *re
 		logrus.Debugf("Invalidating key: %s", key)
 		if err := rdb.Del(ctx, key).Err(); err != nil {
 			logrus.Errorf("Failed to invalidate key: %s, error: %v", key, err)
+			continue
 		}
+		removed++
 	}
 
 	return nil
 }
 
+// CheckUploadQuota rejects the request with 507 when the files would exceed the app quota.
+// It returns false if the response was already written.
+func CheckUploadQuota(c *gin.Context, repository db.AppRepository, ctxQueryMap map[string]interface{}, files []*multipart.FileHeader) bool {
+	var uploadBytes int64
+	for _, file := range files {
+		uploadBytes += file.Size
+	}
+	return checkUploadQuotaBytes(c, repository, ctxQueryMap, uploadBytes)
+}
+
+func checkUploadQuotaBytes(c *gin.Context, repository db.AppRepository, ctxQueryMap map[string]interface{}, uploadBytes int64) bool {
+	env := viper.GetViper()
+	maxBytes := env.GetInt64("UPLOAD_QUOTA_MAX_BYTES")
+	maxVersions := env.GetInt64("UPLOAD_QUOTA_MAX_VERSIONS")
+	if maxBytes <= 0 && maxVersions <= 0 {
+		return true
+	}
+
+	usage, err := repository.CheckUploadQuota(ctxQueryMap["app_name"].(string), ctxQueryMap["version"].(string), uploadBytes, maxBytes, maxVersions, c.Request.Context())
+	if errors.Is(err, db.ErrQuotaExceeded) {
+		logrus.Warnf("Upload quota exceeded for app %s: %+v", usage.AppName, usage)
+		utils.RespondErrorDetails(c, http.StatusInsufficientStorage, err.Error(), gin.H{
+			"usage":              usage,
+			"max_storage_bytes":  maxBytes,
+			"max_versions_count": maxVersions,
+			"upload_bytes":       uploadBytes,
+		})
+		return false
+	} else if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	return true
+}
+
+// Release assets are classified by the form field they're sent in: "file" holds the artifacts,
+// "signature" files are stored next to them and "notes" files are used as the changelog.
+const (
+	fileFieldArtifact  = "file"
+	fileFieldSignature = "signature"
+	fileFieldNotes     = "notes"
+
+	maxReleaseNotesBytes = 64 << 10
+)
+
+// readReleaseNotes returns the content of the release notes files
+func readReleaseNotes(files []*multipart.FileHeader) (string, []string, error) {
+	var notes []string
+	var names []string
+	for _, file := range files {
+		if file.Size > maxReleaseNotesBytes {
+			return "", nil, fmt.Errorf("release notes %s exceed %d bytes", file.Filename, maxReleaseNotesBytes)
+		}
+		reader, err := file.Open()
+		if err != nil {
+			return "", nil, err
+		}
+		content, err := io.ReadAll(io.LimitReader(reader, maxReleaseNotesBytes))
+		reader.Close()
+		if err != nil {
+			return "", nil, err
+		}
+		notes = append(notes, strings.TrimSpace(string(content)))
+		names = append(names, file.Filename)
+	}
+	return strings.Join(notes, "\n\n"), names, nil
+}
+
+// UploadToS3 uploads a file of an upload request to the bucket.
+// It's a variable so tests can simulate failed uploads.
+var UploadToS3 = utils.UploadToS3
+
+// removeUploads deletes the files uploaded by a request that failed before the version was stored
+func removeUploads(ctx context.Context, links []string, ctxQueryMap map[string]interface{}) {
+	ctx, cancel := utils.WithTimeout(context.WithoutCancel(ctx), utils.OperationDelete)
+	defer cancel()
+	for _, link := range links {
+		if err := utils.RemoveFromS3(ctx, link, ctxQueryMap, viper.GetViper()); err != nil {
+			logrus.Errorf("Error deleting uploaded file %s: %v", link, err)
+		}
+	}
+}
+
+// VerifyArtifact checks that an uploaded object can be found in the bucket.
+// It's a variable so tests can simulate objects missing after the upload.
+var VerifyArtifact = func(ctx context.Context, bucket, s3Key, link string, ctxQueryMap map[string]interface{}) error {
+	_, _, _, err := utils.StatS3Object(ctx, bucket, s3Key, link, ctxQueryMap, viper.GetViper())
+	return err
+}
+
+// VerifyUploads checks the artifacts uploaded for a version in the bucket, a published version must always be
+// downloadable. When any of them is missing the version is stored as unpublished, also when the artifacts were
+// added to a published version, and the returned warning names the missing objects.
+func VerifyUploads(ctx context.Context, ctxQueryMap map[string]interface{}, files []*multipart.FileHeader, links []string) string {
+	missing := verifyArtifacts(ctx, ctxQueryMap, files, links)
+	if len(missing) == 0 {
+		return ""
+	}
+	ctxQueryMap["publish"] = false
+	ctxQueryMap["unverified"] = true
+	warning := fmt.Sprintf("version stored as unpublished, artifacts could not be verified: %s", strings.Join(missing, ", "))
+	logrus.Warn(warning)
+	return warning
+}
+
+// verifyArtifacts returns the keys of the uploaded objects that can't be found in the bucket
+func verifyArtifacts(ctx context.Context, ctxQueryMap map[string]interface{}, files []*multipart.FileHeader, links []string) []string {
+	var missing []string
+	bucket := utils.ArtifactBucket(ctxQueryMap, viper.GetViper())
+	for i, file := range files {
+		_, s3Key, _ := utils.BuildS3Object(ctxQueryMap, file.Filename, viper.GetViper())
+		if err := VerifyArtifact(ctx, bucket, s3Key, links[i], ctxQueryMap); err != nil {
+			logrus.Errorf("Verification of uploaded artifact %s failed: %v", s3Key, err)
+			missing = append(missing, s3Key)
+		}
+	}
+	return missing
+}
+
 func UploadApp(c *gin.Context, repository db.AppRepository, db *mongo.Database, rdb *redis.Client, performanceMode bool) {
 	// Debug received request (make sense for using only on localhost)
 	// utils.DumpRequest(c)
 
 	ctxQueryMap, err := utils.ValidateParams(c, db)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.RespondError(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	form, err := c.MultipartForm()
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "multipart form data is required",
-		})
+		utils.RespondError(c, http.StatusBadRequest, "multipart form data is required")
+		return
+	}
+
+	files := form.File[fileFieldArtifact] // Assuming the field name is "file" not "files"
+	signatures := form.File[fileFieldSignature]
+
+	notes, noteNames, err := readReleaseNotes(form.File[fileFieldNotes])
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, err.Error())
 		return
 	}
+	// The changelog sent in data wins over the release notes files
+	if notes != "" && utils.GetStringValue(ctxQueryMap, "changelog") == "" {
+		ctxQueryMap["changelog"] = notes
+	}
+	changelogWarning := SanitizeChangelog(ctxQueryMap)
 
-	files := form.File["file"] // Assuming the field name is "file" not "files"
+	ctx, span := tracing.StartSpan(c.Request.Context(), "UploadApp", ctxQueryMap)
+	defer span.End()
+	c.Request = c.Request.WithContext(ctx)
+
+	if !CheckAppDeprecation(c, repository, ctxQueryMap) {
+		return
+	}
+	if !CheckChannelFreeze(c, repository, ctxQueryMap) {
+		return
+	}
+	if !CheckChangelogPolicy(c, repository, ctxQueryMap) {
+		return
+	}
+	if !ApplyChangelogTemplate(c, repository, ctxQueryMap) {
+		return
+	}
+	if !CheckFileTypes(c, files) || !CheckFileTypes(c, signatures) {
+		return
+	}
+	if !CheckUploadQuota(c, repository, ctxQueryMap, files) {
+		return
+	}
+	// Clients asking for an event stream see the files transferred to the bucket, the response is the last event
+	if WantsUploadProgress(c) {
+		progress := startUploadProgress(c)
+		defer progress.finish()
+	}
 
 	var links []string
 	var extensions []string
 	for _, file := range files {
-		link, ext, err := utils.UploadToS3(ctxQueryMap, file, c, viper.GetViper())
+		link, ext, err := UploadToS3(ctxQueryMap, file, c, viper.GetViper())
 		if err != nil {
 			logrus.Error(err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to upload file to S3"})
+			removeUploads(c.Request.Context(), links, ctxQueryMap)
+			utils.RespondError(c, http.StatusInternalServerError, "failed to upload file to S3")
 			return
 		}
 		links = append(links, link)
 		extensions = append(extensions, ext)
 	}
+	var signatureLinks []string
+	for _, file := range signatures {
+		link, _, err := UploadToS3(ctxQueryMap, file, c, viper.GetViper())
+		if err != nil {
+			logrus.Error(err)
+			// The version isn't stored, the files uploaded for it would be left behind
+			removeUploads(c.Request.Context(), append(links, signatureLinks...), ctxQueryMap)
+			utils.RespondError(c, http.StatusInternalServerError, "failed to upload file to S3")
+			return
+		}
+		signatureLinks = append(signatureLinks, link)
+	}
+
+	var warnings []string
+	if changelogWarning != "" {
+		warnings = append(warnings, changelogWarning)
+	}
+	if warning := VerifyUploads(c.Request.Context(), ctxQueryMap, files, links); warning != "" {
+		warnings = append(warnings, warning)
+	}
+
 	var results []interface{}
 	for i, link := range links {
-		result, err := repository.Upload(ctxQueryMap, link, extensions[i], c.Request.Context())
+		result, err := repository.Upload(ctxQueryMap, link, extensions[i], files[i].Size, c.Request.Context())
 		if err != nil {
 			logrus.Error(err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			tracing.RecordError(span, err)
+			if errors.Is(err, errAlreadyExists) {
+				utils.RespondError(c, http.StatusConflict, err.Error())
+				return
+			}
+			if errors.Is(err, errInvalidProperties) {
+				utils.RespondError(c, http.StatusBadRequest, err.Error())
+				return
+			}
+			utils.RespondError(c, http.StatusInternalServerError, err.Error())
 			return
 		}
 		results = append(results, result)
 	}
 
-	if performanceMode && rdb != nil {
+	if CachingEnabled(performanceMode, rdb) {
 
 		publish := utils.GetBoolParam(ctxQueryMap["publish"])
 
-		logrus.Debugf("Uploaded app has publish: %t, invalidation of redis cache is starting.", publish)
+		logrus.Debugf("Uploaded app has publish: %t, invalidation of cache is starting.", publish)
 
-		if publish {
-			if err := InvalidateCache(c.Request.Context(), ctxQueryMap, rdb); err != nil {
+		// An unverified artifact unpublishes the version it was added to
+		if publish || utils.GetBoolParam(ctxQueryMap["unverified"]) {
+			if err := InvalidateCache(c.Request.Context(), ctxQueryMap, rdb, extensions...); err != nil {
 				logrus.Error("Error invalidating cache:", err)
 			}
 		}
 	}
 
 	if len(results) == 0 {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "no results found. Please check your files."})
+		utils.RespondError(c, http.StatusInternalServerError, "no results found. Please check your files.")
 		return
 	}
 
 	if appData, ok := results[0].(model.SpecificApp); ok {
-		c.JSON(http.StatusOK, gin.H{"uploadResult.Uploaded": appData.ID.Hex()})
-		artifacts := utils.ExtractArtifactLinks(results)
-		changelog := utils.ExtractChangelog(results)
-
-		go func() {
-			if viper.GetBool("SLACK_ENABLE") {
-				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-				defer cancel()
-
-				humanReadableData, err := repository.FetchAppByID(appData.ID, ctx)
-				if err != nil || len(humanReadableData) == 0 {
-					logrus.Error("Error fetching human-readable data for Slack notification: ", err)
-					return
-				}
-
-				slackData := humanReadableData[0]
-
-				var platforms, arches, pkgs []string
-				for _, artifact := range slackData.Artifacts {
-					platforms = append(platforms, artifact.Platform)
-					arches = append(arches, artifact.Arch)
-					pkgs = append(pkgs, artifact.Package)
-				}
-				utils.SendSlackNotification(
-					slackData.AppName,
-					slackData.Channel,
-					slackData.Version,
-					platforms,
-					arches,
-					artifacts,
-					changelog,
-					pkgs,
-					viper.GetViper(),
-					slackData.Published,
-					slackData.Critical,
-				)
+		if len(signatureLinks) > 0 {
+			if err := repository.AddSignatures(appData.ID, signatureLinks, c.Request.Context()); err != nil {
+				logrus.Error(err)
+				utils.RespondError(c, http.StatusInternalServerError, err.Error())
+				return
 			}
-		}()
+		}
+
+		legacy := gin.H{"uploadResult.Uploaded": appData.ID.Hex()}
+		response := utils.ResultResponse(utils.ActionCreated, "version", VersionEntity(repository, appData.ID, c.Request.Context()), legacy)
+		if len(warnings) > 0 {
+			response["warning"] = strings.Join(warnings, "; ")
+		}
+		if len(signatures) > 0 || len(noteNames) > 0 {
+			response["files"] = gin.H{
+				"artifacts":  links,
+				"signatures": signatureLinks,
+				"notes":      noteNames,
+			}
+		}
+		c.JSON(http.StatusOK, response)
+		NotifyRelease(repository, appData.ID, utils.ReleaseEventUpload, utils.ExtractArtifactLinks(results), utils.ExtractChangelog(results))
 	} else {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid result type"})
+		utils.RespondError(c, http.StatusInternalServerError, "Invalid result type")
 	}
 }
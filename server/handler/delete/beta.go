@@ -0,0 +1,34 @@
+package delete
+
+import (
+	db "faynoSync/mongod"
+	"faynoSync/server/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func RevokeBetaToken(c *gin.Context, repository db.AppRepository) {
+	if !utils.RequireAdmin(c, "revoke beta tokens") {
+		return
+	}
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationDelete)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(c.Query("id"))
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "invalid id format")
+		return
+	}
+
+	result, err := repository.RevokeBetaToken(objID, ctx)
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"revokeBetaTokenResult.Revoked": result})
+}
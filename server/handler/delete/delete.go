@@ -3,12 +3,13 @@ package delete
 import (
 	"context"
 	db "faynoSync/mongod"
+	"faynoSync/server/handler/create"
 	"faynoSync/server/utils"
+	"fmt"
 	"net/http"
-	"strings"
-	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -18,55 +19,121 @@ import (
 
 func DeleteSpecificVersionOfApp(c *gin.Context, repository db.AppRepository) {
 	env := viper.GetViper()
-	ctx, ctxErr := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	ctx, ctxErr := utils.WithTimeout(c.Request.Context(), utils.OperationDelete)
 	defer ctxErr()
 
 	// Convert string to ObjectID
 	objID, err := primitive.ObjectIDFromHex(c.Query("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if create.PreviewRequested(c) {
+		create.RespondVersionImpact(c, repository, objID, ctx)
 		return
 	}
 
+	entity := create.VersionEntity(repository, objID, ctx)
+
 	//request on repository
 	links, result, err := repository.DeleteSpecificVersionOfApp(objID, ctx)
 	if err != nil {
 		logrus.Error(err)
 	}
 
+	var ctxQuery map[string]interface{}
+	if entity != nil {
+		ctxQuery = map[string]interface{}{"app_name": entity.AppName, "version": entity.Version}
+	}
 	for _, link := range links {
-		subLink := strings.TrimPrefix(link, env.GetString("S3_ENDPOINT"))
-		utils.DeleteFromS3(subLink, c, viper.GetViper())
+		utils.DeleteFromS3(link, ctxQuery, c, env)
 	}
-	c.JSON(http.StatusOK, gin.H{"deleteSpecificAppResult.DeletedCount": result})
+	legacy := gin.H{"deleteSpecificAppResult.DeletedCount": result}
+	c.JSON(http.StatusOK, utils.ResultResponse(utils.ActionDeleted, "version", entity, legacy))
 }
 
 func DeleteApp(c *gin.Context, repository db.AppRepository) {
-	deleteEntity(c, repository, "app")
+	deleteEntity(c, repository, "app", nil, false)
 }
 
-func DeleteChannel(c *gin.Context, repository db.AppRepository) {
-	deleteEntity(c, repository, "channel")
+func DeleteChannel(c *gin.Context, repository db.AppRepository, rdb *redis.Client, performanceMode bool) {
+	deleteEntity(c, repository, "channel", rdb, performanceMode)
 }
 
-func DeleteArch(c *gin.Context, repository db.AppRepository) {
-	deleteEntity(c, repository, "arch")
+func DeleteArch(c *gin.Context, repository db.AppRepository, rdb *redis.Client, performanceMode bool) {
+	deleteEntity(c, repository, "arch", rdb, performanceMode)
 }
 
-func DeletePlatform(c *gin.Context, repository db.AppRepository) {
-	deleteEntity(c, repository, "platform")
+func DeletePlatform(c *gin.Context, repository db.AppRepository, rdb *redis.Client, performanceMode bool) {
+	deleteEntity(c, repository, "platform", rdb, performanceMode)
 }
 
-func deleteEntity(c *gin.Context, repository db.AppRepository, itemType string) {
-	ctx, ctxErr := context.WithTimeout(c.Request.Context(), 30*time.Second)
+// checkDependentVersions refuses to delete a channel, platform or arch that is still used by versions.
+// With force=true and DELETE_CASCADE enabled the dependent artifacts are deleted instead.
+// The cached responses of the apps that lost versions or artifacts are invalidated.
+// It returns false if the response was already written.
+func checkDependentVersions(c *gin.Context, repository db.AppRepository, itemType string, id primitive.ObjectID, rdb *redis.Client, performanceMode bool, ctx context.Context) bool {
+	dependents, err := repository.CountDependentVersions(itemType, id, ctx)
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, "failed to check versions using "+itemType)
+		return false
+	}
+	if dependents == 0 {
+		return true
+	}
+
+	force := utils.GetBoolParam(c.Query("force"))
+	if !force || !viper.GetBool("DELETE_CASCADE") {
+		msg := fmt.Sprintf("%s is used by %d versions", itemType, dependents)
+		if force {
+			msg += ", set DELETE_CASCADE to delete them"
+		}
+		utils.RespondErrorDetails(c, http.StatusConflict, msg, gin.H{"dependent_versions": dependents})
+		return false
+	}
+
+	links, appNames, err := repository.DeleteDependentVersions(itemType, id, ctx)
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, "failed to delete versions using "+itemType)
+		return false
+	}
+	logrus.Infof("Deleted %d artifacts using %s %s", len(links), itemType, id.Hex())
+	for _, link := range links {
+		if err := utils.RemoveFromS3(ctx, link, nil, viper.GetViper()); err != nil {
+			logrus.Errorf("Error deleting artifact %s: %v", link, err)
+		}
+	}
+
+	if create.CachingEnabled(performanceMode, rdb) {
+		for _, appName := range appNames {
+			cacheParams := map[string]interface{}{"app_name": appName, "channel": "*"}
+			if err := create.InvalidateCache(ctx, cacheParams, rdb); err != nil {
+				logrus.Error("Error invalidating cache:", err)
+			}
+		}
+	}
+	return true
+}
+
+func deleteEntity(c *gin.Context, repository db.AppRepository, itemType string, rdb *redis.Client, performanceMode bool) {
+	ctx, ctxErr := utils.WithTimeout(c.Request.Context(), utils.OperationDelete)
 	defer ctxErr()
 
 	// Convert string to ObjectID
 	objID, err := primitive.ObjectIDFromHex(c.Query("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if itemType != "app" && !checkDependentVersions(c, repository, itemType, objID, rdb, performanceMode, ctx) {
 		return
 	}
+
+	// The response returns the entity as it was before it was deleted
+	entity := create.ItemEntity(repository, itemType, objID, ctx)
+
 	var result interface{}
 	switch itemType {
 	case "channel":
@@ -78,17 +145,21 @@ func deleteEntity(c *gin.Context, repository db.AppRepository, itemType string)
 	case "app":
 		result, err = repository.DeleteApp(objID, ctx)
 	default:
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item type"})
+		utils.RespondError(c, http.StatusBadRequest, "Invalid item type")
 		return
 	}
 	if err != nil {
 		logrus.Error(err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete " + itemType})
+		utils.RespondError(c, http.StatusInternalServerError, "failed to delete "+itemType)
 		return
 	}
+	if itemType != "app" {
+		utils.RefreshStrictCatalog(ctx)
+	}
 	var tag language.Tag
 	titleCase := cases.Title(tag)
 
 	capitalizedItemType := titleCase.String(itemType)
-	c.JSON(http.StatusOK, gin.H{"delete" + capitalizedItemType + "Result.DeletedCount": result})
+	legacy := gin.H{"delete" + capitalizedItemType + "Result.DeletedCount": result}
+	c.JSON(http.StatusOK, utils.ResultResponse(utils.ActionDeleted, itemType, entity, legacy))
 }
@@ -0,0 +1,37 @@
+package delete
+
+import (
+	db "faynoSync/mongod"
+	"faynoSync/server/handler/create"
+	"faynoSync/server/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func DeleteFlag(c *gin.Context, repository db.AppRepository, rdb *redis.Client, performanceMode bool) {
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationDelete)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(c.Query("id"))
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "invalid id format")
+		return
+	}
+	if !create.RequireFlagInScope(c, repository, objID, ctx) {
+		return
+	}
+
+	result, err := repository.DeleteFlag(objID, ctx)
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, "failed to delete flag")
+		return
+	}
+	create.InvalidateFlagsCache(c, rdb, performanceMode)
+
+	c.JSON(http.StatusOK, gin.H{"deleteFlagResult.DeletedCount": result})
+}
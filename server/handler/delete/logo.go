@@ -0,0 +1,43 @@
+package delete
+
+import (
+	"errors"
+	db "faynoSync/mongod"
+	"faynoSync/server/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// DeleteAppLogo removes the logo of an app from the app and the bucket
+func DeleteAppLogo(c *gin.Context, repository db.AppRepository) {
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationDelete)
+	defer cancel()
+
+	appName := c.Query("app_name")
+	if !utils.IsValidAppName(appName) || appName == "" {
+		utils.RespondError(c, http.StatusBadRequest, "invalid app_name parameter")
+		return
+	}
+
+	previous, err := repository.SetAppLogo(appName, "", ctx)
+	if errors.Is(err, db.ErrAppNotFound) {
+		utils.RespondError(c, http.StatusNotFound, "app not found")
+		return
+	}
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if previous != "" {
+		env := viper.GetViper()
+		if err := utils.RemoveFromS3(ctx, previous, map[string]interface{}{"app_name": appName}, env); err != nil {
+			logrus.Errorf("Error deleting logo %s: %v", previous, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deleteAppLogoResult.Deleted": previous != ""})
+}
@@ -0,0 +1,106 @@
+package delete
+
+import (
+	"errors"
+	db "faynoSync/mongod"
+	"faynoSync/server/handler/create"
+	"faynoSync/server/utils"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// DeleteVersionRange deletes the versions of an app older than before_version with their files.
+// Critical versions are kept unless include_critical is set, dry_run only lists what would be deleted.
+func DeleteVersionRange(c *gin.Context, repository db.AppRepository, rdb *redis.Client, performanceMode bool) {
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationDelete)
+	defer cancel()
+
+	r := db.VersionRange{
+		AppName:       c.Query("app_name"),
+		Channel:       c.Query("channel"),
+		Platform:      c.Query("platform"),
+		Arch:          c.Query("arch"),
+		BeforeVersion: c.Query("before_version"),
+	}
+	if r.AppName == "" || r.BeforeVersion == "" {
+		utils.RespondError(c, http.StatusBadRequest, "app_name and before_version are required")
+		return
+	}
+	switch {
+	case !utils.IsValidAppName(r.AppName):
+		utils.RespondError(c, http.StatusBadRequest, "invalid app_name parameter")
+		return
+	case !utils.IsValidVersion(r.BeforeVersion):
+		utils.RespondError(c, http.StatusBadRequest, "invalid before_version parameter")
+		return
+	case !utils.IsValidChannelName(r.Channel):
+		utils.RespondError(c, http.StatusBadRequest, "invalid channel parameter")
+		return
+	case !utils.IsValidPlatformName(r.Platform):
+		utils.RespondError(c, http.StatusBadRequest, "invalid platform parameter")
+		return
+	case !utils.IsValidArchName(r.Arch):
+		utils.RespondError(c, http.StatusBadRequest, "invalid arch parameter")
+		return
+	}
+	if scope, scoped := utils.ScopedApps(c); scoped && !slices.Contains(scope, r.AppName) {
+		utils.RespondError(c, http.StatusForbidden, "app is outside the scope of the token")
+		return
+	}
+
+	var dryRun bool
+	for param, target := range map[string]*bool{"include_critical": &r.IncludeCritical, "dry_run": &dryRun} {
+		if value := c.Query(param); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				utils.RespondError(c, http.StatusBadRequest, "invalid "+param+" parameter")
+				return
+			}
+			*target = parsed
+		}
+	}
+
+	result, err := repository.DeleteVersionRange(r, dryRun, ctx)
+	switch {
+	case errors.Is(err, db.ErrAppMetaNotFound), errors.Is(err, db.ErrChannelNotFound):
+		utils.RespondError(c, http.StatusNotFound, err.Error())
+		return
+	case err != nil:
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, "failed to delete versions")
+		return
+	}
+
+	if !dryRun && len(result.Versions) > 0 {
+		logrus.Infof("Deleted %d versions and %d artifacts of app %s older than %s", result.DeletedCount, result.DeletedArtifacts, r.AppName, r.BeforeVersion)
+		for _, link := range result.Links {
+			if err := utils.RemoveFromS3(ctx, link, map[string]interface{}{"app_name": r.AppName}, viper.GetViper()); err != nil {
+				logrus.Errorf("Error deleting artifact %s: %v", link, err)
+			}
+		}
+
+		if create.CachingEnabled(performanceMode, rdb) {
+			cacheChannel := r.Channel
+			if cacheChannel == "" {
+				cacheChannel = "*"
+			}
+			cacheParams := map[string]interface{}{"app_name": r.AppName, "channel": cacheChannel}
+			if err := create.InvalidateCache(ctx, cacheParams, rdb); err != nil {
+				logrus.Error("Error invalidating cache:", err)
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"deleteVersionRangeResult.DeletedCount": result.DeletedCount,
+		"deleted_artifacts":                     result.DeletedArtifacts,
+		"versions":                              result.Versions,
+		"dry_run":                               dryRun,
+	})
+}
@@ -0,0 +1,75 @@
+package delete
+
+import (
+	"errors"
+	db "faynoSync/mongod"
+	"faynoSync/server/handler/create"
+	"faynoSync/server/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// DeleteAppVersions deletes all versions of an app, or of one of its channels, with their files.
+// The app and its channels, platforms and archs are kept. The caller must repeat the app name in confirm.
+func DeleteAppVersions(c *gin.Context, repository db.AppRepository, rdb *redis.Client, performanceMode bool) {
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationDelete)
+	defer cancel()
+
+	if !utils.RequireAdmin(c, "delete all versions of an app") {
+		return
+	}
+
+	appName := c.Query("app_name")
+	channel := c.Query("channel")
+	if appName == "" {
+		utils.RespondError(c, http.StatusBadRequest, "app_name is required")
+		return
+	}
+	if !utils.IsValidAppName(appName) {
+		utils.RespondError(c, http.StatusBadRequest, "invalid app_name parameter")
+		return
+	}
+	if !utils.IsValidChannelName(channel) {
+		utils.RespondError(c, http.StatusBadRequest, "invalid channel parameter")
+		return
+	}
+	if c.Query("confirm") != appName {
+		utils.RespondError(c, http.StatusBadRequest, "set confirm to the app name to delete its versions")
+		return
+	}
+
+	links, result, err := repository.DeleteAppVersions(appName, channel, ctx)
+	switch {
+	case errors.Is(err, db.ErrAppMetaNotFound), errors.Is(err, db.ErrChannelNotFound):
+		utils.RespondError(c, http.StatusNotFound, err.Error())
+		return
+	case err != nil:
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, "failed to delete versions")
+		return
+	}
+
+	logrus.Infof("Deleted %d versions and %d files of app %s", result, len(links), appName)
+	for _, link := range links {
+		if err := utils.RemoveFromS3(ctx, link, map[string]interface{}{"app_name": appName}, viper.GetViper()); err != nil {
+			logrus.Errorf("Error deleting artifact %s: %v", link, err)
+		}
+	}
+
+	if create.CachingEnabled(performanceMode, rdb) {
+		cacheChannel := channel
+		if cacheChannel == "" {
+			cacheChannel = "*"
+		}
+		cacheParams := map[string]interface{}{"app_name": appName, "channel": cacheChannel}
+		if err := create.InvalidateCache(ctx, cacheParams, rdb); err != nil {
+			logrus.Error("Error invalidating cache:", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deleteAppVersionsResult.DeletedCount": result})
+}
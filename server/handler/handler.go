@@ -26,7 +26,12 @@ type AppHandler interface {
 	UpdateSpecificApp(*gin.Context)
 	HealthCheck(*gin.Context)
 	FindLatestVersion(*gin.Context)
+	RateLimit(*gin.Context)
 	FetchLatestVersionOfApp(*gin.Context)
+	FetchLatestVersionsOfApp(*gin.Context)
+	LatestDownloads(*gin.Context)
+	AvailableVersions(*gin.Context)
+	ProxyDownload(*gin.Context)
 	Login(*gin.Context)
 	CreateChannel(*gin.Context)
 	ListChannels(*gin.Context)
@@ -35,12 +40,65 @@ type AppHandler interface {
 	CreateArch(*gin.Context)
 	ListArchs(*gin.Context)
 	CreateApp(*gin.Context)
+	BulkCreate(*gin.Context)
+	CloneApp(*gin.Context)
+	ExportApp(*gin.Context)
+	UploadsStatus(*gin.Context)
+	SendTestNotification(*gin.Context)
+	ImportApp(*gin.Context)
+	PresignUpload(*gin.Context)
+	CompleteUpload(*gin.Context)
 	ListApps(*gin.Context)
 	SignUp(*gin.Context)
 	UpdateApp(*gin.Context)
+	UpdateAppLogo(*gin.Context)
+	DeleteAppLogo(*gin.Context)
+	DeprecateApp(*gin.Context)
+	UndeprecateApp(*gin.Context)
+	SetMissingTarget(*gin.Context)
+	ResetMissingTarget(*gin.Context)
+	SetRequiredTargets(*gin.Context)
+	ResetRequiredTargets(*gin.Context)
+	SetChangelogTemplate(*gin.Context)
+	ResetChangelogTemplate(*gin.Context)
+	SetRateLimit(*gin.Context)
+	ResetRateLimit(*gin.Context)
 	UpdateChannel(*gin.Context)
 	UpdatePlatform(*gin.Context)
 	UpdateArch(*gin.Context)
+	VersionExists(*gin.Context)
+	CompareChangelogs(*gin.Context)
+	UpdateChannelRetention(*gin.Context)
+	UpdateChannelGate(*gin.Context)
+	UpdateChannelFreeze(*gin.Context)
+	UpdateChannelMaintenance(*gin.Context)
+	UpdateChannelChangelogPolicy(*gin.Context)
+	CreateBetaToken(*gin.Context)
+	ListBetaTokens(*gin.Context)
+	RevokeBetaToken(*gin.Context)
+	PinLatestVersion(*gin.Context)
+	UnpinLatestVersion(*gin.Context)
+	YankVersion(*gin.Context)
+	NextBuildNumber(*gin.Context)
+	UnyankVersion(*gin.Context)
+	CreateFlag(*gin.Context)
+	ListFlags(*gin.Context)
+	UpdateFlag(*gin.Context)
+	DeleteFlag(*gin.Context)
+	GetFlags(*gin.Context)
+	DeleteAppVersions(*gin.Context)
+	DeleteVersionRange(*gin.Context)
+	MoveArtifact(*gin.Context)
+	ReplaceArtifact(*gin.Context)
+	BackfillChecksums(*gin.Context)
+	ChecksumBackfillStatus(*gin.Context)
+	GetUploadSchema(*gin.Context)
+	ServerVersion(*gin.Context)
+	CacheStats(*gin.Context)
+	StatsSummary(*gin.Context)
+	Activity(*gin.Context)
+	Metrics(*gin.Context)
+	QueryStats(*gin.Context)
 }
 
 type appHandler struct {
@@ -65,11 +123,46 @@ func (ch *appHandler) FindLatestVersion(c *gin.Context) {
 	info.FindLatestVersion(c, ch.repository, ch.database, ch.redisClient, ch.performanceMode)
 }
 
+func (ch *appHandler) RateLimit(c *gin.Context) {
+	// Call the RateLimit function from the info package
+	info.RateLimit(c, ch.repository, ch.redisClient, ch.performanceMode)
+}
+
 func (ch *appHandler) FetchLatestVersionOfApp(c *gin.Context) {
 	// Call the FetchLatestVersionOfApp function from the info package
 	info.FetchLatestVersionOfApp(c, ch.repository, ch.redisClient, ch.performanceMode)
 }
 
+func (ch *appHandler) FetchLatestVersionsOfApp(c *gin.Context) {
+	// Call the FetchLatestVersionsOfApp function from the info package
+	info.FetchLatestVersionsOfApp(c, ch.repository, ch.redisClient, ch.performanceMode)
+}
+
+func (ch *appHandler) LatestDownloads(c *gin.Context) {
+	// Call the LatestDownloads function from the info package
+	info.LatestDownloads(c, ch.repository)
+}
+
+func (ch *appHandler) AvailableVersions(c *gin.Context) {
+	// Call the AvailableVersions function from the info package
+	info.AvailableVersions(c, ch.repository)
+}
+
+func (ch *appHandler) ProxyDownload(c *gin.Context) {
+	// Call the ProxyDownload function from the info package
+	info.ProxyDownload(c)
+}
+
+func (ch *appHandler) VersionExists(c *gin.Context) {
+	// Call the VersionExists function from the info package
+	info.VersionExists(c, ch.repository)
+}
+
+func (ch *appHandler) CompareChangelogs(c *gin.Context) {
+	// Call the CompareChangelogs function from the info package
+	info.CompareChangelogs(c, ch.repository)
+}
+
 func (ch *appHandler) GetAppByName(c *gin.Context) {
 	// Call the GetAppByName function from the catalog package
 	catalog.GetAppByName(c, ch.repository)
@@ -98,6 +191,7 @@ func (ch *appHandler) ListApps(c *gin.Context) {
 	// Call the ListApps function from the catalog package
 	catalog.ListApps(c, ch.repository)
 }
+
 func (ch *appHandler) CreateChannel(c *gin.Context) {
 	// Call the CreateChannel function from the create package
 	create.CreateChannel(c, ch.repository)
@@ -117,6 +211,46 @@ func (ch *appHandler) CreateApp(c *gin.Context) {
 	create.CreateApp(c, ch.repository)
 }
 
+func (ch *appHandler) BulkCreate(c *gin.Context) {
+	// Call the BulkCreate function from the create package
+	create.BulkCreate(c, ch.repository)
+}
+
+func (ch *appHandler) CloneApp(c *gin.Context) {
+	// Call the CloneApp function from the create package
+	create.CloneApp(c, ch.repository)
+}
+
+func (ch *appHandler) ExportApp(c *gin.Context) {
+	// Call the ExportApp function from the info package
+	info.ExportApp(c, ch.repository)
+}
+
+func (ch *appHandler) ImportApp(c *gin.Context) {
+	// Call the ImportApp function from the create package
+	create.ImportApp(c, ch.repository)
+}
+
+func (ch *appHandler) UploadsStatus(c *gin.Context) {
+	// Call the UploadsStatus function from the info package
+	info.UploadsStatus(c)
+}
+
+func (ch *appHandler) SendTestNotification(c *gin.Context) {
+	// Call the SendTestNotification function from the create package
+	create.SendTestNotification(c)
+}
+
+func (ch *appHandler) PresignUpload(c *gin.Context) {
+	// Call the PresignUpload function from the create package
+	create.PresignUpload(c, ch.repository, ch.database)
+}
+
+func (ch *appHandler) CompleteUpload(c *gin.Context) {
+	// Call the CompleteUpload function from the create package
+	create.CompleteUpload(c, ch.repository, ch.database, ch.redisClient, ch.performanceMode)
+}
+
 func (ch *appHandler) UploadApp(c *gin.Context) {
 	// Call the UploadApp function from the create package
 	create.UploadApp(c, ch.repository, ch.database, ch.redisClient, ch.performanceMode)
@@ -149,17 +283,17 @@ func (ch *appHandler) DeleteSpecificVersionOfApp(c *gin.Context) {
 
 func (ch *appHandler) DeleteChannel(c *gin.Context) {
 	// Call the DeleteChannel function from the delete package
-	delete.DeleteChannel(c, ch.repository)
+	delete.DeleteChannel(c, ch.repository, ch.redisClient, ch.performanceMode)
 }
 
 func (ch *appHandler) DeletePlatform(c *gin.Context) {
 	// Call the DeletePlatform function from the delete package
-	delete.DeletePlatform(c, ch.repository)
+	delete.DeletePlatform(c, ch.repository, ch.redisClient, ch.performanceMode)
 }
 
 func (ch *appHandler) DeleteArch(c *gin.Context) {
 	// Call the DeleteArch function from the delete package
-	delete.DeleteArch(c, ch.repository)
+	delete.DeleteArch(c, ch.repository, ch.redisClient, ch.performanceMode)
 }
 
 func (ch *appHandler) UpdateApp(c *gin.Context) {
@@ -167,11 +301,161 @@ func (ch *appHandler) UpdateApp(c *gin.Context) {
 	update.UpdateApp(c, ch.repository)
 }
 
+func (ch *appHandler) UpdateAppLogo(c *gin.Context) {
+	// Call the UpdateAppLogo function from the update package
+	update.UpdateAppLogo(c, ch.repository)
+}
+
+func (ch *appHandler) DeleteAppLogo(c *gin.Context) {
+	// Call the DeleteAppLogo function from the delete package
+	delete.DeleteAppLogo(c, ch.repository)
+}
+
+func (ch *appHandler) DeprecateApp(c *gin.Context) {
+	// Call the DeprecateApp function from the update package
+	update.DeprecateApp(c, ch.repository, ch.redisClient, ch.performanceMode)
+}
+
+func (ch *appHandler) UndeprecateApp(c *gin.Context) {
+	// Call the UndeprecateApp function from the update package
+	update.UndeprecateApp(c, ch.repository, ch.redisClient, ch.performanceMode)
+}
+
+func (ch *appHandler) SetMissingTarget(c *gin.Context) {
+	// Call the SetMissingTarget function from the update package
+	update.SetMissingTarget(c, ch.repository, ch.redisClient, ch.performanceMode)
+}
+
+func (ch *appHandler) ResetMissingTarget(c *gin.Context) {
+	// Call the ResetMissingTarget function from the update package
+	update.ResetMissingTarget(c, ch.repository, ch.redisClient, ch.performanceMode)
+}
+
+func (ch *appHandler) SetRequiredTargets(c *gin.Context) {
+	// Call the SetRequiredTargets function from the update package
+	update.SetRequiredTargets(c, ch.repository, ch.redisClient, ch.performanceMode)
+}
+
+func (ch *appHandler) ResetRequiredTargets(c *gin.Context) {
+	// Call the ResetRequiredTargets function from the update package
+	update.ResetRequiredTargets(c, ch.repository, ch.redisClient, ch.performanceMode)
+}
+
+func (ch *appHandler) SetChangelogTemplate(c *gin.Context) {
+	// Call the SetChangelogTemplate function from the update package
+	update.SetChangelogTemplate(c, ch.repository)
+}
+
+func (ch *appHandler) ResetChangelogTemplate(c *gin.Context) {
+	// Call the ResetChangelogTemplate function from the update package
+	update.ResetChangelogTemplate(c, ch.repository)
+}
+
+func (ch *appHandler) SetRateLimit(c *gin.Context) {
+	// Call the SetRateLimit function from the update package
+	update.SetRateLimit(c, ch.repository)
+}
+
+func (ch *appHandler) ResetRateLimit(c *gin.Context) {
+	// Call the ResetRateLimit function from the update package
+	update.ResetRateLimit(c, ch.repository)
+}
+
 func (ch *appHandler) UpdateChannel(c *gin.Context) {
 	// Call the UpdateChannel function from the create package
 	update.UpdateChannel(c, ch.repository)
 }
 
+func (ch *appHandler) UpdateChannelRetention(c *gin.Context) {
+	// Call the UpdateChannelRetention function from the update package
+	update.UpdateChannelRetention(c, ch.repository)
+}
+
+func (ch *appHandler) UpdateChannelGate(c *gin.Context) {
+	// Call the UpdateChannelGate function from the update package
+	update.UpdateChannelGate(c, ch.repository)
+}
+
+func (ch *appHandler) UpdateChannelFreeze(c *gin.Context) {
+	// Call the UpdateChannelFreeze function from the update package
+	update.UpdateChannelFreeze(c, ch.repository)
+}
+
+func (ch *appHandler) UpdateChannelMaintenance(c *gin.Context) {
+	// Call the UpdateChannelMaintenance function from the update package
+	update.UpdateChannelMaintenance(c, ch.repository)
+}
+
+func (ch *appHandler) UpdateChannelChangelogPolicy(c *gin.Context) {
+	// Call the UpdateChannelChangelogPolicy function from the update package
+	update.UpdateChannelChangelogPolicy(c, ch.repository)
+}
+
+func (ch *appHandler) CreateBetaToken(c *gin.Context) {
+	// Call the CreateBetaToken function from the create package
+	create.CreateBetaToken(c, ch.repository)
+}
+
+func (ch *appHandler) ListBetaTokens(c *gin.Context) {
+	// Call the ListBetaTokens function from the catalog package
+	catalog.ListBetaTokens(c, ch.repository)
+}
+
+func (ch *appHandler) RevokeBetaToken(c *gin.Context) {
+	// Call the RevokeBetaToken function from the delete package
+	delete.RevokeBetaToken(c, ch.repository)
+}
+
+func (ch *appHandler) PinLatestVersion(c *gin.Context) {
+	// Call the PinLatestVersion function from the update package
+	update.PinLatestVersion(c, ch.repository, ch.redisClient, ch.performanceMode)
+}
+
+func (ch *appHandler) UnpinLatestVersion(c *gin.Context) {
+	// Call the UnpinLatestVersion function from the update package
+	update.UnpinLatestVersion(c, ch.repository, ch.redisClient, ch.performanceMode)
+}
+
+func (ch *appHandler) YankVersion(c *gin.Context) {
+	// Call the YankVersion function from the update package
+	update.YankVersion(c, ch.repository, ch.redisClient, ch.performanceMode)
+}
+
+func (ch *appHandler) UnyankVersion(c *gin.Context) {
+	// Call the UnyankVersion function from the update package
+	update.UnyankVersion(c, ch.repository, ch.redisClient, ch.performanceMode)
+}
+
+func (ch *appHandler) NextBuildNumber(c *gin.Context) {
+	// Call the NextBuildNumber function from the create package
+	create.NextBuildNumber(c, ch.repository)
+}
+
+func (ch *appHandler) CreateFlag(c *gin.Context) {
+	// Call the CreateFlag function from the create package
+	create.CreateFlag(c, ch.repository, ch.redisClient, ch.performanceMode)
+}
+
+func (ch *appHandler) ListFlags(c *gin.Context) {
+	// Call the ListFlags function from the catalog package
+	catalog.ListFlags(c, ch.repository)
+}
+
+func (ch *appHandler) UpdateFlag(c *gin.Context) {
+	// Call the UpdateFlag function from the update package
+	update.UpdateFlag(c, ch.repository, ch.redisClient, ch.performanceMode)
+}
+
+func (ch *appHandler) DeleteFlag(c *gin.Context) {
+	// Call the DeleteFlag function from the delete package
+	delete.DeleteFlag(c, ch.repository, ch.redisClient, ch.performanceMode)
+}
+
+func (ch *appHandler) GetFlags(c *gin.Context) {
+	// Call the GetFlags function from the info package
+	info.GetFlags(c, ch.repository, ch.redisClient, ch.performanceMode)
+}
+
 func (ch *appHandler) UpdatePlatform(c *gin.Context) {
 	// Call the UpdatePlatform function from the create package
 	update.UpdatePlatform(c, ch.repository)
@@ -181,3 +465,68 @@ func (ch *appHandler) UpdateArch(c *gin.Context) {
 	// Call the UpdateArch function from the create package
 	update.UpdateArch(c, ch.repository)
 }
+
+func (ch *appHandler) DeleteAppVersions(c *gin.Context) {
+	// Call the DeleteAppVersions function from the delete package
+	delete.DeleteAppVersions(c, ch.repository, ch.redisClient, ch.performanceMode)
+}
+
+func (ch *appHandler) DeleteVersionRange(c *gin.Context) {
+	// Call the DeleteVersionRange function from the delete package
+	delete.DeleteVersionRange(c, ch.repository, ch.redisClient, ch.performanceMode)
+}
+
+func (ch *appHandler) MoveArtifact(c *gin.Context) {
+	// Call the MoveArtifact function from the update package
+	update.MoveArtifact(c, ch.repository, ch.database, ch.redisClient, ch.performanceMode)
+}
+
+func (ch *appHandler) ReplaceArtifact(c *gin.Context) {
+	// Call the ReplaceArtifact function from the update package
+	update.ReplaceArtifact(c, ch.repository, ch.redisClient, ch.performanceMode)
+}
+
+func (ch *appHandler) BackfillChecksums(c *gin.Context) {
+	// Call the BackfillChecksums function from the update package
+	update.BackfillChecksums(c, ch.repository)
+}
+
+func (ch *appHandler) ChecksumBackfillStatus(c *gin.Context) {
+	// Call the ChecksumBackfillStatus function from the info package
+	info.ChecksumBackfillStatus(c)
+}
+
+func (ch *appHandler) GetUploadSchema(c *gin.Context) {
+	// Call the GetUploadSchema function from the info package
+	info.GetUploadSchema(c)
+}
+
+func (ch *appHandler) ServerVersion(c *gin.Context) {
+	// Call the ServerVersion function from the info package
+	info.ServerVersion(c, ch.redisClient, ch.performanceMode)
+}
+
+func (ch *appHandler) CacheStats(c *gin.Context) {
+	// Call the CacheStats function from the info package
+	info.CacheStats(c, ch.redisClient, ch.performanceMode)
+}
+
+func (ch *appHandler) StatsSummary(c *gin.Context) {
+	// Call the StatsSummary function from the info package
+	info.StatsSummary(c, ch.repository, ch.redisClient, ch.performanceMode)
+}
+
+func (ch *appHandler) Activity(c *gin.Context) {
+	// Call the Activity function from the info package
+	info.Activity(c, ch.repository)
+}
+
+func (ch *appHandler) Metrics(c *gin.Context) {
+	// Call the Metrics function from the info package
+	info.Metrics(c, ch.redisClient, ch.performanceMode)
+}
+
+func (ch *appHandler) QueryStats(c *gin.Context) {
+	// Call the QueryStats function from the info package
+	info.QueryStats(c)
+}
@@ -0,0 +1,58 @@
+package info
+
+import (
+	db "faynoSync/mongod"
+	"faynoSync/server/utils"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// Page sizes of /activity
+const (
+	defaultActivityLimit = 50
+	maxActivityLimit     = 500
+)
+
+// Activity lists the most recently uploaded or updated versions across the catalog, newest first.
+// since (RFC 3339), actor and app_name narrow the feed, scoped tokens only see their apps.
+func Activity(c *gin.Context, repository db.AppRepository) {
+	limit, err := queryInt(c, "limit", defaultActivityLimit)
+	if err != nil || limit < 1 || limit > maxActivityLimit {
+		utils.RespondError(c, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxActivityLimit))
+		return
+	}
+	filter := db.ActivityFilter{Limit: limit, Actor: c.Query("actor"), AppName: c.Query("app_name")}
+	if since := c.Query("since"); since != "" {
+		if filter.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			utils.RespondError(c, http.StatusBadRequest, "since must be an RFC 3339 timestamp")
+			return
+		}
+	}
+	if filter.AppName != "" && !utils.IsValidAppName(filter.AppName) {
+		utils.RespondError(c, http.StatusBadRequest, "invalid app_name parameter")
+		return
+	}
+	if scope, scoped := utils.ScopedApps(c); scoped {
+		if filter.AppName != "" && !slices.Contains(scope, filter.AppName) {
+			utils.RespondError(c, http.StatusForbidden, "app is outside the scope of the token")
+			return
+		}
+		filter.Apps = scope
+	}
+
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationRead)
+	defer cancel()
+
+	activity, err := repository.RecentActivity(filter, ctx)
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, "failed to list the recent activity")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"limit": limit, "activity": activity})
+}
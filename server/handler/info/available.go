@@ -0,0 +1,93 @@
+package info
+
+import (
+	"errors"
+	db "faynoSync/mongod"
+	"faynoSync/server/utils"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Page sizes of /apps/available-versions
+const (
+	defaultAvailableVersionsLimit = 20
+	maxAvailableVersionsLimit     = 100
+)
+
+// AvailableVersions lists the published versions of an app in a channel that clients can pick to install,
+// newest first and paginated with limit and offset. Every version comes with its download links and changelog.
+func AvailableVersions(c *gin.Context, repository db.AppRepository) {
+	appName, channel := c.Query("app_name"), c.Query("channel")
+	if appName == "" || channel == "" {
+		utils.RespondError(c, http.StatusBadRequest, "Parameters 'app_name' and 'channel' are required")
+		return
+	}
+	platform, arch := c.Query("platform"), c.Query("arch")
+	if !utils.IsValidPlatformName(platform) || !utils.IsValidArchName(arch) {
+		utils.RespondError(c, http.StatusBadRequest, "invalid platform or arch parameter")
+		return
+	}
+	env := viper.GetViper()
+	cohort := utils.ClientCohort(c, env)
+	if cohort != "" && !utils.IsValidCohortName(cohort) {
+		utils.RespondError(c, http.StatusBadRequest, "invalid cohort parameter")
+		return
+	}
+	limit, err := queryInt(c, "limit", defaultAvailableVersionsLimit)
+	if err != nil || limit < 1 || limit > maxAvailableVersionsLimit {
+		utils.RespondError(c, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxAvailableVersionsLimit))
+		return
+	}
+	offset, err := queryInt(c, "offset", 0)
+	if err != nil || offset < 0 {
+		utils.RespondError(c, http.StatusBadRequest, "offset must be a non-negative number")
+		return
+	}
+	if !checkChannelAccess(c, repository, channel) {
+		return
+	}
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationRead)
+	defer cancel()
+
+	versions, total, err := repository.AvailableVersions(appName, channel, platform, arch, cohort, offset, limit, ctx)
+	if errors.Is(err, db.ErrAppNotFound) {
+		utils.RespondError(c, http.StatusNotFound, "app not found")
+		return
+	}
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	for i := range versions {
+		for j := range versions[i].Artifacts {
+			versions[i].Artifacts[j].URL = utils.PublicDownloadLink(versions[i].Artifacts[j].URL, env)
+		}
+		for j := range versions[i].Changelog {
+			versions[i].Changelog[j].Changes, versions[i].Changelog[j].Truncated = utils.TruncateChangelog(versions[i].Changelog[j].Changes, env)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"app_name": appName,
+		"channel":  channel,
+		"total":    total,
+		"limit":    limit,
+		"offset":   offset,
+		"versions": versions,
+	})
+}
+
+// queryInt parses an optional integer query parameter
+func queryInt(c *gin.Context, key string, fallback int64) (int64, error) {
+	value := c.Query(key)
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
@@ -0,0 +1,40 @@
+package info
+
+import (
+	"errors"
+	db "faynoSync/mongod"
+	"faynoSync/server/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// betaToken returns the beta token sent in the X-Beta-Token header or the beta_token parameter
+func betaToken(c *gin.Context) string {
+	if token := c.GetHeader("X-Beta-Token"); token != "" {
+		return token
+	}
+	return c.Query("beta_token")
+}
+
+// checkChannelAccess aborts the request when the channel is gated and no valid beta token was sent.
+// Without a token the channel is reported as not found, so its existence isn't revealed.
+func checkChannelAccess(c *gin.Context, repository db.AppRepository, channel string) bool {
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationRead)
+	defer cancel()
+
+	err := repository.CheckChannelAccess(channel, betaToken(c), ctx)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, db.ErrBetaTokenRequired):
+		utils.RespondError(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, db.ErrBetaTokenInvalid):
+		utils.RespondError(c, http.StatusForbidden, err.Error())
+	default:
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+	}
+	return false
+}
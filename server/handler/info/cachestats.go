@@ -0,0 +1,100 @@
+package info
+
+import (
+	"context"
+	"faynoSync/memorycache"
+	"faynoSync/server/utils"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+)
+
+// cachedKeys returns the keys of the cached responses from Redis in performance mode, or from the in-memory cache
+func cachedKeys(ctx context.Context, rdb *redis.Client, performanceMode bool) ([]string, error) {
+	if performanceMode && rdb != nil {
+		var keys []string
+		for _, pattern := range []string{"app_name=*", utils.FlagsCacheInvalidationPattern()} {
+			matched, err := rdb.Keys(ctx, pattern).Result()
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, matched...)
+		}
+		return keys, nil
+	}
+	if memoryCache := memorycache.Default(); memoryCache != nil {
+		return memoryCache.Keys(), nil
+	}
+	return nil, nil
+}
+
+// CacheStats returns the cache hits, misses and invalidations since the start, in total and per app.
+// Only admins can view them, the stats cover all apps.
+func CacheStats(c *gin.Context, rdb *redis.Client, performanceMode bool) {
+	if !utils.RequireAdmin(c, "view the cache stats") {
+		return
+	}
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationRead)
+	defer cancel()
+
+	keys, err := cachedKeys(ctx, rdb, performanceMode)
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	total, apps := utils.CacheStats(keys)
+
+	backend := "none"
+	if performanceMode && rdb != nil {
+		backend = "redis"
+	} else if memorycache.Enabled() {
+		backend = "memory"
+	}
+	response := gin.H{"backend": backend, "total": total, "apps": apps}
+	if backend == "redis" {
+		response["breaker"] = utils.RedisBreaker().State()
+		response["ttls"] = utils.CacheTTLs()
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Metrics exposes the cache counters in the Prometheus text format
+func Metrics(c *gin.Context, rdb *redis.Client, performanceMode bool) {
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationRead)
+	defer cancel()
+
+	keys, err := cachedKeys(ctx, rdb, performanceMode)
+	if err != nil {
+		// The counters are still useful without the number of keys
+		logrus.Error(err)
+	}
+	total, apps := utils.CacheStats(keys)
+
+	var b strings.Builder
+	metric := func(name, help, kind string, value func(utils.CacheCounters) string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		fmt.Fprintf(&b, "%s %s\n", name, value(total))
+		for _, app := range apps {
+			fmt.Fprintf(&b, "%s{app=%q} %s\n", name, app.AppName, value(app.CacheCounters))
+		}
+	}
+	metric("faynosync_cache_hits_total", "Responses served from the cache.", "counter",
+		func(s utils.CacheCounters) string { return fmt.Sprint(s.Hits) })
+	metric("faynosync_cache_misses_total", "Cache lookups that queried the database.", "counter",
+		func(s utils.CacheCounters) string { return fmt.Sprint(s.Misses) })
+	metric("faynosync_cache_invalidations_total", "Invalidations of cached responses.", "counter",
+		func(s utils.CacheCounters) string { return fmt.Sprint(s.Invalidations) })
+	metric("faynosync_cache_invalidated_keys_total", "Cached responses removed by invalidations.", "counter",
+		func(s utils.CacheCounters) string { return fmt.Sprint(s.InvalidatedKeys) })
+	metric("faynosync_cache_hit_ratio", "Share of cache lookups served from the cache.", "gauge",
+		func(s utils.CacheCounters) string { return fmt.Sprintf("%g", s.HitRatio) })
+	metric("faynosync_cache_keys", "Cached responses.", "gauge",
+		func(s utils.CacheCounters) string { return fmt.Sprint(s.Keys) })
+
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(b.String()))
+}
@@ -0,0 +1,34 @@
+package info
+
+import (
+	db "faynoSync/mongod"
+	"faynoSync/server/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// CompareChangelogs returns the changelog entries that exist only in one of two channels of an app,
+// e.g. to find release notes missing in stable before shipping
+func CompareChangelogs(c *gin.Context, repository db.AppRepository) {
+	appName := c.Query("app_name")
+	channelA := c.Query("channel_a")
+	channelB := c.Query("channel_b")
+	if appName == "" || channelA == "" || channelB == "" {
+		utils.RespondError(c, http.StatusBadRequest, "Parameters 'app_name', 'channel_a' and 'channel_b' are required")
+		return
+	}
+
+	ctx, ctxErr := utils.WithTimeout(c.Request.Context(), utils.OperationRead)
+	defer ctxErr()
+
+	diff, err := repository.CompareChangelogs(appName, channelA, channelB, ctx)
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"changelogDiff": diff})
+}
@@ -0,0 +1,17 @@
+package info
+
+import (
+	"faynoSync/server/scheduler"
+	"faynoSync/server/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ChecksumBackfillStatus returns the progress of the running or last checksum backfill of this instance
+func ChecksumBackfillStatus(c *gin.Context) {
+	if !utils.RequireAdmin(c, "view the checksum backfill") {
+		return
+	}
+	c.JSON(http.StatusOK, scheduler.ChecksumBackfillStatus())
+}
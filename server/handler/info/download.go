@@ -0,0 +1,65 @@
+package info
+
+import (
+	"errors"
+	"faynoSync/server/utils"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// ProxyDownload streams an artifact from the bucket to the client, for storage that can't presign links.
+// The link has to carry a signature issued by SAU for the object key and an expiry that hasn't passed.
+func ProxyDownload(c *gin.Context) {
+	env := viper.GetViper()
+	if !utils.SignedDownloadsEnabled(env) {
+		utils.RespondError(c, http.StatusNotFound, "signed downloads are disabled")
+		return
+	}
+
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if key == "" {
+		utils.RespondError(c, http.StatusBadRequest, "object key is required")
+		return
+	}
+	bucket := c.Query("bucket")
+	err := utils.VerifyDownloadSignature(bucket, key, c.Query("expires"), c.Query("signature"), time.Now(), env)
+	if errors.Is(err, utils.ErrDownloadLinkExpired) {
+		utils.RespondError(c, http.StatusGone, err.Error())
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusForbidden, err.Error())
+		return
+	}
+
+	if bucket == "" {
+		bucket = env.GetString("S3_BUCKET_NAME")
+	}
+	// No operation timeout, streaming a large artifact takes longer than any of them
+	object, size, contentType, err := utils.OpenS3Object(c.Request.Context(), bucket, key, env)
+	if utils.IsS3NotFound(err) {
+		utils.RespondError(c, http.StatusNotFound, "object not found")
+		return
+	}
+	if err != nil {
+		logrus.Errorf("Failed to open %s for download: %v", key, err)
+		utils.RespondError(c, http.StatusInternalServerError, "failed to read object from storage")
+		return
+	}
+	defer object.Close()
+
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.DataFromReader(http.StatusOK, size, contentType, object, map[string]string{
+		"Content-Disposition": fmt.Sprintf("attachment; filename=%q", path.Base(key)),
+		"Cache-Control":       "private, no-store",
+	})
+}
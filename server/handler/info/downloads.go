@@ -0,0 +1,65 @@
+package info
+
+import (
+	"errors"
+	db "faynoSync/mongod"
+	"faynoSync/server/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hashicorp/go-version"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// LatestDownloads returns the newest published artifact of every platform and arch of the app in the channel,
+// with public download links. It backs download pages, which list all platforms at once.
+func LatestDownloads(c *gin.Context, repository db.AppRepository) {
+	appName, channel := c.Query("app_name"), c.Query("channel")
+	if appName == "" || channel == "" {
+		utils.RespondError(c, http.StatusBadRequest, "Parameters 'app_name' and 'channel' are required")
+		return
+	}
+	if !checkChannelAccess(c, repository, channel) {
+		return
+	}
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationRead)
+	defer cancel()
+
+	downloads, err := repository.LatestDownloads(appName, channel, ctx)
+	if errors.Is(err, db.ErrAppNotFound) {
+		utils.RespondError(c, http.StatusNotFound, "app not found")
+		return
+	}
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(downloads) == 0 {
+		utils.RespondError(c, http.StatusNotFound, "No matching data found for the provided parameters")
+		return
+	}
+
+	// The downloads are sorted by platform, the version of the release is the newest of them
+	latest := downloads[0]
+	for i := range downloads {
+		downloads[i].URL = utils.PublicDownloadLink(downloads[i].URL, viper.GetViper())
+		if isNewerVersion(downloads[i].Version, latest.Version) {
+			latest = downloads[i]
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"app_name":   appName,
+		"channel":    channel,
+		"version":    latest.Version,
+		"updated_at": latest.UpdatedAt,
+		"downloads":  downloads,
+	})
+}
+
+func isNewerVersion(a, b string) bool {
+	versionA, errA := version.NewVersion(a)
+	versionB, errB := version.NewVersion(b)
+	return errA == nil && errB == nil && versionA.GreaterThan(versionB)
+}
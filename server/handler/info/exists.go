@@ -0,0 +1,46 @@
+package info
+
+import (
+	db "faynoSync/mongod"
+	"faynoSync/server/utils"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+func VersionExists(c *gin.Context, repository db.AppRepository) {
+	appName := c.Query("app_name")
+	version := strings.ReplaceAll(c.Query("version"), "-", ".")
+	if appName == "" || version == "" {
+		utils.RespondError(c, http.StatusBadRequest, "Parameters 'app_name' and 'version' are required")
+		return
+	}
+	if !utils.IsValidAppName(appName) {
+		utils.RespondError(c, http.StatusBadRequest, "invalid app_name parameter")
+		return
+	}
+	if !utils.IsValidVersion(version) {
+		utils.RespondError(c, http.StatusBadRequest, "invalid version parameter")
+		return
+	}
+	if !checkChannelAccess(c, repository, c.Query("channel")) {
+		return
+	}
+
+	ctx, ctxErr := utils.WithTimeout(c.Request.Context(), utils.OperationRead)
+	defer ctxErr()
+
+	// Unpublished versions are only visible to authenticated callers such as CI
+	publishedOnly := !utils.IsAuthenticated(c)
+
+	exists, err := repository.VersionExists(appName, version, c.Query("channel"), c.Query("platform"), c.Query("arch"), c.Query("package"), publishedOnly, ctx)
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"exists": exists})
+}
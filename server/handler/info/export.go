@@ -0,0 +1,70 @@
+package info
+
+import (
+	"errors"
+	db "faynoSync/mongod"
+	"faynoSync/server/utils"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// ExportApp returns the app, its versions and changelogs as a bundle that can be imported on another instance.
+// Every artifact carries its key in the bucket and its checksum, with include_binaries=true also its content.
+// Only admins can export, the bundle carries the keys of the artifacts in the bucket.
+func ExportApp(c *gin.Context, repository db.AppRepository) {
+	if !utils.RequireAdmin(c, "export apps") {
+		return
+	}
+	appName := c.Query("app_name")
+	if appName == "" {
+		utils.RespondError(c, http.StatusBadRequest, "Parameter 'app_name' is required")
+		return
+	}
+	includeBinaries := utils.GetBoolParam(c.Query("include_binaries"))
+
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationRead)
+	defer cancel()
+
+	bundle, err := repository.ExportApp(appName, ctx)
+	if errors.Is(err, db.ErrAppNotFound) {
+		utils.RespondError(c, http.StatusNotFound, "app not found")
+		return
+	}
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	env := viper.GetViper()
+	for i := range bundle.Versions {
+		version := &bundle.Versions[i]
+		for j := range version.Artifacts {
+			artifact := &version.Artifacts[j]
+			var bucket string
+			bucket, artifact.Key = utils.S3ObjectOfLink(artifact.Link, env)
+
+			if _, etag, _, err := utils.StatS3Object(ctx, bucket, artifact.Key, artifact.Link, map[string]interface{}{"app_name": bundle.AppName, "version": version.Version}, env); err != nil {
+				logrus.Warnf("Exporting %s without checksum: %v", artifact.Key, err)
+			} else {
+				artifact.Checksum = etag
+			}
+
+			if includeBinaries {
+				artifact.Content, err = utils.ReadS3Object(ctx, bucket, artifact.Key, env)
+				if err != nil {
+					logrus.Error(err)
+					utils.RespondError(c, http.StatusInternalServerError, "failed to read "+artifact.Key)
+					return
+				}
+			}
+		}
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", appName+".bundle.json"))
+	c.JSON(http.StatusOK, bundle)
+}
@@ -0,0 +1,57 @@
+package info
+
+import (
+	db "faynoSync/mongod"
+	"faynoSync/server/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+)
+
+// GetFlags returns the feature flags that apply to the client's app, channel and version
+func GetFlags(c *gin.Context, repository db.AppRepository, rdb *redis.Client, performanceMode bool) {
+	appName := c.Query("app_name")
+	channel := c.Query("channel")
+	clientVersion := c.Query("version")
+	if appName == "" {
+		utils.RespondError(c, http.StatusBadRequest, "Parameter 'app_name' is required")
+		return
+	}
+	if !utils.IsValidAppName(appName) || !utils.IsValidChannelName(channel) {
+		utils.RespondError(c, http.StatusBadRequest, "invalid app_name or channel parameter")
+		return
+	}
+	if clientVersion != "" && !utils.IsValidVersion(clientVersion) {
+		utils.RespondError(c, http.StatusBadRequest, "invalid version parameter")
+		return
+	}
+	if !checkChannelAccess(c, repository, channel) {
+		return
+	}
+
+	ctx, ctxErr := utils.WithTimeout(c.Request.Context(), utils.OperationRead)
+	defer ctxErr()
+
+	cacheKey := utils.FlagsCacheKey(appName, channel, clientVersion)
+	cachedData, ok, err := cachedResponse(ctx, rdb, performanceMode, cacheKey)
+	if cacheUnavailable(c, err) {
+		return
+	}
+	if ok {
+		c.JSON(http.StatusOK, cachedData)
+		return
+	}
+
+	flags, err := repository.ApplicableFlags(appName, channel, clientVersion, ctx)
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response := gin.H{"flags": flags}
+	cacheResponse(ctx, rdb, performanceMode, cacheKey, response)
+	c.JSON(http.StatusOK, response)
+}
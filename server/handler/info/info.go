@@ -2,12 +2,14 @@ package info
 
 import (
 	"context"
+	"faynoSync/server/utils"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
 	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
@@ -31,5 +33,23 @@ func HealthCheck(c *gin.Context, mongoClient *mongo.Client, redisClient *redis.C
 		}
 	}
 
+	// The deep check also reaches the notification backends when HEALTH_CHECK_NOTIFICATIONS is set.
+	// Updates are still served when one of them fails, the status is degraded. The errors are only logged,
+	// the health check is public.
+	env := viper.GetViper()
+	if c.Query("deep") == "true" && env.GetBool("HEALTH_CHECK_NOTIFICATIONS") {
+		status := "healthy"
+		notifications := gin.H{}
+		for backend, check := range utils.CheckNotificationBackends(ctx, env) {
+			if !check.OK {
+				logrus.Errorf("Notification backend %s check failed: %s", backend, check.Error)
+				status = "degraded"
+			}
+			notifications[backend] = check.OK
+		}
+		c.JSON(http.StatusOK, gin.H{"status": status, "notifications": notifications})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"status": "healthy"})
 }
@@ -3,69 +3,188 @@ package info
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"faynoSync/memorycache"
 	db "faynoSync/mongod"
+	"faynoSync/server/model"
 	"faynoSync/server/utils"
-	"fmt"
+	"faynoSync/tracing"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
 	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func CreateCacheKey(params map[string]interface{}) string {
-	return fmt.Sprintf("app_name=%s&version=%s&channel=%s&platform=%s&arch=%s",
-		params["app_name"], params["version"], params["channel"], params["platform"], params["arch"])
-}
+// The db parameter of FindLatestVersion shadows the package name
+var errVersionNewerThanLatest = db.ErrVersionNewerThanLatest
 
-func cacheResponse(ctx context.Context, rdb *redis.Client, cacheKey string, response gin.H) {
+func cacheResponse(ctx context.Context, rdb *redis.Client, performanceMode bool, cacheKey string, response gin.H) {
 	cachedData, err := json.Marshal(response)
 	if err != nil {
 		logrus.Error("Error marshalling response:", err)
 		return
 	}
-	err = rdb.Set(ctx, cacheKey, cachedData, time.Hour*24).Err()
+	if performanceMode && rdb != nil {
+		// Channels such as nightly can be cached for less time than stable with REDIS_CHANNEL_CACHE_TTLS
+		ttl := utils.ChannelCacheTTL(utils.CacheKeyChannel(cacheKey))
+		if redisSet(ctx, rdb, cacheKey, cachedData, ttl) {
+			logrus.Debugln("Successfully set data to cache:", cachedData)
+		}
+	} else if memoryCache := memorycache.Default(); memoryCache != nil {
+		memoryCache.Set(cacheKey, cachedData)
+		logrus.Debugln("Successfully set data to in-memory cache:", cacheKey)
+	}
+}
+
+// cachedResponse returns the cached response from Redis in performance mode, or from the in-memory cache when enabled.
+// The error is ErrCacheUnavailable when Redis failed or its circuit breaker is open.
+func cachedResponse(ctx context.Context, rdb *redis.Client, performanceMode bool, cacheKey string) (map[string]interface{}, bool, error) {
+	var data []byte
+	if performanceMode && rdb != nil {
+		cached, err := redisGet(ctx, rdb, cacheKey)
+		if err != nil {
+			utils.RecordCacheMiss(cacheKey)
+			if errors.Is(err, redis.Nil) {
+				err = nil
+			}
+			return nil, false, err
+		}
+		data = cached
+	} else if memoryCache := memorycache.Default(); memoryCache != nil {
+		cached, ok := memoryCache.Get(cacheKey)
+		if !ok {
+			utils.RecordCacheMiss(cacheKey)
+			return nil, false, nil
+		}
+		data = cached
+	} else {
+		return nil, false, nil
+	}
+
+	var cachedData map[string]interface{}
+	if json.Unmarshal(data, &cachedData) != nil {
+		utils.RecordCacheMiss(cacheKey)
+		return nil, false, nil
+	}
+	utils.RecordCacheHit(cacheKey)
+	return cachedData, true, nil
+}
+
+// redisGet reads key from Redis through its circuit breaker. A missing key is redis.Nil,
+// ErrCacheUnavailable means Redis failed or is bypassed while the breaker is open.
+func redisGet(ctx context.Context, rdb *redis.Client, key string) ([]byte, error) {
+	breaker := utils.RedisBreaker()
+	if !breaker.Allow() {
+		return nil, utils.ErrCacheUnavailable
+	}
+	data, err := rdb.Get(ctx, key).Bytes()
+	breaker.Record(err)
+	if err != nil && !errors.Is(err, redis.Nil) {
+		logrus.Error("Error getting data from Redis:", err)
+		return nil, utils.ErrCacheUnavailable
+	}
+	return data, err
+}
+
+// redisSet stores key in Redis through its circuit breaker, nothing is stored while the breaker is open
+func redisSet(ctx context.Context, rdb *redis.Client, key string, value []byte, ttl time.Duration) bool {
+	breaker := utils.RedisBreaker()
+	if !breaker.Allow() {
+		return false
+	}
+	err := rdb.Set(ctx, key, value, ttl).Err()
+	breaker.Record(err)
 	if err != nil {
 		logrus.Error("Error setting data to Redis:", err)
-	} else {
-		logrus.Debugln("Successfully set data to cache:", cachedData)
+		return false
 	}
+	return true
 }
 
-func FindLatestVersion(c *gin.Context, repository db.AppRepository, db *mongo.Database, rdb *redis.Client, performanceMode bool) {
-	validatedParams, err := utils.ValidateParamsLatest(c, db)
+// cacheUnavailable responds with 503 when Redis is unavailable and REDIS_FAILURE_MODE is closed.
+// In the default fail-open mode the request goes on without the cache and MongoDB answers it.
+func cacheUnavailable(c *gin.Context, err error) bool {
+	if !errors.Is(err, utils.ErrCacheUnavailable) {
+		return false
+	}
+	if utils.RedisFailureMode(viper.GetViper()) == utils.RedisFailClosed {
+		utils.RespondError(c, http.StatusServiceUnavailable, "the cache is unavailable, try again later")
+		return true
+	}
+	logrus.Debugln("Redis is unavailable, querying the database")
+	return false
+}
+
+func FindLatestVersion(c *gin.Context, repository db.AppRepository, database *mongo.Database, rdb *redis.Client, performanceMode bool) {
+	if !checkChannelAccess(c, repository, c.Query("channel")) {
+		return
+	}
+	validatedParams, err := utils.ValidateParamsLatest(c, database)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.RespondError(c, http.StatusBadRequest, err.Error())
 		return
 	}
 	logrus.Debugf("Validated parameters: %+v", validatedParams)
-	ctx, ctxErr := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	if !checkChannelMaintenance(c, repository, utils.GetStringValue(validatedParams, "channel")) {
+		return
+	}
+	ctx, span := tracing.StartSpan(c.Request.Context(), "FindLatestVersion", validatedParams)
+	defer span.End()
+	ctx, ctxErr := utils.WithTimeout(ctx, utils.OperationRead)
 	defer ctxErr()
 
-	cacheKey := CreateCacheKey(validatedParams)
+	cacheKey := utils.CreateCacheKey(validatedParams)
 	logrus.Debugf("Generated cache key: %s", cacheKey)
-	// Check Redis only if PERFORMANCE_MODE is true and Redis client is not nil
-	if performanceMode && rdb != nil {
-		cachedResponse, err := rdb.Get(ctx, cacheKey).Result()
-		if err == nil {
-			// If cache exists, return the cached response
-			var cachedData map[string]interface{}
-			if json.Unmarshal([]byte(cachedResponse), &cachedData) == nil {
-				logrus.Debugln("Return cached data: ", cachedData)
-				c.JSON(http.StatusOK, cachedData)
-				return
-			}
-		}
+	// Signed download links expire, responses carrying them aren't cached
+	cacheLinks := !utils.SignedDownloadsEnabled(viper.GetViper())
+	// Check Redis if PERFORMANCE_MODE is true, otherwise the in-memory cache if it's enabled
+	cachedData, ok, err := cachedResponse(ctx, rdb, performanceMode, cacheKey)
+	if cacheUnavailable(c, err) {
+		return
+	}
+	if ok && (cacheLinks || cachedData["ahead_of_latest"] == true) {
+		logrus.Debugln("Return cached data: ", cachedData)
+		c.JSON(http.StatusOK, cachedData)
+		return
 	}
 
 	// Request on repository
-	checkResult, err := repository.CheckLatestVersion(validatedParams["app_name"].(string), validatedParams["version"].(string), validatedParams["channel"].(string), validatedParams["platform"].(string), validatedParams["arch"].(string), ctx)
+	preferCritical := validatedParams["prefer_critical"] == "true"
+	step := validatedParams["step"] == "true"
+	checkResult, err := repository.CheckLatestVersion(db.CheckOptions{
+		AppName:        validatedParams["app_name"].(string),
+		Version:        validatedParams["version"].(string),
+		Channel:        validatedParams["channel"].(string),
+		Platform:       validatedParams["platform"].(string),
+		Arch:           validatedParams["arch"].(string),
+		Package:        validatedParams["package"].(string),
+		Cohort:         utils.GetStringValue(validatedParams, "cohort"),
+		PreferCritical: preferCritical,
+		Step:           step,
+	}, ctx)
+	deprecation, deprecationErr := repository.AppDeprecation(validatedParams["app_name"].(string), ctx)
+	if deprecationErr != nil {
+		// Clients still get their update without the deprecation
+		logrus.Error(deprecationErr)
+	}
+	if errors.Is(err, errVersionNewerThanLatest) {
+		// The client is ahead of what is released, e.g. it runs an unpublished build
+		logrus.Debug(err)
+		response := gin.H{"update_available": false, "ahead_of_latest": true}
+		addDeprecation(response, deprecation)
+		cacheResponse(ctx, rdb, performanceMode, cacheKey, response)
+		c.JSON(http.StatusOK, response)
+		return
+	}
 	if err != nil {
 		logrus.Error(err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.RespondError(c, http.StatusBadRequest, err.Error())
 		return
 	}
 	if !checkResult.Found {
@@ -74,6 +193,7 @@ func FindLatestVersion(c *gin.Context, repository db.AppRepository, db *mongo.Da
 		} else {
 			logrus.Infoln(checkResult)
 			response := gin.H{"update_available": false}
+			addDeprecation(response, deprecation)
 			for _, artifact := range checkResult.Artifacts {
 				var key string
 				if artifact.Package == "" {
@@ -82,11 +202,12 @@ func FindLatestVersion(c *gin.Context, repository db.AppRepository, db *mongo.Da
 					key = "update_url_" + strings.TrimPrefix(artifact.Package, ".")
 				}
 				if artifact.Link != "" && strings.Contains(artifact.Link, validatedParams["platform"].(string)) && strings.Contains(artifact.Link, validatedParams["arch"].(string)) {
-					response[key] = artifact.Link
+					response[key] = utils.DownloadURL(artifact.Link, viper.GetViper())
 				}
 			}
-			if performanceMode && rdb != nil {
-				cacheResponse(ctx, rdb, cacheKey, response)
+			addRecommendedPackage(response, checkResult.Artifacts, validatedParams)
+			if cacheLinks {
+				cacheResponse(ctx, rdb, performanceMode, cacheKey, response)
 			}
 			c.JSON(http.StatusOK, response)
 		}
@@ -95,6 +216,11 @@ func FindLatestVersion(c *gin.Context, repository db.AppRepository, db *mongo.Da
 	}
 	logrus.Debug("Check latest version response: ", checkResult)
 	response := gin.H{"update_available": true, "critical": checkResult.Critical}
+	addDeprecation(response, deprecation)
+	if checkResult.NewerAvailable {
+		// A critical version was returned although a newer non-critical one exists
+		response["newer_available"] = true
+	}
 
 	// Add update URLs to the response
 	for _, artifact := range checkResult.Artifacts {
@@ -106,9 +232,13 @@ func FindLatestVersion(c *gin.Context, repository db.AppRepository, db *mongo.Da
 		}
 		if artifact.Link != "" && strings.Contains(artifact.Link, validatedParams["platform"].(string)) && strings.Contains(artifact.Link, validatedParams["arch"].(string)) {
 			logrus.Debugf("Adding link for key %s: %s", key, artifact.Link)
-			response[key] = artifact.Link
+			response[key] = utils.DownloadURL(artifact.Link, viper.GetViper())
 		}
 	}
+	addRecommendedPackage(response, checkResult.Artifacts, validatedParams)
+	if len(checkResult.Properties) > 0 {
+		response["properties"] = checkResult.Properties
+	}
 	// Add changelog to the response last
 	if len(checkResult.Changelog) > 0 {
 		var changelogBuilder strings.Builder
@@ -120,20 +250,28 @@ func FindLatestVersion(c *gin.Context, repository db.AppRepository, db *mongo.Da
 		}
 		// Only add to response if there was any changelog to include
 		if changelogBuilder.Len() > 0 {
-			response["changelog"] = changelogBuilder.String()
+			changelog, truncated := utils.TruncateChangelog(changelogBuilder.String(), viper.GetViper())
+			response["changelog"] = changelog
+			if truncated {
+				response["changelog_truncated"] = true
+			}
 		}
 	}
-	if performanceMode && rdb != nil {
-		cacheResponse(ctx, rdb, cacheKey, response)
+	if cacheLinks {
+		cacheResponse(ctx, rdb, performanceMode, cacheKey, response)
 	}
 	c.JSON(http.StatusOK, response)
 }
 
 func FetchLatestVersionOfApp(c *gin.Context, repository db.AppRepository, rdb *redis.Client, performanceMode bool) {
 	if c.Query("app_name") == "" || c.Query("channel") == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Parameters 'app_name' and 'channel' are required",
-		})
+		utils.RespondError(c, http.StatusBadRequest, "Parameters 'app_name' and 'channel' are required")
+		return
+	}
+	if !checkChannelAccess(c, repository, c.Query("channel")) {
+		return
+	}
+	if !checkChannelMaintenance(c, repository, c.Query("channel")) {
 		return
 	}
 	params := map[string]interface{}{
@@ -141,30 +279,37 @@ func FetchLatestVersionOfApp(c *gin.Context, repository db.AppRepository, rdb *r
 		"channel":  c.Query("channel"),
 		"platform": c.Query("platform"),
 		"arch":     c.Query("arch"),
-		"package":  c.Query("package"),
+		"package":  strings.TrimPrefix(c.Query("package"), "."),
 	}
-	ctx, ctxErr := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	ctx, ctxErr := utils.WithTimeout(c.Request.Context(), utils.OperationRead)
 	defer ctxErr()
 
-	cacheKey := CreateCacheKey(params)
+	cacheKey := utils.CreateCacheKey(params)
 	logrus.Debugf("Generated cache key: %s", cacheKey)
 
-	if performanceMode && rdb != nil {
-		cachedResponse, err := rdb.Get(ctx, cacheKey).Result()
+	// Signed download links expire, responses carrying them aren't cached
+	cacheLinks := performanceMode && rdb != nil && !utils.SignedDownloadsEnabled(viper.GetViper())
+	if cacheLinks {
+		cachedResponse, err := redisGet(ctx, rdb, cacheKey)
+		if cacheUnavailable(c, err) {
+			return
+		}
 		if err == nil {
 			var cachedData map[string]interface{}
-			if json.Unmarshal([]byte(cachedResponse), &cachedData) == nil {
+			if json.Unmarshal(cachedResponse, &cachedData) == nil {
 				logrus.Debugln("Returning cached data: ", cachedData)
+				utils.RecordCacheHit(cacheKey)
 				c.JSON(http.StatusOK, cachedData)
 				return
 			}
 		}
+		utils.RecordCacheMiss(cacheKey)
 	}
 
-	checkResult, err := repository.FetchLatestVersionOfApp(params["app_name"].(string), params["channel"].(string), ctx)
+	checkResult, err := repository.FetchLatestVersionOfApp(params["app_name"].(string), params["channel"].(string), params["package"].(string), ctx)
 	if err != nil {
 		logrus.Error(err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
 		return
 	}
 
@@ -175,10 +320,79 @@ func FetchLatestVersionOfApp(c *gin.Context, repository db.AppRepository, rdb *r
 		logrus.Debugf("Fetched latest version response: %s", string(jsonData))
 	}
 
-	downloadUrls := make(map[string]map[string]map[string]map[string]map[string]string)
+	deprecation, err := repository.AppDeprecation(params["app_name"].(string), ctx)
+	if err != nil {
+		logrus.Error(err)
+	}
+	downloadUrls := latestDownloadURLs(checkResult, params, deprecation)
+
+	// The latest version exists but has no artifact for the requested platform and arch
+	if len(downloadUrls) == 0 && len(checkResult) > 0 && (params["platform"] != "" || params["arch"] != "") {
+		if policy := missingTargetPolicy(repository, params["app_name"].(string), ctx); policy != nil {
+			switch policy.Mode {
+			case model.MissingTargetNoUpdate:
+				c.JSON(http.StatusOK, gin.H{"update_available": false})
+				return
+			case model.MissingTargetFallback:
+				fallbackParams := make(map[string]interface{}, len(params))
+				for key, value := range params {
+					fallbackParams[key] = value
+				}
+				fallbackParams["platform"] = policy.FallbackPlatform
+				fallbackParams["arch"] = policy.FallbackArch
+				logrus.Debugf("Falling back to %s/%s for parameters: %v", policy.FallbackPlatform, policy.FallbackArch, params)
+				downloadUrls = latestDownloadURLs(checkResult, fallbackParams, deprecation)
+			}
+		}
+	}
+
+	if len(downloadUrls) == 0 {
+		logrus.Warnf("No results found for parameters: %v", params)
+		utils.RespondError(c, http.StatusNotFound, "No matching data found for the provided parameters")
+		return
+	}
+
+	urlCount, singleUrl := utils.CountUrls(downloadUrls)
+
+	if urlCount == 1 {
+		logrus.Debugf("Redirecting to the single download URL: %v", singleUrl)
+		c.Redirect(http.StatusFound, singleUrl)
+		return
+	}
+
+	logrus.Debugf("Generated download URLs: %v", downloadUrls)
+
+	c.JSON(http.StatusOK, downloadUrls)
+
+	if cacheLinks {
+		jsonResponse, _ := json.Marshal(downloadUrls)
+		redisSet(ctx, rdb, cacheKey, jsonResponse, 0)
+	}
+}
+
+// missingTargetPolicy returns the policy of the app for a missing platform/arch, or MISSING_TARGET_MODE
+// when the app has none. nil means 404.
+func missingTargetPolicy(repository db.AppRepository, appName string, ctx context.Context) *model.MissingTargetPolicy {
+	policy, err := repository.AppMissingTarget(appName, ctx)
+	if err != nil {
+		logrus.Error(err)
+	}
+	if policy != nil {
+		return policy
+	}
+	// MISSING_TARGET_MODE is validated at startup
+	policy, _ = utils.DefaultMissingTargetPolicy(viper.GetViper())
+	return policy
+}
+
+// latestDownloadURLs returns the download URLs of the artifacts of the latest version matching params,
+// nested by channel, platform, arch and package. The deprecation of the app is added to every package.
+func latestDownloadURLs(checkResult []*model.SpecificAppWithoutIDs, params map[string]interface{}, deprecation *model.AppDeprecation) map[string]map[string]map[string]map[string]map[string]interface{} {
+	downloadUrls := make(map[string]map[string]map[string]map[string]map[string]interface{})
 
 	if len(checkResult) > 0 {
 		latestApp := checkResult[0]
+		preferred := make(map[string]string)
 		for _, artifact := range latestApp.Artifacts {
 
 			if params["channel"] != "" && params["channel"] != latestApp.Channel {
@@ -201,43 +415,90 @@ func FetchLatestVersionOfApp(c *gin.Context, repository db.AppRepository, rdb *r
 			}
 
 			if _, exists := downloadUrls[latestApp.Channel]; !exists {
-				downloadUrls[latestApp.Channel] = make(map[string]map[string]map[string]map[string]string)
+				downloadUrls[latestApp.Channel] = make(map[string]map[string]map[string]map[string]interface{})
 			}
 
 			if _, exists := downloadUrls[latestApp.Channel][artifact.Platform]; !exists {
-				downloadUrls[latestApp.Channel][artifact.Platform] = make(map[string]map[string]map[string]string)
+				downloadUrls[latestApp.Channel][artifact.Platform] = make(map[string]map[string]map[string]interface{})
 			}
 
 			if _, exists := downloadUrls[latestApp.Channel][artifact.Platform][artifact.Arch]; !exists {
-				downloadUrls[latestApp.Channel][artifact.Platform][artifact.Arch] = make(map[string]map[string]string)
+				downloadUrls[latestApp.Channel][artifact.Platform][artifact.Arch] = make(map[string]map[string]interface{})
 			}
 
-			downloadUrls[latestApp.Channel][artifact.Platform][artifact.Arch][packageType] = map[string]string{
-				"url": artifact.Link,
+			packageInfo := map[string]interface{}{
+				"url": utils.DownloadURL(artifact.Link, viper.GetViper()),
+			}
+			if len(latestApp.Properties) > 0 {
+				packageInfo["properties"] = latestApp.Properties
+			}
+			if deprecation != nil {
+				packageInfo["deprecation"] = deprecation
+			}
+			downloadUrls[latestApp.Channel][artifact.Platform][artifact.Arch][packageType] = packageInfo
+			if artifact.Preferred {
+				preferred[artifact.Platform+"/"+artifact.Arch] = packageType
+			}
+		}
+		// Clients choosing between several packages of a platform and arch get the recommended one marked
+		for platform, archs := range downloadUrls[latestApp.Channel] {
+			for arch, packages := range archs {
+				if len(packages) < 2 {
+					continue
+				}
+				names := make([]string, 0, len(packages))
+				for name := range packages {
+					names = append(names, name)
+				}
+				packages[recommendedPackage(names, preferred[platform+"/"+arch])]["recommended"] = true
 			}
 		}
 	}
+	return downloadUrls
+}
 
-	if len(downloadUrls) == 0 {
-		logrus.Warnf("No results found for parameters: %v", params)
-		c.JSON(http.StatusNotFound, gin.H{"error": "No matching data found for the provided parameters"})
-		return
+// recommendedPackage returns the preferred package when it is among packages,
+// otherwise the first one alphabetically so the recommendation doesn't depend on the upload order
+func recommendedPackage(packages []string, preferred string) string {
+	sort.Strings(packages)
+	for _, pkg := range packages {
+		if pkg == preferred {
+			return pkg
+		}
 	}
+	return packages[0]
+}
 
-	urlCount, singleUrl := utils.CountUrls(downloadUrls)
+// addRecommendedPackage adds the recommended package to a checkVersion response offering several packages
+// for the platform and arch, as the package value of the update_url_<package> key
+func addRecommendedPackage(response gin.H, artifacts []db.Artifact, params map[string]interface{}) {
+	var packages []string
+	preferred := ""
+	for _, artifact := range artifacts {
+		if artifact.Link == "" || !strings.Contains(artifact.Link, params["platform"].(string)) || !strings.Contains(artifact.Link, params["arch"].(string)) {
+			continue
+		}
+		pkg := utils.CachePackage(artifact.Package)
+		packages = append(packages, pkg)
+		if artifact.Preferred {
+			preferred = pkg
+		}
+	}
+	if len(packages) > 1 {
+		response["recommended_package"] = recommendedPackage(packages, preferred)
+	}
+}
 
-	if urlCount == 1 {
-		logrus.Debugf("Redirecting to the single download URL: %v", singleUrl)
-		c.Redirect(http.StatusFound, singleUrl)
+// addDeprecation adds the deprecation of the app to a checkVersion response
+func addDeprecation(response gin.H, deprecation *model.AppDeprecation) {
+	if deprecation == nil {
 		return
 	}
-
-	logrus.Debugf("Generated download URLs: %v", downloadUrls)
-
-	c.JSON(http.StatusOK, downloadUrls)
-
-	if performanceMode && rdb != nil {
-		jsonResponse, _ := json.Marshal(downloadUrls)
-		rdb.Set(ctx, cacheKey, jsonResponse, 0)
+	response["deprecated"] = true
+	if deprecation.Message != "" {
+		response["deprecation_message"] = deprecation.Message
+	}
+	if deprecation.EOLDate != "" {
+		response["eol_date"] = deprecation.EOLDate
 	}
 }
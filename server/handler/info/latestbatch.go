@@ -0,0 +1,140 @@
+package info
+
+import (
+	"encoding/json"
+	db "faynoSync/mongod"
+	"faynoSync/server/model"
+	"faynoSync/server/utils"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// maxLatestTargets limits the platform/arch pairs of one batch request
+const maxLatestTargets = 32
+
+// parseLatestTargets splits targets such as linux/amd64,darwin/arm64 into platform/arch pairs, dropping duplicates
+func parseLatestTargets(targets string) ([][2]string, error) {
+	var pairs [][2]string
+	seen := map[string]bool{}
+	for _, target := range strings.Split(targets, ",") {
+		target = strings.TrimSpace(target)
+		if target == "" || seen[target] {
+			continue
+		}
+		platform, arch, ok := strings.Cut(target, "/")
+		if !ok || platform == "" || arch == "" {
+			return nil, fmt.Errorf("invalid target %q, expected platform/arch", target)
+		}
+		seen[target] = true
+		pairs = append(pairs, [2]string{platform, arch})
+	}
+	if len(pairs) == 0 {
+		return nil, fmt.Errorf("parameter 'targets' is required")
+	}
+	if len(pairs) > maxLatestTargets {
+		return nil, fmt.Errorf("at most %d targets can be requested at once", maxLatestTargets)
+	}
+	return pairs, nil
+}
+
+// FetchLatestVersionsOfApp returns the download URLs of the latest version of an app for several platform/arch
+// pairs at once, nested like /apps/latest. Every pair is cached under the key of the matching /apps/latest request,
+// the database is only queried when one of them isn't cached.
+func FetchLatestVersionsOfApp(c *gin.Context, repository db.AppRepository, rdb *redis.Client, performanceMode bool) {
+	if c.Query("app_name") == "" || c.Query("channel") == "" {
+		utils.RespondError(c, http.StatusBadRequest, "Parameters 'app_name' and 'channel' are required")
+		return
+	}
+	targets, err := parseLatestTargets(c.Query("targets"))
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !checkChannelAccess(c, repository, c.Query("channel")) {
+		return
+	}
+	if !checkChannelMaintenance(c, repository, c.Query("channel")) {
+		return
+	}
+	ctx, ctxErr := utils.WithTimeout(c.Request.Context(), utils.OperationRead)
+	defer ctxErr()
+
+	// Signed download links expire, responses carrying them aren't cached
+	cacheLinks := performanceMode && rdb != nil && !utils.SignedDownloadsEnabled(viper.GetViper())
+
+	downloadUrls := make(map[string]map[string]map[string]map[string]map[string]interface{})
+	var checkResult []*model.SpecificAppWithoutIDs
+	var deprecation *model.AppDeprecation
+	fetched := false
+	for _, target := range targets {
+		params := map[string]interface{}{
+			"app_name": c.Query("app_name"),
+			"channel":  c.Query("channel"),
+			"platform": target[0],
+			"arch":     target[1],
+			"package":  strings.TrimPrefix(c.Query("package"), "."),
+		}
+		cacheKey := utils.CreateCacheKey(params)
+
+		var targetUrls map[string]map[string]map[string]map[string]map[string]interface{}
+		if cacheLinks {
+			cached, err := redisGet(ctx, rdb, cacheKey)
+			if cacheUnavailable(c, err) {
+				return
+			}
+			if err == nil && json.Unmarshal(cached, &targetUrls) == nil {
+				utils.RecordCacheHit(cacheKey)
+			} else {
+				targetUrls = nil
+				utils.RecordCacheMiss(cacheKey)
+			}
+		}
+
+		if targetUrls == nil {
+			if !fetched {
+				checkResult, err = repository.FetchLatestVersionOfApp(params["app_name"].(string), params["channel"].(string), params["package"].(string), ctx)
+				if err != nil {
+					logrus.Error(err)
+					utils.RespondError(c, http.StatusInternalServerError, err.Error())
+					return
+				}
+				if deprecation, err = repository.AppDeprecation(params["app_name"].(string), ctx); err != nil {
+					logrus.Error(err)
+				}
+				fetched = true
+			}
+			targetUrls = latestDownloadURLs(checkResult, params, deprecation)
+			if cacheLinks && len(targetUrls) > 0 {
+				jsonResponse, _ := json.Marshal(targetUrls)
+				redisSet(ctx, rdb, cacheKey, jsonResponse, 0)
+			}
+		}
+
+		for channel, platforms := range targetUrls {
+			if _, exists := downloadUrls[channel]; !exists {
+				downloadUrls[channel] = make(map[string]map[string]map[string]map[string]interface{})
+			}
+			for platform, archs := range platforms {
+				if _, exists := downloadUrls[channel][platform]; !exists {
+					downloadUrls[channel][platform] = make(map[string]map[string]map[string]interface{})
+				}
+				for arch, packages := range archs {
+					downloadUrls[channel][platform][arch] = packages
+				}
+			}
+		}
+	}
+
+	if len(downloadUrls) == 0 {
+		logrus.Warnf("No results found for %s in %s for targets %v", c.Query("app_name"), c.Query("channel"), targets)
+		utils.RespondError(c, http.StatusNotFound, "No matching data found for the provided parameters")
+		return
+	}
+	c.JSON(http.StatusOK, downloadUrls)
+}
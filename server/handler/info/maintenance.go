@@ -0,0 +1,34 @@
+package info
+
+import (
+	db "faynoSync/mongod"
+	"faynoSync/server/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// checkChannelMaintenance responds that no update is available when the update checks of the channel are disabled.
+// It runs before the cache is read and the response isn't cached, the checks work again as soon as they're enabled.
+// A failed lookup doesn't stop the update check.
+func checkChannelMaintenance(c *gin.Context, repository db.AppRepository, channel string) bool {
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationRead)
+	defer cancel()
+
+	maintenance, err := repository.ChannelMaintenance(channel, ctx)
+	if err != nil {
+		logrus.Error(err)
+		return true
+	}
+	if maintenance == nil {
+		return true
+	}
+	logrus.Debugf("Update checks of channel %s are disabled: %s", channel, maintenance.Reason)
+	response := gin.H{"update_available": false, "maintenance": true}
+	if maintenance.Reason != "" {
+		response["maintenance_reason"] = maintenance.Reason
+	}
+	c.JSON(http.StatusOK, response)
+	return false
+}
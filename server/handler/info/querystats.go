@@ -0,0 +1,22 @@
+package info
+
+import (
+	db "faynoSync/mongod"
+	"faynoSync/server/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QueryStats returns the count and durations of the MongoDB commands per collection since the start.
+// Only admins can view them.
+func QueryStats(c *gin.Context) {
+	if !utils.RequireAdmin(c, "view the query stats") {
+		return
+	}
+	threshold := ""
+	if db.SlowQueryThreshold() > 0 {
+		threshold = db.SlowQueryThreshold().String()
+	}
+	c.JSON(http.StatusOK, gin.H{"slow_query_threshold": threshold, "queries": db.QueryStatistics()})
+}
@@ -0,0 +1,70 @@
+package info
+
+import (
+	"errors"
+	db "faynoSync/mongod"
+	"faynoSync/server/model"
+	"faynoSync/server/utils"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+)
+
+// RateLimit limits the requests to the public info endpoints for apps with a rate limit set by an admin.
+// Clients get their remaining quota in the X-RateLimit-* headers and 429 with Retry-After once it's used up.
+// Counters are kept in Redis in performance mode. The request goes on when the limit can't be read.
+func RateLimit(c *gin.Context, repository db.AppRepository, rdb *redis.Client, performanceMode bool) {
+	appName := c.Query("app_name")
+	if appName == "" || !utils.IsValidAppName(appName) {
+		c.Next()
+		return
+	}
+
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationRead)
+	defer cancel()
+
+	limiter := utils.AppRateLimiter()
+	limit, err := limiter.Limit(appName, func() (*model.AppRateLimit, error) {
+		return repository.AppRateLimit(appName, ctx)
+	})
+	if errors.Is(err, db.ErrAppNotFound) {
+		// Unknown apps aren't cached, so random names can't fill the cache of the limiter
+		c.Next()
+		return
+	}
+	if err != nil {
+		logrus.Errorf("Error getting rate limit of %s: %v", appName, err)
+		c.Next()
+		return
+	}
+	if limit == nil {
+		c.Next()
+		return
+	}
+
+	var counter *redis.Client
+	if performanceMode {
+		counter = rdb
+	}
+	status, err := limiter.Take(ctx, counter, appName, *limit)
+	if err != nil {
+		logrus.Errorf("Invalid rate limit of %s: %v", appName, err)
+		c.Next()
+		return
+	}
+	for name, value := range status.Headers() {
+		c.Header(name, value)
+	}
+	if !status.Allowed {
+		retryAfter := int64(time.Until(status.Reset).Seconds()) + 1
+		c.Header("Retry-After", strconv.FormatInt(retryAfter, 10))
+		utils.AbortError(c, http.StatusTooManyRequests, fmt.Sprintf("rate limit of %s exceeded, retry after %d seconds", appName, retryAfter))
+		return
+	}
+	c.Next()
+}
@@ -0,0 +1,13 @@
+package info
+
+import (
+	"faynoSync/server/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetUploadSchema returns the JSON schema of the upload "data" field for client-side validation
+func GetUploadSchema(c *gin.Context) {
+	c.JSON(http.StatusOK, utils.UpRequestSchema())
+}
@@ -0,0 +1,65 @@
+package info
+
+import (
+	"encoding/json"
+	db "faynoSync/mongod"
+	"faynoSync/server/model"
+	"faynoSync/server/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+const (
+	statsSummaryCacheKey = "stats:summary"
+	// statsSummaryCacheTTL is short, the summary isn't invalidated when apps change
+	statsSummaryCacheTTL = 30 * time.Second
+)
+
+// StatsSummary returns the number of apps, versions and artifacts, the storage they use, the counts per channel,
+// the newest release of every app and the usage of every app against the upload quota. In performance mode it is cached in Redis for a short while.
+func StatsSummary(c *gin.Context, repository db.AppRepository, rdb *redis.Client, performanceMode bool) {
+	if !utils.RequireAdmin(c, "view the catalog statistics") {
+		return
+	}
+
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationRead)
+	defer cancel()
+
+	if performanceMode && rdb != nil {
+		if cached, err := rdb.Get(ctx, statsSummaryCacheKey).Bytes(); err == nil {
+			var summary model.StatsSummary
+			if json.Unmarshal(cached, &summary) == nil {
+				c.JSON(http.StatusOK, withUploadQuota(summary))
+				return
+			}
+		}
+	}
+
+	summary, err := repository.StatsSummary(ctx)
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, "failed to compute the catalog statistics")
+		return
+	}
+
+	if performanceMode && rdb != nil {
+		if data, err := json.Marshal(summary); err == nil {
+			if err := rdb.Set(ctx, statsSummaryCacheKey, data, statsSummaryCacheTTL).Err(); err != nil {
+				logrus.Error("Error setting data to Redis:", err)
+			}
+		}
+	}
+	c.JSON(http.StatusOK, withUploadQuota(summary))
+}
+
+// withUploadQuota adds the configured upload quota to the summary, it isn't cached with it
+func withUploadQuota(summary model.StatsSummary) model.StatsSummary {
+	summary.MaxStorageBytes = viper.GetInt64("UPLOAD_QUOTA_MAX_BYTES")
+	summary.MaxVersionsCount = viper.GetInt64("UPLOAD_QUOTA_MAX_VERSIONS")
+	return summary
+}
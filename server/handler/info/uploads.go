@@ -0,0 +1,19 @@
+package info
+
+import (
+	"faynoSync/server/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UploadsStatus returns the uploads to the bucket in progress on this instance and the recently finished ones.
+// Presigned uploads go directly to the bucket and are not listed.
+// The uploads of all apps are listed, so only admins can view them.
+func UploadsStatus(c *gin.Context) {
+	if !utils.RequireAdmin(c, "view the uploads") {
+		return
+	}
+	active, recent := utils.DefaultUploadTracker().Snapshot()
+	c.JSON(http.StatusOK, gin.H{"active": active, "recent": recent})
+}
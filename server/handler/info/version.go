@@ -0,0 +1,58 @@
+package info
+
+import (
+	"faynoSync/memorycache"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/spf13/viper"
+)
+
+// Version and Commit identify the build. They are set with
+// -ldflags "-X faynoSync/server/handler/info.Version=v1.4.0 -X faynoSync/server/handler/info.Commit=<sha>"
+var (
+	Version = "dev"
+	Commit  = ""
+)
+
+var startedAt = time.Now()
+
+// buildCommit falls back to the revision recorded by the Go toolchain when Commit isn't set
+func buildCommit() string {
+	if Commit != "" {
+		return Commit
+	}
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range buildInfo.Settings {
+			if setting.Key == "vcs.revision" {
+				return setting.Value
+			}
+		}
+	}
+	return "unknown"
+}
+
+// ServerVersion returns the build and the enabled features, so clients can gate on server capabilities.
+// It's public, so it doesn't expose any configuration values.
+func ServerVersion(c *gin.Context, redisClient *redis.Client, performanceMode bool) {
+	env := viper.GetViper()
+	storageDriver := env.GetString("STORAGE_DRIVER")
+	c.JSON(http.StatusOK, gin.H{
+		"version":        Version,
+		"commit":         buildCommit(),
+		"go_version":     runtime.Version(),
+		"uptime_seconds": int64(time.Since(startedAt).Seconds()),
+		"features": gin.H{
+			"slack":        env.GetBool("SLACK_ENABLE"),
+			"email":        env.GetBool("EMAIL_ENABLE"),
+			"redis":        performanceMode && redisClient != nil,
+			"memory_cache": memorycache.Enabled(),
+			"presign":      storageDriver == "minio" || storageDriver == "aws",
+			"tls":          env.GetString("TLS_CERT_FILE") != "" || env.GetString("TLS_AUTOCERT_DOMAINS") != "",
+		},
+	})
+}
@@ -1,13 +1,12 @@
 package sign
 
 import (
-	"context"
 	"faynoSync/server/utils"
 	"net/http"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -19,11 +18,11 @@ func Login(c *gin.Context, database *mongo.Database) {
 	}
 
 	if err := c.BindJSON(&credentials); err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		utils.AbortError(c, http.StatusBadRequest, "invalid request body")
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationRead)
 	defer cancel()
 
 	// Check user credentials against the MongoDB "admins" collection
@@ -31,23 +30,45 @@ func Login(c *gin.Context, database *mongo.Database) {
 	var result bson.M
 	err := admins.FindOne(ctx, bson.M{"username": credentials.Username}).Decode(&result)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid username or password"})
+		utils.AbortError(c, http.StatusUnauthorized, "invalid username or password")
 		return
 	}
 
 	// Compare the hashed password
 	hashedPassword := result["password"].(string)
 	if err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(credentials.Password)); err != nil {
-		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid username or password"})
+		utils.AbortError(c, http.StatusUnauthorized, "invalid username or password")
 		return
 	}
 
+	// Users with an apps list are scoped to these apps, others are admins
+	var apps []string
+	if scope, ok := result["apps"].(primitive.A); ok {
+		apps = make([]string, 0, len(scope))
+		for _, app := range scope {
+			if name, ok := app.(string); ok {
+				apps = append(apps, name)
+			}
+		}
+	}
+
+	// The token carries the scope and the role, requests are authorized without looking the user up
+	identity := utils.Identity{Username: credentials.Username, Apps: apps}
+	identity.Role, _ = result["role"].(string)
+	if identity.Role == "" {
+		identity.Role = utils.DefaultRole(apps)
+	}
+
 	// Create JWT token
-	token, err := utils.GenerateJWT(credentials.Username)
+	token, err := utils.GenerateIdentityJWT(identity)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to create token"})
+		utils.AbortError(c, http.StatusInternalServerError, "failed to create token")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	response := gin.H{"token": token, "role": identity.Role}
+	if identity.Scoped() {
+		response["apps"] = identity.Apps
+	}
+	c.JSON(http.StatusOK, response)
 }
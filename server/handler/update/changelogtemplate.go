@@ -0,0 +1,65 @@
+package update
+
+import (
+	"encoding/json"
+	"errors"
+	db "faynoSync/mongod"
+	"faynoSync/server/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// SetChangelogTemplate sets the template the changelogs of an app's uploads and updates are wrapped in, e.g. to add
+// a header and a link to the full release notes. The template is checked before it is stored. Only admins can set it.
+func SetChangelogTemplate(c *gin.Context, repository db.AppRepository) {
+	jsonData := c.PostForm("data")
+	if jsonData == "" {
+		utils.RespondError(c, http.StatusBadRequest, "No JSON data provided")
+		return
+	}
+	var params struct {
+		AppName  string `json:"app_name"`
+		Template string `json:"template"`
+	}
+	if err := json.Unmarshal([]byte(jsonData), &params); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid JSON data")
+		return
+	}
+	if err := utils.ValidateChangelogTemplate(params.Template); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	setChangelogTemplate(c, repository, params.AppName, params.Template, "setChangelogTemplateResult.Updated")
+}
+
+// ResetChangelogTemplate removes the changelog template of an app, changelogs are stored as uploaded again
+func ResetChangelogTemplate(c *gin.Context, repository db.AppRepository) {
+	setChangelogTemplate(c, repository, c.Query("app_name"), "", "resetChangelogTemplateResult.Reset")
+}
+
+func setChangelogTemplate(c *gin.Context, repository db.AppRepository, appName, changelogTemplate, resultKey string) {
+	if !utils.RequireAdmin(c, "change the changelog template of an app") {
+		return
+	}
+	if appName == "" || !utils.IsValidAppName(appName) {
+		utils.RespondError(c, http.StatusBadRequest, "invalid app_name parameter")
+		return
+	}
+
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationWrite)
+	defer cancel()
+
+	err := repository.SetAppChangelogTemplate(appName, changelogTemplate, ctx)
+	if errors.Is(err, db.ErrAppNotFound) {
+		utils.RespondError(c, http.StatusNotFound, "app not found")
+		return
+	}
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{resultKey: true})
+}
@@ -0,0 +1,43 @@
+package update
+
+import (
+	"encoding/json"
+	db "faynoSync/mongod"
+	"faynoSync/server/scheduler"
+	"faynoSync/server/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// BackfillChecksums starts computing the checksums and sizes of artifacts uploaded before they were recorded.
+// The optional after of data continues a stopped run after the last version it processed.
+// It runs in the background, GET /maintenance/checksums reports the progress.
+func BackfillChecksums(c *gin.Context, repository db.AppRepository) {
+	if !utils.RequireAdmin(c, "backfill checksums") {
+		return
+	}
+	var params struct {
+		After string `json:"after"`
+	}
+	if jsonData := c.PostForm("data"); jsonData != "" {
+		if err := json.Unmarshal([]byte(jsonData), &params); err != nil {
+			utils.RespondError(c, http.StatusBadRequest, "Invalid JSON data")
+			return
+		}
+	}
+	var after primitive.ObjectID
+	if params.After != "" {
+		var err error
+		if after, err = primitive.ObjectIDFromHex(params.After); err != nil {
+			utils.RespondError(c, http.StatusBadRequest, "invalid after format")
+			return
+		}
+	}
+	if !scheduler.StartChecksumBackfill(repository, after) {
+		utils.RespondError(c, http.StatusConflict, "checksum backfill is already running")
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"backfillChecksumsResult.Started": scheduler.ChecksumBackfillStatus()})
+}
@@ -0,0 +1,87 @@
+package update
+
+import (
+	"encoding/json"
+	"errors"
+	db "faynoSync/mongod"
+	"faynoSync/server/handler/create"
+	"faynoSync/server/model"
+	"faynoSync/server/utils"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+)
+
+// maxDeprecationMessageBytes keeps the messages returned to every client short
+const maxDeprecationMessageBytes = 1024
+
+// DeprecateApp marks an app at the end of its life. Clients keep getting its versions with the
+// deprecation message and end-of-life date, uploads are refused. Only admins can deprecate apps.
+func DeprecateApp(c *gin.Context, repository db.AppRepository, rdb *redis.Client, performanceMode bool) {
+	jsonData := c.PostForm("data")
+	if jsonData == "" {
+		utils.RespondError(c, http.StatusBadRequest, "No JSON data provided")
+		return
+	}
+	var params struct {
+		AppName string `json:"app_name"`
+		model.AppDeprecation
+	}
+	if err := json.Unmarshal([]byte(jsonData), &params); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid JSON data")
+		return
+	}
+	if len(params.Message) > maxDeprecationMessageBytes {
+		utils.RespondError(c, http.StatusBadRequest, fmt.Sprintf("message is too long (max %d bytes)", maxDeprecationMessageBytes))
+		return
+	}
+	if params.EOLDate != "" {
+		if _, err := time.Parse("2006-01-02", params.EOLDate); err != nil {
+			utils.RespondError(c, http.StatusBadRequest, "eol_date must be a date like 2006-01-02")
+			return
+		}
+	}
+	deprecation := params.AppDeprecation
+	setDeprecation(c, repository, rdb, performanceMode, params.AppName, &deprecation, "deprecateAppResult.Deprecated")
+}
+
+// UndeprecateApp lifts the deprecation of an app
+func UndeprecateApp(c *gin.Context, repository db.AppRepository, rdb *redis.Client, performanceMode bool) {
+	setDeprecation(c, repository, rdb, performanceMode, c.Query("app_name"), nil, "undeprecateAppResult.Undeprecated")
+}
+
+func setDeprecation(c *gin.Context, repository db.AppRepository, rdb *redis.Client, performanceMode bool, appName string, deprecation *model.AppDeprecation, resultKey string) {
+	if !utils.RequireAdmin(c, "change the deprecation of an app") {
+		return
+	}
+	if appName == "" || !utils.IsValidAppName(appName) {
+		utils.RespondError(c, http.StatusBadRequest, "invalid app_name parameter")
+		return
+	}
+
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationWrite)
+	defer cancel()
+
+	err := repository.SetAppDeprecation(appName, deprecation, ctx)
+	if errors.Is(err, db.ErrAppNotFound) {
+		utils.RespondError(c, http.StatusNotFound, "app not found")
+		return
+	}
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// The cached responses of every channel carry the deprecation
+	if create.CachingEnabled(performanceMode, rdb) {
+		if err := create.InvalidateCache(ctx, map[string]interface{}{"app_name": appName, "channel": "*"}, rdb); err != nil {
+			logrus.Error("Error invalidating cache:", err)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{resultKey: true})
+}
@@ -0,0 +1,60 @@
+package update
+
+import (
+	"encoding/json"
+	db "faynoSync/mongod"
+	"faynoSync/server/handler/create"
+	"faynoSync/server/model"
+	"faynoSync/server/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func UpdateFlag(c *gin.Context, repository db.AppRepository, rdb *redis.Client, performanceMode bool) {
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationWrite)
+	defer cancel()
+
+	jsonData := c.PostForm("data")
+	if jsonData == "" {
+		utils.RespondError(c, http.StatusBadRequest, "No JSON data provided")
+		return
+	}
+
+	var params struct {
+		ID string `json:"id"`
+		model.Flag
+	}
+	if err := json.Unmarshal([]byte(jsonData), &params); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid JSON data")
+		return
+	}
+	if params.Key == "" {
+		utils.RespondError(c, http.StatusBadRequest, "key is required")
+		return
+	}
+	if err := utils.ValidateFlag(params.Flag); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(params.ID)
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "invalid id format")
+		return
+	}
+	if !create.RequireFlagInScope(c, repository, objectID, ctx) {
+		return
+	}
+
+	result, err := repository.UpdateFlag(objectID, params.Flag, ctx)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	create.InvalidateFlagsCache(c, rdb, performanceMode)
+
+	c.JSON(http.StatusOK, gin.H{"updateFlagResult.Updated": result})
+}
@@ -0,0 +1,73 @@
+package update
+
+import (
+	"errors"
+	db "faynoSync/mongod"
+	"faynoSync/server/model"
+	"faynoSync/server/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ifMatchRevision returns the revision the client sent with If-Match, db.AnyRevision without one.
+// It responds with 412 when app, the version as currently stored, is at another revision,
+// so nothing is uploaded for an edit that would be refused.
+func ifMatchRevision(c *gin.Context, app *model.SpecificAppWithoutIDs) (int64, bool) {
+	revision, ok, err := utils.IfMatchRevision(c)
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, err.Error())
+		return 0, false
+	}
+	if !ok {
+		return db.AnyRevision, true
+	}
+	if app.Revision != revision {
+		c.Header("ETag", utils.RevisionETag(app.Revision))
+		utils.RespondError(c, http.StatusPreconditionFailed, db.ErrRevisionMismatch.Error())
+		return 0, false
+	}
+	return revision, true
+}
+
+// checkIfMatch checks the If-Match header of an update of the version objID and stores the revision in ctxQueryMap,
+// the update is then only applied while the version is still at it
+func checkIfMatch(c *gin.Context, repository db.AppRepository, objID primitive.ObjectID, ctxQueryMap map[string]interface{}) bool {
+	if c.GetHeader("If-Match") == "" {
+		return true
+	}
+	apps, err := repository.FetchAppByID(objID, c.Request.Context())
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, "failed to get version")
+		return false
+	}
+	if len(apps) == 0 {
+		utils.RespondError(c, http.StatusNotFound, "version not found")
+		return false
+	}
+	revision, ok := ifMatchRevision(c, apps[0])
+	if ok && revision != db.AnyRevision {
+		ctxQueryMap["if_match"] = revision
+	}
+	return ok
+}
+
+// revisionConflict responds with 412 when another request changed the version since its revision was checked
+func revisionConflict(c *gin.Context, err error) bool {
+	if !errors.Is(err, db.ErrRevisionMismatch) {
+		return false
+	}
+	utils.RespondError(c, http.StatusPreconditionFailed, err.Error())
+	return true
+}
+
+// nextRevision advances the revision of ctxQueryMap after an applied update,
+// every file of an update with several files is stored with its own update of the version
+func nextRevision(ctxQueryMap map[string]interface{}) {
+	if revision, ok := ctxQueryMap["if_match"].(int64); ok {
+		ctxQueryMap["if_match"] = revision + 1
+	}
+}
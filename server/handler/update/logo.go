@@ -0,0 +1,90 @@
+package update
+
+import (
+	"errors"
+	db "faynoSync/mongod"
+	"faynoSync/server/utils"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// UpdateAppLogo uploads the logo of an app to the bucket, replacing the previous one
+func UpdateAppLogo(c *gin.Context, repository db.AppRepository) {
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationWrite)
+	defer cancel()
+
+	appName := c.PostForm("app_name")
+	if !utils.IsValidAppName(appName) || appName == "" {
+		utils.RespondError(c, http.StatusBadRequest, "invalid app_name parameter")
+		return
+	}
+	file, err := c.FormFile("logo")
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "logo file is required")
+		return
+	}
+
+	env := viper.GetViper()
+	maxBytes := utils.LogoMaxBytes(env)
+	reader, err := file.Open()
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, "failed to open logo")
+		return
+	}
+	defer reader.Close()
+	// Read one byte more than allowed, so an oversized logo is detected without reading all of it
+	data, err := io.ReadAll(io.LimitReader(reader, maxBytes+1))
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, "failed to read logo")
+		return
+	}
+	contentType, extension, err := utils.ValidateLogo(data, maxBytes)
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	apps, err := repository.ListApps([]string{appName}, ctx)
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(apps) == 0 {
+		utils.RespondError(c, http.StatusNotFound, "app not found")
+		return
+	}
+
+	link, s3Key := utils.BuildLogoObject(appName, extension, env)
+	link, err = utils.WriteS3Object(ctx, utils.S3Bucket("", env), s3Key, data, contentType, link, env)
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, "failed to upload logo")
+		return
+	}
+
+	previous, err := repository.SetAppLogo(appName, link, ctx)
+	if errors.Is(err, db.ErrAppNotFound) {
+		utils.RespondError(c, http.StatusNotFound, "app not found")
+		return
+	}
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	// A logo of another image type was stored under another key
+	if previous != "" && previous != link {
+		if err := utils.RemoveFromS3(ctx, previous, map[string]interface{}{"app_name": appName}, env); err != nil {
+			logrus.Errorf("Error deleting previous logo %s: %v", previous, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"updateAppLogoResult.Logo": utils.PublicDownloadLink(link, env)})
+}
@@ -0,0 +1,76 @@
+package update
+
+import (
+	"encoding/json"
+	"errors"
+	db "faynoSync/mongod"
+	"faynoSync/server/handler/create"
+	"faynoSync/server/model"
+	"faynoSync/server/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+)
+
+// SetMissingTarget sets what /apps/latest returns for an app when its latest version has no artifact for the
+// requested platform and arch: 404, no update, or the artifacts of a fallback combination. Only admins can set it.
+func SetMissingTarget(c *gin.Context, repository db.AppRepository, rdb *redis.Client, performanceMode bool) {
+	jsonData := c.PostForm("data")
+	if jsonData == "" {
+		utils.RespondError(c, http.StatusBadRequest, "No JSON data provided")
+		return
+	}
+	var params struct {
+		AppName string `json:"app_name"`
+		model.MissingTargetPolicy
+	}
+	if err := json.Unmarshal([]byte(jsonData), &params); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid JSON data")
+		return
+	}
+	if err := utils.ValidateMissingTargetPolicy(params.MissingTargetPolicy); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	policy := params.MissingTargetPolicy
+	setMissingTarget(c, repository, rdb, performanceMode, params.AppName, &policy, "setMissingTargetResult.Updated")
+}
+
+// ResetMissingTarget removes the missing platform/arch policy of an app, MISSING_TARGET_MODE applies again
+func ResetMissingTarget(c *gin.Context, repository db.AppRepository, rdb *redis.Client, performanceMode bool) {
+	setMissingTarget(c, repository, rdb, performanceMode, c.Query("app_name"), nil, "resetMissingTargetResult.Reset")
+}
+
+func setMissingTarget(c *gin.Context, repository db.AppRepository, rdb *redis.Client, performanceMode bool, appName string, policy *model.MissingTargetPolicy, resultKey string) {
+	if !utils.RequireAdmin(c, "change the missing target policy of an app") {
+		return
+	}
+	if appName == "" || !utils.IsValidAppName(appName) {
+		utils.RespondError(c, http.StatusBadRequest, "invalid app_name parameter")
+		return
+	}
+
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationWrite)
+	defer cancel()
+
+	err := repository.SetAppMissingTarget(appName, policy, ctx)
+	if errors.Is(err, db.ErrAppNotFound) {
+		utils.RespondError(c, http.StatusNotFound, "app not found")
+		return
+	}
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Fallback responses are cached under the key of the requested platform and arch
+	if create.CachingEnabled(performanceMode, rdb) {
+		if err := create.InvalidateCache(ctx, map[string]interface{}{"app_name": appName, "channel": "*"}, rdb); err != nil {
+			logrus.Error("Error invalidating cache:", err)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{resultKey: true})
+}
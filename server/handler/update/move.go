@@ -0,0 +1,143 @@
+package update
+
+import (
+	"encoding/json"
+	"errors"
+	db "faynoSync/mongod"
+	"faynoSync/server/handler/create"
+	"faynoSync/server/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type moveArtifactParams struct {
+	ID       string `json:"id"`
+	Link     string `json:"link"`
+	Platform string `json:"platform"`
+	Arch     string `json:"arch"`
+}
+
+// MoveArtifact reassigns an artifact of a version to another platform and arch.
+// The file is copied to the key of the new platform and arch, so it doesn't have to be uploaded again.
+// Tokens scoped to apps can only move artifacts of their apps.
+func MoveArtifact(c *gin.Context, repository db.AppRepository, database *mongo.Database, rdb *redis.Client, performanceMode bool) {
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationWrite)
+	defer cancel()
+
+	jsonData := c.PostForm("data")
+	if jsonData == "" {
+		utils.RespondError(c, http.StatusBadRequest, "No JSON data provided")
+		return
+	}
+	var params moveArtifactParams
+	if err := json.Unmarshal([]byte(jsonData), &params); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid JSON data")
+		return
+	}
+	objID, err := primitive.ObjectIDFromHex(params.ID)
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "invalid id format")
+		return
+	}
+	if params.Link == "" {
+		utils.RespondError(c, http.StatusBadRequest, "link is required")
+		return
+	}
+	if !utils.IsValidPlatformName(params.Platform) {
+		utils.RespondError(c, http.StatusBadRequest, "invalid platform parameter")
+		return
+	}
+	if !utils.IsValidArchName(params.Arch) {
+		utils.RespondError(c, http.StatusBadRequest, "invalid arch parameter")
+		return
+	}
+	if err := utils.CheckPlatforms(params.Platform, database, c); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := utils.CheckArchs(params.Arch, database, c); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	apps, err := repository.FetchAppByID(objID, ctx)
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, "failed to get version")
+		return
+	}
+	if len(apps) == 0 {
+		utils.RespondError(c, http.StatusNotFound, "version not found")
+		return
+	}
+	app := apps[0]
+	if !utils.RequireAppInScope(c, app.AppName) {
+		return
+	}
+
+	found := false
+	var pkg, platform, arch string
+	for _, artifact := range app.Artifacts {
+		if artifact.Link == params.Link {
+			found = true
+			pkg, platform, arch = artifact.Package, artifact.Platform, artifact.Arch
+		}
+	}
+	if !found {
+		utils.RespondError(c, http.StatusNotFound, db.ErrArtifactNotFound.Error())
+		return
+	}
+	if platform == params.Platform && arch == params.Arch {
+		utils.RespondError(c, http.StatusConflict, "artifact already uses this platform and arch")
+		return
+	}
+	for _, artifact := range app.Artifacts {
+		if artifact.Package == pkg && artifact.Platform == params.Platform && artifact.Arch == params.Arch {
+			utils.RespondError(c, http.StatusConflict, "version already has a "+pkg+" artifact for this platform and arch")
+			return
+		}
+	}
+
+	env := viper.GetViper()
+	target := map[string]interface{}{"app_name": app.AppName, "version": app.Version, "channel": app.Channel, "platform": params.Platform, "arch": params.Arch}
+	sourceBucket, sourceKey := utils.S3ObjectOfLink(params.Link, env)
+	newLink, targetKey, _ := utils.BuildS3Object(target, app.AppName+pkg, env)
+
+	newLink, err = utils.CopyS3Object(ctx, sourceBucket, sourceKey, utils.ArtifactBucket(target, env), targetKey, newLink, env)
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, "failed to copy artifact")
+		return
+	}
+
+	removable, err := repository.MoveArtifact(objID, params.Link, newLink, params.Platform, params.Arch, ctx)
+	if errors.Is(err, db.ErrArtifactNotFound) {
+		utils.RespondError(c, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	for _, link := range removable {
+		if err := utils.RemoveFromS3(ctx, link, target, env); err != nil {
+			logrus.Errorf("Error deleting artifact %s: %v", link, err)
+		}
+	}
+
+	if create.CachingEnabled(performanceMode, rdb) {
+		cacheParams := map[string]interface{}{"app_name": app.AppName, "channel": app.Channel}
+		if err := create.InvalidateCache(ctx, cacheParams, rdb); err != nil {
+			logrus.Error("Error invalidating cache:", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"moveArtifactResult.Moved": newLink})
+}
@@ -0,0 +1,90 @@
+package update
+
+import (
+	"encoding/json"
+	"errors"
+	db "faynoSync/mongod"
+	"faynoSync/server/handler/create"
+	"faynoSync/server/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+)
+
+type latestPinParams struct {
+	AppName  string `json:"app_name"`
+	Channel  string `json:"channel"`
+	Platform string `json:"platform"`
+	Arch     string `json:"arch"`
+	Version  string `json:"version"`
+}
+
+// PinLatestVersion makes an explicit version the latest one, e.g. when the newest version is broken
+func PinLatestVersion(c *gin.Context, repository db.AppRepository, rdb *redis.Client, performanceMode bool) {
+	jsonData := c.PostForm("data")
+	if jsonData == "" {
+		utils.RespondError(c, http.StatusBadRequest, "No JSON data provided")
+		return
+	}
+
+	var params latestPinParams
+	if err := json.Unmarshal([]byte(jsonData), &params); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid JSON data")
+		return
+	}
+	if params.Version == "" {
+		utils.RespondError(c, http.StatusBadRequest, "version is required")
+		return
+	}
+	if !utils.IsValidVersion(params.Version) {
+		utils.RespondError(c, http.StatusBadRequest, "invalid version parameter")
+		return
+	}
+
+	setLatestPin(c, repository, rdb, performanceMode, params, "pinLatestVersionResult.Pinned")
+}
+
+// UnpinLatestVersion restores the automatic selection of the latest version
+func UnpinLatestVersion(c *gin.Context, repository db.AppRepository, rdb *redis.Client, performanceMode bool) {
+	params := latestPinParams{
+		AppName:  c.Query("app_name"),
+		Channel:  c.Query("channel"),
+		Platform: c.Query("platform"),
+		Arch:     c.Query("arch"),
+	}
+	setLatestPin(c, repository, rdb, performanceMode, params, "unpinLatestVersionResult.Unpinned")
+}
+
+func setLatestPin(c *gin.Context, repository db.AppRepository, rdb *redis.Client, performanceMode bool, params latestPinParams, resultKey string) {
+	if !utils.RequireAdmin(c, "pin the latest version") {
+		return
+	}
+	if params.AppName == "" || params.Channel == "" {
+		utils.RespondError(c, http.StatusBadRequest, "Parameters 'app_name' and 'channel' are required")
+		return
+	}
+
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationWrite)
+	defer cancel()
+
+	result, err := repository.PinLatestVersion(params.AppName, params.Channel, params.Platform, params.Arch, params.Version, ctx)
+	if errors.Is(err, db.ErrPinnedVersionNotFound) {
+		utils.RespondError(c, http.StatusNotFound, err.Error())
+		return
+	} else if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if create.CachingEnabled(performanceMode, rdb) {
+		cacheParams := map[string]interface{}{"app_name": params.AppName, "channel": params.Channel}
+		if err := create.InvalidateCache(ctx, cacheParams, rdb); err != nil {
+			logrus.Error("Error invalidating cache:", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{resultKey: result})
+}
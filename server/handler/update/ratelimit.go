@@ -0,0 +1,70 @@
+package update
+
+import (
+	"encoding/json"
+	"errors"
+	db "faynoSync/mongod"
+	"faynoSync/server/model"
+	"faynoSync/server/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// SetRateLimit sets how many requests the public info endpoints accept for an app per period, so a single
+// noisy app can't use up the capacity shared with the others. Only admins can set it.
+func SetRateLimit(c *gin.Context, repository db.AppRepository) {
+	jsonData := c.PostForm("data")
+	if jsonData == "" {
+		utils.RespondError(c, http.StatusBadRequest, "No JSON data provided")
+		return
+	}
+	var params struct {
+		AppName string `json:"app_name"`
+		model.AppRateLimit
+	}
+	if err := json.Unmarshal([]byte(jsonData), &params); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid JSON data")
+		return
+	}
+	if _, err := utils.ValidateRateLimit(params.AppRateLimit); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit := params.AppRateLimit
+	setRateLimit(c, repository, params.AppName, &limit, "setRateLimitResult.Updated")
+}
+
+// ResetRateLimit removes the rate limit of an app
+func ResetRateLimit(c *gin.Context, repository db.AppRepository) {
+	setRateLimit(c, repository, c.Query("app_name"), nil, "resetRateLimitResult.Reset")
+}
+
+func setRateLimit(c *gin.Context, repository db.AppRepository, appName string, limit *model.AppRateLimit, resultKey string) {
+	if !utils.RequireAdmin(c, "change the rate limit of an app") {
+		return
+	}
+	if appName == "" || !utils.IsValidAppName(appName) {
+		utils.RespondError(c, http.StatusBadRequest, "invalid app_name parameter")
+		return
+	}
+
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationWrite)
+	defer cancel()
+
+	err := repository.SetAppRateLimit(appName, limit, ctx)
+	if errors.Is(err, db.ErrAppNotFound) {
+		utils.RespondError(c, http.StatusNotFound, "app not found")
+		return
+	}
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Other instances pick up the change once their cached limit expires
+	utils.AppRateLimiter().Forget(appName)
+	c.JSON(http.StatusOK, gin.H{resultKey: true})
+}
@@ -0,0 +1,177 @@
+package update
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	db "faynoSync/mongod"
+	"faynoSync/server/handler/create"
+	"faynoSync/server/utils"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type replaceArtifactParams struct {
+	ID   string `json:"id"`
+	Link string `json:"link"`
+}
+
+// ReplaceArtifact overwrites the file of an artifact in the bucket, e.g. with a re-signed build.
+// Unlike uploads, which refuse to store an artifact twice, the key and the link stay the same,
+// only the size and the checksum of the artifact are updated.
+func ReplaceArtifact(c *gin.Context, repository db.AppRepository, rdb *redis.Client, performanceMode bool) {
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationWrite)
+	defer cancel()
+
+	jsonData := c.PostForm("data")
+	if jsonData == "" {
+		utils.RespondError(c, http.StatusBadRequest, "No JSON data provided")
+		return
+	}
+	var params replaceArtifactParams
+	if err := json.Unmarshal([]byte(jsonData), &params); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid JSON data")
+		return
+	}
+	objID, err := primitive.ObjectIDFromHex(params.ID)
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "invalid id format")
+		return
+	}
+	if params.Link == "" {
+		utils.RespondError(c, http.StatusBadRequest, "link is required")
+		return
+	}
+	file, err := c.FormFile("file")
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "file is required")
+		return
+	}
+
+	apps, err := repository.FetchAppByID(objID, ctx)
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, "failed to get version")
+		return
+	}
+	if len(apps) == 0 {
+		utils.RespondError(c, http.StatusNotFound, "version not found")
+		return
+	}
+	app := apps[0]
+	// With If-Match a replacement of a version changed in the meantime is refused, e.g. two re-signed builds at once
+	revision, ok := ifMatchRevision(c, app)
+	if !ok {
+		return
+	}
+
+	found := false
+	var platform, arch, pkg string
+	for _, artifact := range app.Artifacts {
+		if artifact.Link == params.Link {
+			found = true
+			platform, arch, pkg = artifact.Platform, artifact.Arch, artifact.Package
+		}
+	}
+	if !found {
+		utils.RespondError(c, http.StatusNotFound, db.ErrArtifactNotFound.Error())
+		return
+	}
+	if pkg != "" && !strings.HasSuffix(strings.ToLower(file.Filename), strings.ToLower(pkg)) {
+		utils.RespondError(c, http.StatusBadRequest, "file must be a "+pkg+" package like the artifact it replaces")
+		return
+	}
+	if !create.CheckFileTypes(c, []*multipart.FileHeader{file}) {
+		return
+	}
+
+	ctxQueryMap := map[string]interface{}{"app_name": app.AppName, "version": app.Version, "channel": app.Channel, "platform": platform, "arch": arch}
+	if !create.CheckAppDeprecation(c, repository, ctxQueryMap) {
+		return
+	}
+	if !create.CheckChannelFreeze(c, repository, ctxQueryMap) {
+		return
+	}
+
+	reader, err := file.Open()
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusBadRequest, "failed to read file")
+		return
+	}
+	data, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusBadRequest, "failed to read file")
+		return
+	}
+	sum := sha256.Sum256(data)
+	checksum := hex.EncodeToString(sum[:])
+
+	// The object can't be overwritten together with the revision check, the revision is claimed first
+	// so a concurrent replacement is refused before it touches the file
+	if revision != db.AnyRevision {
+		err := repository.ClaimRevision(objID, revision, ctx)
+		if revisionConflict(c, err) {
+			return
+		}
+		if errors.Is(err, db.ErrVersionNotFound) {
+			utils.RespondError(c, http.StatusNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			logrus.Error(err)
+			utils.RespondError(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+		revision++
+	}
+
+	env := viper.GetViper()
+	bucket, s3Key := utils.S3ObjectOfLink(params.Link, env)
+	if err := utils.ReplaceS3Object(ctx, bucket, s3Key, data, ctxQueryMap, env); err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, "failed to upload file to S3")
+		return
+	}
+
+	err = repository.ReplaceArtifact(objID, params.Link, int64(len(data)), checksum, revision, ctx)
+	if revisionConflict(c, err) {
+		return
+	}
+	if errors.Is(err, db.ErrArtifactNotFound) {
+		utils.RespondError(c, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if create.CachingEnabled(performanceMode, rdb) {
+		cacheParams := map[string]interface{}{"app_name": app.AppName, "channel": app.Channel}
+		if err := create.InvalidateCache(ctx, cacheParams, rdb); err != nil {
+			logrus.Error("Error invalidating cache:", err)
+		}
+	}
+
+	if revision != db.AnyRevision {
+		c.Header("ETag", utils.RevisionETag(revision+1))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"replaceArtifactResult.Replaced": params.Link,
+		"size":                           len(data),
+		"checksum":                       checksum,
+	})
+}
@@ -0,0 +1,84 @@
+package update
+
+import (
+	"encoding/json"
+	"errors"
+	db "faynoSync/mongod"
+	"faynoSync/server/handler/create"
+	"faynoSync/server/model"
+	"faynoSync/server/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+)
+
+// SetRequiredTargets sets the platform and arch combinations a version of an app needs artifacts for before
+// /checkVersion and /apps/latest offer it as the latest one, so a half-uploaded release reaches no platform.
+// Only admins can set it.
+func SetRequiredTargets(c *gin.Context, repository db.AppRepository, rdb *redis.Client, performanceMode bool) {
+	jsonData := c.PostForm("data")
+	if jsonData == "" {
+		utils.RespondError(c, http.StatusBadRequest, "No JSON data provided")
+		return
+	}
+	var params struct {
+		AppName         string                 `json:"app_name"`
+		RequiredTargets []model.RequiredTarget `json:"required_targets"`
+	}
+	if err := json.Unmarshal([]byte(jsonData), &params); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid JSON data")
+		return
+	}
+	if len(params.RequiredTargets) == 0 {
+		utils.RespondError(c, http.StatusBadRequest, "required_targets must list at least one platform and arch")
+		return
+	}
+	if err := utils.ValidateRequiredTargets(params.RequiredTargets); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	setRequiredTargets(c, repository, rdb, performanceMode, params.AppName, params.RequiredTargets, "setRequiredTargetsResult.Updated")
+}
+
+// ResetRequiredTargets removes the required targets of an app, every published version can be the latest again
+func ResetRequiredTargets(c *gin.Context, repository db.AppRepository, rdb *redis.Client, performanceMode bool) {
+	setRequiredTargets(c, repository, rdb, performanceMode, c.Query("app_name"), nil, "resetRequiredTargetsResult.Reset")
+}
+
+func setRequiredTargets(c *gin.Context, repository db.AppRepository, rdb *redis.Client, performanceMode bool, appName string, targets []model.RequiredTarget, resultKey string) {
+	if !utils.RequireAdmin(c, "change the required targets of an app") {
+		return
+	}
+	if appName == "" || !utils.IsValidAppName(appName) {
+		utils.RespondError(c, http.StatusBadRequest, "invalid app_name parameter")
+		return
+	}
+
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationWrite)
+	defer cancel()
+
+	err := repository.SetAppRequiredTargets(appName, targets, ctx)
+	if errors.Is(err, db.ErrAppNotFound) {
+		utils.RespondError(c, http.StatusNotFound, "app not found")
+		return
+	}
+	if errors.Is(err, db.ErrUnknownTarget) {
+		utils.RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// The cached latest versions may be incomplete releases or skip the ones completed now
+	if create.CachingEnabled(performanceMode, rdb) {
+		if err := create.InvalidateCache(ctx, map[string]interface{}{"app_name": appName, "channel": "*"}, rdb); err != nil {
+			logrus.Error("Error invalidating cache:", err)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{resultKey: true})
+}
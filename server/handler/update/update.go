@@ -1,12 +1,15 @@
 package update
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
 	db "faynoSync/mongod"
 	"faynoSync/server/handler/create"
+	"faynoSync/server/model"
 	"faynoSync/server/utils"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -20,42 +23,42 @@ import (
 )
 
 func UpdateItem(c *gin.Context, repository db.AppRepository, itemType string) {
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationWrite)
 	defer cancel()
 
 	jsonData := c.PostForm("data")
 	if jsonData == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No JSON data provided"})
+		utils.RespondError(c, http.StatusBadRequest, "No JSON data provided")
 		return
 	}
 
 	var params map[string]string
 	if err := json.Unmarshal([]byte(jsonData), &params); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON data"})
+		utils.RespondError(c, http.StatusBadRequest, "Invalid JSON data")
 		return
 	}
 
 	id, idExists := params["id"]
 	if !idExists || id == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "id is required"})
+		utils.RespondError(c, http.StatusBadRequest, "id is required")
 		return
 	}
 
 	paramName := itemType
 	paramValue, exists := params[paramName]
 	if !exists || paramValue == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": paramName + " is required"})
+		utils.RespondError(c, http.StatusBadRequest, paramName+" is required")
 		return
 	}
 	if err := utils.ValidateItemName(itemType, paramValue); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.RespondError(c, http.StatusBadRequest, err.Error())
 		return
 	}
 	var result interface{}
 	var err error
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id format"})
+		utils.RespondError(c, http.StatusBadRequest, "invalid id format")
 		return
 	}
 	switch itemType {
@@ -68,19 +71,27 @@ func UpdateItem(c *gin.Context, repository db.AppRepository, itemType string) {
 	case "app":
 		result, err = repository.UpdateApp(objectID, paramValue, ctx)
 	default:
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item type"})
+		utils.RespondError(c, http.StatusBadRequest, "Invalid item type")
 		return
 	}
 
+	if errors.Is(err, db.ErrAlreadyExists) {
+		utils.RespondError(c, http.StatusConflict, err.Error())
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
 		return
 	}
+	if itemType != "app" {
+		utils.RefreshStrictCatalog(ctx)
+	}
 	var tag language.Tag
 	titleCase := cases.Title(tag)
 
 	capitalizedItemType := titleCase.String(itemType)
-	c.JSON(http.StatusOK, gin.H{"update" + capitalizedItemType + "Result.Updated": result})
+	legacy := gin.H{"update" + capitalizedItemType + "Result.Updated": result}
+	c.JSON(http.StatusOK, utils.ResultResponse(utils.ActionUpdated, itemType, create.ItemEntity(repository, itemType, objectID, ctx), legacy))
 }
 
 func UpdateChannel(c *gin.Context, repository db.AppRepository) {
@@ -99,64 +110,325 @@ func UpdateApp(c *gin.Context, repository db.AppRepository) {
 	UpdateItem(c, repository, "app")
 }
 
+func UpdateChannelRetention(c *gin.Context, repository db.AppRepository) {
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationWrite)
+	defer cancel()
+
+	jsonData := c.PostForm("data")
+	if jsonData == "" {
+		utils.RespondError(c, http.StatusBadRequest, "No JSON data provided")
+		return
+	}
+
+	var params struct {
+		ID string `json:"id"`
+		model.ChannelRetention
+	}
+	if err := json.Unmarshal([]byte(jsonData), &params); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid JSON data")
+		return
+	}
+	if params.UnpublishAfterDays < 0 || params.DeleteAfterDays < 0 {
+		utils.RespondError(c, http.StatusBadRequest, "retention days can't be negative")
+		return
+	}
+	if params.KeepLatest < 0 {
+		utils.RespondError(c, http.StatusBadRequest, "keep_latest can't be negative")
+		return
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(params.ID)
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "invalid id format")
+		return
+	}
+
+	result, err := repository.SetChannelRetention(objectID, params.ChannelRetention, ctx)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"updateChannelRetentionResult.Updated": result})
+}
+
+func UpdateChannelGate(c *gin.Context, repository db.AppRepository) {
+	if !utils.RequireAdmin(c, "gate channels") {
+		return
+	}
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationWrite)
+	defer cancel()
+
+	jsonData := c.PostForm("data")
+	if jsonData == "" {
+		utils.RespondError(c, http.StatusBadRequest, "No JSON data provided")
+		return
+	}
+
+	var params struct {
+		ID    string `json:"id"`
+		Gated bool   `json:"gated"`
+	}
+	if err := json.Unmarshal([]byte(jsonData), &params); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid JSON data")
+		return
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(params.ID)
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "invalid id format")
+		return
+	}
+
+	result, err := repository.SetChannelGate(objectID, params.Gated, ctx)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"updateChannelGateResult.Updated": result})
+}
+
+func UpdateChannelChangelogPolicy(c *gin.Context, repository db.AppRepository) {
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationWrite)
+	defer cancel()
+
+	jsonData := c.PostForm("data")
+	if jsonData == "" {
+		utils.RespondError(c, http.StatusBadRequest, "No JSON data provided")
+		return
+	}
+
+	var params struct {
+		ID               string `json:"id"`
+		RequireChangelog bool   `json:"require_changelog"`
+	}
+	if err := json.Unmarshal([]byte(jsonData), &params); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid JSON data")
+		return
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(params.ID)
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "invalid id format")
+		return
+	}
+
+	result, err := repository.SetChannelChangelogRequired(objectID, params.RequireChangelog, ctx)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"updateChannelChangelogPolicyResult.Updated": result})
+}
+
+// maxFreezeWindows limits the windows of a channel freeze
+const maxFreezeWindows = 100
+
+func UpdateChannelFreeze(c *gin.Context, repository db.AppRepository) {
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationWrite)
+	defer cancel()
+
+	jsonData := c.PostForm("data")
+	if jsonData == "" {
+		utils.RespondError(c, http.StatusBadRequest, "No JSON data provided")
+		return
+	}
+
+	var params struct {
+		ID string `json:"id"`
+		model.ChannelFreeze
+	}
+	if err := json.Unmarshal([]byte(jsonData), &params); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid JSON data")
+		return
+	}
+	if len(params.Windows) > maxFreezeWindows {
+		utils.RespondError(c, http.StatusBadRequest, fmt.Sprintf("a channel can have at most %d freeze windows", maxFreezeWindows))
+		return
+	}
+	for _, window := range params.Windows {
+		if window.Start.IsZero() || !window.End.After(window.Start) {
+			utils.RespondError(c, http.StatusBadRequest, "freeze windows need a start and an end after it")
+			return
+		}
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(params.ID)
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "invalid id format")
+		return
+	}
+
+	result, err := repository.SetChannelFreeze(objectID, params.ChannelFreeze, ctx)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"updateChannelFreezeResult.Updated": result})
+}
+
+// UpdateChannelMaintenance disables or enables the update checks of a channel, e.g. during an incident.
+// It affects all apps of the channel, only admins can change it.
+func UpdateChannelMaintenance(c *gin.Context, repository db.AppRepository) {
+	if !utils.RequireAdmin(c, "change the maintenance of a channel") {
+		return
+	}
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationWrite)
+	defer cancel()
+
+	jsonData := c.PostForm("data")
+	if jsonData == "" {
+		utils.RespondError(c, http.StatusBadRequest, "No JSON data provided")
+		return
+	}
+
+	var params struct {
+		ID              string `json:"id"`
+		UpdatesDisabled bool   `json:"updates_disabled"`
+		Reason          string `json:"reason"`
+	}
+	if err := json.Unmarshal([]byte(jsonData), &params); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid JSON data")
+		return
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(params.ID)
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "invalid id format")
+		return
+	}
+
+	var maintenance *model.ChannelMaintenance
+	if params.UpdatesDisabled {
+		maintenance = &model.ChannelMaintenance{Reason: params.Reason, Since: time.Now().UTC()}
+	}
+	result, err := repository.SetChannelMaintenance(objectID, maintenance, ctx)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"updateChannelMaintenanceResult.Updated": result})
+}
+
 func UpdateSpecificApp(c *gin.Context, repository db.AppRepository, db *mongo.Database, rdb *redis.Client, performanceMode bool) {
 	ctxQueryMap, err := utils.ValidateParams(c, db)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.RespondError(c, http.StatusBadRequest, err.Error())
 		return
 	}
 	// Convert string to ObjectID
 	objID, err := primitive.ObjectIDFromHex(ctxQueryMap["id"].(string))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.RespondError(c, http.StatusBadRequest, err.Error())
 		return
 	}
+	// With If-Match concurrent edits of the version are refused instead of overwriting each other
+	if !checkIfMatch(c, repository, objID, ctxQueryMap) {
+		return
+	}
+	var warnings []string
+	if warning := create.SanitizeChangelog(ctxQueryMap); warning != "" {
+		warnings = append(warnings, warning)
+	}
+	if !create.CheckChangelogPolicy(c, repository, ctxQueryMap) {
+		return
+	}
+	if !create.ApplyChangelogTemplate(c, repository, ctxQueryMap) {
+		return
+	}
+	// Publishing an unpublished version is announced like a release
+	wasPublished := true
+	if utils.GetBoolParam(ctxQueryMap["publish"]) && viper.GetBool("EMAIL_ENABLE") {
+		if current, err := repository.FetchAppByID(objID, c.Request.Context()); err == nil && len(current) > 0 {
+			wasPublished = current[0].Published
+		}
+	}
 	form, _ := c.MultipartForm()
 	var links []string
 	var extensions []string
+	var sizes []int64
 	var result bool
 	if form != nil {
 		files := form.File["file"] // Assuming the field name is "file" not "files"
 
+		if len(files) > 0 && !create.CheckAppDeprecation(c, repository, ctxQueryMap) {
+			return
+		}
+		if len(files) > 0 && !create.CheckChannelFreeze(c, repository, ctxQueryMap) {
+			return
+		}
+		if !create.CheckFileTypes(c, files) {
+			return
+		}
+		if !create.CheckUploadQuota(c, repository, ctxQueryMap, files) {
+			return
+		}
+
 		for _, file := range files {
 			link, ext, err := utils.UploadToS3(ctxQueryMap, file, c, viper.GetViper())
 			if err != nil {
 				logrus.Error(err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to upload file to S3"})
+				utils.RespondError(c, http.StatusInternalServerError, "failed to upload file to S3")
 				return
 			}
 			links = append(links, link)
 			extensions = append(extensions, ext)
+			sizes = append(sizes, file.Size)
+		}
+		if verifyWarning := create.VerifyUploads(c.Request.Context(), ctxQueryMap, files, links); verifyWarning != "" {
+			warnings = append(warnings, verifyWarning)
 		}
 	}
 
 	if len(links) > 0 {
 		for i, link := range links {
-			result, err = repository.UpdateSpecificApp(objID, ctxQueryMap, link, extensions[i], c.Request.Context())
+			result, err = repository.UpdateSpecificApp(objID, ctxQueryMap, link, extensions[i], sizes[i], c.Request.Context())
+			if revisionConflict(c, err) {
+				return
+			}
 			if err != nil {
 				logrus.Errorf("Error updating link %d: %v", i, err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				utils.RespondError(c, http.StatusInternalServerError, err.Error())
 				return
 			}
+			nextRevision(ctxQueryMap)
 		}
 	} else {
 		// Handle the case when there are no files to upload
-		result, err = repository.UpdateSpecificApp(objID, ctxQueryMap, "", "", c.Request.Context())
+		result, err = repository.UpdateSpecificApp(objID, ctxQueryMap, "", "", 0, c.Request.Context())
+		if revisionConflict(c, err) {
+			return
+		}
 		if err != nil {
 			logrus.Error(err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			utils.RespondError(c, http.StatusInternalServerError, err.Error())
 			return
 		}
 	}
-	if performanceMode && rdb != nil {
+	if create.CachingEnabled(performanceMode, rdb) {
 		publish := utils.GetBoolParam(ctxQueryMap["publish"])
-		logrus.Debugf("Updating app has publish: %t, invalidation of redis cache is starting.", publish)
+		logrus.Debugf("Updating app has publish: %t, invalidation of cache is starting.", publish)
 
-		if publish {
+		if publish || utils.GetBoolParam(ctxQueryMap["unverified"]) {
 			if err := create.InvalidateCache(c.Request.Context(), ctxQueryMap, rdb); err != nil {
 				logrus.Error("Error invalidating cache:", err)
 			}
 		}
 	}
-	c.JSON(http.StatusOK, gin.H{"updatedResult.Updated": result})
+	entity := create.VersionEntity(repository, objID, c.Request.Context())
+	legacy := gin.H{"updatedResult.Updated": result}
+	// Return the changelog as stored, after replacing or appending the sent one
+	if utils.GetStringValue(ctxQueryMap, "changelog") != "" && entity != nil {
+		legacy["updatedResult.Changelog"] = entity.Changelog
+	}
+	response := utils.ResultResponse(utils.ActionUpdated, "version", entity, legacy)
+	if entity != nil {
+		c.Header("ETag", utils.RevisionETag(entity.Revision))
+	}
+	if len(warnings) > 0 {
+		response["warning"] = strings.Join(warnings, "; ")
+	}
+	c.JSON(http.StatusOK, response)
+	if result && !wasPublished && utils.GetBoolParam(ctxQueryMap["publish"]) {
+		create.NotifyRelease(repository, objID, utils.ReleaseEventPublish, nil, nil)
+	}
 }
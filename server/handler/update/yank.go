@@ -0,0 +1,97 @@
+package update
+
+import (
+	"encoding/json"
+	"errors"
+	db "faynoSync/mongod"
+	"faynoSync/server/handler/create"
+	"faynoSync/server/utils"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxYankReasonBytes keeps the reasons returned in search results short
+const maxYankReasonBytes = 1024
+
+// YankVersion stops offering a version as the latest one while keeping it downloadable.
+// Tokens scoped to apps can only yank versions of their apps.
+func YankVersion(c *gin.Context, repository db.AppRepository, rdb *redis.Client, performanceMode bool) {
+	jsonData := c.PostForm("data")
+	if jsonData == "" {
+		utils.RespondError(c, http.StatusBadRequest, "No JSON data provided")
+		return
+	}
+	var params struct {
+		ID     string `json:"id"`
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal([]byte(jsonData), &params); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid JSON data")
+		return
+	}
+	if len(params.Reason) > maxYankReasonBytes {
+		utils.RespondError(c, http.StatusBadRequest, fmt.Sprintf("reason is too long (max %d bytes)", maxYankReasonBytes))
+		return
+	}
+	setYanked(c, repository, rdb, performanceMode, params.ID, true, params.Reason, "yankVersionResult.Yanked")
+}
+
+// UnyankVersion offers a yanked version as the latest one again
+func UnyankVersion(c *gin.Context, repository db.AppRepository, rdb *redis.Client, performanceMode bool) {
+	setYanked(c, repository, rdb, performanceMode, c.Query("id"), false, "", "unyankVersionResult.Unyanked")
+}
+
+func setYanked(c *gin.Context, repository db.AppRepository, rdb *redis.Client, performanceMode bool, id string, yanked bool, reason, resultKey string) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "invalid id format")
+		return
+	}
+
+	ctx, cancel := utils.WithTimeout(c.Request.Context(), utils.OperationWrite)
+	defer cancel()
+
+	apps, err := repository.FetchAppByID(objID, ctx)
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, "failed to get version")
+		return
+	}
+	if len(apps) == 0 {
+		utils.RespondError(c, http.StatusNotFound, db.ErrVersionNotFound.Error())
+		return
+	}
+	if !utils.RequireAppInScope(c, apps[0].AppName) {
+		return
+	}
+
+	if yanked && create.PreviewRequested(c) {
+		create.RespondVersionImpact(c, repository, objID, ctx)
+		return
+	}
+	result, err := repository.YankVersion(objID, yanked, reason, ctx)
+	if errors.Is(err, db.ErrVersionNotFound) {
+		utils.RespondError(c, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		logrus.Error(err)
+		utils.RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// The latest version of the channel may have changed
+	if create.CachingEnabled(performanceMode, rdb) {
+		cacheParams := map[string]interface{}{"app_name": apps[0].AppName, "channel": apps[0].Channel}
+		if err := create.InvalidateCache(ctx, cacheParams, rdb); err != nil {
+			logrus.Error("Error invalidating cache:", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{resultKey: result})
+}
@@ -0,0 +1,85 @@
+package model
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"sync/atomic"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// changelogCompressMinBytes is the size from which the changes are stored gzipped, 0 stores them as text
+var changelogCompressMinBytes atomic.Int64
+
+// SetChangelogCompression stores changes of at least minBytes gzipped, 0 disables compression.
+// Compressed changes are always read back transparently, also after compression was disabled.
+func SetChangelogCompression(minBytes int64) {
+	changelogCompressMinBytes.Store(minBytes)
+}
+
+// changelogDocument is the stored form of Changelog
+type changelogDocument struct {
+	Version     string `bson:"version"`
+	Changes     string `bson:"changes"`
+	ChangesGzip []byte `bson:"changes_gzip,omitempty"`
+	Raw         string `bson:"raw,omitempty"`
+	Date        string `bson:"date"`
+}
+
+func (c Changelog) MarshalBSON() ([]byte, error) {
+	doc := changelogDocument{Version: c.Version, Changes: c.Changes, Raw: c.Raw, Date: c.Date}
+	if minBytes := changelogCompressMinBytes.Load(); minBytes > 0 && int64(len(c.Changes)) >= minBytes {
+		compressed, err := gzipString(c.Changes)
+		if err != nil {
+			return nil, err
+		}
+		// Keep the text when it doesn't compress
+		if len(compressed) < len(c.Changes) {
+			doc.Changes = ""
+			doc.ChangesGzip = compressed
+		}
+	}
+	return bson.Marshal(doc)
+}
+
+func (c *Changelog) UnmarshalBSON(data []byte) error {
+	var doc changelogDocument
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	*c = Changelog{Version: doc.Version, Changes: doc.Changes, Raw: doc.Raw, Date: doc.Date}
+	if len(doc.ChangesGzip) > 0 {
+		changes, err := gunzipString(doc.ChangesGzip)
+		if err != nil {
+			return err
+		}
+		c.Changes = changes
+	}
+	return nil
+}
+
+func gzipString(s string) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write([]byte(s)); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func gunzipString(data []byte) (string, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	decompressed, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+	return string(decompressed), nil
+}
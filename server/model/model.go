@@ -1,57 +1,382 @@
 package model
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
 
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Artifact is a file of a version. Checksum is the hex SHA-256 of the file, it's set when the file is uploaded with a
+// presigned URL or replaced in place.
 type Artifact struct {
 	Link     string             `bson:"link"`
 	Platform primitive.ObjectID `bson:"platform"`
 	Arch     primitive.ObjectID `bson:"arch"`
 	Package  string             `bson:"package"`
+	Size     int64              `bson:"size,omitempty"`
+	Checksum string             `bson:"checksum,omitempty"`
+	// Preferred marks the package recommended when the version has several for the platform and arch
+	Preferred bool `bson:"preferred,omitempty"`
+}
+
+// Download is the newest published artifact of an app for a platform, arch and package
+type Download struct {
+	Platform  string             `json:"platform"`
+	Arch      string             `json:"arch"`
+	Package   string             `json:"package"`
+	Version   string             `json:"version"`
+	Critical  bool               `json:"critical"`
+	URL       string             `json:"url"`
+	Size      int64              `json:"size,omitempty"`
+	UpdatedAt primitive.DateTime `json:"updated_at"`
+}
+
+// AvailableVersion is a published version clients can pick to install, with the artifacts of the requested platform and arch
+type AvailableVersion struct {
+	Version   string              `json:"version"`
+	Critical  bool                `json:"critical"`
+	Artifacts []AvailableArtifact `json:"artifacts"`
+	Changelog []Changelog         `json:"changelog,omitempty"`
+	UpdatedAt primitive.DateTime  `json:"updated_at"`
+}
+
+// AvailableArtifact is a downloadable artifact of an AvailableVersion
+type AvailableArtifact struct {
+	Platform string `json:"platform"`
+	Arch     string `json:"arch"`
+	Package  string `json:"package"`
+	URL      string `json:"url"`
+	Size     int64  `json:"size,omitempty"`
+	Checksum string `json:"checksum,omitempty"`
 }
 
 type App struct {
-	ID         primitive.ObjectID `bson:"_id"`
-	AppName    string             `bson:"app_name"`
-	Logo       string             `bson:"logo"`
+	ID          primitive.ObjectID `bson:"_id"`
+	AppName     string             `bson:"app_name"`
+	Logo        string             `bson:"logo"`
+	Deprecation *AppDeprecation    `bson:"deprecation,omitempty" json:"Deprecation,omitempty"`
+	// MissingTarget is what /apps/latest returns when the latest version has no artifact for the requested platform and arch
+	MissingTarget *MissingTargetPolicy `bson:"missing_target,omitempty" json:"MissingTarget,omitempty"`
+	// RequiredTargets are the platform and arch combinations a version needs artifacts for to be the latest one
+	RequiredTargets []RequiredTarget `bson:"required_targets,omitempty" json:"RequiredTargets,omitempty"`
+	// ChangelogTemplate wraps the changelogs of uploads and updates, see utils.ChangelogTemplateData
+	ChangelogTemplate string `bson:"changelog_template,omitempty" json:"ChangelogTemplate,omitempty"`
+	// RateLimit caps the requests to the public info endpoints for the app
+	RateLimit  *AppRateLimit      `bson:"rate_limit,omitempty" json:"RateLimit,omitempty"`
 	Updated_at primitive.DateTime `bson:"updated_at"`
 }
 
-type SpecificApp struct {
-	ID         primitive.ObjectID `bson:"_id"`
-	AppID      primitive.ObjectID `bson:"app_id"`
-	AppName    string             `bson:"app_name,omitempty" json:"AppName,omitempty"`
-	Version    string             `bson:"version"`
-	ChannelID  primitive.ObjectID `bson:"channel_id"`
-	Channel    string             `bson:"channel,omitempty" json:"channel,omitempty"`
-	Published  bool               `bson:"published"`
-	Critical   bool               `bson:"critical"`
-	Artifacts  []Artifact         `bson:"artifacts"`
-	Changelog  []Changelog        `bson:"changelog"`
-	Updated_at primitive.DateTime `bson:"updated_at"`
+// AppRateLimit allows Requests requests to the public info endpoints of an app per Period, e.g. 1000 per 1m
+type AppRateLimit struct {
+	Requests int64  `bson:"requests" json:"requests"`
+	Period   string `bson:"period" json:"period"`
 }
 
-type SpecificArtifactsWithoutIDs struct {
-	Link     string `bson:"link" json:"link"`
+// AppDeprecation marks an app at the end of its life. Clients keep getting its versions together
+// with the message and the end-of-life date, new uploads are refused.
+type AppDeprecation struct {
+	Message string `bson:"message,omitempty" json:"message,omitempty"`
+	// EOLDate is the date support ends, as YYYY-MM-DD
+	EOLDate string `bson:"eol_date,omitempty" json:"eol_date,omitempty"`
+}
+
+// Modes of a MissingTargetPolicy
+const (
+	// MissingTargetNotFound responds 404, the default
+	MissingTargetNotFound = "not_found"
+	// MissingTargetNoUpdate responds 200 with update_available false
+	MissingTargetNoUpdate = "no_update"
+	// MissingTargetFallback responds with the artifacts of the fallback platform and arch
+	MissingTargetFallback = "fallback"
+)
+
+// MissingTargetPolicy sets the response of /apps/latest when the latest version has no artifact
+// for the requested platform and arch.
+type MissingTargetPolicy struct {
+	Mode string `bson:"mode" json:"mode"`
+	// FallbackPlatform and FallbackArch are the combination served in fallback mode, e.g. universal builds.
+	// An empty one matches any platform or arch.
+	FallbackPlatform string `bson:"fallback_platform,omitempty" json:"fallback_platform,omitempty"`
+	FallbackArch     string `bson:"fallback_arch,omitempty" json:"fallback_arch,omitempty"`
+}
+
+// RequiredTarget is a platform and arch combination every complete release of an app has an artifact for
+type RequiredTarget struct {
 	Platform string `bson:"platform" json:"platform"`
 	Arch     string `bson:"arch" json:"arch"`
-	Package  string `bson:"package" json:"package"`
+}
+
+type SpecificApp struct {
+	ID         primitive.ObjectID     `bson:"_id"`
+	AppID      primitive.ObjectID     `bson:"app_id"`
+	AppName    string                 `bson:"app_name,omitempty" json:"AppName,omitempty"`
+	Version    string                 `bson:"version"`
+	ChannelID  primitive.ObjectID     `bson:"channel_id"`
+	Channel    string                 `bson:"channel,omitempty" json:"channel,omitempty"`
+	Published  bool                   `bson:"published"`
+	Critical   bool                   `bson:"critical"`
+	Artifacts  []Artifact             `bson:"artifacts"`
+	Changelog  []Changelog            `bson:"changelog"`
+	Properties map[string]interface{} `bson:"properties,omitempty"`
+	Signatures []string               `bson:"signatures,omitempty"`
+	Yanked     bool                   `bson:"yanked,omitempty"`
+	YankReason string                 `bson:"yank_reason,omitempty"`
+	UpdatedBy  string                 `bson:"updated_by,omitempty"`
+	Revision   int64                  `bson:"revision,omitempty"`
+	Updated_at primitive.DateTime     `bson:"updated_at"`
+}
+
+type SpecificArtifactsWithoutIDs struct {
+	Link      string `bson:"link" json:"link"`
+	Platform  string `bson:"platform" json:"platform"`
+	Arch      string `bson:"arch" json:"arch"`
+	Package   string `bson:"package" json:"package"`
+	Checksum  string `bson:"checksum,omitempty" json:"checksum,omitempty"`
+	Preferred bool   `bson:"preferred,omitempty" json:"preferred,omitempty"`
+}
+
+// Targeting limits the clients a version is offered to by the cohort they send to /checkVersion.
+// A version with allowed cohorts is only offered to them, denied cohorts never get it.
+type Targeting struct {
+	Allow []string `bson:"allow,omitempty" json:"allow,omitempty"`
+	Deny  []string `bson:"deny,omitempty" json:"deny,omitempty"`
 }
 
 type SpecificAppWithoutIDs struct {
-	ID        primitive.ObjectID            `bson:"_id,omitempty" json:"ID"`
-	AppName   string                        `bson:"app_name" json:"AppName"`
-	Version   string                        `bson:"version" json:"Version"`
-	Channel   string                        `bson:"channel" json:"Channel"`
-	Published bool                          `bson:"published" json:"Published"`
-	Critical  bool                          `bson:"critical" json:"Critical"`
-	Artifacts []SpecificArtifactsWithoutIDs `bson:"artifacts" json:"Artifacts"`
-	Changelog []Changelog                   `bson:"changelog" json:"Changelog"`
-	UpdatedAt primitive.DateTime            `bson:"updated_at" json:"Updated_at"`
+	ID         primitive.ObjectID            `bson:"_id,omitempty" json:"ID"`
+	AppName    string                        `bson:"app_name" json:"AppName"`
+	Logo       string                        `bson:"-" json:"Logo,omitempty"`
+	Version    string                        `bson:"version" json:"Version"`
+	Channel    string                        `bson:"channel" json:"Channel"`
+	Published  bool                          `bson:"published" json:"Published"`
+	Critical   bool                          `bson:"critical" json:"Critical"`
+	Artifacts  []SpecificArtifactsWithoutIDs `bson:"artifacts" json:"Artifacts,omitempty"`
+	Changelog  []Changelog                   `bson:"changelog" json:"Changelog,omitempty"`
+	Properties map[string]interface{}        `bson:"properties,omitempty" json:"Properties,omitempty"`
+	Targeting  *Targeting                    `bson:"targeting,omitempty" json:"Targeting,omitempty"`
+	Signatures []string                      `bson:"signatures,omitempty" json:"Signatures,omitempty"`
+	Yanked     bool                          `bson:"yanked,omitempty" json:"Yanked,omitempty"`
+	YankReason string                        `bson:"yank_reason,omitempty" json:"YankReason,omitempty"`
+	Revision   int64                         `bson:"revision,omitempty" json:"Revision,omitempty"`
+	UpdatedAt  primitive.DateTime            `bson:"updated_at" json:"Updated_at"`
+	// Complete tells whether the version has artifacts for all required targets of its app, nil when it has none
+	Complete *bool `bson:"-" json:"Complete,omitempty"`
+}
+
+// SpecificAppSnakeCase is SpecificAppWithoutIDs serialized with snake_case keys
+type SpecificAppSnakeCase struct {
+	ID         primitive.ObjectID            `json:"id"`
+	AppName    string                        `json:"app_name"`
+	Logo       string                        `json:"logo,omitempty"`
+	Version    string                        `json:"version"`
+	Channel    string                        `json:"channel"`
+	Published  bool                          `json:"published"`
+	Critical   bool                          `json:"critical"`
+	Artifacts  []SpecificArtifactsWithoutIDs `json:"artifacts,omitempty"`
+	Changelog  []ChangelogSnakeCase          `json:"changelog,omitempty"`
+	Properties map[string]interface{}        `json:"properties,omitempty"`
+	Targeting  *Targeting                    `json:"targeting,omitempty"`
+	Signatures []string                      `json:"signatures,omitempty"`
+	Yanked     bool                          `json:"yanked,omitempty"`
+	YankReason string                        `json:"yank_reason,omitempty"`
+	Revision   int64                         `json:"revision,omitempty"`
+	UpdatedAt  primitive.DateTime            `json:"updated_at"`
+	Complete   *bool                         `json:"complete,omitempty"`
+}
+
+type ChangelogSnakeCase struct {
+	Version   string `json:"version"`
+	Changes   string `json:"changes"`
+	Raw       string `json:"raw,omitempty"`
+	Date      string `json:"date"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// ChangelogDiff lists the changelog entries of two channels of an app matched by version
+type ChangelogDiff struct {
+	OnlyInA []ChangelogSnakeCase  `json:"only_in_a"`
+	OnlyInB []ChangelogSnakeCase  `json:"only_in_b"`
+	Common  []ChangelogDiffCommon `json:"common"`
+}
+
+type ChangelogDiffCommon struct {
+	Version  string `json:"version"`
+	ChangesA string `json:"changes_a"`
+	ChangesB string `json:"changes_b"`
+	Equal    bool   `json:"equal"`
+}
+
+type AppUsage struct {
+	AppID         primitive.ObjectID `json:"-"`
+	AppName       string             `json:"app_name"`
+	StorageBytes  int64              `json:"storage_bytes"`
+	VersionsCount int64              `json:"versions_count"`
+}
+
+// StatsSummary is the overview of the whole catalog. StorageBytes adds up the stored sizes of the artifacts.
+type StatsSummary struct {
+	Apps           int64           `json:"apps"`
+	Versions       int64           `json:"versions"`
+	Artifacts      int64           `json:"artifacts"`
+	StorageBytes   int64           `json:"storage_bytes"`
+	Channels       []ChannelStats  `json:"channels"`
+	LatestReleases []LatestRelease `json:"latest_releases"`
+	// Usage is tracked per app for the upload quota, MaxStorageBytes and MaxVersionsCount are the quota, 0 is unlimited
+	Usage            []AppUsage `json:"usage"`
+	MaxStorageBytes  int64      `json:"max_storage_bytes"`
+	MaxVersionsCount int64      `json:"max_versions_count"`
 }
+
+// ChannelStats counts the versions of a channel, versions without a channel have an empty name
+type ChannelStats struct {
+	Channel      string `json:"channel"`
+	Versions     int64  `json:"versions"`
+	StorageBytes int64  `json:"storage_bytes"`
+}
+
+// LatestRelease is the newest published version of an app, in any channel
+type LatestRelease struct {
+	AppName   string             `json:"app_name"`
+	Version   string             `json:"version"`
+	Channel   string             `json:"channel"`
+	UpdatedAt primitive.DateTime `json:"updated_at"`
+}
+
+// Activity is the latest upload or update of a version, an entry of the activity feed.
+// Actor is empty for versions stored before the uploader was recorded.
+type Activity struct {
+	AppName   string             `json:"app_name"`
+	Version   string             `json:"version"`
+	Channel   string             `json:"channel"`
+	Actor     string             `json:"actor,omitempty"`
+	UpdatedAt primitive.DateTime `json:"updated_at"`
+}
+
+// VersionImpact is what deleting or yanking a version would change for the clients of its channel
+type VersionImpact struct {
+	ID        string `json:"id"`
+	AppName   string `json:"app_name"`
+	Version   string `json:"version"`
+	Channel   string `json:"channel"`
+	Published bool   `json:"published"`
+	Yanked    bool   `json:"yanked"`
+	// Latest is set when the version is offered to the clients of the channel as the latest one
+	Latest bool `json:"latest"`
+	// Pinned is set when the version is pinned as the latest one for all platforms and archs
+	Pinned bool `json:"pinned"`
+	// NextLatest is offered instead of the version once it's removed, empty when no version is left
+	NextLatest string `json:"next_latest,omitempty"`
+	Artifacts  int    `json:"artifacts"`
+}
+
 type Channel struct {
-	ID          primitive.ObjectID `bson:"_id"`
-	ChannelName string             `bson:"channel_name"`
-	Updated_at  primitive.DateTime `bson:"updated_at"`
+	ID               primitive.ObjectID  `bson:"_id"`
+	ChannelName      string              `bson:"channel_name"`
+	Retention        *ChannelRetention   `bson:"retention,omitempty" json:"Retention,omitempty"`
+	Gated            bool                `bson:"gated,omitempty" json:"Gated,omitempty"`
+	Freeze           *ChannelFreeze      `bson:"freeze,omitempty" json:"Freeze,omitempty"`
+	RequireChangelog bool                `bson:"require_changelog,omitempty" json:"RequireChangelog,omitempty"`
+	Maintenance      *ChannelMaintenance `bson:"maintenance,omitempty" json:"Maintenance,omitempty"`
+	Updated_at       primitive.DateTime  `bson:"updated_at"`
+}
+
+// BetaToken grants access to gated channels. Only the hash of the token is stored,
+// an empty Channels list grants access to all gated channels.
+type BetaToken struct {
+	ID         primitive.ObjectID `bson:"_id" json:"ID"`
+	Label      string             `bson:"label" json:"label"`
+	Channels   []string           `bson:"channels" json:"channels"`
+	Revoked    bool               `bson:"revoked" json:"revoked"`
+	Updated_at primitive.DateTime `bson:"updated_at" json:"Updated_at"`
+}
+
+// ChannelRetention defines after how many days non-critical versions of a channel
+// are unpublished or deleted. A value of 0 disables the corresponding step.
+// The KeepLatest newest versions of every app in the channel are never touched.
+type ChannelRetention struct {
+	UnpublishAfterDays int `bson:"unpublish_after_days" json:"unpublish_after_days"`
+	DeleteAfterDays    int `bson:"delete_after_days" json:"delete_after_days"`
+	KeepLatest         int `bson:"keep_latest,omitempty" json:"keep_latest,omitempty"`
+}
+
+// ChannelFreeze blocks uploads to a channel, until it's unfrozen or during its windows.
+// Clients can still check for updates and download frozen channels.
+type ChannelFreeze struct {
+	Frozen  bool           `bson:"frozen,omitempty" json:"frozen"`
+	Reason  string         `bson:"reason,omitempty" json:"reason,omitempty"`
+	Windows []FreezeWindow `bson:"windows,omitempty" json:"windows,omitempty"`
+}
+
+// ChannelMaintenance disables the update checks of a channel during an incident. Clients are told no update
+// is available, uploads and downloads keep working.
+type ChannelMaintenance struct {
+	Reason string    `bson:"reason,omitempty" json:"reason,omitempty"`
+	Since  time.Time `bson:"since" json:"since"`
+}
+
+// FreezeWindow is a time range in which uploads are blocked, from Start up to End
+type FreezeWindow struct {
+	Start  time.Time `bson:"start" json:"start"`
+	End    time.Time `bson:"end" json:"end"`
+	Reason string    `bson:"reason,omitempty" json:"reason,omitempty"`
+}
+
+// Active reports whether the channel is frozen at now, with the reason and the end of the freeze.
+// The end is nil when the channel is frozen until it's unfrozen, overlapping windows extend each other.
+func (f ChannelFreeze) Active(now time.Time) (bool, string, *time.Time) {
+	if f.Frozen {
+		return true, f.Reason, nil
+	}
+	var until *time.Time
+	var reason string
+	for extended := true; extended; {
+		extended = false
+		at := now
+		if until != nil {
+			at = *until
+		}
+		for _, window := range f.Windows {
+			if window.Start.After(at) || !window.End.After(at) {
+				continue
+			}
+			if until == nil {
+				reason = window.Reason
+			}
+			end := window.End
+			until = &end
+			extended = true
+			break
+		}
+	}
+	if until == nil {
+		return false, "", nil
+	}
+	if reason == "" {
+		reason = f.Reason
+	}
+	return true, reason, until
+}
+
+// LatestPin overrides the automatic selection of the latest version for a channel.
+// An empty platform or arch applies the pin to all of them.
+type LatestPin struct {
+	ChannelID  primitive.ObjectID `bson:"channel_id"`
+	PlatformID primitive.ObjectID `bson:"platform"`
+	ArchID     primitive.ObjectID `bson:"arch"`
+	Version    string             `bson:"version"`
+}
+
+// Flag is a remote feature flag served to the clients of an app.
+// Empty channel, min_version and max_version apply the flag to all channels and versions.
+type Flag struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"ID"`
+	AppName    string             `bson:"app_name" json:"app_name"`
+	Key        string             `bson:"key" json:"key"`
+	Value      interface{}        `bson:"value" json:"value"`
+	Channel    string             `bson:"channel" json:"channel"`
+	MinVersion string             `bson:"min_version" json:"min_version"`
+	MaxVersion string             `bson:"max_version" json:"max_version"`
+	Updated_at primitive.DateTime `bson:"updated_at" json:"Updated_at"`
 }
 
 type Platform struct {
@@ -69,23 +394,76 @@ type Arch struct {
 type Changelog struct {
 	Version string `bson:"version"`
 	Changes string `bson:"changes"`
-	Date    string `bson:"date"`
+	// Raw are the changes as uploaded when Changes was rendered with the changelog template of the app
+	Raw  string `bson:"raw,omitempty" json:",omitempty"`
+	Date string `bson:"date"`
+	// Truncated is set in responses when Changes was cut to CHANGELOG_MAX_BYTES, it is never stored
+	Truncated bool `bson:"-" json:",omitempty"`
+}
+
+// AppBundleFormat is the version of the AppBundle layout written by exports
+const AppBundleFormat = 1
+
+// AppBundle is the portable export of an app. Entities are referenced by name,
+// so the bundle can be imported on another instance.
+type AppBundle struct {
+	Format         int             `json:"format"`
+	ExportedAt     time.Time       `json:"exported_at"`
+	AppName        string          `json:"app_name"`
+	VersionPattern string          `json:"version_pattern,omitempty"`
+	Channels       []string        `json:"channels"`
+	Platforms      []string        `json:"platforms"`
+	Archs          []string        `json:"archs"`
+	Versions       []BundleVersion `json:"versions"`
+}
+
+type BundleVersion struct {
+	Version    string                 `json:"version"`
+	Channel    string                 `json:"channel,omitempty"`
+	Published  bool                   `json:"published"`
+	Critical   bool                   `json:"critical"`
+	Artifacts  []BundleArtifact       `json:"artifacts"`
+	Changelog  []ChangelogSnakeCase   `json:"changelog,omitempty"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+	Signatures []string               `json:"signatures,omitempty"`
+	UpdatedAt  time.Time              `json:"updated_at"`
+}
+
+// BundleArtifact points at the stored object by its key in the bucket
+type BundleArtifact struct {
+	Key      string `json:"key"`
+	Link     string `json:"link"`
+	Platform string `json:"platform,omitempty"`
+	Arch     string `json:"arch,omitempty"`
+	Package  string `json:"package"`
+	Size     int64  `json:"size,omitempty"`
+	Checksum string `json:"checksum,omitempty"`
+	// Content is the object itself, only set in bundles exported with include_binaries
+	Content []byte `json:"content,omitempty"`
 }
 
 type Credentials struct {
-	Username  string `json:"username"`
-	Password  string `json:"password"`
-	SecretKey string `json:"api_key"`
+	Username  string   `json:"username"`
+	Password  string   `json:"password"`
+	SecretKey string   `json:"api_key"`
+	Apps      []string `json:"apps,omitempty"`
+	Role      string   `json:"role,omitempty"`
 }
 
 type UpRequest struct {
-	Id        string `json:"id"`
-	AppName   string `json:"app_name"`
-	Version   string `json:"version"`
-	Channel   string `json:"channel"`
-	Publish   bool   `json:"publish"`
-	Critical  bool   `json:"critical"`
-	Platform  string `json:"platform"`
-	Arch      string `json:"arch"`
-	Changelog string `json:"changelog"`
+	Id            string                 `json:"id"`
+	AppName       string                 `json:"app_name"`
+	Version       string                 `json:"version"`
+	Channel       string                 `json:"channel"`
+	Publish       bool                   `json:"publish"`
+	Critical      bool                   `json:"critical"`
+	Platform      string                 `json:"platform"`
+	Arch          string                 `json:"arch"`
+	Changelog     string                 `json:"changelog"`
+	ChangelogMode string                 `json:"changelog_mode"`
+	Properties    map[string]interface{} `json:"properties"`
+	AllowCohorts  []string               `json:"allow_cohorts"`
+	DenyCohorts   []string               `json:"deny_cohorts"`
+	// PreferredPackage is the package recommended to clients among the ones uploaded for the platform and arch
+	PreferredPackage string `json:"preferred_package"`
 }
@@ -0,0 +1,182 @@
+package scheduler
+
+import (
+	"context"
+	db "faynoSync/mongod"
+	"faynoSync/server/utils"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// checksumBackfillBatch is the number of versions read from the database at once
+const checksumBackfillBatch = 100
+
+// maxBackfillErrors limits the failures kept in the progress, the log has all of them
+const maxBackfillErrors = 20
+
+// ChecksumBackfillProgress reports a run of the checksum backfill.
+// LastID is the last version processed, a new run started after it continues where this one stopped.
+type ChecksumBackfillProgress struct {
+	Running    bool       `json:"running"`
+	Total      int64      `json:"total"`
+	Scanned    int64      `json:"scanned"`
+	Updated    int64      `json:"updated"`
+	Failed     int64      `json:"failed"`
+	LastID     string     `json:"last_id,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Errors     []string   `json:"errors,omitempty"`
+}
+
+var (
+	backfillMu       sync.Mutex
+	backfillProgress ChecksumBackfillProgress
+)
+
+// ChecksumBackfillStatus returns the progress of the running or last checksum backfill
+func ChecksumBackfillStatus() ChecksumBackfillProgress {
+	backfillMu.Lock()
+	defer backfillMu.Unlock()
+	progress := backfillProgress
+	progress.Errors = append([]string(nil), backfillProgress.Errors...)
+	return progress
+}
+
+// StartChecksumBackfill runs the checksum backfill in the background.
+// It returns false without starting it when a backfill is already running.
+func StartChecksumBackfill(repository db.AppRepository, after primitive.ObjectID) bool {
+	if !beginBackfill() {
+		return false
+	}
+	go runChecksumBackfill(context.Background(), repository, after)
+	return true
+}
+
+// RunChecksumBackfill computes the SHA-256 and size of every artifact stored without them and records them.
+// It blocks until all versions after the version id are processed and returns the progress.
+func RunChecksumBackfill(ctx context.Context, repository db.AppRepository, after primitive.ObjectID) (ChecksumBackfillProgress, error) {
+	if !beginBackfill() {
+		return ChecksumBackfillStatus(), fmt.Errorf("checksum backfill is already running")
+	}
+	err := runChecksumBackfill(ctx, repository, after)
+	return ChecksumBackfillStatus(), err
+}
+
+func beginBackfill() bool {
+	backfillMu.Lock()
+	defer backfillMu.Unlock()
+	if backfillProgress.Running {
+		return false
+	}
+	now := time.Now()
+	backfillProgress = ChecksumBackfillProgress{Running: true, StartedAt: &now}
+	return true
+}
+
+func runChecksumBackfill(ctx context.Context, repository db.AppRepository, after primitive.ObjectID) (err error) {
+	env := viper.GetViper()
+	defer func() {
+		backfillMu.Lock()
+		now := time.Now()
+		backfillProgress.Running = false
+		backfillProgress.FinishedAt = &now
+		progress := backfillProgress
+		backfillMu.Unlock()
+		logrus.WithFields(logrus.Fields{
+			"scanned": progress.Scanned,
+			"updated": progress.Updated,
+			"failed":  progress.Failed,
+			"last_id": progress.LastID,
+		}).Info("Checksum backfill finished")
+		if err != nil {
+			logrus.Error("Checksum backfill stopped: ", err)
+		}
+	}()
+
+	total, err := repository.CountArtifactsMissingChecksums(after, ctx)
+	if err != nil {
+		return err
+	}
+	updateProgress(func(p *ChecksumBackfillProgress) { p.Total = total })
+	logrus.Infof("Checksum backfill started for %d versions", total)
+
+	touchedApps := map[primitive.ObjectID]bool{}
+	for {
+		versions, err := repository.ArtifactsMissingChecksums(after, checksumBackfillBatch, ctx)
+		if err != nil {
+			return err
+		}
+		if len(versions) == 0 {
+			break
+		}
+		for _, version := range versions {
+			updated := false
+			for _, artifact := range version.Artifacts {
+				if artifact.Checksum != "" && artifact.Size > 0 {
+					continue
+				}
+				size, checksum, sumErr := artifactChecksum(ctx, artifact.Link, version.Version, env)
+				if sumErr == nil {
+					sumErr = repository.BackfillArtifactChecksum(version.ID, artifact.Link, size, checksum, ctx)
+				}
+				if sumErr != nil {
+					if ctx.Err() != nil {
+						return ctx.Err()
+					}
+					logrus.Errorf("Checksum backfill of %s %s failed: %v", version.Version, artifact.Link, sumErr)
+					message := fmt.Sprintf("%s %s: %v", version.ID.Hex(), artifact.Link, sumErr)
+					updateProgress(func(p *ChecksumBackfillProgress) {
+						p.Failed++
+						if len(p.Errors) < maxBackfillErrors {
+							p.Errors = append(p.Errors, message)
+						}
+					})
+					continue
+				}
+				updated = true
+				updateProgress(func(p *ChecksumBackfillProgress) { p.Updated++ })
+			}
+			if updated {
+				touchedApps[version.AppID] = true
+			}
+			after = version.ID
+			updateProgress(func(p *ChecksumBackfillProgress) {
+				p.Scanned++
+				p.LastID = version.ID.Hex()
+			})
+		}
+	}
+
+	// Sizes of artifacts count towards the storage usage of their apps
+	for appID := range touchedApps {
+		if usageErr := repository.RecomputeAppUsage(appID, ctx); usageErr != nil {
+			logrus.Errorf("Error recomputing usage of app %s: %v", appID.Hex(), usageErr)
+		}
+	}
+	return nil
+}
+
+func updateProgress(update func(*ChecksumBackfillProgress)) {
+	backfillMu.Lock()
+	defer backfillMu.Unlock()
+	update(&backfillProgress)
+}
+
+// artifactChecksum streams the object a link points at to compute its SHA-256, the size is taken from its metadata
+func artifactChecksum(ctx context.Context, link, version string, env *viper.Viper) (int64, string, error) {
+	bucket, s3Key := utils.S3ObjectOfLink(link, env)
+	size, _, _, err := utils.StatS3Object(ctx, bucket, s3Key, link, map[string]interface{}{"version": version}, env)
+	if err != nil {
+		return 0, "", err
+	}
+	checksum, err := utils.ChecksumS3Object(ctx, bucket, s3Key, size, env)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, checksum, nil
+}
@@ -0,0 +1,61 @@
+package scheduler
+
+import (
+	"context"
+	db "faynoSync/mongod"
+	"faynoSync/server/handler/create"
+	"faynoSync/server/utils"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// StartRetentionScheduler periodically applies the retention rules of all channels
+func StartRetentionScheduler(repository db.AppRepository, rdb *redis.Client, interval time.Duration) {
+	logrus.Infof("Retention scheduler is enabled, running every %s", interval)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			RunRetention(repository, rdb)
+			<-ticker.C
+		}
+	}()
+}
+
+// RunRetention unpublishes and deletes expired versions, removes their artifacts and invalidates caches
+func RunRetention(repository db.AppRepository, rdb *redis.Client) {
+	env := viper.GetViper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	results, err := repository.ApplyChannelRetention(time.Now(), ctx)
+	if err != nil {
+		logrus.Error("Error applying channel retention: ", err)
+		return
+	}
+
+	for _, result := range results {
+		logrus.WithFields(logrus.Fields{
+			"app_name":    result.AppName,
+			"channel":     result.Channel,
+			"unpublished": result.Unpublished,
+			"deleted":     result.Deleted,
+		}).Info("Channel retention applied")
+
+		for _, link := range result.Links {
+			if err := utils.RemoveFromS3(ctx, link, map[string]interface{}{"app_name": result.AppName}, env); err != nil {
+				logrus.Errorf("Error deleting artifact %s: %v", link, err)
+			}
+		}
+
+		if create.CachingEnabled(rdb != nil, rdb) {
+			params := map[string]interface{}{"app_name": result.AppName, "channel": result.Channel}
+			if err := create.InvalidateCache(ctx, params, rdb); err != nil {
+				logrus.Error("Error invalidating cache:", err)
+			}
+		}
+	}
+}
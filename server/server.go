@@ -1,26 +1,109 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
+	"faynoSync/memorycache"
 	db "faynoSync/mongod"
 	"faynoSync/redisdb"
 	"faynoSync/server/handler"
+	"faynoSync/server/model"
+	"faynoSync/server/scheduler"
 	"faynoSync/server/utils"
+	"faynoSync/tracing"
+	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/acme/autocert"
 )
 
 func StartServer(config *viper.Viper, flags map[string]interface{}) {
 	mongoUrl := config.GetString("MONGODB_URL")
 
-	router := gin.Default()
+	shutdownTracer, err := tracing.InitTracer(config.GetString("OTEL_EXPORTER_OTLP_ENDPOINT"), config.GetString("OTEL_SERVICE_NAME"), config.GetBool("OTEL_EXPORTER_OTLP_INSECURE"))
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	defer shutdownTracer(context.Background())
+
+	requestLogMode := utils.RequestLogMode(config)
+	if !utils.ValidRequestLogMode(requestLogMode) {
+		logrus.Fatalf("invalid REQUEST_LOG %q, allowed: %s, %s, %s", requestLogMode, utils.RequestLogOff, utils.RequestLogBasic, utils.RequestLogFull)
+	}
+
+	if mode := utils.RedisFailureMode(config); !utils.ValidRedisFailureMode(mode) {
+		logrus.Fatalf("invalid REDIS_FAILURE_MODE %q, allowed: %s, %s", mode, utils.RedisFailOpen, utils.RedisFailClosed)
+	}
+	utils.ConfigureRedisBreaker(config)
+	if err := utils.ConfigureCacheTTLs(config); err != nil {
+		logrus.Fatal(err)
+	}
 
+	trustedProxies, err := utils.TrustedProxies(config)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	tlsSettings, err := utils.ReadTLSSettings(config)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	// gin.Default with an access log that doesn't print signatures and tokens passed in queries
+	router := gin.New()
+	// Logs and handlers see the real client IP, forwarded headers of other sources are ignored
+	if err := utils.ConfigureTrustedProxies(router, trustedProxies); err != nil {
+		logrus.Fatal(err)
+	}
+	router.Use(gin.LoggerWithFormatter(utils.AccessLogFormatter), gin.Recovery())
+	router.Use(utils.RequestLogMiddleware(requestLogMode))
+	router.Use(utils.TracingMiddleware())
+	router.Use(utils.SecurityHeadersMiddleware(utils.SecurityHeaders(config), trustedProxies))
+
+	if sort := config.GetString("SEARCH_DEFAULT_SORT"); sort != "" && !db.ValidSearchSort(sort) {
+		logrus.Fatalf("invalid SEARCH_DEFAULT_SORT %q, allowed: %s", sort, strings.Join(db.SearchSorts, ", "))
+	}
+	if config.GetInt("SEARCH_MAX_VERSIONS") < 0 {
+		logrus.Fatalf("invalid SEARCH_MAX_VERSIONS %d, it must not be negative", config.GetInt("SEARCH_MAX_VERSIONS"))
+	}
+
+	if scheme := config.GetString("ARTIFACT_NAME_SCHEME"); !utils.ValidArtifactNameScheme(scheme) {
+		logrus.Fatalf("invalid ARTIFACT_NAME_SCHEME %q, allowed: %s, %s", scheme, utils.ArtifactNameVersion, utils.ArtifactNameFull)
+	}
+
+	if err := utils.ValidateEmailConfig(config); err != nil {
+		logrus.Fatal(err)
+	}
+
+	if err := utils.ValidateClaimNames(config); err != nil {
+		logrus.Fatal(err)
+	}
+
+	if _, err := utils.DefaultMissingTargetPolicy(config); err != nil {
+		logrus.Fatal(err)
+	}
+
+	if config.GetString("DOWNLOAD_SIGNING_SECRET") != "" && config.GetString("DOWNLOAD_PROXY_BASE") == "" {
+		logrus.Fatal("DOWNLOAD_SIGNING_SECRET requires DOWNLOAD_PROXY_BASE, the public address of the API signed links point at")
+	}
+
+	db.SetSlowQueryThreshold(config.GetDuration("MONGODB_SLOW_QUERY_THRESHOLD"))
 	client, configDB := db.ConnectToDatabase(mongoUrl, flags)
 
+	// -create-admin provisions the admin user and exits instead of serving
+	if create, _ := flags["create_admin"].(bool); create {
+		if err := createAdmin(config, flags, client.Database(configDB.Database)); err != nil {
+			logrus.Fatal(err)
+		}
+		return
+	}
+
 	db := db.NewAppRepository(&configDB, client)
 
 	mongoDatabase := client.Database(configDB.Database)
@@ -37,34 +120,145 @@ func StartServer(config *viper.Viper, flags map[string]interface{}) {
 		}
 		redisClient = redisdb.ConnectToRedis(redisConfig)
 	}
+	if err := utils.ValidateBuckets(context.Background(), config); err != nil {
+		logrus.Fatal(err)
+	}
+	if err := utils.ValidateServerSideEncryption(context.Background(), config); err != nil {
+		logrus.Fatal(err)
+	}
+
+	// Use the in-memory cache only when Redis isn't configured
+	if config.GetBool("MEMORY_CACHE_ENABLE") && redisClient == nil {
+		size := config.GetInt("MEMORY_CACHE_SIZE")
+		if size <= 0 {
+			size = 1000
+		}
+		ttl := config.GetDuration("MEMORY_CACHE_TTL")
+		if ttl <= 0 {
+			ttl = 5 * time.Minute
+		}
+		memoryCache := memorycache.Init(size, ttl)
+
+		// Instances behind a load balancer announce their invalidations to each other through Redis
+		if config.GetBool("CACHE_INVALIDATION_BROADCAST") {
+			broadcastClient := redisdb.ConnectToRedis(redisdb.RedisConfig{
+				Addr:     config.GetString("REDIS_HOST") + ":" + config.GetString("REDIS_PORT"),
+				Password: config.GetString("REDIS_PASSWORD"),
+				DB:       config.GetInt("REDIS_DB"),
+			})
+			if err := redisdb.StartInvalidationBroadcast(context.Background(), broadcastClient, memoryCache); err != nil {
+				logrus.Fatal(err)
+			}
+		}
+	}
+	model.SetChangelogCompression(config.GetInt64("CHANGELOG_COMPRESS_MIN_BYTES"))
+	utils.InitUploadTracker(config.GetInt("UPLOAD_HISTORY_SIZE"))
+	if config.GetBool("STRICT_CATALOG") {
+		if err := utils.InitStrictCatalog(context.Background(), mongoDatabase, config); err != nil {
+			logrus.Fatal(err)
+		}
+	}
+	// -backfill-checksums runs the backfill once and exits instead of serving
+	if backfill, _ := flags["backfill_checksums"].(bool); backfill {
+		progress, err := scheduler.RunChecksumBackfill(context.Background(), db, primitive.NilObjectID)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		logrus.Infof("Checksums of %d artifacts backfilled, %d failed", progress.Updated, progress.Failed)
+		return
+	}
 	handler := handler.NewAppHandler(client, db, mongoDatabase, redisClient, config.GetBool("PERFORMANCE_MODE"))
 	os.Setenv("API_KEY", config.GetString("API_KEY"))
 
+	if config.GetBool("RETENTION_ENABLE") {
+		interval := config.GetDuration("RETENTION_CHECK_INTERVAL")
+		if interval <= 0 {
+			interval = time.Hour
+		}
+		scheduler.StartRetentionScheduler(db, redisClient, interval)
+	}
+
 	// Add authentication middleware to required paths
 	authMiddleware := utils.AuthMiddleware()
 
 	router.GET("/health", handler.HealthCheck)
+	router.GET("/version", handler.ServerVersion)
+	// The metrics list app names, so they are only served when enabled
+	if config.GetBool("METRICS_ENABLE") {
+		router.GET("/metrics", handler.Metrics)
+	}
 
 	allowedCORS := config.GetString("ALLOWED_CORS")
 	allowedOrigins := strings.Split(allowedCORS, ",")
 
 	router.Use(corsMiddleware(allowedOrigins))
-	router.GET("/checkVersion", handler.FindLatestVersion)
-	router.GET("/apps/latest", handler.FetchLatestVersionOfApp)
+	// Apps with a rate limit set by an admin are limited on the public info endpoints
+	router.GET("/checkVersion", handler.RateLimit, handler.FindLatestVersion)
+	router.GET("/apps/latest", handler.RateLimit, handler.FetchLatestVersionOfApp)
+	router.GET("/apps/latest/batch", handler.RateLimit, handler.FetchLatestVersionsOfApp)
+	router.GET("/apps/downloads", handler.RateLimit, handler.LatestDownloads)
+	router.GET("/apps/available-versions", handler.RateLimit, handler.AvailableVersions)
+	router.GET("/download/*key", handler.ProxyDownload)
+	router.GET("/apps/exists", handler.RateLimit, handler.VersionExists)
+	router.GET("/apps/flags", handler.RateLimit, handler.GetFlags)
+	router.GET("/upload/schema", handler.GetUploadSchema)
 	router.POST("/signup", handler.SignUp)
 	router.POST("/login", handler.Login)
 
 	router.Use(authMiddleware)
 
+	// Uploads are unlimited unless MAX_CONCURRENT_UPLOADS is set
+	uploadLimit := gin.HandlerFunc(func(c *gin.Context) { c.Next() })
+	if maxUploads := config.GetInt("MAX_CONCURRENT_UPLOADS"); maxUploads > 0 {
+		uploadLimit = utils.UploadLimitMiddleware(maxUploads, config.GetInt64("UPLOAD_LIMIT_EXEMPT_BYTES"))
+	}
+
 	router.GET("/", handler.GetAllApps)
-	router.POST("/upload", handler.UploadApp)
-	router.POST("/apps/update", handler.UpdateSpecificApp)
+	router.POST("/upload", uploadLimit, handler.UploadApp)
+	router.POST("/apps/upload/presign", handler.PresignUpload)
+	router.POST("/apps/upload/complete", handler.CompleteUpload)
+	router.GET("/uploads/status", handler.UploadsStatus)
+	router.POST("/notifications/test", handler.SendTestNotification)
+	router.GET("/cache/stats", handler.CacheStats)
+	router.GET("/stats/summary", handler.StatsSummary)
+	router.GET("/activity", handler.Activity)
+	router.GET("/debug/queries", handler.QueryStats)
+	router.POST("/apps/update", uploadLimit, handler.UpdateSpecificApp)
+	router.POST("/apps/artifact/move", handler.MoveArtifact)
+	router.POST("/apps/artifact/replace", uploadLimit, handler.ReplaceArtifact)
+	router.POST("/maintenance/checksums", handler.BackfillChecksums)
+	router.GET("/maintenance/checksums", handler.ChecksumBackfillStatus)
 	router.POST("/app/update", handler.UpdateApp)
+	router.POST("/app/logo", handler.UpdateAppLogo)
+	router.DELETE("/app/logo", handler.DeleteAppLogo)
+	router.POST("/app/deprecate", handler.DeprecateApp)
+	router.DELETE("/app/deprecate", handler.UndeprecateApp)
+	router.POST("/app/missing-target", handler.SetMissingTarget)
+	router.DELETE("/app/missing-target", handler.ResetMissingTarget)
+	router.POST("/app/required-targets", handler.SetRequiredTargets)
+	router.DELETE("/app/required-targets", handler.ResetRequiredTargets)
+	router.POST("/app/changelog-template", handler.SetChangelogTemplate)
+	router.DELETE("/app/changelog-template", handler.ResetChangelogTemplate)
+	router.POST("/app/rate-limit", handler.SetRateLimit)
+	router.DELETE("/app/rate-limit", handler.ResetRateLimit)
 	router.POST("/channel/update", handler.UpdateChannel)
+	router.POST("/channel/retention", handler.UpdateChannelRetention)
+	router.POST("/channel/gate", handler.UpdateChannelGate)
+	router.POST("/channel/freeze", handler.UpdateChannelFreeze)
+	router.POST("/channel/maintenance", handler.UpdateChannelMaintenance)
+	router.POST("/channel/changelog", handler.UpdateChannelChangelogPolicy)
+	router.POST("/apps/latest/pin", handler.PinLatestVersion)
+	router.DELETE("/apps/latest/pin", handler.UnpinLatestVersion)
+	router.POST("/apps/yank", handler.YankVersion)
+	router.DELETE("/apps/yank", handler.UnyankVersion)
+	router.POST("/apps/build/next", handler.NextBuildNumber)
 	router.POST("/platform/update", handler.UpdatePlatform)
 	router.POST("/arch/update", handler.UpdateArch)
-	router.GET("/search", handler.GetAppByName)
+	router.GET("/search", utils.GzipMiddleware(), handler.GetAppByName)
+	router.POST("/search", utils.GzipMiddleware(), handler.GetAppByName)
 	router.DELETE("/apps/delete", handler.DeleteSpecificVersionOfApp)
+	router.DELETE("/apps/delete/range", handler.DeleteVersionRange)
+	router.DELETE("/apps/versions", handler.DeleteAppVersions)
 	router.POST("/channel/create", handler.CreateChannel)
 	router.GET("/channel/list", handler.ListChannels)
 	router.DELETE("/channel/delete", handler.DeleteChannel)
@@ -75,7 +269,19 @@ func StartServer(config *viper.Viper, flags map[string]interface{}) {
 	router.GET("/arch/list", handler.ListArchs)
 	router.DELETE("/arch/delete", handler.DeleteArch)
 	router.POST("/app/create", handler.CreateApp)
+	router.POST("/bulk/create", handler.BulkCreate)
+	router.POST("/apps/clone", handler.CloneApp)
+	router.GET("/apps/export", handler.ExportApp)
+	router.POST("/apps/import", handler.ImportApp)
 	router.GET("/app/list", handler.ListApps)
+	router.GET("/changelog/diff", handler.CompareChangelogs)
+	router.POST("/flags/create", handler.CreateFlag)
+	router.GET("/flags/list", handler.ListFlags)
+	router.POST("/flags/update", handler.UpdateFlag)
+	router.DELETE("/flags/delete", handler.DeleteFlag)
+	router.POST("/beta/tokens/create", handler.CreateBetaToken)
+	router.GET("/beta/tokens/list", handler.ListBetaTokens)
+	router.DELETE("/beta/tokens/revoke", handler.RevokeBetaToken)
 	router.DELETE("/app/delete", handler.DeleteApp)
 
 	// get the port from the configuration file
@@ -83,7 +289,57 @@ func StartServer(config *viper.Viper, flags map[string]interface{}) {
 	if port == "" {
 		port = "9000"
 	}
-	router.Run(":" + port)
+	if err := serve(router, ":"+port, tlsSettings, config); err != nil {
+		logrus.Fatal(err)
+	}
+}
+
+// serve starts the HTTP server, terminating TLS when certificates or autocert domains are configured.
+// HTTP/2 is negotiated automatically over TLS.
+func serve(router http.Handler, addr string, settings utils.TLSSettings, config *viper.Viper) error {
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: router,
+		// Only the headers are time-limited: request bodies of large uploads are streamed
+		// to a temporary file by the multipart parser instead of being buffered in memory.
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+
+	switch {
+	case settings.CertFile != "":
+		srv.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		logrus.Infof("Serving HTTPS on %s using certificate %s", addr, settings.CertFile)
+		return srv.ListenAndServeTLS(settings.CertFile, settings.KeyFile)
+
+	case len(settings.AutocertDomains) > 0:
+		cacheDir := config.GetString("TLS_AUTOCERT_CACHE_DIR")
+		if cacheDir == "" {
+			cacheDir = "certs"
+		}
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(settings.AutocertDomains...),
+			Cache:      autocert.DirCache(cacheDir),
+			Email:      config.GetString("TLS_AUTOCERT_EMAIL"),
+		}
+		srv.TLSConfig = manager.TLSConfig()
+		srv.TLSConfig.MinVersion = tls.VersionTLS12
+
+		// Answer HTTP-01 challenges and redirect the rest to HTTPS
+		if httpPort := config.GetString("TLS_AUTOCERT_HTTP_PORT"); httpPort != "" {
+			go func() {
+				if err := http.ListenAndServe(":"+httpPort, manager.HTTPHandler(nil)); err != nil {
+					logrus.Errorf("ACME HTTP challenge listener stopped: %v", err)
+				}
+			}()
+		}
+		logrus.Infof("Serving HTTPS on %s with Let's Encrypt certificates for %s", addr, strings.Join(settings.AutocertDomains, ", "))
+		return srv.ListenAndServeTLS("", "")
+
+	default:
+		logrus.Infof("Serving HTTP on %s", addr)
+		return srv.ListenAndServe()
+	}
 }
 
 func corsMiddleware(allowedOrigins []string) gin.HandlerFunc {
@@ -101,7 +357,7 @@ func corsMiddleware(allowedOrigins []string) gin.HandlerFunc {
 		if allowed {
 			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
 			c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-			c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+			c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Beta-Token, "+utils.ClientCohortHeader(viper.GetViper()))
 			c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
 		}
 
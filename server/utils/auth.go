@@ -3,24 +3,26 @@ package utils
 import (
 	"errors"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/spf13/viper"
 )
 
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing authorization header"})
+			AbortError(c, http.StatusUnauthorized, "missing authorization header")
 			return
 		}
 
 		// Extract the token from the "Bearer" scheme
 		tokenParts := strings.Fields(authHeader)
 		if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token format"})
+			AbortError(c, http.StatusUnauthorized, "invalid token format")
 			return
 		}
 
@@ -41,28 +43,69 @@ func AuthMiddleware() gin.HandlerFunc {
 				errMsg = "token expired"
 			case errors.Is(err, jwt.ErrTokenNotValidYet):
 				errMsg = "token not active yet"
+			case errors.Is(err, ErrTokenInvalidIssuer):
+				errMsg = "invalid token issuer"
+			case errors.Is(err, ErrTokenInvalidAudience):
+				errMsg = "invalid token audience"
 			default:
 				errMsg = "invalid or expired token"
 			}
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": errMsg})
+			AbortError(c, http.StatusUnauthorized, errMsg)
 			return
 		}
 
 		// Extract claims and set the username in the context
 		claims, ok := token.Claims.(jwt.MapClaims)
 		if !ok || !token.Valid {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid claims"})
+			AbortError(c, http.StatusUnauthorized, "invalid claims")
 			return
 		}
 
-		username, ok := claims["username"].(string)
-		if !ok {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "username not found in claims"})
+		if _, ok := claims["username"].(string); !ok {
+			AbortError(c, http.StatusUnauthorized, "username not found in claims")
 			return
 		}
 
-		// Set the username in the context for later use
-		c.Set("username", username)
+		// Set the identity in the context for later use, handlers read it with CurrentIdentity and ScopedApps
+		identity := identityFromClaims(claims, viper.GetViper())
+		c.Set(identityKey, identity)
+		c.Set("username", identity.Username)
+		c.Set("role", identity.Role)
+		if identity.Scoped() {
+			c.Set("apps", identity.Apps)
+		}
 		c.Next()
 	}
 }
+
+// IsAuthenticated reports whether the request carries a valid bearer token,
+// for public endpoints that expose more data to authenticated callers
+func IsAuthenticated(c *gin.Context) bool {
+	tokenParts := strings.Fields(c.GetHeader("Authorization"))
+	if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
+		return false
+	}
+	token, err := ValidateJWT(tokenParts[1])
+	return err == nil && token.Valid
+}
+
+// ScopedApps returns the app names the caller is allowed to see.
+// It returns false for admins, whose tokens aren't scoped to any app.
+func ScopedApps(c *gin.Context) ([]string, bool) {
+	apps, ok := c.Get("apps")
+	if !ok {
+		return nil, false
+	}
+	scope, ok := apps.([]string)
+	return scope, ok
+}
+
+// RequireAppInScope responds with 403 when the token is scoped to apps and appName isn't one of them.
+// It returns false if the response was already written.
+func RequireAppInScope(c *gin.Context, appName string) bool {
+	if scope, scoped := ScopedApps(c); scoped && !slices.Contains(scope, appName) {
+		RespondError(c, http.StatusForbidden, "app is outside the scope of the token")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/minio/minio-go/v7"
+	"github.com/spf13/viper"
+)
+
+// ChannelBuckets returns the buckets of channels set by S3_CHANNEL_BUCKETS, e.g. nightly=nightly-builds,beta=beta-builds.
+// Channels that aren't listed are stored in S3_BUCKET_NAME.
+func ChannelBuckets(env *viper.Viper) (map[string]string, error) {
+	entries, err := parseChannelList("S3_CHANNEL_BUCKETS", "bucket", env)
+	if err != nil {
+		return nil, err
+	}
+	buckets := map[string]string{}
+	for _, entry := range entries {
+		buckets[entry.Channel] = entry.Value
+	}
+	return buckets, nil
+}
+
+// S3Bucket returns the bucket the artifacts of channel are stored in
+func S3Bucket(channel string, env *viper.Viper) string {
+	if channel != "" {
+		// S3_CHANNEL_BUCKETS is validated at startup
+		buckets, _ := ChannelBuckets(env)
+		if bucket, ok := buckets[channel]; ok {
+			return bucket
+		}
+	}
+	return env.GetString("S3_BUCKET_NAME")
+}
+
+// S3Buckets returns every bucket artifacts are stored in, S3_BUCKET_NAME first
+func S3Buckets(env *viper.Viper) []string {
+	buckets := []string{env.GetString("S3_BUCKET_NAME")}
+	channelBuckets, _ := ChannelBuckets(env)
+	for _, bucket := range channelBuckets {
+		if !slices.Contains(buckets, bucket) {
+			buckets = append(buckets, bucket)
+		}
+	}
+	sort.Strings(buckets[1:])
+	return buckets
+}
+
+// ArtifactBucket returns the bucket the artifacts uploaded with ctxQuery are stored in, the bucket of its channel
+func ArtifactBucket(ctxQuery map[string]interface{}, env *viper.Viper) string {
+	return S3Bucket(GetStringValue(ctxQuery, "channel"), env)
+}
+
+// bucketEndpoint returns the base of links to objects of bucket on AWS. S3_ENDPOINT addresses S3_BUCKET_NAME,
+// e.g. https://<bucket>.s3.amazonaws.com, the name is replaced for other buckets. Endpoints without the name
+// are used path-style, with the bucket appended.
+func bucketEndpoint(bucket string, env *viper.Viper) string {
+	endpoint := env.GetString("S3_ENDPOINT")
+	defaultBucket := env.GetString("S3_BUCKET_NAME")
+	if bucket == defaultBucket {
+		return endpoint
+	}
+	if defaultBucket != "" && strings.Contains(endpoint, defaultBucket) {
+		return strings.Replace(endpoint, defaultBucket, bucket, 1)
+	}
+	return strings.TrimSuffix(endpoint, "/") + "/" + bucket
+}
+
+// splitS3Link returns the bucket and the still escaped key of the object a stored link points at
+func splitS3Link(link string, env *viper.Viper) (string, string) {
+	buckets := S3Buckets(env)
+	if env.GetString("STORAGE_DRIVER") == "minio" {
+		// Minio links are <endpoint>/<bucket>/<key>
+		bucket, best := buckets[0], -1
+		for _, candidate := range buckets {
+			if i := strings.Index(link, "/"+candidate+"/"); i >= 0 && (best < 0 || i < best) {
+				bucket, best = candidate, i
+			}
+		}
+		if best < 0 {
+			return bucket, link
+		}
+		return bucket, link[best+len(bucket)+2:]
+	}
+	// The endpoint of S3_BUCKET_NAME may be a prefix of the others, it's tried last
+	ordered := append(slices.Clone(buckets[1:]), buckets[0])
+	for _, bucket := range ordered {
+		if endpoint := bucketEndpoint(bucket, env); endpoint != "" && strings.HasPrefix(link, endpoint) {
+			return bucket, strings.TrimPrefix(strings.TrimPrefix(link, endpoint), "/")
+		}
+	}
+	return buckets[0], strings.TrimPrefix(link, "/")
+}
+
+// S3ObjectOfLink returns the bucket and the key of the object a stored link points at
+func S3ObjectOfLink(link string, env *viper.Viper) (string, string) {
+	bucket, key := splitS3Link(link, env)
+	if unescaped, err := url.PathUnescape(key); err == nil {
+		key = unescaped
+	}
+	return bucket, key
+}
+
+// ValidateBuckets checks at startup that S3_CHANNEL_BUCKETS is well-formed and that every bucket it
+// references exists, together with S3_BUCKET_NAME. Nothing is checked without channel buckets.
+func ValidateBuckets(ctx context.Context, env *viper.Viper) error {
+	channelBuckets, err := ChannelBuckets(env)
+	if err != nil || len(channelBuckets) == 0 {
+		return err
+	}
+
+	storageClient := createStorageClient()
+	if storageClient == nil {
+		return errors.New("failed to create storage client")
+	}
+	for _, bucket := range S3Buckets(env) {
+		switch client := storageClient.(type) {
+		case *minio.Client:
+			exists, err := client.BucketExists(ctx, bucket)
+			if err != nil {
+				return fmt.Errorf("bucket %s is not accessible: %w", bucket, err)
+			}
+			if !exists {
+				return fmt.Errorf("bucket %s does not exist", bucket)
+			}
+		case *s3.Client:
+			if _, err := client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucket)}); err != nil {
+				return fmt.Errorf("bucket %s is not accessible: %w", bucket, err)
+			}
+		default:
+			return errors.New("unknown storage client type")
+		}
+	}
+	return nil
+}
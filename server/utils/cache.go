@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// cacheKeyFields are the dimensions of a cached update response, in key order.
+// Both the read path and the invalidation pattern are built from this list so they can't drift.
+var cacheKeyFields = []string{"app_name", "version", "channel", "platform", "arch", "package", "prefer_critical", "step", "cohort"}
+
+// cacheInvalidationFields are the dimensions fixed by an upload; the rest are wildcarded on invalidation.
+// The package is fixed only when the uploaded packages are known, see CacheInvalidationPatterns.
+var cacheInvalidationFields = map[string]bool{"app_name": true, "channel": true}
+
+// CreateCacheKey builds the cache key for a read request. Missing params are stored as empty values.
+func CreateCacheKey(params map[string]interface{}) string {
+	parts := make([]string, 0, len(cacheKeyFields))
+	for _, field := range cacheKeyFields {
+		parts = append(parts, fmt.Sprintf("%s=%s", field, GetStringValue(params, field)))
+	}
+	return strings.Join(parts, "&")
+}
+
+// CacheInvalidationPattern builds the Redis pattern matching every cache key affected by an upload.
+func CacheInvalidationPattern(params map[string]interface{}) string {
+	return cacheInvalidationPattern(params, "*")
+}
+
+// CacheInvalidationPatterns builds the patterns matching the cache keys affected by an upload of packages.
+// Responses of other packages are kept, responses for all packages are always matched.
+// Without packages it returns the single CacheInvalidationPattern.
+func CacheInvalidationPatterns(params map[string]interface{}, packages ...string) []string {
+	if len(packages) == 0 {
+		return []string{CacheInvalidationPattern(params)}
+	}
+	patterns := []string{cacheInvalidationPattern(params, "")}
+	seen := map[string]bool{"": true}
+	for _, pkg := range packages {
+		pkg = CachePackage(pkg)
+		if !seen[pkg] {
+			seen[pkg] = true
+			patterns = append(patterns, cacheInvalidationPattern(params, pkg))
+		}
+	}
+	return patterns
+}
+
+// CachePackage converts a stored extension like .deb to the package value of the cache keys
+func CachePackage(extension string) string {
+	if extension == "" {
+		return "no-extension"
+	}
+	return strings.TrimPrefix(extension, ".")
+}
+
+func cacheInvalidationPattern(params map[string]interface{}, pkg string) string {
+	parts := make([]string, 0, len(cacheKeyFields))
+	for _, field := range cacheKeyFields {
+		value := "*"
+		switch {
+		case cacheInvalidationFields[field]:
+			value = GetStringValue(params, field)
+		case field == "package":
+			value = pkg
+		}
+		parts = append(parts, fmt.Sprintf("%s=%s", field, value))
+	}
+	return strings.Join(parts, "&")
+}
+
+// flagsCachePrefix separates cached feature flags from cached update responses
+const flagsCachePrefix = "flags:"
+
+// FlagsCacheKey builds the cache key of the feature flags served to a client
+func FlagsCacheKey(appName, channel, version string) string {
+	return fmt.Sprintf("%sapp_name=%s&channel=%s&version=%s", flagsCachePrefix, appName, channel, version)
+}
+
+// FlagsCacheInvalidationPattern matches the cached feature flags of every app
+func FlagsCacheInvalidationPattern() string {
+	return flagsCachePrefix + "*"
+}
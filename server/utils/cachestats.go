@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// CacheCounters counts the lookups and invalidations of cached responses
+type CacheCounters struct {
+	Hits            int64   `json:"hits"`
+	Misses          int64   `json:"misses"`
+	HitRatio        float64 `json:"hit_ratio"`
+	Invalidations   int64   `json:"invalidations"`
+	InvalidatedKeys int64   `json:"invalidated_keys"`
+	Keys            int64   `json:"keys"`
+}
+
+// CacheAppStats are the counters of the cached responses of one app
+type CacheAppStats struct {
+	AppName string `json:"app_name"`
+	CacheCounters
+}
+
+type cacheStats struct {
+	mu   sync.Mutex
+	apps map[string]*CacheCounters
+}
+
+var cacheStatistics = &cacheStats{apps: map[string]*CacheCounters{}}
+
+// RecordCacheHit counts a response served from the cache
+func RecordCacheHit(cacheKey string) {
+	logrus.Debugf("Cache hit: %s", cacheKey)
+	cacheStatistics.update(CacheKeyApp(cacheKey), func(counters *CacheCounters) { counters.Hits++ })
+}
+
+// RecordCacheMiss counts a lookup that had to query the database
+func RecordCacheMiss(cacheKey string) {
+	logrus.Debugf("Cache miss: %s", cacheKey)
+	cacheStatistics.update(CacheKeyApp(cacheKey), func(counters *CacheCounters) { counters.Misses++ })
+}
+
+// RecordCacheInvalidation counts an invalidation of pattern that removed keys cached responses
+func RecordCacheInvalidation(pattern string, keys int) {
+	cacheStatistics.update(CacheKeyApp(pattern), func(counters *CacheCounters) {
+		counters.Invalidations++
+		counters.InvalidatedKeys += int64(keys)
+	})
+}
+
+// ResetCacheStats drops all counters
+func ResetCacheStats() {
+	cacheStatistics.mu.Lock()
+	defer cacheStatistics.mu.Unlock()
+	cacheStatistics.apps = map[string]*CacheCounters{}
+}
+
+// CacheStats returns the totals and the counters per app, sorted by app name.
+// keys are the currently cached keys, they are counted per app as well.
+// Lookups without an app, e.g. invalidations of all feature flags, only count towards the totals.
+func CacheStats(keys []string) (CacheCounters, []CacheAppStats) {
+	cacheStatistics.mu.Lock()
+	apps := make(map[string]CacheCounters, len(cacheStatistics.apps))
+	for app, counters := range cacheStatistics.apps {
+		apps[app] = *counters
+	}
+	cacheStatistics.mu.Unlock()
+
+	for _, key := range keys {
+		counters := apps[CacheKeyApp(key)]
+		counters.Keys++
+		apps[CacheKeyApp(key)] = counters
+	}
+
+	var total CacheCounters
+	perApp := []CacheAppStats{}
+	for app, counters := range apps {
+		counters.HitRatio = hitRatio(counters.Hits, counters.Misses)
+		total.Hits += counters.Hits
+		total.Misses += counters.Misses
+		total.Invalidations += counters.Invalidations
+		total.InvalidatedKeys += counters.InvalidatedKeys
+		total.Keys += counters.Keys
+		if app != "" {
+			perApp = append(perApp, CacheAppStats{AppName: app, CacheCounters: counters})
+		}
+	}
+	total.HitRatio = hitRatio(total.Hits, total.Misses)
+	sort.Slice(perApp, func(i, j int) bool { return perApp[i].AppName < perApp[j].AppName })
+	return total, perApp
+}
+
+// CacheKeyApp returns the app of a cache key or invalidation pattern, empty when it matches all apps
+func CacheKeyApp(cacheKey string) string {
+	return cacheKeyField(cacheKey, "app_name")
+}
+
+// CacheKeyChannel returns the channel of a cache key, empty when it has none
+func CacheKeyChannel(cacheKey string) string {
+	return cacheKeyField(cacheKey, "channel")
+}
+
+// cacheKeyField returns a field of a cache key, empty when it's missing or a wildcard
+func cacheKeyField(cacheKey, field string) string {
+	for _, part := range strings.Split(strings.TrimPrefix(cacheKey, flagsCachePrefix), "&") {
+		if value, ok := strings.CutPrefix(part, field+"="); ok {
+			if strings.ContainsAny(value, "*?[") {
+				return ""
+			}
+			return value
+		}
+	}
+	return ""
+}
+
+func (s *cacheStats) update(app string, fn func(*CacheCounters)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counters, ok := s.apps[app]
+	if !ok {
+		counters = &CacheCounters{}
+		s.apps[app] = counters
+	}
+	fn(counters)
+}
+
+func hitRatio(hits, misses int64) float64 {
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// DefaultRedisCacheTTL is how long responses are cached in Redis when REDIS_CACHE_TTL isn't set
+const DefaultRedisCacheTTL = 24 * time.Hour
+
+// RedisCacheTTL returns REDIS_CACHE_TTL, the TTL of cached responses of channels without an override
+func RedisCacheTTL(env *viper.Viper) time.Duration {
+	if ttl, err := time.ParseDuration(env.GetString("REDIS_CACHE_TTL")); err == nil && ttl > 0 {
+		return ttl
+	}
+	return DefaultRedisCacheTTL
+}
+
+// ChannelCacheTTLs returns the TTLs of channels set by REDIS_CHANNEL_CACHE_TTLS, e.g. nightly=5m,stable=48h
+func ChannelCacheTTLs(env *viper.Viper) (map[string]time.Duration, error) {
+	entries, err := parseChannelList("REDIS_CHANNEL_CACHE_TTLS", "duration", env)
+	if err != nil {
+		return nil, err
+	}
+	ttls := map[string]time.Duration{}
+	for _, entry := range entries {
+		ttl, err := time.ParseDuration(entry.Value)
+		if err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("invalid REDIS_CHANNEL_CACHE_TTLS entry %q, expected channel=duration", entry.Entry)
+		}
+		ttls[entry.Channel] = ttl
+	}
+	return ttls, nil
+}
+
+// cacheTTLs are the TTLs applied by ConfigureCacheTTLs
+var cacheTTLs = struct {
+	sync.RWMutex
+	def      time.Duration
+	channels map[string]time.Duration
+}{def: DefaultRedisCacheTTL}
+
+// ConfigureCacheTTLs checks REDIS_CACHE_TTL and REDIS_CHANNEL_CACHE_TTLS at startup and applies them
+func ConfigureCacheTTLs(env *viper.Viper) error {
+	if value := env.GetString("REDIS_CACHE_TTL"); value != "" {
+		if ttl, err := time.ParseDuration(value); err != nil || ttl <= 0 {
+			return fmt.Errorf("invalid REDIS_CACHE_TTL %q, expected a positive duration such as 24h", value)
+		}
+	}
+	channels, err := ChannelCacheTTLs(env)
+	if err != nil {
+		return err
+	}
+	cacheTTLs.Lock()
+	defer cacheTTLs.Unlock()
+	cacheTTLs.def, cacheTTLs.channels = RedisCacheTTL(env), channels
+	return nil
+}
+
+// ChannelCacheTTL returns how long responses of channel are cached in Redis
+func ChannelCacheTTL(channel string) time.Duration {
+	cacheTTLs.RLock()
+	defer cacheTTLs.RUnlock()
+	if ttl, ok := cacheTTLs.channels[channel]; ok {
+		return ttl
+	}
+	return cacheTTLs.def
+}
+
+// CacheTTLStats are the effective TTLs of cached responses, in seconds
+type CacheTTLStats struct {
+	Default  int64            `json:"default"`
+	Channels map[string]int64 `json:"channels"`
+}
+
+// CacheTTLs returns the default TTL and the overrides per channel
+func CacheTTLs() CacheTTLStats {
+	cacheTTLs.RLock()
+	defer cacheTTLs.RUnlock()
+	stats := CacheTTLStats{Default: int64(cacheTTLs.def.Seconds()), Channels: map[string]int64{}}
+	for channel, ttl := range cacheTTLs.channels {
+		stats.Channels[channel] = int64(ttl.Seconds())
+	}
+	return stats
+}
@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// catalogFields maps the item types to the apps_meta field holding their name
+var catalogFields = map[string]string{
+	"channel":  "channel_name",
+	"platform": "platform_name",
+	"arch":     "arch_id",
+}
+
+// strictCatalog is the in-memory allowlist of channels, platforms and archs.
+// Uploads are validated against it instead of querying the database when STRICT_CATALOG is enabled.
+type strictCatalog struct {
+	mu       sync.RWMutex
+	database *mongo.Database
+	items    map[string]map[string]struct{}
+}
+
+var catalog *strictCatalog
+
+// InitStrictCatalog loads the allowlist from the database and checks that the channels, platforms
+// and archs listed in STRICT_CATALOG_CHANNELS, STRICT_CATALOG_PLATFORMS and STRICT_CATALOG_ARCHS exist
+func InitStrictCatalog(ctx context.Context, database *mongo.Database, env *viper.Viper) error {
+	loaded := &strictCatalog{database: database}
+	if err := loaded.refresh(ctx); err != nil {
+		return err
+	}
+
+	expected := map[string]string{
+		"channel":  "STRICT_CATALOG_CHANNELS",
+		"platform": "STRICT_CATALOG_PLATFORMS",
+		"arch":     "STRICT_CATALOG_ARCHS",
+	}
+	var missing []string
+	for itemType, key := range expected {
+		for _, name := range strings.Split(env.GetString(key), ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if _, ok := loaded.items[itemType][name]; !ok {
+				missing = append(missing, itemType+" "+name)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("strict catalog: not found in database: %s", strings.Join(missing, ", "))
+	}
+
+	catalog = loaded
+	logrus.Infof("Strict catalog mode is enabled (%d channels, %d platforms, %d archs)",
+		len(loaded.items["channel"]), len(loaded.items["platform"]), len(loaded.items["arch"]))
+	return nil
+}
+
+// DisableStrictCatalog drops the allowlist, validation falls back to the database
+func DisableStrictCatalog() {
+	catalog = nil
+}
+
+// RefreshStrictCatalog reloads the allowlist after a channel, platform or arch was changed.
+// It does nothing when strict catalog mode is disabled.
+func RefreshStrictCatalog(ctx context.Context) {
+	if catalog == nil {
+		return
+	}
+	if err := catalog.refresh(ctx); err != nil {
+		logrus.Errorf("Error refreshing strict catalog: %v", err)
+	}
+}
+
+func (s *strictCatalog) refresh(ctx context.Context) error {
+	items := make(map[string]map[string]struct{}, len(catalogFields))
+	for itemType, field := range catalogFields {
+		names, err := s.database.Collection("apps_meta").Distinct(ctx, field, bson.M{field: bson.M{"$exists": true}})
+		if err != nil {
+			return err
+		}
+		items[itemType] = make(map[string]struct{}, len(names))
+		for _, name := range names {
+			if value, ok := name.(string); ok {
+				items[itemType][value] = struct{}{}
+			}
+		}
+	}
+
+	s.mu.Lock()
+	s.items = items
+	s.mu.Unlock()
+	return nil
+}
+
+// lookup reports whether name is allowed for the item type and whether the item type has any entries.
+// ok is false when strict catalog mode is disabled.
+func (s *strictCatalog) lookup(itemType, name string) (exists, populated, ok bool) {
+	if s == nil {
+		return false, false, false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, exists = s.items[itemType][name]
+	return exists, len(s.items[itemType]) > 0, true
+}
+
+// checkCatalog validates the name against the allowlist with the same errors as the database checks.
+// handled is false when strict catalog mode is disabled.
+func checkCatalog(itemType, plural, input string) (handled bool, err error) {
+	exists, populated, ok := catalog.lookup(itemType, input)
+	if !ok {
+		return false, nil
+	}
+	if input == "" {
+		if populated {
+			return true, fmt.Errorf("you have a created %s, setting %s is required", plural, itemType)
+		}
+		return true, nil
+	}
+	if !exists {
+		return true, fmt.Errorf("wrong name of %s. %s does not exist", itemType, strings.ToUpper(itemType[:1])+itemType[1:])
+	}
+	return true, nil
+}
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// maxChangelogTemplateBytes keeps the header and footer of the changelogs short
+const maxChangelogTemplateBytes = 4096
+
+// ChangelogTemplateData is what the changelog template of an app is rendered with,
+// e.g. "## {{.AppName}} {{.Version}}\n{{.Changes}}\nFull notes: https://example.com/notes/{{.Version}}"
+type ChangelogTemplateData struct {
+	AppName string
+	Version string
+	Channel string
+	Changes string
+}
+
+// sampleChangelog renders templates when they are configured, it must appear in the result
+var sampleChangelog = ChangelogTemplateData{AppName: "app", Version: "1.0.0", Channel: "stable", Changes: "sample changes"}
+
+// ValidateChangelogTemplate checks the changelog template of an app before it is stored.
+// It must render and include the supplied changes, so no upload loses its notes.
+func ValidateChangelogTemplate(text string) error {
+	if strings.TrimSpace(text) == "" {
+		return errors.New("template must not be blank")
+	}
+	if len(text) > maxChangelogTemplateBytes {
+		return fmt.Errorf("template is too long (max %d bytes)", maxChangelogTemplateBytes)
+	}
+	rendered, err := RenderChangelogTemplate(text, sampleChangelog)
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(rendered, sampleChangelog.Changes) {
+		return errors.New("template must include the changes with {{.Changes}}")
+	}
+	return nil
+}
+
+// RenderChangelogTemplate wraps the changes of a version in the changelog template of its app
+func RenderChangelogTemplate(text string, data ChangelogTemplateData) (string, error) {
+	tmpl, err := template.New("changelog").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid template: %w", err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("invalid template: %w", err)
+	}
+	return out.String(), nil
+}
@@ -2,23 +2,41 @@ package utils
 
 import (
 	"errors"
+	"fmt"
 	"net/http/httputil"
+	"regexp"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// DumpRequest logs the request line and headers at debug level, with the Authorization header, API keys,
+// passwords and signed query parameters redacted. The body isn't dumped, the form fields the handler
+// already parsed are logged redacted instead, files by name and size.
 func DumpRequest(c *gin.Context) {
-	requestDump, err := httputil.DumpRequest(c.Request, true)
+	request := c.Request.Clone(c.Request.Context())
+	request.Header = RedactHeaders(c.Request.Header)
+	request.URL.RawQuery = RedactQuery(c.Request.URL.RawQuery)
+	request.RequestURI = request.URL.RequestURI()
+	requestDump, err := httputil.DumpRequest(request, false)
 	if err != nil {
 		logrus.Errorln("Error dumping request:", err)
+		return
 	}
-	logrus.Debugln("Request data:", string(requestDump))
+	logrus.WithFields(logrus.Fields{
+		"form":  requestForm(c.Request),
+		"files": requestFiles(c.Request),
+	}).Debugln("Request data:", string(requestDump))
 }
 
 func CheckPlatforms(input string, db *mongo.Database, ctx *gin.Context) error {
+	if handled, err := checkCatalog("platform", "platforms", input); handled {
+		return err
+	}
 	if input == "" {
 		filter := bson.M{"platform_name": bson.M{"$exists": true}}
 		count, err := db.Collection("apps_meta").CountDocuments(ctx, filter)
@@ -49,6 +67,12 @@ func CheckPlatforms(input string, db *mongo.Database, ctx *gin.Context) error {
 }
 
 func CheckArchsLatest(input string, db *mongo.Database, ctx *gin.Context) (string, error) {
+	if exists, _, ok := catalog.lookup("arch", input); ok {
+		if !exists {
+			return "", nil
+		}
+		return input, nil
+	}
 	if input == "" {
 		filter := bson.M{"arch_id": bson.M{"$exists": true}}
 		count, err := db.Collection("apps_meta").CountDocuments(ctx, filter)
@@ -78,6 +102,9 @@ func CheckArchsLatest(input string, db *mongo.Database, ctx *gin.Context) (strin
 }
 
 func CheckArchs(input string, db *mongo.Database, ctx *gin.Context) error {
+	if handled, err := checkCatalog("arch", "archs", input); handled {
+		return err
+	}
 	if input == "" {
 		filter := bson.M{"arch_id": bson.M{"$exists": true}}
 		count, err := db.Collection("apps_meta").CountDocuments(ctx, filter)
@@ -108,6 +135,9 @@ func CheckArchs(input string, db *mongo.Database, ctx *gin.Context) error {
 }
 
 func CheckChannels(input string, db *mongo.Database, ctx *gin.Context) error {
+	if handled, err := checkCatalog("channel", "channels", input); handled {
+		return err
+	}
 	if input == "" {
 		filter := bson.M{"channel_name": bson.M{"$exists": true}}
 		count, err := db.Collection("apps_meta").CountDocuments(ctx, filter)
@@ -138,6 +168,12 @@ func CheckChannels(input string, db *mongo.Database, ctx *gin.Context) error {
 }
 
 func CheckPlatformsLatest(input string, db *mongo.Database, ctx *gin.Context) (string, error) {
+	if exists, _, ok := catalog.lookup("platform", input); ok {
+		if !exists {
+			return "", nil
+		}
+		return input, nil
+	}
 	if input == "" {
 		filter := bson.M{"platform_name": bson.M{"$exists": true}}
 		count, err := db.Collection("apps_meta").CountDocuments(ctx, filter)
@@ -166,3 +202,34 @@ func CheckPlatformsLatest(input string, db *mongo.Database, ctx *gin.Context) (s
 	}
 	return input, nil
 }
+
+// CheckVersion validates the version against the version_pattern of the app, or the default format when the app has none
+func CheckVersion(appName, version string, db *mongo.Database, ctx *gin.Context) error {
+	var app struct {
+		VersionPattern string `bson:"version_pattern"`
+	}
+	opts := options.FindOne().SetProjection(bson.M{"version_pattern": 1})
+	err := db.Collection("apps_meta").FindOne(ctx, bson.M{"app_name": appName}, opts).Decode(&app)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+	if app.VersionPattern == "" {
+		if !IsValidVersion(version) {
+			return errors.New("invalid version parameter")
+		}
+		return nil
+	}
+
+	// Versions are part of the S3 keys whatever the pattern allows
+	if version == "" || strings.Contains(version, "/") {
+		return errors.New("invalid version parameter")
+	}
+	validVersion, err := regexp.Compile(app.VersionPattern)
+	if err != nil {
+		return fmt.Errorf("invalid version_pattern of app %s: %w", appName, err)
+	}
+	if !validVersion.MatchString(version) {
+		return fmt.Errorf("invalid version parameter, versions of %s must match %s", appName, app.VersionPattern)
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+// defaultCohortHeader is the header clients identify their cohort with when CLIENT_COHORT_HEADER isn't set
+const defaultCohortHeader = "X-Client-Cohort"
+
+// ClientCohortHeader returns the header clients send their cohort in
+func ClientCohortHeader(env *viper.Viper) string {
+	if header := env.GetString("CLIENT_COHORT_HEADER"); header != "" {
+		return header
+	}
+	return defaultCohortHeader
+}
+
+// ClientCohort returns the cohort a client sent to /checkVersion, from the cohort parameter or the cohort header.
+// The cohort isn't authenticated, it selects which versions are offered but doesn't protect them.
+func ClientCohort(c *gin.Context, env *viper.Viper) string {
+	if cohort := c.Query("cohort"); cohort != "" {
+		return cohort
+	}
+	return c.GetHeader(ClientCohortHeader(env))
+}
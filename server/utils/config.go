@@ -0,0 +1,170 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// configKeys are the documented configuration keys. They are bound to environment variables so they
+// are listed in the startup dump even when they are only set in the environment. Keys built at runtime,
+// such as S3_OBJECT_TAGS_<CHANNEL>, are read from the environment as well.
+var configKeys = []string{
+	"STORAGE_DRIVER", "S3_ACCESS_KEY", "S3_SECRET_KEY", "S3_REGION", "S3_BUCKET_NAME", "S3_CHANNEL_BUCKETS", "S3_ENDPOINT", "MINIO_SECURE",
+	"S3_ROLE_ARN", "S3_ROLE_SESSION_NAME", "S3_ROLE_EXTERNAL_ID",
+	"S3_SSE", "S3_SSE_KMS_KEY_ID", "S3_MAX_RETRIES", "S3_RETRY_BASE_DELAY", "S3_OBJECT_TAGS", "STAGED_UPLOADS",
+	"ARTIFACT_NAME_SCHEME", "PUBLIC_DOWNLOAD_BASE", "MIRROR_DOWNLOAD_BASES", "SINGLE_DOWNLOAD_URL",
+	"DOWNLOAD_SIGNING_SECRET", "DOWNLOAD_URL_EXPIRY", "DOWNLOAD_PROXY_BASE",
+	"ALLOWED_CORS", "TRUSTED_PROXIES", "PORT", "TLS_CERT_FILE", "TLS_KEY_FILE", "TLS_AUTOCERT_DOMAINS", "TLS_AUTOCERT_CACHE_DIR",
+	"TLS_AUTOCERT_EMAIL", "TLS_AUTOCERT_HTTP_PORT", "SECURITY_HEADERS_ENABLE", "SECURITY_HEADER_HSTS",
+	"SECURITY_HEADER_CONTENT_TYPE_OPTIONS", "SECURITY_HEADER_FRAME_OPTIONS", "SECURITY_HEADER_CSP",
+	"SECURITY_HEADER_REFERRER_POLICY", "MONGODB_URL", "MONGODB_URL_TESTS", "MONGODB_SLOW_QUERY_THRESHOLD",
+	"API_KEY", "ADMIN_USERNAME", "ADMIN_PASSWORD", "JWT_SECRET", "JWT_ISSUER", "JWT_AUDIENCE", "JWT_APPS_CLAIM", "JWT_ROLE_CLAIM", "PERFORMANCE_MODE",
+	"REDIS_HOST", "REDIS_PORT", "REDIS_PASSWORD", "REDIS_DB", "REDIS_FAILURE_MODE", "REDIS_BREAKER_THRESHOLD",
+	"REDIS_BREAKER_COOLDOWN", "REDIS_CACHE_TTL", "REDIS_CHANNEL_CACHE_TTLS", "MEMORY_CACHE_ENABLE", "MEMORY_CACHE_SIZE",
+	"MEMORY_CACHE_TTL", "METRICS_ENABLE", "CACHE_INVALIDATION_BROADCAST", "MAX_CONCURRENT_UPLOADS",
+	"UPLOAD_LIMIT_EXEMPT_BYTES", "CLIENT_COHORT_HEADER", "LOGO_MAX_BYTES", "UPLOAD_HISTORY_SIZE",
+	"UPLOAD_QUOTA_MAX_BYTES", "UPLOAD_QUOTA_MAX_VERSIONS", "UPLOAD_FILE_TYPE_CHECK", "UPLOAD_DISALLOWED_TYPES", "CHANGELOG_COMPRESS_MIN_BYTES", "CHANGELOG_MAX_BYTES",
+	"CHANGELOG_STORE_MAX_BYTES", "SEARCH_DEFAULT_SORT", "SEARCH_MAX_VERSIONS", "MISSING_TARGET_MODE", "MISSING_TARGET_FALLBACK", "STRICT_CATALOG",
+	"STRICT_CATALOG_CHANNELS", "STRICT_CATALOG_PLATFORMS",
+	"STRICT_CATALOG_ARCHS", "RETENTION_ENABLE", "RETENTION_CHECK_INTERVAL", "OTEL_EXPORTER_OTLP_ENDPOINT",
+	"OTEL_EXPORTER_OTLP_INSECURE", "OTEL_SERVICE_NAME", "PRESIGN_EXPIRY", "RESPONSE_CASE", "LEGACY_RESULT_KEYS",
+	"TIMEOUT_READ", "TIMEOUT_WRITE", "TIMEOUT_DELETE", "DELETE_CASCADE",
+	"SLACK_ENABLE", "SLACK_BOT_TOKEN", "SLACK_CHANNEL", "EMAIL_ENABLE", "SMTP_HOST", "SMTP_PORT",
+	"SMTP_USERNAME", "SMTP_PASSWORD", "EMAIL_FROM", "EMAIL_TO", "EMAIL_EVENTS", "EMAIL_CHANNELS",
+	"EMAIL_SUBJECT_TEMPLATE", "EMAIL_BODY_TEMPLATE", "EMAIL_TIMEOUT", "HEALTH_CHECK_NOTIFICATIONS", "REQUEST_LOG",
+}
+
+// secretKeyParts mark the keys whose values are never logged
+var secretKeyParts = []string{"SECRET", "PASSWORD", "TOKEN", "API_KEY", "ACCESS_KEY"}
+
+// ConfigValue is a configuration key with its value and where it was read from: env, file or default
+type ConfigValue struct {
+	Key    string
+	Value  string
+	Source string
+}
+
+// LoadConfig reads the configuration from the .env file in dir and from environment variables.
+// Environment variables win over the file, also when they are empty. They are named like the keys,
+// prefixed with CONFIG_ENV_PREFIX and an underscore when it's set, e.g. FAYNOSYNC_S3_ENDPOINT.
+// The file is optional, containers can be configured by environment variables only.
+func LoadConfig(env *viper.Viper, dir string) error {
+	env.SetConfigType("env")
+	env.SetConfigName(".env")
+	env.AddConfigPath(dir)
+
+	if prefix := os.Getenv("CONFIG_ENV_PREFIX"); prefix != "" {
+		env.SetEnvPrefix(prefix)
+	}
+	env.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
+	env.AllowEmptyEnv(true)
+	env.AutomaticEnv()
+	for _, key := range configKeys {
+		if err := env.BindEnv(key); err != nil {
+			return err
+		}
+	}
+
+	if err := env.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if !errors.As(err, &notFound) {
+			return err
+		}
+		logrus.Infof("No .env file found in %s, using environment variables only", dir)
+	}
+	return nil
+}
+
+// EffectiveConfig returns the keys that are set with their values and sources, sorted by key.
+// Values of secrets are replaced by *****, passwords in URLs are redacted.
+func EffectiveConfig(env *viper.Viper) []ConfigValue {
+	var values []ConfigValue
+	for _, key := range env.AllKeys() {
+		if !env.IsSet(key) {
+			continue
+		}
+		name := strings.ToUpper(key)
+		source := "default"
+		if _, ok := os.LookupEnv(envName(name)); ok {
+			source = "env"
+		} else if env.InConfig(key) {
+			source = "file"
+		}
+		values = append(values, ConfigValue{Key: name, Value: redactConfigValue(name, env.GetString(key)), Source: source})
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].Key < values[j].Key })
+	return values
+}
+
+// LogEffectiveConfig logs the effective configuration at startup, without secrets
+func LogEffectiveConfig(env *viper.Viper) {
+	for _, value := range EffectiveConfig(env) {
+		logrus.WithField("source", value.Source).Infof("Config %s=%s", value.Key, value.Value)
+	}
+}
+
+// envName returns the environment variable a key is read from
+func envName(key string) string {
+	if prefix := os.Getenv("CONFIG_ENV_PREFIX"); prefix != "" {
+		return strings.ToUpper(prefix + "_" + key)
+	}
+	return key
+}
+
+func redactConfigValue(key, value string) string {
+	if value == "" {
+		return value
+	}
+	for _, part := range secretKeyParts {
+		if strings.Contains(key, part) {
+			return "*****"
+		}
+	}
+	if strings.Contains(value, "://") && strings.Contains(value, "@") {
+		// Connection strings such as MONGODB_URL carry credentials
+		parsed, err := url.Parse(value)
+		if err != nil {
+			return "*****"
+		}
+		return parsed.Redacted()
+	}
+	return value
+}
+
+// channelEntry is an entry of a channel=value list
+type channelEntry struct {
+	Channel string
+	Value   string
+	// Entry is the entry as configured, for errors
+	Entry string
+}
+
+// parseChannelList parses the list of channel=value entries set by key, e.g. nightly=nightly-builds,beta=beta-builds.
+// Every channel may be listed once, expected names the value in errors.
+func parseChannelList(key, expected string, env *viper.Viper) ([]channelEntry, error) {
+	var entries []channelEntry
+	listed := map[string]bool{}
+	for _, pair := range strings.Split(env.GetString(key), ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		channel, value, _ := strings.Cut(pair, "=")
+		channel, value = strings.TrimSpace(channel), strings.TrimSpace(value)
+		if channel == "" || value == "" {
+			return nil, fmt.Errorf("invalid %s entry %q, expected channel=%s", key, pair, expected)
+		}
+		if listed[channel] {
+			return nil, fmt.Errorf("invalid %s: channel %s is listed twice", key, channel)
+		}
+		listed[channel] = true
+		entries = append(entries, channelEntry{Channel: channel, Value: value, Entry: pair})
+	}
+	return entries, nil
+}
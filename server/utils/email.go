@@ -0,0 +1,268 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Release events that can send an email, selected with EMAIL_EVENTS.
+// An upload that publishes its version is a publish event.
+const (
+	ReleaseEventUpload  = "upload"
+	ReleaseEventPublish = "publish"
+)
+
+const (
+	defaultEmailSubject = `{{.AppName}} {{.Version}} {{if .Published}}released{{else}}uploaded{{end}} to {{.Channel}}`
+	defaultEmailBody    = `App: {{.AppName}}
+Channel: {{.Channel}}
+Version: {{.Version}}
+Published: {{.Published}}
+Critical: {{.Critical}}
+{{if .Artifacts}}
+Artifacts:
+{{range .Artifacts}}- {{.}}
+{{end}}{{end}}{{if .Changelog}}
+Changelog:
+{{range .Changelog}}- {{.}}
+{{end}}{{end}}`
+	// emailQueueSize is the number of emails waiting for the worker before further ones are dropped
+	emailQueueSize = 100
+)
+
+// ReleaseNotification is the data of a release email, available to EMAIL_SUBJECT_TEMPLATE and EMAIL_BODY_TEMPLATE
+type ReleaseNotification struct {
+	Event     string
+	AppName   string
+	Channel   string
+	Version   string
+	Published bool
+	Critical  bool
+	Artifacts []string
+	Changelog []string
+}
+
+var (
+	emailQueue     chan ReleaseNotification
+	emailQueueOnce sync.Once
+)
+
+// emailList splits a comma-separated setting, dropping empty entries
+func emailList(env *viper.Viper, key string) []string {
+	var values []string
+	for _, value := range strings.Split(env.GetString(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// emailEvents returns the events sending emails, publish unless EMAIL_EVENTS is set
+func emailEvents(env *viper.Viper) []string {
+	if events := emailList(env, "EMAIL_EVENTS"); len(events) > 0 {
+		return events
+	}
+	return []string{ReleaseEventPublish}
+}
+
+// ValidateEmailConfig checks the email settings at startup when EMAIL_ENABLE is set
+func ValidateEmailConfig(env *viper.Viper) error {
+	if !env.GetBool("EMAIL_ENABLE") {
+		return nil
+	}
+	if env.GetString("SMTP_HOST") == "" || env.GetString("EMAIL_FROM") == "" || len(emailList(env, "EMAIL_TO")) == 0 {
+		return errors.New("EMAIL_ENABLE requires SMTP_HOST, EMAIL_FROM and EMAIL_TO")
+	}
+	for _, event := range emailEvents(env) {
+		if event != ReleaseEventUpload && event != ReleaseEventPublish {
+			return fmt.Errorf("invalid EMAIL_EVENTS entry %q, allowed: %s, %s", event, ReleaseEventUpload, ReleaseEventPublish)
+		}
+	}
+	if _, err := renderEmail(ReleaseNotification{Event: ReleaseEventPublish, Published: true}, env); err != nil {
+		return err
+	}
+	return nil
+}
+
+// EmailNotificationEnabled reports whether the event of a release in channel sends an email.
+// EMAIL_CHANNELS limits the emails to the listed channels, all channels are notified without it.
+func EmailNotificationEnabled(event, channel string, env *viper.Viper) bool {
+	if !env.GetBool("EMAIL_ENABLE") || !slices.Contains(emailEvents(env), event) {
+		return false
+	}
+	channels := emailList(env, "EMAIL_CHANNELS")
+	return len(channels) == 0 || slices.Contains(channels, channel)
+}
+
+// QueueEmailNotification hands a release email to the worker sending them one after another.
+// Emails are dropped with an error in the log when the queue is full, uploads never wait for the SMTP server.
+func QueueEmailNotification(notification ReleaseNotification, env *viper.Viper) {
+	emailQueueOnce.Do(func() {
+		emailQueue = make(chan ReleaseNotification, emailQueueSize)
+		go func() {
+			for notification := range emailQueue {
+				if err := SendEmailNotification(notification, env); err != nil {
+					logrus.Errorf("Error sending email for %s %s: %v", notification.AppName, notification.Version, err)
+				}
+			}
+		}()
+	})
+	select {
+	case emailQueue <- notification:
+	default:
+		logrus.Errorf("Email queue is full, dropping the email for %s %s", notification.AppName, notification.Version)
+	}
+}
+
+// renderEmail returns the subject and body of a release email from their templates
+func renderEmail(notification ReleaseNotification, env *viper.Viper) ([2]string, error) {
+	var rendered [2]string
+	for i, setting := range []struct{ key, fallback string }{
+		{"EMAIL_SUBJECT_TEMPLATE", defaultEmailSubject},
+		{"EMAIL_BODY_TEMPLATE", defaultEmailBody},
+	} {
+		text := env.GetString(setting.key)
+		if text == "" {
+			text = setting.fallback
+		}
+		tmpl, err := template.New(setting.key).Parse(text)
+		if err != nil {
+			return rendered, fmt.Errorf("invalid %s: %w", setting.key, err)
+		}
+		var out bytes.Buffer
+		if err := tmpl.Execute(&out, notification); err != nil {
+			return rendered, fmt.Errorf("invalid %s: %w", setting.key, err)
+		}
+		rendered[i] = out.String()
+	}
+	// Line breaks in the subject would start new headers
+	rendered[0] = strings.Join(strings.Fields(rendered[0]), " ")
+	return rendered, nil
+}
+
+// buildEmailMessage returns the RFC 5322 message of a release email
+func buildEmailMessage(from string, to []string, subject, body string, now time.Time) []byte {
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", from)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&message, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	message.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+	return message.Bytes()
+}
+
+// SendEmailNotification sends a release email over SMTP_HOST
+func SendEmailNotification(notification ReleaseNotification, env *viper.Viper) error {
+	rendered, err := renderEmail(notification, env)
+	if err != nil {
+		return err
+	}
+	if err := sendEmail(context.Background(), rendered[0], rendered[1], env); err != nil {
+		return err
+	}
+	logrus.Debugf("Release email for %s %s sent to %s", notification.AppName, notification.Version, strings.Join(emailList(env, "EMAIL_TO"), ", "))
+	return nil
+}
+
+// sendEmail sends an email from EMAIL_FROM to EMAIL_TO
+func sendEmail(ctx context.Context, subject, body string, env *viper.Viper) error {
+	to := emailList(env, "EMAIL_TO")
+	from := env.GetString("EMAIL_FROM")
+
+	client, err := smtpSession(ctx, env)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	for _, recipient := range to {
+		if err := client.Rcpt(recipient); err != nil {
+			return err
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(buildEmailMessage(from, to, subject, body, time.Now())); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// smtpSession connects and logs in to SMTP_HOST. Port 465 uses implicit TLS, other ports upgrade with
+// STARTTLS when the server offers it. The whole session is bounded by EMAIL_TIMEOUT and the deadline of ctx.
+func smtpSession(ctx context.Context, env *viper.Viper) (*smtp.Client, error) {
+	host := env.GetString("SMTP_HOST")
+	port := env.GetString("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+	timeout := env.GetDuration("EMAIL_TIMEOUT")
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	deadline := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+
+	dialer := &net.Dialer{Deadline: deadline}
+	address := net.JoinHostPort(host, port)
+	var conn net.Conn
+	var err error
+	if port == "465" {
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host}}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", address)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if ok, _ := client.Extension("STARTTLS"); ok && port != "465" {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+	if username := env.GetString("SMTP_USERNAME"); username != "" {
+		if err := client.Auth(smtp.PlainAuth("", username, env.GetString("SMTP_PASSWORD"), host)); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+	return client, nil
+}
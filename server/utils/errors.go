@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Codes of the error envelope. Each status has one code, so clients can branch on either.
+const (
+	ErrorCodeValidation    = "validation_error"
+	ErrorCodeUnauthorized  = "unauthorized"
+	ErrorCodeForbidden     = "forbidden"
+	ErrorCodeNotFound      = "not_found"
+	ErrorCodeConflict      = "conflict"
+	ErrorCodeGone          = "gone"
+	ErrorCodeLocked        = "locked"
+	ErrorCodeQuotaExceeded = "quota_exceeded"
+	ErrorCodeUnavailable   = "unavailable"
+	ErrorCodeInternal      = "internal_error"
+)
+
+// ErrorBody is the error of a failed request, returned as {"error": {"message", "code", "details"}}.
+// Details carry the context of some errors, e.g. the end-of-life date of a deprecated app.
+type ErrorBody struct {
+	Message string `json:"message"`
+	Code    string `json:"code"`
+	Details gin.H  `json:"details,omitempty"`
+}
+
+// ErrorCode returns the code of the error envelope for a status
+func ErrorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return ErrorCodeValidation
+	case http.StatusUnauthorized:
+		return ErrorCodeUnauthorized
+	case http.StatusForbidden:
+		return ErrorCodeForbidden
+	case http.StatusNotFound:
+		return ErrorCodeNotFound
+	case http.StatusConflict:
+		return ErrorCodeConflict
+	case http.StatusGone:
+		return ErrorCodeGone
+	case http.StatusLocked:
+		return ErrorCodeLocked
+	case http.StatusInsufficientStorage:
+		return ErrorCodeQuotaExceeded
+	case http.StatusServiceUnavailable:
+		return ErrorCodeUnavailable
+	}
+	if status >= http.StatusInternalServerError {
+		return ErrorCodeInternal
+	}
+	return ErrorCodeValidation
+}
+
+// ErrorResponse returns the error envelope of a failed request, details may be nil
+func ErrorResponse(status int, message string, details gin.H) gin.H {
+	return gin.H{"error": ErrorBody{Message: message, Code: ErrorCode(status), Details: details}}
+}
+
+// RespondError responds with the error envelope
+func RespondError(c *gin.Context, status int, message string) {
+	c.JSON(status, ErrorResponse(status, message, nil))
+}
+
+// RespondErrorDetails responds with the error envelope and the details of the error
+func RespondErrorDetails(c *gin.Context, status int, message string, details gin.H) {
+	c.JSON(status, ErrorResponse(status, message, details))
+}
+
+// AbortError responds with the error envelope and stops the remaining handlers, for middlewares
+func AbortError(c *gin.Context, status int, message string) {
+	c.AbortWithStatusJSON(status, ErrorResponse(status, message, nil))
+}
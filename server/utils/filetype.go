@@ -0,0 +1,170 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// ErrFileTypeMismatch is returned when the content of an uploaded file isn't the format its extension declares
+var ErrFileTypeMismatch = errors.New("file content does not match its extension")
+
+// ErrFileTypeDisallowed is returned for uploads of a type in UPLOAD_DISALLOWED_TYPES
+var ErrFileTypeDisallowed = errors.New("file type is not allowed")
+
+// defaultDisallowedTypes are rejected when UPLOAD_DISALLOWED_TYPES isn't set. Browsers run these files
+// when they are opened from the download domain, so they could be used for stored XSS.
+var defaultDisallowedTypes = []string{"html", "htm", "xhtml", "svg", "js", "mjs", "text/html", "application/xhtml+xml", "image/svg+xml", "text/javascript"}
+
+// File type check modes, set with UPLOAD_FILE_TYPE_CHECK
+const (
+	FileTypeCheckOff    = "off"
+	FileTypeCheckWarn   = "warn"
+	FileTypeCheckStrict = "strict"
+)
+
+// FileTypeCheckMode returns how the content of uploaded files is checked against their extension, off by default
+func FileTypeCheckMode(env *viper.Viper) string {
+	switch mode := strings.ToLower(strings.TrimSpace(env.GetString("UPLOAD_FILE_TYPE_CHECK"))); mode {
+	case FileTypeCheckWarn, FileTypeCheckStrict:
+		return mode
+	default:
+		return FileTypeCheckOff
+	}
+}
+
+// fileSignatures are the magic bytes of the known package formats, by extension.
+// A file matches when it starts with any of them.
+var fileSignatures = map[string][][]byte{
+	// Local file header, or the end of central directory of an empty archive
+	".zip": {[]byte("PK\x03\x04"), []byte("PK\x05\x06")},
+	".exe": {[]byte("MZ")},
+	// An ar archive whose first member is debian-binary
+	".deb": {[]byte("!<arch>\ndebian-binary")},
+	// macOS flat packages are xar archives
+	".pkg": {[]byte("xar!")},
+}
+
+// dmgTrailerSize is the size of the koly block that ends every UDIF disk image
+const dmgTrailerSize = 512
+
+// CheckFileType verifies that the content of the file is the format of its extension.
+// Files with other extensions are not checked.
+func CheckFileType(file *multipart.FileHeader) error {
+	extension := strings.ToLower(filepath.Ext(file.Filename))
+	signatures, known := fileSignatures[extension]
+	if !known && extension != ".dmg" {
+		return nil
+	}
+
+	content, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	if extension == ".dmg" {
+		trailer := make([]byte, 4)
+		if file.Size < dmgTrailerSize {
+			return fmt.Errorf("%w: %s is not a disk image", ErrFileTypeMismatch, file.Filename)
+		}
+		if _, err := content.ReadAt(trailer, file.Size-dmgTrailerSize); err != nil && err != io.EOF {
+			return err
+		}
+		if !bytes.Equal(trailer, []byte("koly")) {
+			return fmt.Errorf("%w: %s is not a disk image", ErrFileTypeMismatch, file.Filename)
+		}
+		return nil
+	}
+
+	header := make([]byte, 32)
+	n, err := io.ReadFull(content, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	for _, signature := range signatures {
+		if bytes.HasPrefix(header[:n], signature) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s is not a %s file", ErrFileTypeMismatch, file.Filename, extension)
+}
+
+// DisallowedFileTypes returns the extensions, without dot, and the MIME types of UPLOAD_DISALLOWED_TYPES.
+// Entries with a slash are MIME types, the specific MIME type of an extension is disallowed too. "none" allows every type.
+func DisallowedFileTypes(env *viper.Viper) (extensions, mimeTypes map[string]bool) {
+	entries := defaultDisallowedTypes
+	if value := strings.TrimSpace(env.GetString("UPLOAD_DISALLOWED_TYPES")); value != "" {
+		entries = strings.Split(value, ",")
+	}
+	extensions, mimeTypes = make(map[string]bool), make(map[string]bool)
+	for _, entry := range entries {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "" || entry == "none":
+		case strings.Contains(entry, "/"):
+			mimeTypes[entry] = true
+		default:
+			entry = strings.TrimPrefix(entry, ".")
+			extensions[entry] = true
+			// Generic types would reject every binary or text file that is sniffed as them
+			mimeType, _, err := mime.ParseMediaType(mime.TypeByExtension("." + entry))
+			if err == nil && mimeType != "application/octet-stream" && mimeType != "text/plain" {
+				mimeTypes[mimeType] = true
+			}
+		}
+	}
+	return extensions, mimeTypes
+}
+
+// IsDisallowedExtension reports whether the extension of filename is in UPLOAD_DISALLOWED_TYPES
+func IsDisallowedExtension(filename string, env *viper.Viper) bool {
+	extensions, _ := DisallowedFileTypes(env)
+	return extensions[strings.TrimPrefix(strings.ToLower(filepath.Ext(filename)), ".")]
+}
+
+// CheckDisallowedFileType rejects files whose extension or sniffed content is in UPLOAD_DISALLOWED_TYPES,
+// so an HTML page is refused whatever it's named.
+func CheckDisallowedFileType(file *multipart.FileHeader, env *viper.Viper) error {
+	if IsDisallowedExtension(file.Filename, env) {
+		return fmt.Errorf("%w: %s", ErrFileTypeDisallowed, file.Filename)
+	}
+	_, mimeTypes := DisallowedFileTypes(env)
+	if len(mimeTypes) == 0 {
+		return nil
+	}
+
+	content, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+	header := make([]byte, 512)
+	n, err := io.ReadFull(content, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	sniffed := sniffContentType(header[:n])
+	if mimeTypes[sniffed] {
+		return fmt.Errorf("%w: %s is %s", ErrFileTypeDisallowed, file.Filename, sniffed)
+	}
+	return nil
+}
+
+// sniffContentType returns the MIME type of the content without parameters.
+// http.DetectContentType reports SVG images as XML or text, they are recognized by their root element.
+func sniffContentType(header []byte) string {
+	sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(header))
+	if strings.HasPrefix(sniffed, "text/") && bytes.Contains(bytes.ToLower(header), []byte("<svg")) {
+		return "image/svg+xml"
+	}
+	return sniffed
+}
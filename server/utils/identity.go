@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/spf13/viper"
+)
+
+// Default roles of users that have no role of their own
+const (
+	RoleAdmin  = "admin"
+	RoleScoped = "scoped"
+)
+
+// legacyAppsClaim is the claim the apps of scoped tokens were always issued in
+const legacyAppsClaim = "apps"
+
+// identityKey is the gin context key of the Identity of the request
+const identityKey = "identity"
+
+var roleRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// Identity is the caller of a request as carried by the claims of its token, so handlers and services
+// behind the server can authorize it without querying the users. Apps is nil for admins.
+type Identity struct {
+	Username string   `json:"username"`
+	Role     string   `json:"role"`
+	Apps     []string `json:"apps,omitempty"`
+}
+
+// Scoped reports whether the identity only has access to its apps
+func (i Identity) Scoped() bool {
+	return i.Apps != nil
+}
+
+// DefaultRole is the role of users that have no role of their own
+func DefaultRole(apps []string) string {
+	if apps != nil {
+		return RoleScoped
+	}
+	return RoleAdmin
+}
+
+// ValidateRole checks the role given to a user, an empty role means the default one
+func ValidateRole(role string) error {
+	if role != "" && !roleRegex.MatchString(role) {
+		return errors.New("invalid role, use up to 64 letters, digits, '-' or '_'")
+	}
+	return nil
+}
+
+// AppsClaim is the claim the apps of scoped tokens are issued in, JWT_APPS_CLAIM or "apps"
+func AppsClaim(env *viper.Viper) string {
+	if claim := env.GetString("JWT_APPS_CLAIM"); claim != "" {
+		return claim
+	}
+	return legacyAppsClaim
+}
+
+// RoleClaim is the claim the role is issued in, JWT_ROLE_CLAIM or "role"
+func RoleClaim(env *viper.Viper) string {
+	if claim := env.GetString("JWT_ROLE_CLAIM"); claim != "" {
+		return claim
+	}
+	return "role"
+}
+
+// reservedClaims are set on every token, JWT_APPS_CLAIM and JWT_ROLE_CLAIM can't overwrite them
+var reservedClaims = []string{"username", "exp", "iss", "aud"}
+
+// ValidateClaimNames checks that JWT_APPS_CLAIM and JWT_ROLE_CLAIM don't overwrite other claims
+func ValidateClaimNames(env *viper.Viper) error {
+	apps, role := AppsClaim(env), RoleClaim(env)
+	if apps == role {
+		return fmt.Errorf("JWT_APPS_CLAIM and JWT_ROLE_CLAIM can't both be %q", apps)
+	}
+	for _, reserved := range reservedClaims {
+		if apps == reserved || role == reserved {
+			return fmt.Errorf("JWT_APPS_CLAIM and JWT_ROLE_CLAIM can't be the reserved claim %q", reserved)
+		}
+	}
+	return nil
+}
+
+// identityFromClaims reads the identity from the claims of a validated token.
+// Tokens issued before JWT_APPS_CLAIM was changed keep their scope, the legacy claim is read when the
+// configured one is missing. Tokens without a role get the default one.
+func identityFromClaims(claims jwt.MapClaims, env *viper.Viper) Identity {
+	username, _ := claims["username"].(string)
+	identity := Identity{Username: username}
+
+	apps, ok := claims[AppsClaim(env)].([]interface{})
+	if !ok {
+		apps, ok = claims[legacyAppsClaim].([]interface{})
+	}
+	if ok {
+		identity.Apps = make([]string, 0, len(apps))
+		for _, app := range apps {
+			if name, ok := app.(string); ok {
+				identity.Apps = append(identity.Apps, name)
+			}
+		}
+	}
+
+	identity.Role, _ = claims[RoleClaim(env)].(string)
+	if identity.Role == "" {
+		identity.Role = DefaultRole(identity.Apps)
+	}
+	return identity
+}
+
+// CurrentIdentity returns the identity set by AuthMiddleware, false on routes without authentication
+func CurrentIdentity(c *gin.Context) (Identity, bool) {
+	value, ok := c.Get(identityKey)
+	if !ok {
+		return Identity{}, false
+	}
+	identity, ok := value.(Identity)
+	return identity, ok
+}
+
+// CurrentRole returns the role of the caller, empty on routes without authentication
+func CurrentRole(c *gin.Context) string {
+	identity, _ := CurrentIdentity(c)
+	return identity.Role
+}
+
+// IsAdmin reports whether the caller has the admin role and a token that isn't scoped to apps
+func IsAdmin(c *gin.Context) bool {
+	identity, ok := CurrentIdentity(c)
+	return ok && identity.Role == RoleAdmin && !identity.Scoped()
+}
+
+// RequireAdmin responds with 403 unless the caller is an admin, action completes the message,
+// e.g. "backfill checksums". It returns false if the response was already written.
+func RequireAdmin(c *gin.Context, action string) bool {
+	if IsAdmin(c) {
+		return true
+	}
+	RespondError(c, http.StatusForbidden, "only admins can "+action)
+	return false
+}
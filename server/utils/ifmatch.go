@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errInvalidIfMatch = errors.New(`invalid If-Match header, expected the revision of the version, e.g. "3"`)
+
+// IfMatchRevision returns the revision of a version sent in the If-Match header, as "3", W/"3" or 3.
+// It reports false without the header or with *, which matches any revision.
+func IfMatchRevision(c *gin.Context) (int64, bool, error) {
+	value := strings.TrimSpace(c.GetHeader("If-Match"))
+	if value == "" || value == "*" {
+		return 0, false, nil
+	}
+	value = strings.Trim(strings.TrimPrefix(value, "W/"), `"`)
+	revision, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || revision < 0 {
+		return 0, false, errInvalidIfMatch
+	}
+	return revision, true, nil
+}
+
+// RevisionETag returns the ETag of a version at revision, clients send it back with If-Match
+func RevisionETag(revision int64) string {
+	return fmt.Sprintf(`"%d"`, revision)
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// uploadRetryAfter is the Retry-After value, in seconds, sent when all upload slots are taken
+const uploadRetryAfter = 30
+
+// UploadLimitMiddleware limits the number of uploads processed at the same time to max.
+// Further uploads are rejected with 503 instead of waiting, so clients retry later.
+// Requests declaring a body smaller than exemptBytes don't take a slot, 0 exempts none.
+func UploadLimitMiddleware(max int, exemptBytes int64) gin.HandlerFunc {
+	slots := make(chan struct{}, max)
+	return func(c *gin.Context) {
+		if exemptBytes > 0 && c.Request.ContentLength >= 0 && c.Request.ContentLength < exemptBytes {
+			c.Next()
+			return
+		}
+
+		select {
+		case slots <- struct{}{}:
+		default:
+			logrus.Warnf("Rejected upload to %s: %d uploads are already in progress", c.Request.URL.Path, max)
+			c.Header("Retry-After", strconv.Itoa(uploadRetryAfter))
+			AbortError(c, http.StatusServiceUnavailable, "too many uploads in progress, retry later")
+			return
+		}
+		defer func() { <-slots }()
+
+		c.Next()
+	}
+}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/spf13/viper"
+)
+
+// defaultLogoMaxBytes is the size limit of app logos when LOGO_MAX_BYTES isn't set
+const defaultLogoMaxBytes = 1 << 20
+
+// logoTypes maps the accepted image types to the extension of the stored logo.
+// SVG isn't accepted, it can carry scripts that run when the logo is opened from the bucket.
+var logoTypes = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// LogoMaxBytes returns the size limit of app logos
+func LogoMaxBytes(env *viper.Viper) int64 {
+	if maxBytes := env.GetInt64("LOGO_MAX_BYTES"); maxBytes > 0 {
+		return maxBytes
+	}
+	return defaultLogoMaxBytes
+}
+
+// ValidateLogo checks that data is a PNG, JPEG, GIF or WebP image within maxBytes.
+// The type is detected from the content, the file name and the sent content type are ignored.
+// It returns the content type and the extension of the stored logo.
+func ValidateLogo(data []byte, maxBytes int64) (string, string, error) {
+	if len(data) == 0 {
+		return "", "", fmt.Errorf("logo is empty")
+	}
+	if int64(len(data)) > maxBytes {
+		return "", "", fmt.Errorf("logo is larger than %d bytes", maxBytes)
+	}
+	contentType := http.DetectContentType(data)
+	extension, ok := logoTypes[contentType]
+	if !ok {
+		return "", "", fmt.Errorf("logo must be a PNG, JPEG, GIF or WebP image, got %s", contentType)
+	}
+	return contentType, extension, nil
+}
+
+// BuildLogoObject returns the link and the key of the logo of an app, stored next to its artifacts
+func BuildLogoObject(appName, extension string, env *viper.Viper) (string, string) {
+	s3Key := appName + "/logo" + extension
+	return fmt.Sprintf("%s/%s", env.GetString("S3_ENDPOINT"), url.PathEscape(s3Key)), s3Key
+}
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// MirrorBases returns the base URLs of the mirrors configured with MIRROR_DOWNLOAD_BASES.
+// Every mirror serves a copy of the bucket under the same keys, e.g. a replicated bucket or its CDN.
+func MirrorBases(env *viper.Viper) []string {
+	var bases []string
+	for _, base := range strings.Split(env.GetString("MIRROR_DOWNLOAD_BASES"), ",") {
+		if base = strings.TrimSuffix(strings.TrimSpace(base), "/"); base != "" {
+			bases = append(bases, base)
+		}
+	}
+	return bases
+}
+
+// DownloadLinks returns the links an artifact can be downloaded from, the public download link first
+// and the mirrors after it. Links that don't start with S3_ENDPOINT have no mirrors.
+// With signed downloads enabled the first link is a signed /download link instead of the public one.
+func DownloadLinks(link string, env *viper.Viper) []string {
+	links := []string{clientDownloadLink(link, env)}
+	endpoint := strings.TrimSuffix(env.GetString("S3_ENDPOINT"), "/")
+	if endpoint == "" || !strings.HasPrefix(link, endpoint+"/") {
+		return links
+	}
+	for _, base := range MirrorBases(env) {
+		mirror := base + strings.TrimPrefix(link, endpoint)
+		if mirror != links[0] {
+			links = append(links, mirror)
+		}
+	}
+	return links
+}
+
+// DownloadURL returns the download links of an artifact as a list when mirrors are configured.
+// Without mirrors, or with SINGLE_DOWNLOAD_URL for clients that expect one URL, it returns the public download link.
+func DownloadURL(link string, env *viper.Viper) interface{} {
+	if env.GetBool("SINGLE_DOWNLOAD_URL") || len(MirrorBases(env)) == 0 {
+		return clientDownloadLink(link, env)
+	}
+	return DownloadLinks(link, env)
+}
+
+// clientDownloadLink returns the signed /download link when signed downloads are enabled, otherwise the public download link
+func clientDownloadLink(link string, env *viper.Viper) string {
+	if SignedDownloadsEnabled(env) {
+		return SignDownloadLink(link, time.Now(), env)
+	}
+	return PublicDownloadLink(link, env)
+}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"faynoSync/server/model"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// ValidateMissingTargetPolicy checks the mode of a policy and the names of its fallback combination
+func ValidateMissingTargetPolicy(policy model.MissingTargetPolicy) error {
+	switch policy.Mode {
+	case model.MissingTargetNotFound, model.MissingTargetNoUpdate:
+		if policy.FallbackPlatform != "" || policy.FallbackArch != "" {
+			return fmt.Errorf("fallback_platform and fallback_arch are only used with mode %s", model.MissingTargetFallback)
+		}
+	case model.MissingTargetFallback:
+		if policy.FallbackPlatform == "" && policy.FallbackArch == "" {
+			return fmt.Errorf("mode %s requires fallback_platform or fallback_arch", model.MissingTargetFallback)
+		}
+		if !IsValidPlatformName(policy.FallbackPlatform) {
+			return fmt.Errorf("invalid fallback_platform")
+		}
+		if !IsValidArchName(policy.FallbackArch) {
+			return fmt.Errorf("invalid fallback_arch")
+		}
+	default:
+		return fmt.Errorf("invalid mode %q, allowed: %s, %s, %s", policy.Mode, model.MissingTargetNotFound, model.MissingTargetNoUpdate, model.MissingTargetFallback)
+	}
+	return nil
+}
+
+// DefaultMissingTargetPolicy returns the policy of apps without their own, set by MISSING_TARGET_MODE and
+// MISSING_TARGET_FALLBACK (platform/arch, e.g. universal/all). It's nil when MISSING_TARGET_MODE isn't set.
+func DefaultMissingTargetPolicy(env *viper.Viper) (*model.MissingTargetPolicy, error) {
+	mode := strings.TrimSpace(env.GetString("MISSING_TARGET_MODE"))
+	if mode == "" {
+		return nil, nil
+	}
+	policy := model.MissingTargetPolicy{Mode: mode}
+	if fallback := strings.TrimSpace(env.GetString("MISSING_TARGET_FALLBACK")); fallback != "" {
+		platform, arch, ok := strings.Cut(fallback, "/")
+		if !ok {
+			return nil, fmt.Errorf("invalid MISSING_TARGET_FALLBACK %q, expected platform/arch", fallback)
+		}
+		policy.FallbackPlatform, policy.FallbackArch = platform, arch
+	}
+	if err := ValidateMissingTargetPolicy(policy); err != nil {
+		return nil, fmt.Errorf("invalid MISSING_TARGET_MODE: %w", err)
+	}
+	return &policy, nil
+}
@@ -9,10 +9,14 @@ import (
 	"github.com/spf13/viper"
 )
 
+// SlackAPI returns the client of the Slack bot with SLACK_BOT_TOKEN
+var SlackAPI = func(env *viper.Viper) *slack.Client {
+	return slack.New(env.GetString("SLACK_BOT_TOKEN"))
+}
+
 func SendSlackNotification(appName, channel, version string, platforms, arches, artifacts, changelog, extensions []string, env *viper.Viper, publish, critical bool) {
-	token := env.GetString("SLACK_BOT_TOKEN")
 	channelID := env.GetString("SLACK_CHANNEL")
-	api := slack.New(token)
+	api := SlackAPI(env)
 
 	logrus.WithFields(logrus.Fields{
 		"App Name":            appName,
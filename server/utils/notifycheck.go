@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/spf13/viper"
+)
+
+// Notification backends, enabled with SLACK_ENABLE and EMAIL_ENABLE
+const (
+	NotificationSlack = "slack"
+	NotificationEmail = "email"
+)
+
+// notificationCheckTTL is how long the results of CheckNotificationBackends are reused,
+// so the public health check doesn't log in to Slack and SMTP on every request
+const notificationCheckTTL = 30 * time.Second
+
+const (
+	testNotificationSubject = "faynoSync test notification"
+	testNotificationText    = "This is a test notification from faynoSync. Release notifications are delivered here."
+)
+
+// NotificationCheck is the result of checking a notification backend or sending a test notification through it
+type NotificationCheck struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+type notificationBackend struct {
+	name    string
+	enabled string
+	check   func(ctx context.Context, env *viper.Viper) error
+	send    func(ctx context.Context, env *viper.Viper) error
+}
+
+var notificationBackends = []notificationBackend{
+	{name: NotificationSlack, enabled: "SLACK_ENABLE", check: checkSlack, send: sendSlackTest},
+	{name: NotificationEmail, enabled: "EMAIL_ENABLE", check: checkSMTP, send: sendEmailTest},
+}
+
+// notificationChecks holds the last results of CheckNotificationBackends until expires
+var notificationChecks struct {
+	mu      sync.Mutex
+	results map[string]NotificationCheck
+	expires time.Time
+}
+
+// NotificationBackends returns the names of the enabled notification backends
+func NotificationBackends(env *viper.Viper) []string {
+	var names []string
+	for _, backend := range notificationBackends {
+		if env.GetBool(backend.enabled) {
+			names = append(names, backend.name)
+		}
+	}
+	return names
+}
+
+// CheckNotificationBackends checks the enabled backends without sending anything: Slack by authenticating
+// SLACK_BOT_TOKEN, email by logging in to SMTP_HOST. The results are reused for notificationCheckTTL,
+// concurrent callers wait for the running check instead of starting their own.
+func CheckNotificationBackends(ctx context.Context, env *viper.Viper) map[string]NotificationCheck {
+	notificationChecks.mu.Lock()
+	defer notificationChecks.mu.Unlock()
+	if notificationChecks.results != nil && time.Now().Before(notificationChecks.expires) {
+		return maps.Clone(notificationChecks.results)
+	}
+
+	results := runNotificationBackends(ctx, env, func(backend notificationBackend) func(context.Context, *viper.Viper) error {
+		return backend.check
+	})
+	// A check cut short by the caller says nothing about the backends
+	if ctx.Err() == nil {
+		notificationChecks.results = results
+		notificationChecks.expires = time.Now().Add(notificationCheckTTL)
+	}
+	return maps.Clone(results)
+}
+
+// SendTestNotifications sends a test notification through each enabled backend.
+// The cached results of CheckNotificationBackends are dropped, so the next check reaches the backends.
+func SendTestNotifications(ctx context.Context, env *viper.Viper) map[string]NotificationCheck {
+	notificationChecks.mu.Lock()
+	notificationChecks.results = nil
+	notificationChecks.mu.Unlock()
+
+	return runNotificationBackends(ctx, env, func(backend notificationBackend) func(context.Context, *viper.Viper) error {
+		return backend.send
+	})
+}
+
+// runNotificationBackends runs the check or send of the enabled backends at the same time,
+// so a slow SMTP server doesn't delay the result of Slack
+func runNotificationBackends(ctx context.Context, env *viper.Viper, run func(notificationBackend) func(context.Context, *viper.Viper) error) map[string]NotificationCheck {
+	results := make(map[string]NotificationCheck)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, backend := range notificationBackends {
+		if !env.GetBool(backend.enabled) {
+			continue
+		}
+		wg.Add(1)
+		go func(backend notificationBackend) {
+			defer wg.Done()
+			result := NotificationCheck{OK: true}
+			if err := run(backend)(ctx, env); err != nil {
+				result = NotificationCheck{Error: err.Error()}
+			}
+			mu.Lock()
+			results[backend.name] = result
+			mu.Unlock()
+		}(backend)
+	}
+	wg.Wait()
+	return results
+}
+
+func checkSlack(ctx context.Context, env *viper.Viper) error {
+	_, err := SlackAPI(env).AuthTestContext(ctx)
+	return err
+}
+
+func sendSlackTest(ctx context.Context, env *viper.Viper) error {
+	_, _, err := SlackAPI(env).PostMessageContext(ctx, env.GetString("SLACK_CHANNEL"), slack.MsgOptionText(testNotificationText, false))
+	return err
+}
+
+func checkSMTP(ctx context.Context, env *viper.Viper) error {
+	client, err := smtpSession(ctx, env)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if err := client.Noop(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func sendEmailTest(ctx context.Context, env *viper.Viper) error {
+	return sendEmail(ctx, testNotificationSubject, testNotificationText, env)
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+// forwardedIPHeaders are the headers the client IP is read from on requests of trusted proxies, in order
+var forwardedIPHeaders = []string{"X-Forwarded-For", "X-Real-IP"}
+
+// TrustedProxies parses TRUSTED_PROXIES, the comma-separated addresses and CIDRs of the load balancers
+// and proxies in front of the server. None are trusted when it isn't set.
+func TrustedProxies(env *viper.Viper) ([]*net.IPNet, error) {
+	var proxies []*net.IPNet
+	for _, entry := range strings.Split(env.GetString("TRUSTED_PROXIES"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			proxies = append(proxies, network)
+			continue
+		}
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid TRUSTED_PROXIES entry %q, expected an IP address or a CIDR", entry)
+		}
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 8*net.IPv4len
+		}
+		proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	return proxies, nil
+}
+
+// ConfigureTrustedProxies makes c.ClientIP() return the real client: on requests of the proxies it is read
+// from X-Forwarded-For, skipping the proxies themselves, or X-Real-IP. Other requests can't spoof it with
+// these headers, their client is the peer of the connection.
+func ConfigureTrustedProxies(router *gin.Engine, proxies []*net.IPNet) error {
+	cidrs := make([]string, len(proxies))
+	for i, proxy := range proxies {
+		cidrs[i] = proxy.String()
+	}
+	router.ForwardedByClientIP = true
+	router.RemoteIPHeaders = forwardedIPHeaders
+	return router.SetTrustedProxies(cidrs)
+}
+
+// FromTrustedProxy reports whether the peer of the connection is one of the proxies
+func FromTrustedProxy(r *http.Request, proxies []*net.IPNet) bool {
+	host, _, err := net.SplitHostPort(strings.TrimSpace(r.RemoteAddr))
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, proxy := range proxies {
+		if proxy.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,211 @@
+package utils
+
+import (
+	"container/list"
+	"context"
+	"faynoSync/server/model"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// rateLimitMaxPeriod is the longest period of a rate limit, counters live for one period
+	rateLimitMaxPeriod = 24 * time.Hour
+	// rateLimitCacheTTL is how long the limit of an app is kept in memory before it's read again
+	rateLimitCacheTTL = 30 * time.Second
+	// rateLimitCacheSize caps the number of apps whose limits and counters are kept in memory,
+	// the least recently used app is evicted first
+	rateLimitCacheSize = 10000
+	rateLimitKeyPrefix = "faynosync:ratelimit:"
+)
+
+// ValidateRateLimit checks the number of requests and the period of a rate limit and returns the period
+func ValidateRateLimit(limit model.AppRateLimit) (time.Duration, error) {
+	if limit.Requests <= 0 {
+		return 0, fmt.Errorf("requests must be greater than 0")
+	}
+	period, err := time.ParseDuration(limit.Period)
+	if err != nil || period < time.Second || period > rateLimitMaxPeriod {
+		return 0, fmt.Errorf("invalid period %q, expected a duration between 1s and %s, e.g. 1m", limit.Period, rateLimitMaxPeriod)
+	}
+	return period, nil
+}
+
+// RateLimitStatus is the quota of an app in the current window, sent to clients in X-RateLimit-* headers
+type RateLimitStatus struct {
+	Limit     int64
+	Remaining int64
+	// Reset is when the current window ends and the quota is restored
+	Reset   time.Time
+	Allowed bool
+}
+
+// Headers returns the X-RateLimit-* headers of the status, Reset is in Unix seconds
+func (s RateLimitStatus) Headers() map[string]string {
+	return map[string]string{
+		"X-RateLimit-Limit":     strconv.FormatInt(s.Limit, 10),
+		"X-RateLimit-Remaining": strconv.FormatInt(s.Remaining, 10),
+		"X-RateLimit-Reset":     strconv.FormatInt(s.Reset.Unix(), 10),
+	}
+}
+
+// rateLimitEntry is the cached limit and the in-memory counter of an app
+type rateLimitEntry struct {
+	app     string
+	limit   *model.AppRateLimit
+	expires time.Time
+	// start and count are the window of the in-memory counter
+	start time.Time
+	count int64
+}
+
+// RateLimiter counts the requests of each app in fixed windows of the period of its limit.
+// Counters are kept in Redis in performance mode, so all instances share them, and in memory otherwise
+// or while Redis is unavailable.
+type RateLimiter struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	order      *list.List
+	now        func() time.Time
+}
+
+// NewRateLimiter returns a rate limiter without cached limits or counters
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{
+		maxEntries: rateLimitCacheSize,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+		now:        time.Now,
+	}
+}
+
+var appRateLimiter = NewRateLimiter()
+
+// AppRateLimiter returns the rate limiter of the public info endpoints
+func AppRateLimiter() *RateLimiter {
+	return appRateLimiter
+}
+
+// Limit returns the rate limit of app, calling load when it isn't cached or the cached one is older than
+// rateLimitCacheTTL. Apps without a limit are cached as well, so their requests don't query the database.
+// Nothing is cached when load fails, so load should fail for names of apps that don't exist.
+func (r *RateLimiter) Limit(app string, load func() (*model.AppRateLimit, error)) (*model.AppRateLimit, error) {
+	r.mu.Lock()
+	if element, ok := r.entries[app]; ok {
+		item := element.Value.(*rateLimitEntry)
+		if r.now().Before(item.expires) {
+			r.order.MoveToFront(element)
+			r.mu.Unlock()
+			return item.limit, nil
+		}
+	}
+	r.mu.Unlock()
+
+	limit, err := load()
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	item := r.entry(app)
+	item.limit = limit
+	item.expires = r.now().Add(rateLimitCacheTTL)
+	return limit, nil
+}
+
+// Forget drops the cached limit and the in-memory counter of app after its limit was changed.
+// It only affects this instance, the others read the new limit once their cached one expires.
+func (r *RateLimiter) Forget(app string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if element, ok := r.entries[app]; ok {
+		r.removeElement(element)
+	}
+}
+
+// Take counts a request of app against limit. The counter is kept in rdb when it's not nil.
+func (r *RateLimiter) Take(ctx context.Context, rdb *redis.Client, app string, limit model.AppRateLimit) (RateLimitStatus, error) {
+	period, err := ValidateRateLimit(limit)
+	if err != nil {
+		return RateLimitStatus{}, err
+	}
+	start := r.now().Truncate(period)
+
+	count, ok := int64(0), false
+	if rdb != nil {
+		count, ok = redisRateCount(ctx, rdb, rateLimitKeyPrefix+app+":"+strconv.FormatInt(start.Unix(), 10), period)
+	}
+	if !ok {
+		count = r.memoryRateCount(app, start)
+	}
+
+	remaining := limit.Requests - count
+	if remaining < 0 {
+		remaining = 0
+	}
+	return RateLimitStatus{
+		Limit:     limit.Requests,
+		Remaining: remaining,
+		Reset:     start.Add(period),
+		Allowed:   count <= limit.Requests,
+	}, nil
+}
+
+func (r *RateLimiter) memoryRateCount(app string, start time.Time) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	item := r.entry(app)
+	if !item.start.Equal(start) {
+		item.start = start
+		item.count = 0
+	}
+	item.count++
+	return item.count
+}
+
+// entry returns the entry of app as the most recently used one, adding it and evicting the least
+// recently used entry when the cache is full. r.mu must be held.
+func (r *RateLimiter) entry(app string) *rateLimitEntry {
+	if element, ok := r.entries[app]; ok {
+		r.order.MoveToFront(element)
+		return element.Value.(*rateLimitEntry)
+	}
+	item := &rateLimitEntry{app: app}
+	r.entries[app] = r.order.PushFront(item)
+	for r.maxEntries > 0 && r.order.Len() > r.maxEntries {
+		r.removeElement(r.order.Back())
+	}
+	return item
+}
+
+func (r *RateLimiter) removeElement(element *list.Element) {
+	r.order.Remove(element)
+	delete(r.entries, element.Value.(*rateLimitEntry).app)
+}
+
+// redisRateCount increments the counter at key through the Redis circuit breaker. It reports false
+// when Redis failed or is bypassed, the request is then counted in memory.
+func redisRateCount(ctx context.Context, rdb *redis.Client, key string, period time.Duration) (int64, bool) {
+	breaker := RedisBreaker()
+	if !breaker.Allow() {
+		return 0, false
+	}
+	var incr *redis.IntCmd
+	_, err := rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, key)
+		pipe.Expire(ctx, key, period)
+		return nil
+	})
+	breaker.Record(err)
+	if err != nil {
+		logrus.Error("Error counting request in Redis:", err)
+		return 0, false
+	}
+	return incr.Val(), true
+}
@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// REDIS_FAILURE_MODE values. Update checks either bypass an unavailable Redis and query MongoDB,
+// or are answered with 503 so MongoDB isn't flooded with the traffic the cache normally absorbs.
+const (
+	RedisFailOpen   = "open"
+	RedisFailClosed = "closed"
+)
+
+// Defaults of the circuit breaker in front of Redis
+const (
+	defaultRedisBreakerThreshold = 5
+	defaultRedisBreakerCooldown  = 30 * time.Second
+)
+
+// ErrCacheUnavailable is returned for cache lookups while Redis is failing or the breaker is open
+var ErrCacheUnavailable = errors.New("cache is unavailable")
+
+// RedisFailureMode returns the configured REDIS_FAILURE_MODE, fail-open when it isn't set
+func RedisFailureMode(env *viper.Viper) string {
+	mode := strings.ToLower(strings.TrimSpace(env.GetString("REDIS_FAILURE_MODE")))
+	if mode == "" {
+		return RedisFailOpen
+	}
+	return mode
+}
+
+// ValidRedisFailureMode reports whether mode is one of the REDIS_FAILURE_MODE values
+func ValidRedisFailureMode(mode string) bool {
+	return mode == RedisFailOpen || mode == RedisFailClosed
+}
+
+// Circuit breaker states
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// CircuitBreaker stops calls to a failing backend. After threshold consecutive failures it opens and rejects
+// calls for the cooldown, then lets a single trial call through: its success closes the breaker, its failure
+// opens it for another cooldown.
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+	trial     bool
+	now       func() time.Time
+}
+
+// NewCircuitBreaker returns a closed breaker, non-positive values fall back to the defaults
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	b := &CircuitBreaker{now: time.Now}
+	b.Configure(threshold, cooldown)
+	return b
+}
+
+// Configure changes the threshold and cooldown of the breaker and closes it
+func (b *CircuitBreaker) Configure(threshold int, cooldown time.Duration) {
+	if threshold <= 0 {
+		threshold = defaultRedisBreakerThreshold
+	}
+	if cooldown <= 0 {
+		cooldown = defaultRedisBreakerCooldown
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.threshold, b.cooldown = threshold, cooldown
+	b.failures, b.openUntil, b.trial = 0, time.Time{}, false
+}
+
+// Allow reports whether a call may be made, false while the breaker is open
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return true
+	}
+	if b.trial || b.now().Before(b.openUntil) {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+// Record counts the result of a call. redis.Nil is a cache miss, not a failure.
+func (b *CircuitBreaker) Record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+	if err == nil || errors.Is(err, redis.Nil) {
+		if b.failures >= b.threshold {
+			logrus.Infoln("Redis is available again, closing the circuit breaker")
+		}
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		if b.failures == b.threshold {
+			logrus.Errorf("Redis failed %d times in a row, bypassing it for %s: %v", b.failures, b.cooldown, err)
+		}
+		b.openUntil = b.now().Add(b.cooldown)
+	}
+}
+
+// State returns closed, open or half-open when the cooldown is over and the next call is a trial
+func (b *CircuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case b.failures < b.threshold:
+		return BreakerClosed
+	case b.trial || b.now().Before(b.openUntil):
+		return BreakerOpen
+	default:
+		return BreakerHalfOpen
+	}
+}
+
+var redisBreaker = NewCircuitBreaker(defaultRedisBreakerThreshold, defaultRedisBreakerCooldown)
+
+// RedisBreaker returns the circuit breaker in front of the Redis cache
+func RedisBreaker() *CircuitBreaker {
+	return redisBreaker
+}
+
+// ConfigureRedisBreaker applies REDIS_BREAKER_THRESHOLD and REDIS_BREAKER_COOLDOWN to the breaker
+func ConfigureRedisBreaker(env *viper.Viper) {
+	redisBreaker.Configure(env.GetInt("REDIS_BREAKER_THRESHOLD"), env.GetDuration("REDIS_BREAKER_COOLDOWN"))
+}
@@ -0,0 +1,223 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Request log modes set by REQUEST_LOG
+const (
+	RequestLogOff   = "off"
+	RequestLogBasic = "basic"
+	RequestLogFull  = "full"
+)
+
+const redactedValue = "*****"
+
+// sensitiveFieldParts mark the headers, query parameters and form fields whose values are never logged.
+// Names are compared upper-cased with dashes as underscores, so X-Api-Key matches API_KEY.
+var sensitiveFieldParts = append([]string{"AUTHORIZATION", "COOKIE", "SIGNATURE", "CREDENTIAL"}, secretKeyParts...)
+
+// IsSensitiveField reports whether the value of a header, query parameter or form field must not be logged
+func IsSensitiveField(name string) bool {
+	name = strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+	for _, part := range sensitiveFieldParts {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactHeaders returns a copy of headers with the values of sensitive headers replaced
+func RedactHeaders(headers http.Header) http.Header {
+	redacted := make(http.Header, len(headers))
+	for name, values := range headers {
+		redacted[name] = redactList(name, values)
+	}
+	return redacted
+}
+
+// RedactValues returns a copy of query or form values with the sensitive ones replaced.
+// Values holding JSON, such as the data field of admin requests, are redacted key by key.
+func RedactValues(values url.Values) url.Values {
+	redacted := make(url.Values, len(values))
+	for name, list := range values {
+		list = redactList(name, list)
+		for i, value := range list {
+			if document, ok := redactJSON(value); ok {
+				list[i] = document
+			}
+		}
+		redacted[name] = list
+	}
+	return redacted
+}
+
+// RedactQuery returns the raw query with the values of sensitive parameters replaced.
+// A query that can't be parsed is dropped as a whole, it could still carry a secret.
+func RedactQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return redactedValue
+	}
+	return RedactValues(values).Encode()
+}
+
+// RedactPath redacts the query of a request path such as /download/key?signature=...
+func RedactPath(path string) string {
+	path, rawQuery, found := strings.Cut(path, "?")
+	if !found {
+		return path
+	}
+	return path + "?" + RedactQuery(rawQuery)
+}
+
+func redactList(name string, values []string) []string {
+	list := make([]string, len(values))
+	for i, value := range values {
+		if IsSensitiveField(name) && value != "" {
+			value = redactedValue
+		}
+		list[i] = value
+	}
+	return list
+}
+
+// redactJSON redacts the sensitive keys of a JSON object or array, it returns false for any other value
+func redactJSON(value string) (string, bool) {
+	trimmed := strings.TrimSpace(value)
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+		return value, false
+	}
+	var document interface{}
+	if err := json.Unmarshal([]byte(trimmed), &document); err != nil {
+		return value, false
+	}
+	redacted, err := json.Marshal(redactJSONValue(document))
+	if err != nil {
+		return value, false
+	}
+	return string(redacted), true
+}
+
+func redactJSONValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if IsSensitiveField(key) {
+				v[key] = redactedValue
+			} else {
+				v[key] = redactJSONValue(field)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactJSONValue(item)
+		}
+	}
+	return value
+}
+
+// RequestLogMode returns the configured REQUEST_LOG mode, off when it isn't set
+func RequestLogMode(env *viper.Viper) string {
+	mode := strings.ToLower(strings.TrimSpace(env.GetString("REQUEST_LOG")))
+	if mode == "" {
+		return RequestLogOff
+	}
+	return mode
+}
+
+// ValidRequestLogMode reports whether mode is one of the REQUEST_LOG modes
+func ValidRequestLogMode(mode string) bool {
+	return mode == RequestLogOff || mode == RequestLogBasic || mode == RequestLogFull
+}
+
+// AccessLogFormatter formats gin's access log like its default formatter, with sensitive query parameters
+// such as signatures of download links redacted
+func AccessLogFormatter(param gin.LogFormatterParams) string {
+	param.Path = RedactPath(param.Path)
+	if param.Latency > time.Minute {
+		param.Latency = param.Latency.Truncate(time.Second)
+	}
+	return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		param.StatusCode,
+		param.Latency,
+		param.ClientIP,
+		param.Method,
+		param.Path,
+		param.ErrorMessage,
+	)
+}
+
+// RequestLogMiddleware logs every handled request as a structured entry. basic logs the method, path,
+// redacted query, status, duration and client; full adds the redacted headers and the form fields the
+// handler read. Uploaded files are logged by name and size, never their content.
+func RequestLogMiddleware(mode string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if mode != RequestLogBasic && mode != RequestLogFull {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		c.Next()
+
+		fields := logrus.Fields{
+			"method":      c.Request.Method,
+			"path":        c.Request.URL.Path,
+			"status":      c.Writer.Status(),
+			"duration_ms": time.Since(start).Milliseconds(),
+			"client_ip":   c.ClientIP(),
+		}
+		if query := RedactQuery(c.Request.URL.RawQuery); query != "" {
+			fields["query"] = query
+		}
+		if username, ok := c.Get("username"); ok {
+			fields["username"] = username
+		}
+		if mode == RequestLogFull {
+			fields["headers"] = RedactHeaders(c.Request.Header)
+			if form := requestForm(c.Request); len(form) > 0 {
+				fields["form"] = form
+			}
+			if files := requestFiles(c.Request); len(files) > 0 {
+				fields["files"] = files
+			}
+		}
+		logrus.WithFields(fields).Info("request")
+	}
+}
+
+// requestForm returns the redacted form values the handler parsed, the body isn't read for logging
+func requestForm(r *http.Request) url.Values {
+	if r.MultipartForm != nil {
+		return RedactValues(r.MultipartForm.Value)
+	}
+	return RedactValues(r.PostForm)
+}
+
+// requestFiles lists the uploaded files of a parsed multipart form as name and size per field
+func requestFiles(r *http.Request) map[string][]string {
+	if r.MultipartForm == nil {
+		return nil
+	}
+	files := map[string][]string{}
+	for field, headers := range r.MultipartForm.File {
+		for _, header := range headers {
+			files[field] = append(files[field], fmt.Sprintf("%s (%d bytes)", header.Filename, header.Size))
+		}
+	}
+	return files
+}
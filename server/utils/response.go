@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"encoding/json"
+	"faynoSync/server/model"
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+const (
+	ResponseCasePascal = "pascal"
+	ResponseCaseSnake  = "snake"
+)
+
+// ResponseCase returns the key casing requested with ?case=, falling back to RESPONSE_CASE.
+// PascalCase is the default to keep existing clients working.
+func ResponseCase(c *gin.Context) (string, error) {
+	responseCase := c.Query("case")
+	if responseCase == "" {
+		responseCase = viper.GetString("RESPONSE_CASE")
+	}
+
+	switch responseCase {
+	case "", ResponseCasePascal:
+		return ResponseCasePascal, nil
+	case ResponseCaseSnake:
+		return ResponseCaseSnake, nil
+	default:
+		return "", fmt.Errorf("invalid case parameter, allowed: %s, %s", ResponseCasePascal, ResponseCaseSnake)
+	}
+}
+
+// FormatApps converts apps to the requested key casing
+func FormatApps(apps []*model.SpecificAppWithoutIDs, responseCase string) interface{} {
+	if responseCase != ResponseCaseSnake {
+		return apps
+	}
+
+	formatted := make([]*model.SpecificAppSnakeCase, 0, len(apps))
+	for _, app := range apps {
+		changelog := make([]model.ChangelogSnakeCase, 0, len(app.Changelog))
+		for _, entry := range app.Changelog {
+			changelog = append(changelog, model.ChangelogSnakeCase{
+				Version:   entry.Version,
+				Changes:   entry.Changes,
+				Raw:       entry.Raw,
+				Date:      entry.Date,
+				Truncated: entry.Truncated,
+			})
+		}
+		formatted = append(formatted, &model.SpecificAppSnakeCase{
+			ID:         app.ID,
+			AppName:    app.AppName,
+			Logo:       app.Logo,
+			Version:    app.Version,
+			Channel:    app.Channel,
+			Published:  app.Published,
+			Critical:   app.Critical,
+			Artifacts:  app.Artifacts,
+			Changelog:  changelog,
+			Properties: app.Properties,
+			Targeting:  app.Targeting,
+			Signatures: app.Signatures,
+			Yanked:     app.Yanked,
+			YankReason: app.YankReason,
+			Revision:   app.Revision,
+			UpdatedAt:  app.UpdatedAt,
+			Complete:   app.Complete,
+		})
+	}
+	return formatted
+}
+
+// pascalAppKeys maps the selectable fields of apps to their keys in PascalCase responses
+var pascalAppKeys = map[string]string{
+	"app_name":    "AppName",
+	"logo":        "Logo",
+	"version":     "Version",
+	"channel":     "Channel",
+	"published":   "Published",
+	"critical":    "Critical",
+	"artifacts":   "Artifacts",
+	"changelog":   "Changelog",
+	"properties":  "Properties",
+	"targeting":   "Targeting",
+	"signatures":  "Signatures",
+	"yanked":      "Yanked",
+	"yank_reason": "YankReason",
+	"updated_at":  "Updated_at",
+}
+
+// SelectAppFields keeps only the id and the selected fields of apps formatted by FormatApps.
+// fields are snake_case names, they are matched to the keys of responseCase.
+func SelectAppFields(formatted interface{}, fields []string, responseCase string) ([]map[string]interface{}, error) {
+	data, err := json.Marshal(formatted)
+	if err != nil {
+		return nil, err
+	}
+	var apps []map[string]interface{}
+	if err := json.Unmarshal(data, &apps); err != nil {
+		return nil, err
+	}
+
+	keep := map[string]bool{"ID": true, "id": true}
+	for _, field := range fields {
+		if responseCase == ResponseCaseSnake {
+			keep[field] = true
+		} else {
+			keep[pascalAppKeys[field]] = true
+		}
+		// Completeness is derived from the artifacts
+		if field == "artifacts" {
+			keep["complete"], keep["Complete"] = true, true
+		}
+	}
+	for _, app := range apps {
+		for key := range app {
+			if !keep[key] {
+				delete(app, key)
+			}
+		}
+	}
+	return apps, nil
+}
+
+// TruncateChangelog cuts changes to CHANGELOG_MAX_BYTES, keeping whole UTF-8 characters.
+// It reports whether changes was cut. 0 keeps the changelog whole.
+func TruncateChangelog(changes string, env *viper.Viper) (string, bool) {
+	maxBytes := env.GetInt("CHANGELOG_MAX_BYTES")
+	if maxBytes <= 0 || len(changes) <= maxBytes {
+		return changes, false
+	}
+	cut := maxBytes
+	for cut > 0 && !utf8.RuneStart(changes[cut]) {
+		cut--
+	}
+	return changes[:cut], true
+}
+
+// TruncateChangelogs cuts the changelog entries of the apps with TruncateChangelog and flags the cut ones
+func TruncateChangelogs(apps []*model.SpecificAppWithoutIDs) {
+	env := viper.GetViper()
+	for _, app := range apps {
+		for i := range app.Changelog {
+			app.Changelog[i].Changes, app.Changelog[i].Truncated = TruncateChangelog(app.Changelog[i].Changes, env)
+		}
+	}
+}
+
+// Actions of the responses of create, update and delete requests
+const (
+	ActionCreated = "created"
+	ActionUpdated = "updated"
+	ActionDeleted = "deleted"
+)
+
+// LegacyResultKeys reports whether responses keep the result keys clients read before, like
+// "createAppResult.Created", next to the entity. LEGACY_RESULT_KEYS=false drops them, they will be removed
+// in the next major version.
+func LegacyResultKeys(env *viper.Viper) bool {
+	legacy, err := strconv.ParseBool(env.GetString("LEGACY_RESULT_KEYS"))
+	return err != nil || legacy
+}
+
+// ResultResponse is the response of a create, update or delete request: the action, the type of the entity
+// and the entity as stored after the request, or as it was before it was deleted. legacy are the result keys
+// the endpoint returned before, kept while LegacyResultKeys is set.
+func ResultResponse(action, entityType string, entity interface{}, legacy gin.H) gin.H {
+	response := gin.H{}
+	if LegacyResultKeys(viper.GetViper()) {
+		for key, value := range legacy {
+			response[key] = value
+		}
+	}
+	response["action"] = action
+	response["type"] = entityType
+	response["entity"] = entity
+	return response
+}
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Defaults of the retries of failed S3 operations when S3_MAX_RETRIES and S3_RETRY_BASE_DELAY aren't set
+const (
+	defaultS3MaxRetries    = 3
+	defaultS3RetryBaseWait = 200 * time.Millisecond
+	maxS3RetryWait         = 10 * time.Second
+)
+
+// throttlingCodes are the S3 error codes returned when requests are rate limited
+var throttlingCodes = map[string]bool{
+	"SlowDown":                 true,
+	"Throttling":               true,
+	"ThrottlingException":      true,
+	"RequestLimitExceeded":     true,
+	"TooManyRequestsException": true,
+	"RequestTimeout":           true,
+}
+
+// S3RetryPolicy defines how often and how long apart failed S3 operations are retried
+type S3RetryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+}
+
+// S3Retries returns the retry policy configured with S3_MAX_RETRIES and S3_RETRY_BASE_DELAY.
+// S3_MAX_RETRIES=0 disables the retries.
+func S3Retries(env *viper.Viper) S3RetryPolicy {
+	policy := S3RetryPolicy{MaxRetries: defaultS3MaxRetries, BaseDelay: defaultS3RetryBaseWait}
+	if env.IsSet("S3_MAX_RETRIES") {
+		policy.MaxRetries = env.GetInt("S3_MAX_RETRIES")
+	}
+	if delay := env.GetDuration("S3_RETRY_BASE_DELAY"); delay > 0 {
+		policy.BaseDelay = delay
+	}
+	return policy
+}
+
+// Do runs operation until it succeeds, fails with an error that isn't retryable or the retries are used up.
+// The delay doubles after every attempt, up to 10s. Waiting stops when ctx is done.
+func (p S3RetryPolicy) Do(ctx context.Context, name string, operation func() error) error {
+	delay := p.BaseDelay
+	for attempt := 0; ; attempt++ {
+		err := operation()
+		if err == nil || attempt >= p.MaxRetries || !IsRetryableS3Error(err) {
+			return err
+		}
+		logrus.Warnf("S3 %s failed (attempt %d of %d), retrying in %s: %v", name, attempt+1, p.MaxRetries+1, delay, err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		delay *= 2
+		if delay > maxS3RetryWait {
+			delay = maxS3RetryWait
+		}
+	}
+}
+
+// IsRetryableS3Error reports whether err is transient: a 5xx response, throttling or a network error.
+// Client errors such as 403 and 404 and cancelled contexts aren't retried.
+func IsRetryableS3Error(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if response := minio.ToErrorResponse(err); response.StatusCode != 0 || response.Code != "" {
+		return retryableStatus(response.StatusCode, response.Code)
+	}
+
+	// AWS SDK errors carry the status code of the response and the S3 error code
+	var code string
+	var apiErr interface{ ErrorCode() string }
+	if errors.As(err, &apiErr) {
+		code = apiErr.ErrorCode()
+	}
+	var responseErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &responseErr) {
+		return retryableStatus(responseErr.HTTPStatusCode(), code)
+	}
+	if code != "" {
+		return throttlingCodes[code]
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func retryableStatus(status int, code string) bool {
+	return status >= http.StatusInternalServerError || status == http.StatusTooManyRequests || throttlingCodes[code]
+}
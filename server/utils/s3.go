@@ -1,17 +1,26 @@
 package utils
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"faynoSync/tracing"
 	"fmt"
+	"io"
 	"mime/multipart"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
+	awsv2 "github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/gin-gonic/gin"
 	"github.com/minio/minio-go/v7"
@@ -40,8 +49,7 @@ func createStorageClient() interface{} {
 
 	case "aws":
 		// Set up AWS S3 client
-		creds := credentials.NewStaticCredentialsProvider(env.GetString("S3_ACCESS_KEY"), env.GetString("S3_SECRET_KEY"), "")
-		cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithCredentialsProvider(creds), config.WithRegion(env.GetString("S3_REGION")))
+		cfg, err := AWSConfig(env)
 		if err != nil {
 			logrus.Errorf("error setting up AWS S3 client: %v", err)
 			return nil
@@ -54,30 +62,52 @@ func createStorageClient() interface{} {
 	}
 }
 
-func UploadToS3(ctxQuery map[string]interface{}, file *multipart.FileHeader, c *gin.Context, env *viper.Viper) (string, string, error) {
-	// // Create an S3 client using another func
-	storageClient := createStorageClient()
-
-	if storageClient == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create storage client"})
-		return "", "", errors.New("failed to create storage client")
+// AWSConfig returns the configuration of the AWS S3 client. Without S3_ROLE_ARN the static keys are used.
+// With it the role is assumed through STS, signed with the static keys or, when they are empty, the default
+// credential chain (e.g. an instance profile), and the temporary credentials are refreshed before they expire.
+func AWSConfig(env *viper.Viper) (awsv2.Config, error) {
+	roleARN := env.GetString("S3_ROLE_ARN")
+	opts := []func(*config.LoadOptions) error{config.WithRegion(env.GetString("S3_REGION"))}
+	if env.GetString("S3_ACCESS_KEY") != "" || roleARN == "" {
+		creds := credentials.NewStaticCredentialsProvider(env.GetString("S3_ACCESS_KEY"), env.GetString("S3_SECRET_KEY"), "")
+		opts = append(opts, config.WithCredentialsProvider(creds))
+	}
+	cfg, err := config.LoadDefaultConfig(context.TODO(), opts...)
+	if err != nil || roleARN == "" {
+		return cfg, err
 	}
 
+	provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), roleARN, func(o *stscreds.AssumeRoleOptions) {
+		if name := env.GetString("S3_ROLE_SESSION_NAME"); name != "" {
+			o.RoleSessionName = name
+		}
+		if externalID := env.GetString("S3_ROLE_EXTERNAL_ID"); externalID != "" {
+			o.ExternalID = awsv2.String(externalID)
+		}
+	})
+	cfg.Credentials = awsv2.NewCredentialsCache(provider)
+	logrus.Infof("S3 client assumes role %s", roleARN)
+	return cfg, nil
+}
+
+// BuildS3Object returns the public link, the object key and the extension used to store fileName
+func BuildS3Object(ctxQuery map[string]interface{}, fileName string, env *viper.Viper) (string, string, string) {
 	var extension string
 	// Extract base filename and extension
-	baseFileName := file.Filename
+	baseFileName := fileName
 	dotIndex := strings.Index(baseFileName, ".")
 	if dotIndex > -1 {
 		extension = baseFileName[dotIndex:]
 	}
 	// Generate new file name
-	newFileName := fmt.Sprintf("%s-%s%s", ctxQuery["app_name"].(string), ctxQuery["version"].(string), extension)
+	newFileName := artifactFileName(ctxQuery, env) + extension
 
+	bucket := ArtifactBucket(ctxQuery, env)
 	var link string
 	var s3Key string
 	if ctxQuery["channel"].(string) == "" && ctxQuery["platform"].(string) == "" && ctxQuery["arch"].(string) == "" {
-		link = fmt.Sprintf("%s/%s/%s", env.GetString("S3_ENDPOINT"), ctxQuery["app_name"].(string), newFileName)
 		s3Key = ctxQuery["app_name"].(string) + "/" + newFileName
+		link = fmt.Sprintf("%s/%s/%s", bucketEndpoint(bucket, env), ctxQuery["app_name"].(string), newFileName)
 	} else {
 		s3PathSegments := []string{ctxQuery["app_name"].(string)}
 
@@ -95,52 +125,225 @@ func UploadToS3(ctxQuery map[string]interface{}, file *multipart.FileHeader, c *
 
 		s3PathSegments = append(s3PathSegments, newFileName)
 		encodedPath := url.PathEscape(strings.Join(s3PathSegments, "/"))
-		link = fmt.Sprintf("%s/%s", env.GetString("S3_ENDPOINT"), encodedPath)
 		s3Key = strings.Join(s3PathSegments, "/")
+		link = fmt.Sprintf("%s/%s", bucketEndpoint(bucket, env), encodedPath)
+	}
+	return link, s3Key, extension
+}
+
+// Naming schemes of the stored artifacts, selected with ARTIFACT_NAME_SCHEME
+const (
+	// ArtifactNameVersion names artifacts app-version.ext
+	ArtifactNameVersion = "version"
+	// ArtifactNameFull names artifacts app-version-channel-platform-arch.ext, leaving out the empty parts
+	ArtifactNameFull = "full"
+)
+
+// ValidArtifactNameScheme reports whether scheme is a known ARTIFACT_NAME_SCHEME, empty selects ArtifactNameVersion
+func ValidArtifactNameScheme(scheme string) bool {
+	return scheme == "" || scheme == ArtifactNameVersion || scheme == ArtifactNameFull
+}
+
+// artifactFileName returns the stored file name without extension
+func artifactFileName(ctxQuery map[string]interface{}, env *viper.Viper) string {
+	parts := []string{ctxQuery["app_name"].(string), ctxQuery["version"].(string)}
+	if env.GetString("ARTIFACT_NAME_SCHEME") == ArtifactNameFull {
+		for _, field := range []string{"channel", "platform", "arch"} {
+			if value := GetStringValue(ctxQuery, field); value != "" {
+				parts = append(parts, value)
+			}
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+// S3KeyFromLink returns the key of the object a stored link points at, in any of the buckets.
+// Keys of stored artifacts have to be taken from their links, they may be named with a previous ARTIFACT_NAME_SCHEME.
+func S3KeyFromLink(link string, env *viper.Viper) string {
+	_, key := S3ObjectOfLink(link, env)
+	return key
+}
+
+// PublicDownloadLink returns the link clients should download the artifact from.
+// Stored links point at S3_ENDPOINT, when PUBLIC_DOWNLOAD_BASE is set (e.g. a CDN in front of the bucket)
+// the endpoint is replaced by it. Links that don't start with S3_ENDPOINT are returned unchanged.
+func PublicDownloadLink(link string, env *viper.Viper) string {
+	publicBase := strings.TrimSuffix(env.GetString("PUBLIC_DOWNLOAD_BASE"), "/")
+	endpoint := strings.TrimSuffix(env.GetString("S3_ENDPOINT"), "/")
+	if publicBase == "" || endpoint == "" || !strings.HasPrefix(link, endpoint+"/") {
+		return link
+	}
+	return publicBase + strings.TrimPrefix(link, endpoint)
+}
+
+// logS3Operation writes a structured log entry for an interaction with the bucket.
+// Only the object coordinates are logged, never the credentials.
+func logS3Operation(fields logrus.Fields, operation, bucket, s3Key string, size int64, start time.Time, err error) {
+	entry := logrus.WithFields(fields).WithFields(logrus.Fields{
+		"operation": operation,
+		"s3_key":    s3Key,
+		"bucket":    bucket,
+		"bytes":     size,
+		"duration":  time.Since(start).String(),
+	})
+	if err != nil {
+		entry.WithField("outcome", "failure").WithError(err).Error("S3 operation failed")
+		return
+	}
+	entry.WithField("outcome", "success").Info("S3 operation completed")
+}
+
+// s3LogFields returns the app coordinates of a request for S3 log entries, leaving out the unknown ones
+func s3LogFields(ctxQuery map[string]interface{}) logrus.Fields {
+	fields := logrus.Fields{}
+	for _, key := range []string{"app_name", "version"} {
+		if value := GetStringValue(ctxQuery, key); value != "" {
+			fields[key] = value
+		}
 	}
+	return fields
+}
+
+func UploadToS3(ctxQuery map[string]interface{}, file *multipart.FileHeader, c *gin.Context, env *viper.Viper) (string, string, error) {
+	ctx, span := tracing.StartSpan(c.Request.Context(), "UploadToS3", ctxQuery)
+	defer span.End()
+
+	// // Create an S3 client using another func
+	storageClient := createStorageClient()
+
+	if storageClient == nil {
+		RespondError(c, http.StatusInternalServerError, "failed to create storage client")
+		return "", "", errors.New("failed to create storage client")
+	}
+
+	link, s3Key, extension := BuildS3Object(ctxQuery, file.Filename, env)
+	bucket := ArtifactBucket(ctxQuery, env)
 
 	// Open the file for reading
 	fileReader, err := file.Open()
 	if err != nil {
 		logrus.Error(err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to open file for reading"})
+		tracing.RecordError(span, err)
+		return "", "", errors.New("failed to open file for reading")
+	}
+	defer fileReader.Close()
+
+	// Tags let bucket lifecycle rules act on the artifacts, e.g. expire nightly builds
+	tags, err := ObjectTags(ctxQuery, env)
+	if err != nil {
+		logrus.Error(err)
+		tracing.RecordError(span, err)
+		return "", "", err
+	}
+
+	// With STAGED_UPLOADS the file is uploaded next to its final key and only promoted once complete.
+	// The staged object is removed whether the promotion succeeds or not.
+	uploadKey := s3Key
+	if StagedUploads(env) {
+		uploadKey, err = stagingKey(s3Key)
+		if err != nil {
+			logrus.Error(err)
+			tracing.RecordError(span, err)
+			RespondError(c, http.StatusInternalServerError, "failed to upload file to S3")
+			return "", "", err
+		}
+		defer func() {
+			if err := removeStagedObject(ctx, bucket, uploadKey, env); err != nil {
+				logrus.Errorf("Failed to remove staged object %s: %v", uploadKey, err)
+			}
+		}()
 	}
 
-	// Upload file to S3
+	// Upload file to S3, the progress is reported by GET /uploads/status
+	tracked := DefaultUploadTracker().Start(ctxQuery, file.Filename, file.Size)
+	notifyUploadObserver(c, tracked)
+	start := time.Now()
 	switch client := storageClient.(type) {
 	case *minio.Client:
-		var uploadInfo minio.UploadInfo
-		uploadInfo, err = client.PutObject(c.Request.Context(), env.GetString("S3_BUCKET_NAME"), s3Key, fileReader, -1, minio.PutObjectOptions{})
-
-		logrus.Debugln("Upload Info:", uploadInfo)
-		link = uploadInfo.Location
+		var opts minio.PutObjectOptions
+		opts, err = minioPutOptions(env)
+		if err != nil {
+			break
+		}
+		opts.UserTags = tags
+		err = S3Retries(env).Do(ctx, "upload", func() error {
+			body, err := rewindUpload(fileReader, tracked)
+			if err != nil {
+				return err
+			}
+			uploadInfo, err := client.PutObject(ctx, bucket, uploadKey, body, -1, opts)
+			link = uploadInfo.Location
+			return err
+		})
 	case *s3.Client:
-		_, err = client.PutObject(c.Request.Context(), &s3.PutObjectInput{
-			Bucket: aws.String(env.GetString("S3_BUCKET_NAME")),
-			Key:    aws.String(s3Key),
-			Body:   fileReader,
+		err = S3Retries(env).Do(ctx, "upload", func() error {
+			body, err := rewindUpload(fileReader, tracked)
+			if err != nil {
+				return err
+			}
+			input := &s3.PutObjectInput{
+				Bucket: aws.String(bucket),
+				Key:    aws.String(uploadKey),
+				Body:   body,
+			}
+			if len(tags) > 0 {
+				input.Tagging = aws.String(EncodeObjectTags(tags))
+			}
+			if err := applyAWSEncryption(input, env); err != nil {
+				return err
+			}
+			_, err = client.PutObject(ctx, input)
+			return err
 		})
 	default:
 		logrus.Errorf("unknown storage client type")
-		return "", "", errors.New("unknown storage client type")
+		err = errors.New("unknown storage client type")
+		tracked.Finish(err)
+		return "", "", err
+	}
+	tracked.Finish(err)
+	logS3Operation(s3LogFields(ctxQuery), "upload", bucket, uploadKey, file.Size, start, err)
+	if err == nil && uploadKey != s3Key {
+		link, err = PromoteStagedUpload(ctx, bucket, uploadKey, s3Key, link, file.Size, ctxQuery, env)
+		if err != nil {
+			logrus.Errorf("Failed to promote staged object %s: %v", uploadKey, err)
+		}
 	}
 	if err != nil {
-		logrus.Error(err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to upload file to S3"})
-
+		RespondError(c, http.StatusInternalServerError, "failed to upload file to S3")
 	}
+	tracing.RecordError(span, err)
 	return link, extension, err
 }
 
-func DeleteFromS3(objectKey string, c *gin.Context, env *viper.Viper) {
+// rewindUpload returns a reader of the whole file for another upload attempt
+func rewindUpload(file multipart.File, tracked *TrackedUpload) (io.Reader, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	tracked.Reset()
+	return tracked.Reader(file), nil
+}
 
+func DeleteFromS3(objectKey string, ctxQuery map[string]interface{}, c *gin.Context, env *viper.Viper) {
+	if err := RemoveFromS3(c.Request.Context(), objectKey, ctxQuery, env); err != nil {
+		logrus.Error(err)
+		RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+}
+
+// RemoveFromS3 deletes the object a stored link points at from its bucket without writing to a request context,
+// so it can be used by background jobs. Keys relative to S3_ENDPOINT are deleted from S3_BUCKET_NAME.
+// The app_name and version of ctxQuery, when known, are logged with the deletion.
+func RemoveFromS3(ctx context.Context, link string, ctxQuery map[string]interface{}, env *viper.Viper) error {
 	storageClient := createStorageClient()
 
 	if storageClient == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create storage client"})
+		return errors.New("failed to create storage client")
 	}
-	var err error
-	objectKey = strings.TrimPrefix(objectKey, "/")
+	link = strings.TrimPrefix(link, "/")
+	start := time.Now()
 	// Delete object from bucket
 	switch client := storageClient.(type) {
 	case *minio.Client:
@@ -148,37 +351,346 @@ func DeleteFromS3(objectKey string, c *gin.Context, env *viper.Viper) {
 			GovernanceBypass: true,
 			VersionID:        "",
 		}
-		var objectKeyAfterBucket string
-		parts := strings.Split(objectKey, env.GetString("S3_BUCKET_NAME"))
-		if len(parts) > 1 {
-			objectKeyAfterBucket = strings.TrimPrefix(parts[1], "/")
-			logrus.Infof("Deleting object with key after bucket name: '%s'", objectKeyAfterBucket)
-		}
+		// The link names the bucket the object is stored in
+		bucket, objectKeyAfterBucket := splitS3Link("/"+link, env)
 		decodedKey, err := url.QueryUnescape(objectKeyAfterBucket)
 		if err != nil {
-			logrus.Error("Failed to decode object key: ", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to decode object key"})
-			return
+			logS3Operation(s3LogFields(ctxQuery), "delete", bucket, objectKeyAfterBucket, 0, start, err)
+			return errors.New("failed to decode object key")
 		}
-		err = client.RemoveObject(context.Background(), env.GetString("S3_BUCKET_NAME"), decodedKey, opts)
+		err = S3Retries(env).Do(ctx, "delete", func() error {
+			return client.RemoveObject(ctx, bucket, decodedKey, opts)
+		})
+		logS3Operation(s3LogFields(ctxQuery), "delete", bucket, decodedKey, 0, start, err)
 		if err != nil {
-			logrus.Error(err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete file from Minio"})
+			return errors.New("failed to delete file from Minio")
 		}
 
 	case *s3.Client:
-		_, err = client.DeleteObject(context.TODO(), &s3.DeleteObjectInput{
-			Bucket: aws.String(env.GetString("S3_BUCKET_NAME")),
-			Key:    aws.String(objectKey),
+		// Links of channel buckets start with the endpoint of their bucket
+		bucket, objectKey := splitS3Link(link, env)
+		err := S3Retries(env).Do(ctx, "delete", func() error {
+			_, err := client.DeleteObject(ctx, &s3.DeleteObjectInput{
+				Bucket: aws.String(bucket),
+				Key:    aws.String(objectKey),
+			})
+			return err
 		})
+		logS3Operation(s3LogFields(ctxQuery), "delete", bucket, objectKey, 0, start, err)
 		if err != nil {
-			logrus.Error(err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete file from S3"})
+			return errors.New("failed to delete file from S3")
 		}
 	default:
 		logrus.Errorf("unknown storage client type")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "unknown storage client type"})
+		return errors.New("unknown storage client type")
 	}
 
-	logrus.Infof("Object '%s' deleted from bucket '%s'\n", objectKey, env.GetString("S3_BUCKET_NAME"))
+	return nil
+}
+
+// CopyS3Object copies an object to dstKey in dstBucket and returns the public link of the copy.
+// The copy is stored in the bucket of its channel, which may differ from the bucket of the source.
+func CopyS3Object(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey, link string, env *viper.Viper) (string, error) {
+	start := time.Now()
+	link, err := copyS3Object(ctx, srcBucket, srcKey, dstBucket, dstKey, link, env)
+	logS3Operation(logrus.Fields{"source_bucket": srcBucket, "source_key": srcKey}, "copy", dstBucket, dstKey, 0, start, err)
+	return link, err
+}
+
+func copyS3Object(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey, link string, env *viper.Viper) (string, error) {
+	storageClient := createStorageClient()
+	if storageClient == nil {
+		return "", errors.New("failed to create storage client")
+	}
+
+	switch client := storageClient.(type) {
+	case *minio.Client:
+		opts, err := minioPutOptions(env)
+		if err != nil {
+			return "", err
+		}
+		dst := minio.CopyDestOptions{Bucket: dstBucket, Object: dstKey, Encryption: opts.ServerSideEncryption}
+		if _, err := client.CopyObject(ctx, dst, minio.CopySrcOptions{Bucket: srcBucket, Object: srcKey}); err != nil {
+			return "", err
+		}
+		// Keep links in the same format as minio PutObject returns them
+		return fmt.Sprintf("%s/%s/%s", client.EndpointURL(), dstBucket, dstKey), nil
+	case *s3.Client:
+		// The copy is encrypted like an uploaded object
+		put := &s3.PutObjectInput{}
+		if err := applyAWSEncryption(put, env); err != nil {
+			return "", err
+		}
+		_, err := client.CopyObject(ctx, &s3.CopyObjectInput{
+			Bucket:               aws.String(dstBucket),
+			Key:                  aws.String(dstKey),
+			CopySource:           aws.String((&url.URL{Path: srcBucket + "/" + srcKey}).EscapedPath()),
+			ServerSideEncryption: put.ServerSideEncryption,
+			SSEKMSKeyId:          put.SSEKMSKeyId,
+		})
+		if err != nil {
+			return "", err
+		}
+		return link, nil
+	default:
+		return "", errors.New("unknown storage client type")
+	}
+}
+
+// PresignUpload returns a presigned URL that allows a client to PUT the object directly to the bucket
+func PresignUpload(ctx context.Context, bucket, s3Key string, expiry time.Duration, env *viper.Viper) (string, error) {
+	start := time.Now()
+	presignedURL, err := presignUpload(ctx, bucket, s3Key, expiry, env)
+	logS3Operation(nil, "presign", bucket, s3Key, 0, start, err)
+	return presignedURL, err
+}
+
+func presignUpload(ctx context.Context, bucket, s3Key string, expiry time.Duration, env *viper.Viper) (string, error) {
+	storageClient := createStorageClient()
+	if storageClient == nil {
+		return "", errors.New("failed to create storage client")
+	}
+
+	switch client := storageClient.(type) {
+	case *minio.Client:
+		presignedURL, err := client.PresignedPutObject(ctx, bucket, s3Key, expiry)
+		if err != nil {
+			return "", err
+		}
+		return presignedURL.String(), nil
+	case *s3.Client:
+		input := &s3.PutObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(s3Key),
+		}
+		// The client has to send the matching x-amz-server-side-encryption headers
+		if err := applyAWSEncryption(input, env); err != nil {
+			return "", err
+		}
+		request, err := s3.NewPresignClient(client).PresignPutObject(ctx, input, s3.WithPresignExpires(expiry))
+		if err != nil {
+			return "", err
+		}
+		return request.URL, nil
+	default:
+		return "", errors.New("unknown storage client type")
+	}
+}
+
+// StatS3Object returns the size, the ETag and the public link of an object that already exists in the bucket
+func StatS3Object(ctx context.Context, bucket, s3Key, link string, ctxQuery map[string]interface{}, env *viper.Viper) (int64, string, string, error) {
+	start := time.Now()
+	size, etag, link, err := statS3Object(ctx, bucket, s3Key, link, env)
+	logS3Operation(s3LogFields(ctxQuery), "stat", bucket, s3Key, size, start, err)
+	return size, etag, link, err
+}
+
+func statS3Object(ctx context.Context, bucket, s3Key, link string, env *viper.Viper) (int64, string, string, error) {
+	storageClient := createStorageClient()
+	if storageClient == nil {
+		return 0, "", "", errors.New("failed to create storage client")
+	}
+
+	switch client := storageClient.(type) {
+	case *minio.Client:
+		var info minio.ObjectInfo
+		err := S3Retries(env).Do(ctx, "stat", func() (err error) {
+			info, err = client.StatObject(ctx, bucket, s3Key, minio.StatObjectOptions{})
+			return err
+		})
+		if err != nil {
+			return 0, "", "", err
+		}
+		// Keep links in the same format as minio PutObject returns them
+		link = fmt.Sprintf("%s/%s/%s", client.EndpointURL(), bucket, s3Key)
+		return info.Size, strings.Trim(info.ETag, `"`), link, nil
+	case *s3.Client:
+		var output *s3.HeadObjectOutput
+		err := S3Retries(env).Do(ctx, "stat", func() (err error) {
+			output, err = client.HeadObject(ctx, &s3.HeadObjectInput{
+				Bucket: aws.String(bucket),
+				Key:    aws.String(s3Key),
+			})
+			return err
+		})
+		if err != nil {
+			return 0, "", "", err
+		}
+		return output.ContentLength, strings.Trim(aws.StringValue(output.ETag), `"`), link, nil
+	default:
+		return 0, "", "", errors.New("unknown storage client type")
+	}
+}
+
+// ReadS3Object downloads the object stored under s3Key in bucket
+func ReadS3Object(ctx context.Context, bucket, s3Key string, env *viper.Viper) ([]byte, error) {
+	start := time.Now()
+	data, err := readS3Object(ctx, bucket, s3Key)
+	logS3Operation(nil, "read", bucket, s3Key, int64(len(data)), start, err)
+	return data, err
+}
+
+// OpenS3Object opens the object stored under s3Key in bucket for streaming and returns its size and content type.
+// The caller has to close the returned reader.
+func OpenS3Object(ctx context.Context, bucket, s3Key string, env *viper.Viper) (io.ReadCloser, int64, string, error) {
+	storageClient := createStorageClient()
+	if storageClient == nil {
+		return nil, 0, "", errors.New("failed to create storage client")
+	}
+
+	switch client := storageClient.(type) {
+	case *minio.Client:
+		object, err := client.GetObject(ctx, bucket, s3Key, minio.GetObjectOptions{})
+		if err != nil {
+			return nil, 0, "", err
+		}
+		// Minio only requests the object on the first read or stat
+		info, err := object.Stat()
+		if err != nil {
+			object.Close()
+			return nil, 0, "", err
+		}
+		return object, info.Size, info.ContentType, nil
+	case *s3.Client:
+		output, err := client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(s3Key),
+		})
+		if err != nil {
+			return nil, 0, "", err
+		}
+		return output.Body, output.ContentLength, aws.StringValue(output.ContentType), nil
+	default:
+		return nil, 0, "", errors.New("unknown storage client type")
+	}
+}
+
+// ChecksumS3Object streams the object stored under s3Key to compute its hex SHA-256.
+// It fails unless exactly size bytes were read, size is usually taken from StatS3Object.
+func ChecksumS3Object(ctx context.Context, bucket, s3Key string, size int64, env *viper.Viper) (string, error) {
+	object, _, _, err := OpenS3Object(ctx, bucket, s3Key, env)
+	if err != nil {
+		return "", err
+	}
+	defer object.Close()
+	hash := sha256.New()
+	read, err := io.Copy(hash, object)
+	if err != nil {
+		return "", err
+	}
+	if read != size {
+		return "", fmt.Errorf("read %d bytes of %s, expected %d", read, s3Key, size)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// IsS3NotFound reports whether err is returned for an object that doesn't exist
+func IsS3NotFound(err error) bool {
+	if response := minio.ToErrorResponse(err); response.Code != "" {
+		return response.Code == "NoSuchKey"
+	}
+	var apiErr interface{ ErrorCode() string }
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchKey"
+}
+
+func readS3Object(ctx context.Context, bucket, s3Key string) ([]byte, error) {
+	storageClient := createStorageClient()
+	if storageClient == nil {
+		return nil, errors.New("failed to create storage client")
+	}
+
+	switch client := storageClient.(type) {
+	case *minio.Client:
+		object, err := client.GetObject(ctx, bucket, s3Key, minio.GetObjectOptions{})
+		if err != nil {
+			return nil, err
+		}
+		defer object.Close()
+		return io.ReadAll(object)
+	case *s3.Client:
+		output, err := client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(s3Key),
+		})
+		if err != nil {
+			return nil, err
+		}
+		defer output.Body.Close()
+		return io.ReadAll(output.Body)
+	default:
+		return nil, errors.New("unknown storage client type")
+	}
+}
+
+// WriteS3Object stores data under s3Key in bucket and returns the public link of the object.
+// An empty contentType leaves the content type to the storage.
+func WriteS3Object(ctx context.Context, bucket, s3Key string, data []byte, contentType, link string, env *viper.Viper) (string, error) {
+	start := time.Now()
+	link, err := writeS3Object(ctx, bucket, s3Key, data, contentType, link, nil, env)
+	logS3Operation(nil, "upload", bucket, s3Key, int64(len(data)), start, err)
+	return link, err
+}
+
+// ReplaceS3Object overwrites the object under s3Key in bucket with data. The object is tagged again
+// from ctxQuery, since the tags of the replaced object are dropped with it.
+func ReplaceS3Object(ctx context.Context, bucket, s3Key string, data []byte, ctxQuery map[string]interface{}, env *viper.Viper) error {
+	tags, err := ObjectTags(ctxQuery, env)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	_, err = writeS3Object(ctx, bucket, s3Key, data, "", "", tags, env)
+	logS3Operation(s3LogFields(ctxQuery), "replace", bucket, s3Key, int64(len(data)), start, err)
+	return err
+}
+
+func writeS3Object(ctx context.Context, bucket, s3Key string, data []byte, contentType, link string, tags map[string]string, env *viper.Viper) (string, error) {
+	storageClient := createStorageClient()
+	if storageClient == nil {
+		return "", errors.New("failed to create storage client")
+	}
+
+	switch client := storageClient.(type) {
+	case *minio.Client:
+		opts, err := minioPutOptions(env)
+		if err != nil {
+			return "", err
+		}
+		opts.ContentType = contentType
+		opts.UserTags = tags
+		var uploadInfo minio.UploadInfo
+		err = S3Retries(env).Do(ctx, "upload", func() (err error) {
+			uploadInfo, err = client.PutObject(ctx, bucket, s3Key, bytes.NewReader(data), int64(len(data)), opts)
+			return err
+		})
+		if err != nil {
+			return "", err
+		}
+		return uploadInfo.Location, nil
+	case *s3.Client:
+		input := &s3.PutObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(s3Key),
+		}
+		if contentType != "" {
+			input.ContentType = aws.String(contentType)
+		}
+		if len(tags) > 0 {
+			input.Tagging = aws.String(EncodeObjectTags(tags))
+		}
+		if err := applyAWSEncryption(input, env); err != nil {
+			return "", err
+		}
+		err := S3Retries(env).Do(ctx, "upload", func() error {
+			input.Body = bytes.NewReader(data)
+			_, err := client.PutObject(ctx, input)
+			return err
+		})
+		if err != nil {
+			return "", err
+		}
+		return link, nil
+	default:
+		return "", errors.New("unknown storage client type")
+	}
 }
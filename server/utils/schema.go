@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"faynoSync/server/model"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// upRequestRequired are the fields every upload or update has to send
+var upRequestRequired = []string{"app_name", "version"}
+
+// upRequestDescriptions document the fields of the upload data whose rules depend on the stored catalog
+var upRequestDescriptions = map[string]string{
+	"id":                "Version to update, only used by /apps/update.",
+	"version":           "The pattern is the default format, apps created with a version_pattern use theirs instead.",
+	"channel":           "Required when any channel exists.",
+	"platform":          "Required when any platform exists.",
+	"arch":              "Required when any arch exists.",
+	"allow_cohorts":     "Only clients sending one of these cohorts to /checkVersion are offered the version. Replaces the stored cohorts on /apps/update, [] removes them.",
+	"deny_cohorts":      "Clients sending one of these cohorts to /checkVersion are never offered the version.",
+	"changelog_mode":    "How /apps/update applies the changelog: replace (default) replaces the entries of the version, append adds another entry.",
+	"preferred_package": "Package like dmg or no-extension recommended to clients when the version has several for the platform and arch. Unmarks the package preferred before.",
+	"properties":        fmt.Sprintf("Flat custom properties, at most %d keys of %d characters. Values are strings of at most %d bytes, numbers, booleans or null.", maxPropertiesCount, maxPropertyKeyLength, maxPropertyValueBytes),
+}
+
+var upRequestPatterns = map[string]string{
+	"app_name":          appNamePattern,
+	"version":           versionPattern,
+	"channel":           channelNamePattern,
+	"platform":          platformNamePattern,
+	"arch":              archNamePattern,
+	"preferred_package": packageNamePattern,
+}
+
+// UpRequestSchema returns the JSON schema of the "data" field sent to /upload and /apps/update
+func UpRequestSchema() map[string]interface{} {
+	properties := make(map[string]interface{})
+	for field, kind := range upRequestTypes() {
+		property := map[string]interface{}{"type": kind}
+		if pattern, ok := upRequestPatterns[field]; ok {
+			property["pattern"] = pattern
+		}
+		if description, ok := upRequestDescriptions[field]; ok {
+			property["description"] = description
+		}
+		if field == "allow_cohorts" || field == "deny_cohorts" {
+			property["items"] = map[string]interface{}{"type": "string", "pattern": cohortNamePattern}
+		}
+		if field == "changelog_mode" {
+			property["enum"] = ChangelogModes
+		}
+		if field == "properties" {
+			property["maxProperties"] = maxPropertiesCount
+			property["additionalProperties"] = map[string]interface{}{"type": []string{"string", "number", "boolean", "null"}}
+		}
+		properties[field] = property
+	}
+	return map[string]interface{}{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"title":                "Upload data",
+		"type":                 "object",
+		"properties":           properties,
+		"required":             upRequestRequired,
+		"additionalProperties": false,
+	}
+}
+
+// upRequestTypes maps the fields of model.UpRequest to their JSON types
+func upRequestTypes() map[string]string {
+	upReqType := reflect.TypeOf(model.UpRequest{})
+	types := make(map[string]string, upReqType.NumField())
+	for i := 0; i < upReqType.NumField(); i++ {
+		field := upReqType.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		switch field.Type.Kind() {
+		case reflect.Bool:
+			types[name] = "boolean"
+		case reflect.Map, reflect.Struct:
+			types[name] = "object"
+		case reflect.Slice:
+			types[name] = "array"
+		case reflect.Int, reflect.Int64, reflect.Float64:
+			types[name] = "number"
+		default:
+			types[name] = "string"
+		}
+	}
+	return types
+}
+
+// upRequestViolations checks the decoded upload data against UpRequestSchema.
+// Unknown fields are reported separately by unknownUpRequestFields.
+func upRequestViolations(data map[string]interface{}) []string {
+	var violations []string
+	for _, field := range upRequestRequired {
+		if _, ok := data[field]; !ok {
+			violations = append(violations, fmt.Sprintf("%s is required", field))
+		}
+	}
+	for field, expected := range upRequestTypes() {
+		value, ok := data[field]
+		if !ok {
+			continue
+		}
+		if actual := jsonType(value); actual != expected {
+			violations = append(violations, fmt.Sprintf("%s must be of type %s, got %s", field, expected, actual))
+		}
+	}
+	sort.Strings(violations)
+	return violations
+}
+
+// jsonType returns the JSON type name of a value decoded by encoding/json
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+// securityHeaders are the headers sent by default, each can be overridden with its setting.
+// X-XSS-Protection isn't sent, browsers removed the filter and it can introduce issues itself.
+var securityHeaders = []struct {
+	setting, header, value string
+}{
+	{"SECURITY_HEADER_HSTS", "Strict-Transport-Security", "max-age=63072000; includeSubDomains"},
+	{"SECURITY_HEADER_CONTENT_TYPE_OPTIONS", "X-Content-Type-Options", "nosniff"},
+	{"SECURITY_HEADER_FRAME_OPTIONS", "X-Frame-Options", "DENY"},
+	{"SECURITY_HEADER_CSP", "Content-Security-Policy", "default-src 'none'; frame-ancestors 'none'"},
+	{"SECURITY_HEADER_REFERRER_POLICY", "Referrer-Policy", "no-referrer"},
+}
+
+// SecurityHeaders returns the security headers to send with their configured values.
+// A setting with an empty value drops its header, SECURITY_HEADERS_ENABLE=false drops all of them.
+func SecurityHeaders(env *viper.Viper) map[string]string {
+	if env.IsSet("SECURITY_HEADERS_ENABLE") && !env.GetBool("SECURITY_HEADERS_ENABLE") {
+		return nil
+	}
+	headers := map[string]string{}
+	for _, h := range securityHeaders {
+		value := h.value
+		if env.IsSet(h.setting) {
+			value = env.GetString(h.setting)
+		}
+		if value != "" {
+			headers[h.header] = value
+		}
+	}
+	return headers
+}
+
+// SecurityHeadersMiddleware sets headers on every response.
+// Strict-Transport-Security is only sent over HTTPS, browsers ignore it on plain HTTP.
+// X-Forwarded-Proto is only honoured on requests of the trusted proxies.
+func SecurityHeadersMiddleware(headers map[string]string, proxies []*net.IPNet) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for name, value := range headers {
+			if name == "Strict-Transport-Security" && !isHTTPS(c.Request, proxies) {
+				continue
+			}
+			c.Header(name, value)
+		}
+		c.Next()
+	}
+}
+
+// isHTTPS reports whether the client connected over TLS, directly or through a trusted proxy terminating it
+func isHTTPS(r *http.Request, proxies []*net.IPNet) bool {
+	return r.TLS != nil || (r.Header.Get("X-Forwarded-Proto") == "https" && FromTrustedProxy(r, proxies))
+}
@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// defaultDownloadURLExpiry is how long signed download links are valid when DOWNLOAD_URL_EXPIRY isn't set
+const defaultDownloadURLExpiry = time.Hour
+
+// Errors returned for download links that can't be served
+var (
+	ErrDownloadLinkExpired   = errors.New("download link expired")
+	ErrDownloadLinkSignature = errors.New("invalid download link signature")
+)
+
+// SignedDownloadsEnabled reports whether download links are signed by SAU and served through /download.
+// Both DOWNLOAD_SIGNING_SECRET and DOWNLOAD_PROXY_BASE, the public address of the API, have to be set.
+func SignedDownloadsEnabled(env *viper.Viper) bool {
+	return env.GetString("DOWNLOAD_SIGNING_SECRET") != "" && env.GetString("DOWNLOAD_PROXY_BASE") != ""
+}
+
+// DownloadURLExpiry returns how long signed download links are valid
+func DownloadURLExpiry(env *viper.Viper) time.Duration {
+	if expiry := env.GetDuration("DOWNLOAD_URL_EXPIRY"); expiry > 0 {
+		return expiry
+	}
+	return defaultDownloadURLExpiry
+}
+
+// SignDownloadLink returns a link to /download that serves the object link points at until the expiry.
+// The object key is signed together with the expiry, so neither can be changed by the client.
+// Objects of other buckets than S3_BUCKET_NAME name their bucket in the signed bucket parameter.
+// Links that don't start with S3_ENDPOINT aren't stored by SAU and are returned unchanged.
+func SignDownloadLink(link string, now time.Time, env *viper.Viper) string {
+	endpoint := strings.TrimSuffix(env.GetString("S3_ENDPOINT"), "/")
+	if endpoint == "" || !strings.HasPrefix(link, endpoint+"/") {
+		return link
+	}
+	bucket, key := S3ObjectOfLink(link, env)
+	if bucket == env.GetString("S3_BUCKET_NAME") {
+		bucket = ""
+	}
+	expires := strconv.FormatInt(now.Add(DownloadURLExpiry(env)).Unix(), 10)
+
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	query := url.Values{"expires": {expires}, "signature": {downloadSignature(bucket, key, expires, env)}}
+	if bucket != "" {
+		query.Set("bucket", bucket)
+	}
+	return fmt.Sprintf("%s/download/%s?%s", strings.TrimSuffix(env.GetString("DOWNLOAD_PROXY_BASE"), "/"), strings.Join(segments, "/"), query.Encode())
+}
+
+// VerifyDownloadSignature checks that signature was issued for key in bucket with the expiry expires,
+// and that the expiry, a Unix timestamp, hasn't passed at now. An empty bucket is S3_BUCKET_NAME.
+func VerifyDownloadSignature(bucket, key, expires, signature string, now time.Time, env *viper.Viper) error {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || signature == "" {
+		return ErrDownloadLinkSignature
+	}
+	if !hmac.Equal([]byte(signature), []byte(downloadSignature(bucket, key, expires, env))) {
+		return ErrDownloadLinkSignature
+	}
+	if now.Unix() > expiresAt {
+		return ErrDownloadLinkExpired
+	}
+	return nil
+}
+
+// downloadSignature is the hex encoded HMAC-SHA256 of the key and the expiry, and of the bucket when it isn't S3_BUCKET_NAME
+func downloadSignature(bucket, key, expires string, env *viper.Viper) string {
+	mac := hmac.New(sha256.New, []byte(env.GetString("DOWNLOAD_SIGNING_SECRET")))
+	mac.Write([]byte(key + "\n" + expires))
+	if bucket != "" {
+		mac.Write([]byte("\n" + bucket))
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
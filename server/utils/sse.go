@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+const (
+	SSEAES256 = "AES256"
+	SSEKMS    = "aws:kms"
+)
+
+// sseProbeKey is written and removed at startup to check that the bucket accepts the encryption settings
+const sseProbeKey = ".faynosync-sse-check"
+
+// serverSideEncryption returns the configured S3_SSE mode and S3_SSE_KMS_KEY_ID
+func serverSideEncryption(env *viper.Viper) (string, string, error) {
+	mode := env.GetString("S3_SSE")
+	keyID := env.GetString("S3_SSE_KMS_KEY_ID")
+	switch mode {
+	case "", SSEAES256:
+		return mode, "", nil
+	case SSEKMS:
+		if keyID == "" {
+			return "", "", errors.New("S3_SSE_KMS_KEY_ID is required when S3_SSE is aws:kms")
+		}
+		return mode, keyID, nil
+	default:
+		return "", "", fmt.Errorf("invalid S3_SSE value %q, allowed: %s, %s", mode, SSEAES256, SSEKMS)
+	}
+}
+
+func minioPutOptions(env *viper.Viper) (minio.PutObjectOptions, error) {
+	var opts minio.PutObjectOptions
+	mode, keyID, err := serverSideEncryption(env)
+	if err != nil {
+		return opts, err
+	}
+	switch mode {
+	case SSEAES256:
+		opts.ServerSideEncryption = encrypt.NewSSE()
+	case SSEKMS:
+		opts.ServerSideEncryption, err = encrypt.NewSSEKMS(keyID, nil)
+	}
+	return opts, err
+}
+
+func applyAWSEncryption(input *s3.PutObjectInput, env *viper.Viper) error {
+	mode, keyID, err := serverSideEncryption(env)
+	if err != nil {
+		return err
+	}
+	switch mode {
+	case SSEAES256:
+		input.ServerSideEncryption = types.ServerSideEncryptionAes256
+	case SSEKMS:
+		input.ServerSideEncryption = types.ServerSideEncryptionAwsKms
+		input.SSEKMSKeyId = aws.String(keyID)
+	}
+	return nil
+}
+
+// ValidateServerSideEncryption checks at startup that objects can be written with the configured
+// encryption, e.g. that the KMS key exists and may be used, by writing and removing a probe object.
+func ValidateServerSideEncryption(ctx context.Context, env *viper.Viper) error {
+	mode, _, err := serverSideEncryption(env)
+	if err != nil || mode == "" {
+		return err
+	}
+
+	storageClient := createStorageClient()
+	if storageClient == nil {
+		return errors.New("failed to create storage client")
+	}
+	probe := []byte("faynoSync")
+
+	// Every bucket of S3_CHANNEL_BUCKETS receives uploads encrypted the same way
+	for _, bucket := range S3Buckets(env) {
+		switch client := storageClient.(type) {
+		case *minio.Client:
+			opts, err := minioPutOptions(env)
+			if err != nil {
+				return err
+			}
+			if _, err := client.PutObject(ctx, bucket, sseProbeKey, bytes.NewReader(probe), int64(len(probe)), opts); err != nil {
+				return fmt.Errorf("server-side encryption %s is not usable in bucket %s: %w", mode, bucket, err)
+			}
+			err = client.RemoveObject(ctx, bucket, sseProbeKey, minio.RemoveObjectOptions{})
+			if err != nil {
+				return err
+			}
+		case *s3.Client:
+			input := &s3.PutObjectInput{
+				Bucket: aws.String(bucket),
+				Key:    aws.String(sseProbeKey),
+				Body:   bytes.NewReader(probe),
+			}
+			if err := applyAWSEncryption(input, env); err != nil {
+				return err
+			}
+			if _, err := client.PutObject(ctx, input); err != nil {
+				return fmt.Errorf("server-side encryption %s is not usable in bucket %s: %w", mode, bucket, err)
+			}
+			if _, err := client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(bucket), Key: aws.String(sseProbeKey)}); err != nil {
+				return err
+			}
+		default:
+			return errors.New("unknown storage client type")
+		}
+	}
+
+	logrus.Infof("Server-side encryption %s is enabled", mode)
+	return nil
+}
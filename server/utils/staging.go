@@ -0,0 +1,196 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/minio/minio-go/v7"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// StagedUploads reports whether artifacts are uploaded to a staging key and promoted to their final key
+// once complete, so their public link never points at a partially written object
+func StagedUploads(env *viper.Viper) bool {
+	return env.GetBool("STAGED_UPLOADS")
+}
+
+// stagingKey returns a random key next to s3Key, so the staged object is stored in the bucket of the final one
+func stagingKey(s3Key string) (string, error) {
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return s3Key + ".staging-" + hex.EncodeToString(suffix), nil
+}
+
+// Objects up to maxCopySize are copied with a single CopyObject, larger ones part by part
+const (
+	maxCopySize  = 5 << 30
+	copyPartSize = 512 << 20
+	maxCopyParts = 10000
+)
+
+// PromoteStagedUpload checks that the staged object has the expected size and copies it to s3Key in the same bucket,
+// returning the link of the promoted object. It's a variable so tests can simulate a failed promotion.
+var PromoteStagedUpload = func(ctx context.Context, bucket, staged, s3Key, link string, size int64, ctxQuery map[string]interface{}, env *viper.Viper) (string, error) {
+	stagedSize, _, _, err := StatS3Object(ctx, bucket, staged, link, ctxQuery, env)
+	if err != nil {
+		return "", fmt.Errorf("failed to verify staged object %s: %w", staged, err)
+	}
+	if size >= 0 && stagedSize != size {
+		return "", fmt.Errorf("staged object %s has %d bytes, expected %d", staged, stagedSize, size)
+	}
+	start := time.Now()
+	link, err = promoteStagedObject(ctx, bucket, staged, s3Key, link, stagedSize, env)
+	fields := s3LogFields(ctxQuery)
+	fields["source_key"] = staged
+	logS3Operation(fields, "copy", bucket, s3Key, stagedSize, start, err)
+	return link, err
+}
+
+// promoteStagedObject copies the staged object to s3Key. A single CopyObject is limited to 5 GiB,
+// larger objects are copied with a multipart copy.
+func promoteStagedObject(ctx context.Context, bucket, staged, s3Key, link string, size int64, env *viper.Viper) (string, error) {
+	storageClient := createStorageClient()
+	if storageClient == nil {
+		return "", errors.New("failed to create storage client")
+	}
+
+	switch client := storageClient.(type) {
+	case *minio.Client:
+		opts, err := minioPutOptions(env)
+		if err != nil {
+			return "", err
+		}
+		// ComposeObject copies objects above 5 GiB part by part, smaller ones in one request
+		dst := minio.CopyDestOptions{Bucket: bucket, Object: s3Key, Encryption: opts.ServerSideEncryption}
+		if _, err := client.ComposeObject(ctx, dst, minio.CopySrcOptions{Bucket: bucket, Object: staged}); err != nil {
+			return "", err
+		}
+		// Keep links in the same format as minio PutObject returns them
+		return fmt.Sprintf("%s/%s/%s", client.EndpointURL(), bucket, s3Key), nil
+	case *s3.Client:
+		if size <= maxCopySize {
+			return copyS3Object(ctx, bucket, staged, bucket, s3Key, link, env)
+		}
+		if err := multipartCopyS3(ctx, client, bucket, staged, s3Key, size, env); err != nil {
+			return "", err
+		}
+		return link, nil
+	default:
+		return "", errors.New("unknown storage client type")
+	}
+}
+
+// multipartCopyS3 copies an object of size bytes within bucket with UploadPartCopy, the multipart upload is aborted when a part fails
+func multipartCopyS3(ctx context.Context, client *s3.Client, bucket, srcKey, dstKey string, size int64, env *viper.Viper) error {
+	// The copy is encrypted like an uploaded object
+	put := &s3.PutObjectInput{}
+	if err := applyAWSEncryption(put, env); err != nil {
+		return err
+	}
+	upload, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:               aws.String(bucket),
+		Key:                  aws.String(dstKey),
+		ServerSideEncryption: put.ServerSideEncryption,
+		SSEKMSKeyId:          put.SSEKMSKeyId,
+	})
+	if err != nil {
+		return err
+	}
+
+	partSize := int64(copyPartSize)
+	if parts := (size + partSize - 1) / partSize; parts > maxCopyParts {
+		partSize = (size + maxCopyParts - 1) / maxCopyParts
+	}
+	copySource := aws.String((&url.URL{Path: bucket + "/" + srcKey}).EscapedPath())
+	var completed []types.CompletedPart
+	for offset, partNumber := int64(0), int32(1); offset < size; offset, partNumber = offset+partSize, partNumber+1 {
+		end := offset + partSize - 1
+		if end >= size {
+			end = size - 1
+		}
+		var part *s3.UploadPartCopyOutput
+		err = S3Retries(env).Do(ctx, "copy", func() error {
+			var err error
+			part, err = client.UploadPartCopy(ctx, &s3.UploadPartCopyInput{
+				Bucket:          aws.String(bucket),
+				Key:             aws.String(dstKey),
+				UploadId:        upload.UploadId,
+				PartNumber:      partNumber,
+				CopySource:      copySource,
+				CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", offset, end)),
+			})
+			return err
+		})
+		if err != nil {
+			abortMultipartCopy(ctx, client, bucket, dstKey, upload.UploadId)
+			return fmt.Errorf("failed to copy part %d of %s: %w", partNumber, srcKey, err)
+		}
+		completed = append(completed, types.CompletedPart{ETag: part.CopyPartResult.ETag, PartNumber: partNumber})
+	}
+
+	_, err = client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(bucket),
+		Key:             aws.String(dstKey),
+		UploadId:        upload.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+	})
+	if err != nil {
+		abortMultipartCopy(ctx, client, bucket, dstKey, upload.UploadId)
+	}
+	return err
+}
+
+// abortMultipartCopy removes the parts of a failed copy, also after the request was cancelled
+func abortMultipartCopy(ctx context.Context, client *s3.Client, bucket, key string, uploadID *string) {
+	ctx, cancel := WithTimeout(context.WithoutCancel(ctx), OperationDelete)
+	defer cancel()
+	if _, err := client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(key),
+		UploadId: uploadID,
+	}); err != nil {
+		logrus.Errorf("Error aborting the multipart copy of %s: %v", key, err)
+	}
+}
+
+// removeStagedObject deletes a staged object, also after the request was cancelled
+func removeStagedObject(ctx context.Context, bucket, staged string, env *viper.Viper) error {
+	ctx, cancel := WithTimeout(context.WithoutCancel(ctx), OperationDelete)
+	defer cancel()
+
+	storageClient := createStorageClient()
+	if storageClient == nil {
+		return errors.New("failed to create storage client")
+	}
+	start := time.Now()
+	var err error
+	switch client := storageClient.(type) {
+	case *minio.Client:
+		err = S3Retries(env).Do(ctx, "delete", func() error {
+			return client.RemoveObject(ctx, bucket, staged, minio.RemoveObjectOptions{})
+		})
+	case *s3.Client:
+		err = S3Retries(env).Do(ctx, "delete", func() error {
+			_, err := client.DeleteObject(ctx, &s3.DeleteObjectInput{
+				Bucket: aws.String(bucket),
+				Key:    aws.String(staged),
+			})
+			return err
+		})
+	default:
+		err = errors.New("unknown storage client type")
+	}
+	logS3Operation(nil, "delete", bucket, staged, 0, start, err)
+	return err
+}
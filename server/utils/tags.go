@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// maxObjectTags is the number of tags S3 allows per object
+const maxObjectTags = 10
+
+// objectTagFields are the upload parameters that can be used in tag templates as {field}
+var objectTagFields = []string{"app_name", "version", "channel", "platform", "arch"}
+
+// objectTagTemplate returns the tag template of uploads to channel.
+// S3_OBJECT_TAGS_<CHANNEL> overrides S3_OBJECT_TAGS, an empty override disables tagging for the channel.
+func objectTagTemplate(channel string, env *viper.Viper) string {
+	if channel != "" {
+		key := "S3_OBJECT_TAGS_" + strings.ToUpper(channel)
+		if env.IsSet(key) {
+			return env.GetString(key)
+		}
+	}
+	return env.GetString("S3_OBJECT_TAGS")
+}
+
+// ObjectTags returns the tags of an uploaded artifact, rendered from the template of its channel.
+// The template is a query string such as channel={channel}&app={app_name}, it returns nil without one.
+func ObjectTags(ctxQuery map[string]interface{}, env *viper.Viper) (map[string]string, error) {
+	template := objectTagTemplate(GetStringValue(ctxQuery, "channel"), env)
+	if template == "" {
+		return nil, nil
+	}
+
+	replacements := make([]string, 0, 2*len(objectTagFields))
+	for _, field := range objectTagFields {
+		replacements = append(replacements, "{"+field+"}", GetStringValue(ctxQuery, field))
+	}
+	replacer := strings.NewReplacer(replacements...)
+
+	tags := map[string]string{}
+	for _, pair := range strings.Split(template, "&") {
+		if pair == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return nil, fmt.Errorf("invalid object tag template %q: empty tag key", template)
+		}
+		tags[key] = replacer.Replace(strings.TrimSpace(value))
+	}
+	if len(tags) > maxObjectTags {
+		return nil, fmt.Errorf("invalid object tag template %q: S3 allows at most %d tags", template, maxObjectTags)
+	}
+	return tags, nil
+}
+
+// EncodeObjectTags formats tags as the URL-encoded query string expected by the S3 Tagging header
+func EncodeObjectTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	values := url.Values{}
+	for _, key := range keys {
+		values.Set(key, tags[key])
+	}
+	return values.Encode()
+}
@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	OperationRead   = "read"
+	OperationWrite  = "write"
+	OperationDelete = "delete"
+)
+
+var defaultTimeouts = map[string]time.Duration{
+	OperationRead:   30 * time.Second,
+	OperationWrite:  60 * time.Second,
+	OperationDelete: 60 * time.Second,
+}
+
+// Timeout returns the timeout of the operation, configured with
+// TIMEOUT_READ, TIMEOUT_WRITE and TIMEOUT_DELETE (e.g. `10s`).
+func Timeout(operation string) time.Duration {
+	if timeout := viper.GetDuration("TIMEOUT_" + strings.ToUpper(operation)); timeout > 0 {
+		return timeout
+	}
+	if timeout, ok := defaultTimeouts[operation]; ok {
+		return timeout
+	}
+	return defaultTimeouts[OperationRead]
+}
+
+// WithTimeout derives a context limited by the timeout of the operation
+func WithTimeout(parent context.Context, operation string) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(parent, Timeout(operation))
+}
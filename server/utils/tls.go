@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// TLSSettings select how the server terminates TLS: with the certificate and key files,
+// with Let's Encrypt certificates for the autocert domains, or not at all
+type TLSSettings struct {
+	CertFile        string
+	KeyFile         string
+	AutocertDomains []string
+}
+
+// ReadTLSSettings reads TLS_CERT_FILE, TLS_KEY_FILE and the comma-separated TLS_AUTOCERT_DOMAINS.
+// A certificate without its key, or a key without its certificate, is refused instead of serving plain HTTP.
+func ReadTLSSettings(env *viper.Viper) (TLSSettings, error) {
+	settings := TLSSettings{
+		CertFile: strings.TrimSpace(env.GetString("TLS_CERT_FILE")),
+		KeyFile:  strings.TrimSpace(env.GetString("TLS_KEY_FILE")),
+	}
+	if (settings.CertFile == "") != (settings.KeyFile == "") {
+		return settings, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	for _, domain := range strings.Split(env.GetString("TLS_AUTOCERT_DOMAINS"), ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			settings.AutocertDomains = append(settings.AutocertDomains, domain)
+		}
+	}
+	return settings, nil
+}
@@ -0,0 +1,204 @@
+package utils
+
+import (
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultUploadHistory is the number of finished uploads kept when UPLOAD_HISTORY_SIZE isn't set
+const defaultUploadHistory = 50
+
+// States of a tracked upload
+const (
+	UploadInProgress = "uploading"
+	UploadCompleted  = "completed"
+	UploadFailed     = "failed"
+)
+
+// UploadStatus describes an upload to the bucket, see GET /uploads/status
+type UploadStatus struct {
+	ID               uint64     `json:"id"`
+	AppName          string     `json:"app_name"`
+	Version          string     `json:"version"`
+	Channel          string     `json:"channel,omitempty"`
+	Platform         string     `json:"platform,omitempty"`
+	Arch             string     `json:"arch,omitempty"`
+	File             string     `json:"file"`
+	BytesTotal       int64      `json:"bytes_total"`
+	BytesTransferred int64      `json:"bytes_transferred"`
+	Status           string     `json:"status"`
+	Error            string     `json:"error,omitempty"`
+	StartedAt        time.Time  `json:"started_at"`
+	FinishedAt       *time.Time `json:"finished_at,omitempty"`
+	ElapsedSeconds   float64    `json:"elapsed_seconds"`
+}
+
+// UploadTracker records the uploads in progress and keeps the most recently finished ones
+type UploadTracker struct {
+	mu      sync.Mutex
+	nextID  uint64
+	active  map[uint64]*TrackedUpload
+	recent  []UploadStatus
+	history int
+}
+
+// TrackedUpload is an upload registered with an UploadTracker
+type TrackedUpload struct {
+	tracker     *UploadTracker
+	status      UploadStatus
+	transferred atomic.Int64
+}
+
+var uploadTracker = NewUploadTracker(defaultUploadHistory)
+
+// NewUploadTracker returns a tracker keeping the last history finished uploads
+func NewUploadTracker(history int) *UploadTracker {
+	if history <= 0 {
+		history = defaultUploadHistory
+	}
+	return &UploadTracker{active: map[uint64]*TrackedUpload{}, history: history}
+}
+
+// InitUploadTracker replaces the shared tracker with one keeping the last history finished uploads
+func InitUploadTracker(history int) {
+	uploadTracker = NewUploadTracker(history)
+}
+
+// DefaultUploadTracker returns the tracker used by the uploads to the bucket
+func DefaultUploadTracker() *UploadTracker {
+	return uploadTracker
+}
+
+// Start registers an upload of file with total bytes for the artifact described by ctxQuery
+func (t *UploadTracker) Start(ctxQuery map[string]interface{}, file string, total int64) *TrackedUpload {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.nextID++
+	upload := &TrackedUpload{tracker: t, status: UploadStatus{
+		ID:         t.nextID,
+		AppName:    GetStringValue(ctxQuery, "app_name"),
+		Version:    GetStringValue(ctxQuery, "version"),
+		Channel:    GetStringValue(ctxQuery, "channel"),
+		Platform:   GetStringValue(ctxQuery, "platform"),
+		Arch:       GetStringValue(ctxQuery, "arch"),
+		File:       file,
+		BytesTotal: total,
+		Status:     UploadInProgress,
+		StartedAt:  time.Now(),
+	}}
+	t.active[upload.status.ID] = upload
+	return upload
+}
+
+// Snapshot returns the uploads in progress, oldest first, and the finished ones, most recent first
+func (t *UploadTracker) Snapshot() ([]UploadStatus, []UploadStatus) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	active := make([]UploadStatus, 0, len(t.active))
+	for _, upload := range t.active {
+		status := upload.status
+		status.BytesTransferred = upload.transferred.Load()
+		status.ElapsedSeconds = now.Sub(status.StartedAt).Seconds()
+		active = append(active, status)
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].ID < active[j].ID })
+
+	recent := make([]UploadStatus, len(t.recent))
+	for i, status := range t.recent {
+		recent[len(t.recent)-1-i] = status
+	}
+	return active, recent
+}
+
+// Status returns the current state of the upload
+func (u *TrackedUpload) Status() UploadStatus {
+	u.tracker.mu.Lock()
+	defer u.tracker.mu.Unlock()
+
+	status := u.status
+	if _, ok := u.tracker.active[status.ID]; ok {
+		status.BytesTransferred = u.transferred.Load()
+		status.ElapsedSeconds = time.Since(status.StartedAt).Seconds()
+		return status
+	}
+	for _, finished := range u.tracker.recent {
+		if finished.ID == status.ID {
+			return finished
+		}
+	}
+	// Dropped from the history already
+	status.BytesTransferred = u.transferred.Load()
+	return status
+}
+
+// uploadObserverKey is the context key of the function notified of the uploads started by a request
+const uploadObserverKey = "uploadObserver"
+
+// ObserveUploads calls observe with every upload to the bucket the request starts, e.g. to report its progress
+func ObserveUploads(c *gin.Context, observe func(*TrackedUpload)) {
+	c.Set(uploadObserverKey, observe)
+}
+
+// notifyUploadObserver passes an upload started by the request to its observer
+func notifyUploadObserver(c *gin.Context, upload *TrackedUpload) {
+	if observe, ok := c.Get(uploadObserverKey); ok {
+		observe.(func(*TrackedUpload))(upload)
+	}
+}
+
+// Reader counts the bytes read from r as transferred
+func (u *TrackedUpload) Reader(r io.Reader) io.Reader {
+	return &countingReader{reader: r, count: &u.transferred}
+}
+
+// Reset sets the transferred bytes back to zero when the upload is started over
+func (u *TrackedUpload) Reset() {
+	u.transferred.Store(0)
+}
+
+// Finish moves the upload to the finished ones, failed when err isn't nil
+func (u *TrackedUpload) Finish(err error) {
+	t := u.tracker
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.active[u.status.ID]; !ok {
+		return
+	}
+	delete(t.active, u.status.ID)
+
+	finishedAt := time.Now()
+	status := u.status
+	status.BytesTransferred = u.transferred.Load()
+	status.FinishedAt = &finishedAt
+	status.ElapsedSeconds = finishedAt.Sub(status.StartedAt).Seconds()
+	status.Status = UploadCompleted
+	if err != nil {
+		status.Status = UploadFailed
+		status.Error = err.Error()
+	}
+
+	t.recent = append(t.recent, status)
+	if len(t.recent) > t.history {
+		t.recent = t.recent[len(t.recent)-t.history:]
+	}
+}
+
+type countingReader struct {
+	reader io.Reader
+	count  *atomic.Int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count.Add(int64(n))
+	return n, err
+}
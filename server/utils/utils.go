@@ -6,6 +6,8 @@ import (
 	"faynoSync/server/model"
 	"fmt"
 	"net/http"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -30,29 +32,103 @@ type ServerSettings struct {
 	Port string
 }
 
-// GenerateJWT generates a new JWT token for the given username
-func GenerateJWT(username string) (string, error) {
+// GenerateJWT generates a new JWT token for the given username.
+// A nil apps list gives access to all apps, otherwise the token is scoped to the listed app names.
+func GenerateJWT(username string, apps []string) (string, error) {
+	return GenerateIdentityJWT(Identity{Username: username, Role: DefaultRole(apps), Apps: apps})
+}
+
+// GenerateIdentityJWT issues a token carrying the identity: its apps in JWT_APPS_CLAIM and its role in JWT_ROLE_CLAIM
+func GenerateIdentityJWT(identity Identity) (string, error) {
 	env := viper.GetViper()
 	// Define JWT claims
 	claims := jwt.MapClaims{
-		"username": username,
+		"username": identity.Username,
 		"exp":      time.Now().Add(24 * time.Hour).Unix(), // Token expiration time (24 hours)
 	}
+	// Scope the token to this service when issuer/audience are configured
+	if issuer := env.GetString("JWT_ISSUER"); issuer != "" {
+		claims["iss"] = issuer
+	}
+	if audience := env.GetString("JWT_AUDIENCE"); audience != "" {
+		claims["aud"] = audience
+	}
+	if identity.Apps != nil {
+		claims[AppsClaim(env)] = identity.Apps
+	}
+	if identity.Role != "" {
+		claims[RoleClaim(env)] = identity.Role
+	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(env.GetString("JWT_SECRET")))
 }
 
+// UpRequestFields returns the allowlist of keys accepted in the "data" field of upload/update requests
+func UpRequestFields() []string {
+	upReqType := reflect.TypeOf(model.UpRequest{})
+	fields := make([]string, 0, upReqType.NumField())
+	for i := 0; i < upReqType.NumField(); i++ {
+		name := strings.Split(upReqType.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+// unknownUpRequestFields returns the sorted keys of jsonData that are not in the allowlist
+func unknownUpRequestFields(jsonData string) ([]string, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(jsonData), &raw); err != nil {
+		return nil, err
+	}
+	allowed := make(map[string]struct{})
+	for _, field := range UpRequestFields() {
+		allowed[field] = struct{}{}
+	}
+	var unknown []string
+	for key := range raw {
+		if _, ok := allowed[key]; !ok {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown, nil
+}
+
 func extractParamsFromPost(c *gin.Context) (map[string]interface{}, error) {
 	jsonData := c.PostForm("data")
 	if jsonData == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No JSON data provided"})
+		RespondError(c, http.StatusBadRequest, "No JSON data provided")
 		return nil, errors.New("no JSON data provided")
 	}
 	logrus.Debug("JSON data: ", jsonData)
+
+	// Typos like "pubish" must not silently fall back to defaults
+	unknown, err := unknownUpRequestFields(jsonData)
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, "Invalid JSON data")
+		return nil, errors.New("invalid JSON data")
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown fields in data: %s (allowed: %s)", strings.Join(unknown, ", "), strings.Join(UpRequestFields(), ", "))
+	}
+
+	// Wrong types, like "publish": "true", are rejected before any file is stored
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(jsonData), &data); err != nil {
+		return nil, errors.New("invalid JSON data")
+	}
+	if violations := upRequestViolations(data); len(violations) > 0 {
+		return nil, fmt.Errorf("invalid data: %s", strings.Join(violations, "; "))
+	}
+
 	var upReq model.UpRequest
-	if err := json.Unmarshal([]byte(jsonData), &upReq); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON data"})
+	decoder := json.NewDecoder(strings.NewReader(jsonData))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&upReq); err != nil {
+		RespondError(c, http.StatusBadRequest, "Invalid JSON data")
 		return nil, errors.New("invalid JSON data")
 	}
 
@@ -61,15 +137,20 @@ func extractParamsFromPost(c *gin.Context) (map[string]interface{}, error) {
 	publishStr := strconv.FormatBool(upReq.Publish)
 	criticalStr := strconv.FormatBool(upReq.Critical)
 	return map[string]interface{}{
-		"id":        upReq.Id,
-		"app_name":  upReq.AppName,
-		"version":   upReq.Version,
-		"channel":   upReq.Channel,
-		"publish":   publishStr,
-		"critical":  criticalStr,
-		"platform":  upReq.Platform,
-		"arch":      upReq.Arch,
-		"changelog": upReq.Changelog,
+		"id":                upReq.Id,
+		"app_name":          upReq.AppName,
+		"version":           upReq.Version,
+		"channel":           upReq.Channel,
+		"publish":           publishStr,
+		"critical":          criticalStr,
+		"platform":          upReq.Platform,
+		"arch":              upReq.Arch,
+		"changelog":         upReq.Changelog,
+		"changelog_mode":    upReq.ChangelogMode,
+		"properties":        upReq.Properties,
+		"allow_cohorts":     upReq.AllowCohorts,
+		"deny_cohorts":      upReq.DenyCohorts,
+		"preferred_package": upReq.PreferredPackage,
 	}, nil
 }
 
@@ -106,16 +187,21 @@ func GetBoolParam(param interface{}) bool {
 	}
 }
 
-func CountUrls(downloadUrls map[string]map[string]map[string]map[string]map[string]string) (int, string) {
+func CountUrls(downloadUrls map[string]map[string]map[string]map[string]map[string]interface{}) (int, string) {
 	count := 0
 	var singleUrl string
 	for _, platformMap := range downloadUrls {
 		for _, archMap := range platformMap {
 			for _, packageMap := range archMap {
 				for _, urlMap := range packageMap {
-					if url, exists := urlMap["url"]; exists {
+					switch url := urlMap["url"].(type) {
+					case string:
 						count++
 						singleUrl = url
+					case []string:
+						// The first link is the primary one, the others are mirrors
+						count++
+						singleUrl = url[0]
 					}
 				}
 			}
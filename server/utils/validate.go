@@ -2,9 +2,13 @@ package utils
 
 import (
 	"errors"
+	"faynoSync/server/model"
 	"fmt"
 	"net/http"
 	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
@@ -12,6 +16,11 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
+var (
+	ErrTokenInvalidIssuer   = errors.New("token has invalid issuer")
+	ErrTokenInvalidAudience = errors.New("token has invalid audience")
+)
+
 // ValidateJWT parses and validates the JWT token
 func ValidateJWT(tokenString string) (*jwt.Token, error) {
 	env := viper.GetViper()
@@ -42,6 +51,16 @@ func ValidateJWT(tokenString string) (*jwt.Token, error) {
 		}
 	}
 
+	// Reject tokens minted for another service sharing the same secret
+	if claims, ok := token.Claims.(jwt.MapClaims); ok {
+		if issuer := env.GetString("JWT_ISSUER"); issuer != "" && !claims.VerifyIssuer(issuer, true) {
+			return nil, ErrTokenInvalidIssuer
+		}
+		if audience := env.GetString("JWT_AUDIENCE"); audience != "" && !claims.VerifyAudience(audience, true) {
+			return nil, ErrTokenInvalidAudience
+		}
+	}
+
 	return token, nil
 }
 
@@ -53,13 +72,24 @@ func ValidateParamsLatest(c *gin.Context, database *mongo.Database) (map[string]
 		"publish":  c.Query("publish"),
 		"platform": c.Query("platform"),
 		"arch":     c.Query("arch"),
+		"package":  strings.TrimPrefix(c.Query("package"), "."),
+	}
+	// Kept as strings, they are part of the cache key
+	if GetBoolParam(c.Query("prefer_critical")) {
+		ctxQueryMap["prefer_critical"] = "true"
+	}
+	if GetBoolParam(c.Query("step")) {
+		ctxQueryMap["step"] = "true"
+	}
+	if cohort := ClientCohort(c, viper.GetViper()); cohort != "" {
+		ctxQueryMap["cohort"] = cohort
 	}
 
 	if !IsValidAppName(ctxQueryMap["app_name"].(string)) {
 		return nil, errors.New("invalid app_name parameter")
 	}
-	if !IsValidVersion(ctxQueryMap["version"].(string)) {
-		return nil, errors.New("invalid version parameter")
+	if err := CheckVersion(ctxQueryMap["app_name"].(string), ctxQueryMap["version"].(string), database, c); err != nil {
+		return nil, err
 	}
 	if !IsValidChannelName(ctxQueryMap["channel"].(string)) {
 		return nil, errors.New("invalid channel parameter")
@@ -73,6 +103,14 @@ func ValidateParamsLatest(c *gin.Context, database *mongo.Database) (map[string]
 		return nil, errors.New("invalid arch parameter")
 	}
 
+	if !IsValidPackageName(ctxQueryMap["package"].(string)) {
+		return nil, errors.New("invalid package parameter")
+	}
+
+	if cohort := GetStringValue(ctxQueryMap, "cohort"); cohort != "" && !IsValidCohortName(cohort) {
+		return nil, errors.New("invalid cohort parameter")
+	}
+
 	errChannels := CheckChannels(ctxQueryMap["channel"].(string), database, c)
 	if errChannels != nil {
 		return nil, errChannels
@@ -107,15 +145,21 @@ func ValidateParams(c *gin.Context, database *mongo.Database) (map[string]interf
 		return nil, err
 	}
 
-	return validateCommonParams(ctxQueryMap, database, c)
+	ctxQueryMap, err = validateCommonParams(ctxQueryMap, database, c)
+	if err != nil {
+		return nil, err
+	}
+	// The caller is stored with the uploaded or updated version for the activity feed
+	ctxQueryMap["updated_by"] = c.GetString("username")
+	return ctxQueryMap, nil
 }
 
 func validateCommonParams(ctxQueryMap map[string]interface{}, database *mongo.Database, c *gin.Context) (map[string]interface{}, error) {
 	if !IsValidAppName(ctxQueryMap["app_name"].(string)) {
 		return nil, errors.New("invalid app_name parameter")
 	}
-	if !IsValidVersion(ctxQueryMap["version"].(string)) {
-		return nil, errors.New("invalid version parameter")
+	if err := CheckVersion(ctxQueryMap["app_name"].(string), ctxQueryMap["version"].(string), database, c); err != nil {
+		return nil, err
 	}
 	if !IsValidChannelName(ctxQueryMap["channel"].(string)) {
 		return nil, errors.New("invalid channel parameter")
@@ -126,6 +170,23 @@ func validateCommonParams(ctxQueryMap map[string]interface{}, database *mongo.Da
 	if !IsValidArchName(ctxQueryMap["arch"].(string)) {
 		return nil, errors.New("invalid arch parameter")
 	}
+	if !IsValidPackageName(GetStringValue(ctxQueryMap, "preferred_package")) {
+		return nil, errors.New("invalid preferred_package parameter")
+	}
+
+	if properties, ok := ctxQueryMap["properties"].(map[string]interface{}); ok {
+		if err := ValidateProperties(properties); err != nil {
+			return nil, err
+		}
+	}
+	if err := ValidateChangelog(GetStringValue(ctxQueryMap, "changelog"), GetStringValue(ctxQueryMap, "changelog_mode")); err != nil {
+		return nil, err
+	}
+	allow, _ := ctxQueryMap["allow_cohorts"].([]string)
+	deny, _ := ctxQueryMap["deny_cohorts"].([]string)
+	if err := ValidateTargeting(allow, deny); err != nil {
+		return nil, err
+	}
 
 	if err := CheckChannels(ctxQueryMap["channel"].(string), database, c); err != nil {
 		return nil, err
@@ -140,6 +201,85 @@ func validateCommonParams(ctxQueryMap map[string]interface{}, database *mongo.Da
 	return ctxQueryMap, nil
 }
 
+const (
+	maxPropertiesCount    = 32
+	maxPropertyKeyLength  = 64
+	maxPropertyValueBytes = 1024
+)
+
+// ValidateProperties keeps per-version custom properties small and flat
+func ValidateProperties(properties map[string]interface{}) error {
+	if len(properties) > maxPropertiesCount {
+		return fmt.Errorf("too many properties: %d (max %d)", len(properties), maxPropertiesCount)
+	}
+	for key, value := range properties {
+		if key == "" || len(key) > maxPropertyKeyLength {
+			return fmt.Errorf("invalid property key %q: must be 1-%d characters", key, maxPropertyKeyLength)
+		}
+		switch v := value.(type) {
+		case string:
+			if len(v) > maxPropertyValueBytes {
+				return fmt.Errorf("property %q value is too large (max %d bytes)", key, maxPropertyValueBytes)
+			}
+		case bool, float64, nil:
+		default:
+			return fmt.Errorf("property %q must be a string, number, boolean or null", key)
+		}
+	}
+	return nil
+}
+
+// How /apps/update applies the changelog to the entries the version already has
+const (
+	ChangelogReplace = "replace"
+	ChangelogAppend  = "append"
+)
+
+// ChangelogModes are the accepted values of changelog_mode, an empty mode replaces
+var ChangelogModes = []string{ChangelogReplace, ChangelogAppend}
+
+// ValidateChangelog rejects changelogs without any text and unknown changelog modes
+func ValidateChangelog(changelog, mode string) error {
+	if changelog != "" && strings.TrimSpace(changelog) == "" {
+		return errors.New("changelog must not be blank")
+	}
+	if mode != "" && mode != ChangelogReplace && mode != ChangelogAppend {
+		return fmt.Errorf("invalid changelog_mode parameter, allowed: %s", strings.Join(ChangelogModes, ", "))
+	}
+	return nil
+}
+
+// changelogTruncationMarker ends changelogs that were cut to CHANGELOG_STORE_MAX_BYTES
+const changelogTruncationMarker = "\n[truncated]"
+
+// SanitizeChangelog cleans a changelog before it is stored: newlines become \n, invalid UTF-8 and control
+// characters other than newlines and tabs are dropped, and it is cut to CHANGELOG_STORE_MAX_BYTES with a marker.
+// It reports whether the changelog was cut. 0 keeps the changelog whole.
+func SanitizeChangelog(changes string, env *viper.Viper) (string, bool) {
+	changes = strings.ReplaceAll(changes, "\r\n", "\n")
+	changes = strings.ReplaceAll(changes, "\r", "\n")
+	changes = strings.Map(func(r rune) rune {
+		if r != '\n' && r != '\t' && unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, strings.ToValidUTF8(changes, ""))
+
+	maxBytes := env.GetInt("CHANGELOG_STORE_MAX_BYTES")
+	if maxBytes <= 0 || len(changes) <= maxBytes {
+		return changes, false
+	}
+	marker := changelogTruncationMarker
+	if maxBytes <= len(marker) {
+		marker = ""
+	}
+	cut := maxBytes - len(marker)
+	for cut > 0 && !utf8.RuneStart(changes[cut]) {
+		cut--
+	}
+	return strings.TrimRight(changes[:cut], " \t\n") + marker, true
+}
+
 func ValidateItemName(itemType, paramValue string) error {
 	switch itemType {
 	case "channel":
@@ -164,31 +304,129 @@ func ValidateItemName(itemType, paramValue string) error {
 	return nil
 }
 
+// Patterns of the names accepted by the API, also published in the upload data schema
+const (
+	appNamePattern      = `^[a-zA-Z0-9\- ]+$`
+	versionPattern      = `^[0-9.-]+$`
+	channelNamePattern  = `^[a-zA-Z0-9]*$`
+	platformNamePattern = `^[a-zA-Z0-9-]*$`
+	archNamePattern     = `^[a-zA-Z0-9]*$`
+	packageNamePattern  = `^[a-zA-Z0-9.-]*$`
+	cohortNamePattern   = `^[a-zA-Z0-9_-]+$`
+)
+
 func IsValidAppName(input string) bool {
 	// Only allow letters and numbers, no special characters
-	validName := regexp.MustCompile(`^[a-zA-Z0-9\- ]+$`)
+	validName := regexp.MustCompile(appNamePattern)
 	return validName.MatchString(input)
 }
 func IsValidVersion(input string) bool {
 	// Only allow numbers and dots, no spaces or special characters
-	validVersion := regexp.MustCompile(`^[0-9.-]+$`)
+	validVersion := regexp.MustCompile(versionPattern)
 	return validVersion.MatchString(input)
 }
 
+// maxVersionPatternLength keeps the custom version patterns of the apps readable
+const maxVersionPatternLength = 256
+
+// ValidateVersionPattern checks a custom version pattern before it's stored with an app
+func ValidateVersionPattern(pattern string) error {
+	if len(pattern) > maxVersionPatternLength {
+		return fmt.Errorf("version_pattern is too long (max %d characters)", maxVersionPatternLength)
+	}
+	if _, err := regexp.Compile(pattern); err != nil {
+		return fmt.Errorf("invalid version_pattern: %v", err)
+	}
+	return nil
+}
+
 func IsValidChannelName(input string) bool {
 	// Allow empty input or only letters and numbers, no spaces or special characters
-	validName := regexp.MustCompile(`^[a-zA-Z0-9]*$`)
+	validName := regexp.MustCompile(channelNamePattern)
 	return validName.MatchString(input)
 }
 
 func IsValidPlatformName(input string) bool {
 	// Allow empty input or only letters, numbers, and hyphens, no spaces or other special characters
-	validName := regexp.MustCompile(`^[a-zA-Z0-9-]*$`)
+	validName := regexp.MustCompile(platformNamePattern)
 	return validName.MatchString(input)
 }
 
 func IsValidArchName(input string) bool {
 	// Allow empty input or only letters and numbers, no spaces or special characters
-	validName := regexp.MustCompile(`^[a-zA-Z0-9]*$`)
+	validName := regexp.MustCompile(archNamePattern)
 	return validName.MatchString(input)
 }
+
+func IsValidPackageName(input string) bool {
+	// Allow empty input or extensions like deb, tar.gz and no-extension
+	validName := regexp.MustCompile(packageNamePattern)
+	return validName.MatchString(input)
+}
+
+func IsValidCohortName(input string) bool {
+	// Only allow letters, numbers, hyphens and underscores, cohorts are sent by clients
+	validName := regexp.MustCompile(cohortNamePattern)
+	return validName.MatchString(input)
+}
+
+// maxTargetingCohorts keeps the targeting rules of a version small
+const maxTargetingCohorts = 32
+
+// ValidateTargeting checks the allowed and denied cohorts of a version, a cohort can't be in both
+func ValidateTargeting(allow, deny []string) error {
+	if len(allow)+len(deny) > maxTargetingCohorts {
+		return fmt.Errorf("too many cohorts: %d (max %d)", len(allow)+len(deny), maxTargetingCohorts)
+	}
+	allowed := make(map[string]bool, len(allow))
+	for _, cohort := range allow {
+		if !IsValidCohortName(cohort) {
+			return fmt.Errorf("invalid cohort %q in allow_cohorts", cohort)
+		}
+		allowed[cohort] = true
+	}
+	for _, cohort := range deny {
+		if !IsValidCohortName(cohort) {
+			return fmt.Errorf("invalid cohort %q in deny_cohorts", cohort)
+		}
+		if allowed[cohort] {
+			return fmt.Errorf("cohort %q is both allowed and denied", cohort)
+		}
+	}
+	return nil
+}
+
+// maxRequiredTargets keeps the completeness requirement of an app small
+const maxRequiredTargets = 64
+
+// ValidateRequiredTargets checks the platform and arch combinations a release of an app needs, each listed once
+func ValidateRequiredTargets(targets []model.RequiredTarget) error {
+	if len(targets) > maxRequiredTargets {
+		return fmt.Errorf("too many required targets: %d (max %d)", len(targets), maxRequiredTargets)
+	}
+	seen := make(map[model.RequiredTarget]bool, len(targets))
+	for _, target := range targets {
+		if target.Platform == "" || target.Arch == "" || !IsValidPlatformName(target.Platform) || !IsValidArchName(target.Arch) {
+			return fmt.Errorf("invalid required target %q, platform and arch are required", target.Platform+"/"+target.Arch)
+		}
+		if seen[target] {
+			return fmt.Errorf("required target %s/%s is listed twice", target.Platform, target.Arch)
+		}
+		seen[target] = true
+	}
+	return nil
+}
+
+// ValidateFlag checks the key, the value and the targeting of a feature flag
+func ValidateFlag(flag model.Flag) error {
+	if !IsValidChannelName(flag.Channel) {
+		return errors.New("invalid channel parameter")
+	}
+	for _, v := range []string{flag.MinVersion, flag.MaxVersion} {
+		if v != "" && !IsValidVersion(v) {
+			return errors.New("invalid version parameter")
+		}
+	}
+	// Flag values follow the same rules as version properties
+	return ValidateProperties(map[string]interface{}{flag.Key: flag.Value})
+}
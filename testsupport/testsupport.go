@@ -0,0 +1,148 @@
+// Package testsupport builds requests and checks responses for tests running against the faynoSync handlers.
+package testsupport
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// FormFile is a file part of a multipart request.
+// Content is sent when set, otherwise the file at Path is read.
+type FormFile struct {
+	Field   string
+	Name    string
+	Path    string
+	Content []byte
+}
+
+// File returns the form file for the artifact at path, sent in the "file" field
+func File(path string) FormFile {
+	return FormFile{Field: "file", Path: path}
+}
+
+// NewMultipartRequest builds a multipart/form-data request with the given fields and files
+func NewMultipartRequest(method, url string, fields map[string]string, files ...FormFile) (*http.Request, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	for _, file := range files {
+		name := file.Name
+		if name == "" {
+			name = filepath.Base(file.Path)
+		}
+		part, err := writer.CreateFormFile(file.Field, name)
+		if err != nil {
+			return nil, err
+		}
+		if file.Content != nil {
+			if _, err := part.Write(file.Content); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := copyFile(part, file.Path); err != nil {
+			return nil, err
+		}
+	}
+	for field, value := range fields {
+		if err := writer.WriteField(field, value); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req, nil
+}
+
+// NewUploadRequest builds a POST request sending data in the "data" field and the files in the "file" field
+func NewUploadRequest(url, data string, paths ...string) (*http.Request, error) {
+	files := make([]FormFile, 0, len(paths))
+	for _, path := range paths {
+		files = append(files, File(path))
+	}
+	return NewMultipartRequest(http.MethodPost, url, map[string]string{"data": data}, files...)
+}
+
+// Authorize sets the bearer token of the request
+func Authorize(req *http.Request, token string) *http.Request {
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+// Serve sends the request to the handler and returns the recorded response
+func Serve(handler http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+// DecodeJSON decodes the response body to a map, failing the test when it is not a JSON object
+func DecodeJSON(t testing.TB, w *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response %q: %v", w.Body.String(), err)
+	}
+	return response
+}
+
+// RequireStatus fails the test when the response has another status code
+func RequireStatus(t testing.TB, w *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("expected status %d, got %d: %s", status, w.Code, w.Body.String())
+	}
+}
+
+// RequireError fails the test unless the response has the status code and the error envelope with the message
+func RequireError(t testing.TB, w *httptest.ResponseRecorder, status int, message string) {
+	t.Helper()
+	RequireStatus(t, w, status)
+	if got := ErrorBody(t, w)["message"]; got != message {
+		t.Fatalf("expected error %q, got %v", message, got)
+	}
+}
+
+// ErrorBody returns the {"message", "code", "details"} object of an error response
+func ErrorBody(t testing.TB, w *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	body, ok := DecodeJSON(t, w)["error"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected an error envelope, got %s", w.Body.String())
+	}
+	return body
+}
+
+// RequireString returns the string value of key in the response, failing the test when it is missing or empty
+func RequireString(t testing.TB, response map[string]interface{}, key string) string {
+	t.Helper()
+	value, ok := response[key].(string)
+	if !ok || value == "" {
+		t.Fatalf("expected %q in response, got %v", key, response)
+	}
+	return value
+}
+
+func copyFile(dst io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(dst, file)
+	return err
+}
@@ -0,0 +1,77 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RedisHook creates a span for every Redis command
+type RedisHook struct{}
+
+func (RedisHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	ctx, _ = Tracer().Start(ctx, "redis."+cmd.Name(),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.system", "redis")),
+	)
+	return ctx, nil
+}
+
+func (RedisHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	span := trace.SpanFromContext(ctx)
+	if err := cmd.Err(); err != nil && err != redis.Nil {
+		EndSpan(span, err)
+		return nil
+	}
+	span.End()
+	return nil
+}
+
+func (RedisHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	ctx, _ = Tracer().Start(ctx, "redis.pipeline",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.system", "redis"), attribute.Int("db.redis.num_cmd", len(cmds))),
+	)
+	return ctx, nil
+}
+
+func (RedisHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	trace.SpanFromContext(ctx).End()
+	return nil
+}
+
+// NewMongoMonitor returns a command monitor that creates a span for every MongoDB command
+func NewMongoMonitor() *event.CommandMonitor {
+	var spans sync.Map
+
+	finish := func(requestID int64, err error) {
+		if span, ok := spans.LoadAndDelete(requestID); ok {
+			EndSpan(span.(trace.Span), err)
+		}
+	}
+
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, evt *event.CommandStartedEvent) {
+			_, span := Tracer().Start(ctx, "mongodb."+evt.CommandName,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					attribute.String("db.system", "mongodb"),
+					attribute.String("db.name", evt.DatabaseName),
+					attribute.String("db.operation", evt.CommandName),
+				),
+			)
+			spans.Store(evt.RequestID, span)
+		},
+		Succeeded: func(ctx context.Context, evt *event.CommandSucceededEvent) {
+			finish(evt.RequestID, nil)
+		},
+		Failed: func(ctx context.Context, evt *event.CommandFailedEvent) {
+			finish(evt.RequestID, errors.New(evt.Failure))
+		},
+	}
+}
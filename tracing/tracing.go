@@ -0,0 +1,79 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "faynoSync"
+
+// InitTracer configures the global tracer provider to export spans via OTLP to endpoint.
+// Tracing stays disabled (no-op) when endpoint is empty.
+func InitTracer(endpoint, serviceName string, insecure bool) (func(context.Context) error, error) {
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	options := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(endpoint)}
+	if insecure {
+		options = append(options, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(context.Background(), options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	if serviceName == "" {
+		serviceName = tracerName
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	logrus.Infof("Tracing is enabled, exporting spans to %s", endpoint)
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the tracer of faynoSync spans
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// StartSpan starts a span and tags it with app_name and channel from params when present
+func StartSpan(ctx context.Context, name string, params map[string]interface{}) (context.Context, trace.Span) {
+	ctx, span := Tracer().Start(ctx, name)
+	for _, key := range []string{"app_name", "channel"} {
+		if value, ok := params[key].(string); ok && value != "" {
+			span.SetAttributes(attribute.String(key, value))
+		}
+	}
+	return ctx, span
+}
+
+// RecordError marks the span as failed when err is not nil
+func RecordError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// EndSpan records err on the span, if any, and ends it
+func EndSpan(span trace.Span, err error) {
+	RecordError(span, err)
+	span.End()
+}